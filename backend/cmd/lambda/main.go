@@ -3,11 +3,20 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+	_ "time/tzdata" // guild quiet hours timezones; Lambda images lack zoneinfo
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -20,9 +29,11 @@ import (
 	"github.com/yourusername/streammaxing/internal/services/discord"
 	"github.com/yourusername/streammaxing/internal/services/encryption"
 	"github.com/yourusername/streammaxing/internal/services/logging"
+	"github.com/yourusername/streammaxing/internal/services/monitoring"
 	"github.com/yourusername/streammaxing/internal/services/notifications"
 	"github.com/yourusername/streammaxing/internal/services/secrets"
 	"github.com/yourusername/streammaxing/internal/services/twitch"
+	"github.com/yourusername/streammaxing/migrations"
 )
 
 // Router maps HTTP routes to handlers with path parameter extraction
@@ -50,22 +61,51 @@ func (router *Router) Handle(method, pattern string, handler http.HandlerFunc) {
 	})
 }
 
-// ServeHTTP handles incoming HTTP requests
+// RouteGroup registers routes under a common path prefix
+type RouteGroup struct {
+	router *Router
+	prefix string
+}
+
+// Group returns a RouteGroup whose patterns are prefixed with prefix
+// ("/api" + "/guilds" registers "/api/guilds").
+func (router *Router) Group(prefix string) *RouteGroup {
+	return &RouteGroup{router: router, prefix: prefix}
+}
+
+// Handle registers a handler for a method and a pattern relative to the
+// group's prefix
+func (g *RouteGroup) Handle(method, pattern string, handler http.HandlerFunc) {
+	g.router.Handle(method, g.prefix+pattern, handler)
+}
+
+// ServeHTTP handles incoming HTTP requests. A path registered only for other
+// methods gets 405 with an Allow header rather than 404.
 func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var allowed []string
 	for _, rt := range router.routes {
+		params, ok := matchPath(rt.pattern, r.URL.Path)
+		if !ok {
+			continue
+		}
 		if rt.method != r.Method {
+			allowed = append(allowed, rt.method)
 			continue
 		}
-		params, ok := matchPath(rt.pattern, r.URL.Path)
-		if ok {
-			// Store path params in context
-			ctx := r.Context()
-			for k, v := range params {
-				ctx = context.WithValue(ctx, pathParamKey(k), v)
-			}
-			rt.handler(w, r.WithContext(ctx))
-			return
+		// Store path params in context
+		ctx := r.Context()
+		for k, v := range params {
+			ctx = context.WithValue(ctx, pathParamKey(k), v)
 		}
+		rt.handler(w, r.WithContext(ctx))
+		return
+	}
+
+	if len(allowed) > 0 {
+		slices.Sort(allowed)
+		w.Header().Set("Allow", strings.Join(slices.Compact(allowed), ", "))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 	http.Error(w, "Not found", http.StatusNotFound)
 }
@@ -78,10 +118,12 @@ func getPathParam(r *http.Request, name string) string {
 	return v
 }
 
-// matchPath checks if a route pattern matches a path and extracts parameters
+// matchPath checks if a route pattern matches a path and extracts parameters.
+// Trailing and duplicate slashes are ignored, so "/api/health/" and
+// "//api//health" match "/api/health".
 func matchPath(pattern, path string) (map[string]string, bool) {
-	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
-	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	patternParts := splitPath(pattern)
+	pathParts := splitPath(path)
 
 	if len(patternParts) != len(pathParts) {
 		return nil, false
@@ -98,6 +140,11 @@ func matchPath(pattern, path string) (map[string]string, bool) {
 	return params, true
 }
 
+// splitPath splits a URL path into its non-empty segments
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+}
+
 // appServices holds all initialized services for the application.
 type appServices struct {
 	cfg               *config.Config
@@ -105,7 +152,9 @@ type appServices struct {
 	sessionSvc        *auth.SessionService
 	guildAuth         *authorization.GuildAuthService
 	securityLogger    *logging.SecurityLogger
-	userRL            *middleware.RateLimiter
+	metrics           *monitoring.CloudWatchMonitor
+	userRL            middleware.Limiter
+	testNotifyRL      middleware.Limiter
 	globalRL          *middleware.GlobalRateLimiter
 	webhookProtection *middleware.WebhookProtection
 	discordAPI        *discord.APIClient
@@ -114,6 +163,8 @@ type appServices struct {
 	twitchOAuth       *twitch.OAuthService
 	twitchEventSub    *twitch.EventSubService
 	fanoutService     *notifications.FanoutService
+	// streamPoller is the missed stream.online fallback (nil = disabled)
+	streamPoller *notifications.StreamPoller
 }
 
 // initServices loads configuration and initializes all services.
@@ -122,13 +173,15 @@ func initServices() *appServices {
 	cfg, err := config.Load()
 	if err != nil {
 		log.Printf("[CONFIG_ERROR] Failed to load config: %v", err)
-		cfg = &config.Config{} // empty config, services will fail gracefully
+		cfg = &config.Config{APIRoutePrefix: config.DefaultAPIRoutePrefix} // empty config, services will fail gracefully
 	}
 
 	// Encryption service (KMS in production, dev fallback locally)
 	encryptionSvc, err := encryption.NewService(cfg.KMSKeyID)
 	if err != nil {
 		log.Printf("[SECURITY_WARN] Failed to init encryption service: %v", err)
+	} else if cfg.KMSDecryptCacheSize != 0 {
+		encryptionSvc.SetDecryptCacheSize(cfg.KMSDecryptCacheSize)
 	}
 
 	// Secrets manager (for session service)
@@ -140,6 +193,14 @@ func initServices() *appServices {
 	// Security logger
 	securityLogger := logging.NewSecurityLogger()
 
+	// CloudWatch metrics (buffered; flushed after each invocation)
+	metrics, err := monitoring.NewCloudWatchMonitor(cfg.IsProduction())
+	if err != nil {
+		log.Printf("[CONFIG_WARN] CloudWatch metrics disabled: %v", err)
+	} else {
+		securityLogger.SetMetrics(metrics)
+	}
+
 	// Session service with revocation support
 	sessionDB := db.NewSessionDB()
 	var sessionSvc *auth.SessionService
@@ -150,16 +211,40 @@ func initServices() *appServices {
 	// Guild authorization service with 5-minute cache TTL
 	guildAuth := authorization.NewGuildAuthService()
 
-	// Rate limiters
-	userRL := middleware.NewRateLimiter(50, 100)
+	// Rate limiters (shared across instances via DynamoDB when configured)
+	userRL := newUserLimiter(cfg, "api", middleware.NewRateLimiter(50, 100), 3000, time.Minute)
 	globalRL := middleware.NewGlobalRateLimiter(1000, 2000)
-	webhookProtection := middleware.NewWebhookProtection()
+	testNotifyRL := newUserLimiter(cfg, "test_notification", middleware.NewRateLimiterEvery(30*time.Second, 3), 3, 90*time.Second) // posts to Discord
+	webhookProtection := middleware.NewWebhookProtectionWithTTL(
+		time.Duration(cfg.WebhookDedupTTLSeconds)*time.Second,
+		time.Duration(cfg.WebhookCleanupIntervalSeconds)*time.Second,
+	)
 
 	// Wire up middleware and handlers with config (no more os.Getenv in any service)
 	if sessionSvc != nil {
 		middleware.SetSessionService(sessionSvc)
 	}
 	middleware.SetSecurityLogger(securityLogger)
+	if cfg.InternalServiceKey != "" {
+		if cfg.InternalServiceKey == cfg.JWTSecret {
+			log.Printf("[CONFIG_ERROR] INTERNAL_SERVICE_KEY must differ from JWT_SECRET, internal endpoints disabled")
+		} else if serviceTokens, err := auth.NewServiceTokenService(cfg.InternalServiceKey); err != nil {
+			log.Printf("[CONFIG_ERROR] Internal endpoints disabled: %v", err)
+		} else {
+			serviceTokens.NonceStore = db.NewServiceNonceDB()
+			middleware.SetServiceTokenService(serviceTokens)
+		}
+	}
+	if cfg.CronSecret != "" {
+		switch {
+		case len(cfg.CronSecret) < 32:
+			log.Printf("[CONFIG_ERROR] CRON_SECRET must be at least 32 bytes, cron access disabled")
+		case cfg.CronSecret == cfg.JWTSecret || cfg.CronSecret == cfg.InternalServiceKey:
+			log.Printf("[CONFIG_ERROR] CRON_SECRET must differ from JWT_SECRET and INTERNAL_SERVICE_KEY, cron access disabled")
+		default:
+			middleware.SetCronSecret(cfg.CronSecret)
+		}
+	}
 	middleware.SetLegacyJWTSecret(cfg.JWTSecret)
 	middleware.SetCORSConfig(cfg.FrontendURL, cfg.IsProduction())
 	handlers.SetHandlerConfig(cfg.FrontendURL, cfg.IsProduction())
@@ -167,13 +252,36 @@ func initServices() *appServices {
 	// Set webhook secret from config
 	twitch.SetWebhookSecret(cfg.TwitchWebhookSecret)
 
+	// Guild webhook URLs are stored encrypted
+	notifications.SetEncryptionService(encryptionSvc)
+
+	// Optional source IP check for webhooks (defense in depth)
+	if cfg.TwitchWebhookIPCheck {
+		if err := twitch.SetEventSubIPRanges(strings.Split(cfg.TwitchWebhookIPRanges, ",")); err != nil {
+			log.Printf("[CONFIG_ERROR] Webhook IP check disabled: %v", err)
+		} else if cfg.TwitchWebhookIPRanges == "" {
+			log.Printf("[CONFIG_WARN] TWITCH_WEBHOOK_IP_CHECK set without TWITCH_WEBHOOK_IP_RANGES, IP check disabled")
+		} else {
+			webhookProtection.SourceAllowed = twitch.IsFromTwitch
+		}
+	}
+
 	// Initialize API clients from config (no more os.Getenv in services)
 	discordAPIClient := discord.NewAPIClient(cfg.DiscordBotToken)
 	discordOAuthSvc := discord.NewOAuthService(cfg.DiscordClientID, cfg.DiscordClientSecret, cfg.DiscordRedirectURI)
 	twitchAPIClient := twitch.NewAPIClient(cfg.TwitchClientID, cfg.TwitchClientSecret)
-	twitchOAuthSvc := twitch.NewOAuthService(cfg.TwitchClientID, cfg.TwitchClientSecret, cfg.APIBaseURL)
-	twitchEventSubSvc := twitch.NewEventSubService(twitchAPIClient, cfg.APIBaseURL, cfg.TwitchWebhookSecret)
+	if cfg.TwitchTokenRefreshWindowSeconds > 0 {
+		twitchAPIClient.TokenRefreshWindow = time.Duration(cfg.TwitchTokenRefreshWindowSeconds) * time.Second
+	}
+	twitchOAuthSvc := twitch.NewOAuthService(cfg.TwitchClientID, cfg.TwitchClientSecret,
+		cfg.APIBaseURL+cfg.APIRoutePrefix+"/auth/twitch/callback")
+	twitchEventSubSvc := twitch.NewEventSubService(twitchAPIClient, cfg.APIBaseURL+cfg.WebhookPath(), cfg.TwitchWebhookSecret)
 	fanoutService := notifications.NewFanoutService(twitchAPIClient, discordAPIClient)
+	fanoutService.UserTokens = twitch.NewUserTokenService(twitchOAuthSvc, encryptionSvc)
+	var streamPoller *notifications.StreamPoller
+	if cfg.StreamPollEnabled {
+		streamPoller = notifications.NewStreamPoller(fanoutService, time.Duration(cfg.StreamPollIntervalSeconds)*time.Second)
+	}
 
 	return &appServices{
 		cfg:               cfg,
@@ -181,7 +289,9 @@ func initServices() *appServices {
 		sessionSvc:        sessionSvc,
 		guildAuth:         guildAuth,
 		securityLogger:    securityLogger,
+		metrics:           metrics,
 		userRL:            userRL,
+		testNotifyRL:      testNotifyRL,
 		globalRL:          globalRL,
 		webhookProtection: webhookProtection,
 		discordAPI:        discordAPIClient,
@@ -190,6 +300,74 @@ func initServices() *appServices {
 		twitchOAuth:       twitchOAuthSvc,
 		twitchEventSub:    twitchEventSubSvc,
 		fanoutService:     fanoutService,
+		streamPoller:      streamPoller,
+	}
+}
+
+// flushMetrics publishes buffered metrics, bounded so a slow CloudWatch call
+// cannot hold up the response.
+func (svc *appServices) flushMetrics(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+	defer cancel()
+	if err := svc.metrics.Flush(ctx); err != nil {
+		log.Printf("[CLOUDWATCH_ERROR] %v", err)
+	}
+}
+
+// newUserLimiter returns a DynamoDB-backed limiter allowing limit requests
+// per window when RATE_LIMIT_TABLE is set, otherwise the in-memory limiter
+// (which also serves as the DynamoDB limiter's fallback).
+func newUserLimiter(cfg *config.Config, name string, inMemory *middleware.RateLimiter, limit int, window time.Duration) middleware.Limiter {
+	if cfg.RateLimitTable == "" {
+		return inMemory
+	}
+	rl, err := middleware.NewDynamoRateLimiter(cfg.RateLimitTable, name, limit, window, inMemory)
+	if err != nil {
+		log.Printf("[CONFIG_WARN] DynamoDB rate limiter unavailable, using in-memory: %v", err)
+		return inMemory
+	}
+	return rl
+}
+
+// dbPoolOptions maps the centralized config onto database pool options.
+func dbPoolOptions(cfg *config.Config) db.PoolOptions {
+	return db.PoolOptions{
+		QueryExecMode:          cfg.DBQueryExecMode,
+		StatementCacheCapacity: cfg.DBStatementCacheCapacity,
+		ConnectAttempts:        cfg.DBConnectAttempts,
+		ConnectTimeout:         time.Duration(cfg.DBConnectTimeoutSeconds) * time.Second,
+	}
+}
+
+// parseDiscordKey parses a hex Ed25519 public key from config, returning nil
+// (and logging) when it is unset or invalid.
+func parseDiscordKey(envName, hexKey string) ed25519.PublicKey {
+	if hexKey == "" {
+		return nil
+	}
+	key, err := discord.ParsePublicKey(hexKey)
+	if err != nil {
+		log.Printf("[CONFIG_ERROR] %s: %v; its Discord webhook is disabled", envName, err)
+		return nil
+	}
+	return key
+}
+
+// runStreamPoller runs the stream poll every interval until ctx is done,
+// for local development where there's no EventBridge schedule.
+func runStreamPoller(ctx context.Context, poller *notifications.StreamPoller) {
+	ticker := time.NewTicker(poller.Interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := poller.PollStreamStatus(ctx); err != nil {
+				log.Printf("[POLL_ERROR] %v", err)
+			}
+		}
 	}
 }
 
@@ -198,152 +376,424 @@ func setupRoutes(router *Router, svc *appServices) {
 	// Initialize handlers — all services come from the centralized config,
 	// no more os.Getenv inside constructors.
 	authHandler := handlers.NewAuthHandler(svc.discordOAuth, svc.sessionSvc, svc.guildAuth, svc.securityLogger)
-	guildHandler := handlers.NewGuildHandler(svc.discordAPI, svc.discordOAuth, svc.guildAuth, svc.securityLogger)
+	guildHandler := handlers.NewGuildHandler(svc.discordAPI, svc.discordOAuth, svc.twitchAPI, svc.twitchEventSub, svc.guildAuth, svc.securityLogger, svc.encryptionSvc)
 	twitchAuthHandler := handlers.NewTwitchAuthHandler(svc.twitchOAuth, svc.twitchEventSub, svc.encryptionSvc, svc.securityLogger)
-	webhookHandler := handlers.NewWebhookHandler(svc.fanoutService, svc.securityLogger)
+	webhookHandler := handlers.NewWebhookHandler(svc.fanoutService, svc.twitchEventSub, svc.securityLogger)
 	preferencesHandler := handlers.NewPreferencesHandler()
 	inviteHandler := handlers.NewInviteHandler(svc.guildAuth, svc.securityLogger)
+	cleanupHandler := handlers.NewCleanupHandler(svc.twitchEventSub, time.Duration(svc.cfg.AuditLogRetentionDays)*24*time.Hour)
 
 	// Helper: wrap handler with rate limiting
 	withRateLimit := func(h http.HandlerFunc) http.HandlerFunc {
 		return svc.globalRL.Middleware(svc.userRL.UserRateLimitMiddleware(h))
 	}
 
-	// Helper: wrap handler with auth + rate limiting
+	// Helper: wrap handler with auth + rate limiting. Dashboard reads are
+	// shed first when the DB pool is exhausted so the webhook keeps its
+	// connections.
 	withAuth := func(h http.HandlerFunc) http.HandlerFunc {
-		return withRateLimit(middleware.AuthMiddleware(h))
+		return withRateLimit(middleware.LoadShedMiddleware(middleware.AuthMiddleware(h)))
 	}
 
+	// Dashboard API routes live under the configurable prefix (default
+	// /api). Health and the Twitch webhook keep fixed paths, so health
+	// checks and existing EventSub subscriptions survive a prefix change,
+	// unless API_ROUTE_PREFIX_ALL moves them under it too.
+	api := router.Group(svc.cfg.APIRoutePrefix)
+
 	// ==================
 	// Public routes (rate limited, no auth)
 	// ==================
 
 	// Health check
-	router.Handle("GET", "/api/health", withRateLimit(healthHandler))
+	router.Handle("GET", svc.cfg.HealthPath(), withRateLimit(healthHandler))
 
 	// Discord OAuth (no auth required)
-	router.Handle("GET", "/api/auth/discord/login", withRateLimit(authHandler.DiscordLogin))
-	router.Handle("GET", "/api/auth/discord/callback", withRateLimit(authHandler.DiscordCallback))
-	router.Handle("POST", "/api/auth/discord/exchange", withRateLimit(authHandler.DiscordExchange))
+	api.Handle("GET", "/auth/discord/login", withRateLimit(authHandler.DiscordLogin))
+	api.Handle("GET", "/auth/discord/callback", withRateLimit(authHandler.DiscordCallback))
+	api.Handle("POST", "/auth/discord/exchange", withRateLimit(authHandler.DiscordExchange))
 
 	// Twitch OAuth callback (no auth middleware - user_id is embedded in the OAuth state parameter)
-	router.Handle("GET", "/api/auth/twitch/callback", withRateLimit(twitchAuthHandler.TwitchCallback))
+	api.Handle("GET", "/auth/twitch/callback", withRateLimit(twitchAuthHandler.TwitchCallback))
 
 	// Webhook endpoint (signature verification, rate limited, idempotency check, no JWT auth)
-	router.Handle("POST", "/webhooks/twitch", svc.webhookProtection.Middleware(webhookHandler.HandleTwitchWebhook))
+	router.Handle("POST", svc.cfg.WebhookPath(), svc.webhookProtection.Middleware(webhookHandler.HandleTwitchWebhook))
+
+	// Discord webhooks (Ed25519-signed, no JWT auth); each is only served
+	// when its verification key is configured:
+	//   guild-removed: GUILD_DELETE forwarded from the Gateway
+	//   interactions:  slash commands, sent by Discord
+	guildEventsKey := parseDiscordKey("DISCORD_GUILD_EVENTS_PUBLIC_KEY", svc.cfg.DiscordGuildEventsPublicKey)
+	appPublicKey := parseDiscordKey("DISCORD_PUBLIC_KEY", svc.cfg.DiscordPublicKey)
+	discordWebhookHandler := handlers.NewDiscordWebhookHandler(guildEventsKey, appPublicKey, cleanupHandler, svc.securityLogger)
+	if guildEventsKey != nil {
+		router.Handle("POST", svc.cfg.DiscordWebhookPath("guild-removed"), withRateLimit(discordWebhookHandler.HandleGuildRemoved))
+	}
+	if appPublicKey != nil {
+		router.Handle("POST", svc.cfg.DiscordWebhookPath("interactions"), withRateLimit(discordWebhookHandler.HandleInteraction))
+	}
+
+	// ==================
+	// Internal routes (service token required, called by our own Lambdas)
+	// ==================
+
+	// Cleanup: daily from EventBridge (X-Cron-Secret) or with a service token
+	router.Handle("POST", "/internal/cleanup", middleware.CronAuthMiddleware(cleanupHandler.RunCleanup))
+
+	// Operational metrics (per Lambda instance)
+	router.Handle("GET", "/internal/metrics/webhooks", middleware.CronAuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(svc.webhookProtection.Stats())
+	}))
+
+	// Pending schema migrations, run once after a deploy
+	router.Handle("POST", "/internal/migrate", middleware.CronAuthMiddleware(migrateHandler))
+
+	// Missed stream.online fallback: on the STREAM_POLL_INTERVAL_SECONDS
+	// EventBridge schedule, when STREAM_POLL_ENABLED
+	if svc.streamPoller != nil {
+		streamPollHandler := handlers.NewStreamPollHandler(svc.streamPoller)
+		router.Handle("POST", "/internal/poll-streams", middleware.CronAuthMiddleware(streamPollHandler.RunPoll))
+	}
 
 	// ==================
 	// Authenticated routes (rate limited + auth required)
 	// ==================
 
 	// Auth
-	router.Handle("POST", "/api/auth/logout", withAuth(authHandler.Logout))
-	router.Handle("GET", "/api/auth/me", withAuth(authHandler.GetMe))
+	api.Handle("POST", "/auth/logout", withAuth(authHandler.Logout))
+	api.Handle("GET", "/auth/me", withAuth(authHandler.GetMe))
+	api.Handle("POST", "/auth/refresh", withAuth(authHandler.RefreshSession))
+	api.Handle("GET", "/auth/sessions", withAuth(authHandler.ListSessions))
+	api.Handle("POST", "/auth/sessions/revoke-all", withAuth(authHandler.RevokeOtherSessions))
 
 	// Guilds
-	router.Handle("GET", "/api/guilds", withAuth(guildHandler.GetUserGuilds))
+	api.Handle("GET", "/guilds", withAuth(guildHandler.GetUserGuilds))
+
+	api.Handle("GET", "/guilds/:guild_id/deletion-impact", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.GetGuildDeletionImpact(w, r, getPathParam(r, "guild_id"))
+	}))
 
-	router.Handle("GET", "/api/guilds/:guild_id/channels", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("DELETE", "/guilds/:guild_id", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.DeleteGuild(w, r, getPathParam(r, "guild_id"))
+	}))
+
+	api.Handle("POST", "/guilds/:guild_id/streamers/:streamer_id/resubscribe", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.Resubscribe(w, r, getPathParam(r, "guild_id"), getPathParam(r, "streamer_id"))
+	}))
+
+	api.Handle("GET", "/guilds/:guild_id/subscriptions", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.GetGuildSubscriptions(w, r, getPathParam(r, "guild_id"))
+	}))
+
+	api.Handle("GET", "/guilds/:guild_id/channels", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		guildHandler.GetGuildChannels(w, r, getPathParam(r, "guild_id"))
 	}))
 
-	router.Handle("GET", "/api/guilds/:guild_id/roles", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("GET", "/guilds/:guild_id/roles", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		guildHandler.GetGuildRoles(w, r, getPathParam(r, "guild_id"))
 	}))
 
-	router.Handle("GET", "/api/guilds/:guild_id/streamers", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("GET", "/guilds/:guild_id/streamers", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		guildHandler.GetGuildStreamers(w, r, getPathParam(r, "guild_id"))
 	}))
 
-	router.Handle("DELETE", "/api/guilds/:guild_id/streamers/:streamer_id", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("DELETE", "/guilds/:guild_id/streamers/:streamer_id", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		guildHandler.UnlinkStreamer(w, r, getPathParam(r, "guild_id"), getPathParam(r, "streamer_id"))
 	}))
 
-	router.Handle("GET", "/api/guilds/:guild_id/streamers/link", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("GET", "/guilds/:guild_id/streamers/link", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		twitchAuthHandler.InitiateStreamerLink(w, r, getPathParam(r, "guild_id"))
 	}))
 
-	router.Handle("GET", "/api/guilds/:guild_id/config", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	// Bulk import by Twitch login (admin)
+	api.Handle("POST", "/guilds/:guild_id/streamers/bulk", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.AddStreamersBulk(w, r, getPathParam(r, "guild_id"))
+	}))
+
+	// Unlink the Twitch account(s) the signed-in user linked
+	api.Handle("DELETE", "/streamers/me", withAuth(twitchAuthHandler.UnlinkMyStreamers))
+
+	api.Handle("GET", "/guilds/:guild_id/config", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		guildHandler.GetGuildConfig(w, r, getPathParam(r, "guild_id"))
 	}))
 
-	router.Handle("PUT", "/api/guilds/:guild_id/config", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("PUT", "/guilds/:guild_id/config", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		guildHandler.UpdateGuildConfig(w, r, getPathParam(r, "guild_id"))
 	}))
 
-	router.Handle("GET", "/api/guilds/:guild_id/bot-install-url", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	// Webhook delivery (admin): the URL is write-only
+	api.Handle("PUT", "/guilds/:guild_id/webhook", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.SetGuildWebhook(w, r, getPathParam(r, "guild_id"))
+	}))
+
+	api.Handle("DELETE", "/guilds/:guild_id/webhook", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.DeleteGuildWebhook(w, r, getPathParam(r, "guild_id"))
+	}))
+
+	api.Handle("GET", "/templates/tones", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.ListTones(w, r)
+	}))
+
+	api.Handle("POST", "/guilds/:guild_id/template/preview", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.PreviewTemplate(w, r, getPathParam(r, "guild_id"))
+	}))
+
+	// Test notification (admin): posts to Discord, so limited per user on top
+	// of the normal limits
+	api.Handle("POST", "/guilds/:guild_id/test-notification", withAuth(svc.testNotifyRL.UserRateLimitMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.SendTestNotification(w, r, getPathParam(r, "guild_id"))
+	})))
+
+	api.Handle("GET", "/guilds/:guild_id/bot-install-url", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		guildHandler.GetBotInstallURL(w, r, getPathParam(r, "guild_id"))
 	}))
 
+	// Notification stats (admin)
+	api.Handle("GET", "/guilds/:guild_id/stats", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.GetGuildStats(w, r, getPathParam(r, "guild_id"))
+	}))
+
+	// Audit log (admin)
+	api.Handle("GET", "/guilds/:guild_id/audit", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.GetGuildAuditLog(w, r, getPathParam(r, "guild_id"))
+	}))
+
+	// Streamer notification history
+	api.Handle("GET", "/guilds/:guild_id/streamers/:streamer_id/history", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.GetStreamerHistory(w, r, getPathParam(r, "guild_id"), getPathParam(r, "streamer_id"))
+	}))
+
+	// Streamer category filter
+	api.Handle("GET", "/guilds/:guild_id/streamers/:streamer_id/filter", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.GetStreamerFilter(w, r, getPathParam(r, "guild_id"), getPathParam(r, "streamer_id"))
+	}))
+
+	api.Handle("PUT", "/guilds/:guild_id/streamers/:streamer_id/filter", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.UpdateStreamerFilter(w, r, getPathParam(r, "guild_id"), getPathParam(r, "streamer_id"))
+	}))
+
+	// Pause or resume a streamer (admin)
+	api.Handle("PUT", "/guilds/:guild_id/streamers/:streamer_id/enabled", withAuth(func(w http.ResponseWriter, r *http.Request) {
+		guildHandler.UpdateStreamerEnabled(w, r, getPathParam(r, "guild_id"), getPathParam(r, "streamer_id"))
+	}))
+
 	// Streamer message (custom notification text)
-	router.Handle("GET", "/api/guilds/:guild_id/streamers/:streamer_id/message", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("GET", "/guilds/:guild_id/streamers/:streamer_id/message", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		guildHandler.GetStreamerMessage(w, r, getPathParam(r, "guild_id"), getPathParam(r, "streamer_id"))
 	}))
 
-	router.Handle("PUT", "/api/guilds/:guild_id/streamers/:streamer_id/message", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("PUT", "/guilds/:guild_id/streamers/:streamer_id/message", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		guildHandler.UpdateStreamerMessage(w, r, getPathParam(r, "guild_id"), getPathParam(r, "streamer_id"))
 	}))
 
 	// Invite links (admin)
-	router.Handle("POST", "/api/guilds/:guild_id/invites", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("POST", "/guilds/:guild_id/invites", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		inviteHandler.CreateInvite(w, r, getPathParam(r, "guild_id"))
 	}))
 
-	router.Handle("GET", "/api/guilds/:guild_id/invites", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("GET", "/guilds/:guild_id/invites", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		inviteHandler.ListInvites(w, r, getPathParam(r, "guild_id"))
 	}))
 
-	router.Handle("DELETE", "/api/guilds/:guild_id/invites/:invite_id", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("DELETE", "/guilds/:guild_id/invites/:invite_id", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		inviteHandler.DeleteInvite(w, r, getPathParam(r, "guild_id"), getPathParam(r, "invite_id"))
 	}))
 
 	// Invite links (public / any user)
-	router.Handle("GET", "/api/invites/:code", withRateLimit(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("GET", "/invites/:code", withRateLimit(func(w http.ResponseWriter, r *http.Request) {
 		inviteHandler.GetInviteInfo(w, r, getPathParam(r, "code"))
 	}))
-	router.Handle("POST", "/api/invites/:code/accept", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("POST", "/invites/:code/accept", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		inviteHandler.AcceptInvite(w, r, getPathParam(r, "code"))
 	}))
 
 	// User preferences
-	router.Handle("GET", "/api/users/me/preferences", withAuth(preferencesHandler.GetUserPreferences))
+	api.Handle("GET", "/users/me/preferences", withAuth(preferencesHandler.GetUserPreferences))
 
-	router.Handle("PUT", "/api/users/me/preferences/:guild_id/:streamer_id", withAuth(func(w http.ResponseWriter, r *http.Request) {
+	api.Handle("PUT", "/users/me/preferences/:guild_id/:streamer_id", withAuth(func(w http.ResponseWriter, r *http.Request) {
 		preferencesHandler.UpdateUserPreference(w, r, getPathParam(r, "guild_id"), getPathParam(r, "streamer_id"))
 	}))
 }
 
-// healthHandler returns API health status
+// healthHandler returns API health status, including whether the database
+// answers a ping. Responds 503 when it doesn't, so health checks can tell
+// "Lambda up, database down" from healthy.
 func healthHandler(w http.ResponseWriter, r *http.Request) {
+	status, dbStatus := "ok", "ok"
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := db.HealthCheck(ctx); err != nil {
+		if db.Pool != nil {
+			log.Printf("[HEALTH] Database ping failed: %v", err)
+		}
+		status, dbStatus = "unhealthy", "error"
+	}
+
 	response := map[string]interface{}{
-		"status":  "ok",
-		"message": "StreamMaxing API v3",
-		"version": "3.0.0",
+		"status":         status,
+		"message":        "StreamMaxing API v3",
+		"version":        "3.0.0",
+		"database":       dbStatus,
+		"database_ready": db.Pool != nil,
 	}
 	w.Header().Set("Content-Type", "application/json")
+	if status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 	json.NewEncoder(w).Encode(response)
 }
 
+// Lambda state, built once during the init phase by initLambda and reused
+// across warm invocations.
+var (
+	lambdaSvc    *appServices
+	lambdaRouter *Router
+)
+
+// initLambda loads config, initializes services, and connects the database
+// during the Lambda init phase, before the first invocation arrives. This
+// keeps connection setup off the first request's latency and lets
+// provisioned concurrency pre-warm the pool.
+func initLambda() {
+	start := time.Now()
+
+	lambdaSvc = initServices()
+	lambdaRouter = NewRouter()
+	setupRoutes(lambdaRouter, lambdaSvc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectBudget)
+	defer cancel()
+	if err := connectDatabase(ctx, lambdaSvc.cfg); errors.Is(err, config.ErrDatabaseNotConfigured) {
+		logDatabaseNotConfigured()
+	} else if err != nil {
+		// Handler retries on the first invocation
+		log.Printf("[INIT_WARN] Database connection failed during init: %v", err)
+	}
+
+	log.Printf("[INIT] Lambda init completed in %v", time.Since(start))
+}
+
+// dbNotConfiguredOnce limits the missing DATABASE_URL error to one log line
+// per cold start; every request would otherwise repeat it.
+var dbNotConfiguredOnce sync.Once
+
+func logDatabaseNotConfigured() {
+	dbNotConfiguredOnce.Do(func() {
+		log.Printf("[CONFIG_ERROR] %v; all database-backed routes will return 503", config.ErrDatabaseNotConfigured)
+	})
+}
+
+// connectBudget bounds connecting and warming up the pool in Lambda, retries
+// included. Lambda allows 10s for the whole init phase, and the services are
+// set up before the database is connected.
+const connectBudget = 7 * time.Second
+
+// reconnectInterval is the minimum time between the Handler's reconnect
+// attempts while the database is down. Each attempt can take the whole
+// connectBudget, and Twitch gives up on a webhook delivery after 10s, so
+// requests in between are answered 503 without trying.
+const reconnectInterval = 30 * time.Second
+
+// errReconnectThrottled reports a request that found the database down
+// within reconnectInterval of the last attempt.
+var errReconnectThrottled = errors.New("database reconnect throttled")
+
+// reconnectThrottle spaces out reconnect attempts on this instance.
+type reconnectThrottle struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+var dbReconnect = &reconnectThrottle{interval: reconnectInterval}
+
+// allow reports whether a reconnect may be attempted at now, and if so
+// holds off the next one for the interval.
+func (t *reconnectThrottle) allow(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Before(t.next) {
+		return false
+	}
+	t.next = now.Add(t.interval)
+	return true
+}
+
+// reconnectDatabase retries the connection from a request, at most once
+// per reconnectInterval.
+func reconnectDatabase(ctx context.Context, cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if !dbReconnect.allow(time.Now()) {
+		return errReconnectThrottled
+	}
+	ctx, cancel := context.WithTimeout(ctx, connectBudget)
+	defer cancel()
+	return connectDatabase(ctx, cfg)
+}
+
+// connectDatabase establishes the pool (no-op if already connected) and
+// eagerly opens MinConns connections, all within ctx. Returns
+// config.ErrDatabaseNotConfigured without trying when there is no database
+// URL.
+func connectDatabase(ctx context.Context, cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err := db.Connect(ctx, cfg.DatabaseURL, dbPoolOptions(cfg)); err != nil {
+		return err
+	}
+
+	if err := db.WarmUp(ctx); err != nil {
+		log.Printf("[INIT_WARN] Connection warm-up incomplete: %v", err)
+	}
+
+	log.Printf("[INIT] Database connected in %v", time.Since(start))
+	return nil
+}
+
+// migrateHandler applies pending schema migrations as a one-off invocation
+// (POST /internal/migrate after a deploy). Migrations never run during
+// Lambda init: its 10s limit could cut one off while it holds the advisory
+// lock.
+func migrateHandler(w http.ResponseWriter, r *http.Request) {
+	if err := migrations.RunMigrations(r.Context()); err != nil {
+		log.Printf("[MIGRATE_ERROR] %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Migrations applied"})
+}
+
 // Handler is the Lambda function handler (API Gateway HTTP API v2 payload format)
 func Handler(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
-	// Load centralized config and initialize all services
-	svc := initServices()
-
-	// Initialize database connection (if not already connected)
+	svc := lambdaSvc
+	router := lambdaRouter
+
+	// Retry the connection if init-phase connect failed. Without a database
+	// the request is answered with an error instead of routed (except the
+	// health check, which reports the database as down), still through the
+	// header middleware below so it carries CORS and security headers.
+	serve := router.ServeHTTP
 	if db.Pool == nil {
-		if err := db.Connect(svc.cfg.DatabaseURL); err != nil {
+		err := reconnectDatabase(ctx, svc.cfg)
+		if errors.Is(err, config.ErrDatabaseNotConfigured) {
+			logDatabaseNotConfigured()
+			serve = databaseUnavailable(svc.cfg, http.StatusServiceUnavailable, "Database not configured", router.ServeHTTP)
+		} else if errors.Is(err, errReconnectThrottled) {
+			serve = databaseUnavailable(svc.cfg, http.StatusServiceUnavailable, "Database unavailable", router.ServeHTTP)
+		} else if err != nil {
 			log.Printf("Failed to connect to database: %v", err)
-			return events.APIGatewayV2HTTPResponse{
-				StatusCode: http.StatusInternalServerError,
-				Body:       `{"error": "Database connection failed"}`,
-			}, nil
+			serve = databaseUnavailable(svc.cfg, http.StatusInternalServerError, "Database connection failed", router.ServeHTTP)
 		}
 	}
 
-	// Create router
-	router := NewRouter()
-	setupRoutes(router, svc)
-
 	// Debug: log raw request info for auth callbacks
 	if strings.Contains(request.RawPath, "callback") {
 		log.Printf("[LAMBDA_DEBUG] RawPath=%s Cookies=%v HeaderCookie=%q",
@@ -363,11 +813,13 @@ func Handler(ctx context.Context, request events.APIGatewayV2HTTPRequest) (event
 	// Create response writer
 	rw := newResponseWriter()
 
-	// Apply middleware chain: security headers → CORS → router
-	handler := middleware.SecurityHeadersMiddleware(middleware.CORSMiddleware(router.ServeHTTP))
+	// Apply middleware chain: security headers → CORS → stage prefix → router
+	handler := middleware.SecurityHeadersMiddleware(middleware.CORSMiddleware(
+		middleware.StripPrefixMiddleware(svc.cfg.APIStagePrefix, serve)))
 
-	// Serve request
+	// Serve request, then publish its metrics before Lambda freezes the instance
 	handler(rw, httpReq)
+	svc.flushMetrics(ctx)
 
 	// Convert response headers: separate Set-Cookie into the Cookies field
 	respHeaders := make(map[string]string)
@@ -401,6 +853,9 @@ func convertAPIGatewayV2Request(req events.APIGatewayV2HTTPRequest) (*http.Reque
 		return nil, err
 	}
 
+	// Client IP as seen by API Gateway
+	httpReq.RemoteAddr = req.RequestContext.HTTP.SourceIP
+
 	// Copy headers (v2 sends single string per header, multi-values are comma-joined)
 	for key, value := range req.Headers {
 		httpReq.Header.Set(key, value)
@@ -463,6 +918,7 @@ func (rw *responseWriter) WriteHeader(statusCode int) {
 func main() {
 	// Check if running in Lambda
 	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+		initLambda()
 		lambda.Start(Handler)
 	} else {
 		// Local development mode
@@ -475,8 +931,50 @@ func main() {
 		svc := initServices()
 		log.Println("Configuration loaded and services initialized")
 
+		// "migrate" applies pending migrations and exits
+		if len(os.Args) > 1 && os.Args[1] == "migrate" {
+			if err := connectDatabase(context.Background(), svc.cfg); err != nil {
+				log.Fatalf("Failed to connect to database: %v", err)
+			}
+			defer db.Close()
+			if err := migrations.RunMigrations(context.Background()); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+			return
+		}
+
+		// "register-commands" registers the bot's slash commands and exits
+		if len(os.Args) > 1 && os.Args[1] == "register-commands" {
+			commands := []discord.ApplicationCommand{handlers.StreamersCommand}
+			if err := svc.discordAPI.OverwriteGlobalCommands(context.Background(), svc.cfg.DiscordClientID, commands); err != nil {
+				log.Fatalf("Failed to register commands: %v", err)
+			}
+			log.Printf("Registered %d slash command(s)", len(commands))
+			return
+		}
+
+		// "service-token [service]" prints a service token (signed with
+		// INTERNAL_SERVICE_KEY) for calling /internal/* once, e.g. from a
+		// deploy step: X-Internal-Token: $(go run ./cmd/lambda service-token deploy)
+		if len(os.Args) > 1 && os.Args[1] == "service-token" {
+			service := "cli"
+			if len(os.Args) > 2 {
+				service = os.Args[2]
+			}
+			serviceTokens, err := auth.NewServiceTokenService(svc.cfg.InternalServiceKey)
+			if err != nil {
+				log.Fatalf("Cannot mint service token: %v", err)
+			}
+			token, err := serviceTokens.Mint(service)
+			if err != nil {
+				log.Fatalf("Cannot mint service token: %v", err)
+			}
+			fmt.Println(token)
+			return
+		}
+
 		// Initialize database
-		if err := db.Connect(svc.cfg.DatabaseURL); err != nil {
+		if err := connectDatabase(context.Background(), svc.cfg); err != nil {
 			log.Printf("Warning: Failed to connect to database: %v", err)
 			log.Println("Running without database connection - some features will not work")
 		} else {
@@ -487,12 +985,66 @@ func main() {
 		router := NewRouter()
 		setupRoutes(router, svc)
 
-		handler := middleware.CORSMiddleware(middleware.LoggingMiddleware(router.ServeHTTP))
+		// Same chain as Handler: security headers → CORS → router
+		handler := middleware.SecurityHeadersMiddleware(middleware.CORSMiddleware(middleware.LoggingMiddleware(requireDatabaseConfig(svc.cfg, router.ServeHTTP))))
+
+		server := &http.Server{Addr: ":8080", Handler: http.HandlerFunc(handler)}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// No EventBridge locally: run the stream poll on a ticker
+		if svc.streamPoller != nil {
+			go runStreamPoller(ctx, svc.streamPoller)
+		}
+
+		shutdownDone := make(chan struct{})
+		go func() {
+			defer close(shutdownDone)
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Graceful shutdown failed: %v", err)
+			}
+		}()
 
 		log.Println("API server listening on http://localhost:8080")
-		if err := http.ListenAndServe(":8080", http.HandlerFunc(handler)); err != nil {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("Server failed: %v", err)
 		}
+
+		// Wait for Shutdown to drain in-flight requests before the final flush
+		<-shutdownDone
+		svc.flushMetrics(context.Background())
+		log.Println("Server stopped")
+	}
+}
+
+// requireDatabaseConfig answers 503 instead of calling next when
+// DATABASE_URL is missing (local mode; Handler does the same on Lambda).
+func requireDatabaseConfig(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := cfg.Validate(); err != nil {
+			logDatabaseNotConfigured()
+			databaseUnavailable(cfg, http.StatusServiceUnavailable, "Database not configured", next)(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// databaseUnavailable answers requests with status and a JSON error, for
+// when there is no database to serve them from. The health check still goes
+// to next, so it can report the database as down.
+func databaseUnavailable(cfg *config.Config, status int, message string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := matchPath(cfg.HealthPath(), r.URL.Path); ok && r.Method == http.MethodGet {
+			next(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": message})
 	}
 }
 
@@ -1,40 +1,115 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/yourusername/streammaxing/internal/services/secrets"
 )
 
+// DefaultAPIRoutePrefix is the base path of the dashboard API routes when
+// API_ROUTE_PREFIX is unset.
+const DefaultAPIRoutePrefix = "/api"
+
+// ErrDatabaseNotConfigured is returned by Validate when DATABASE_URL is unset.
+var ErrDatabaseNotConfigured = errors.New("database not configured: DATABASE_URL is empty")
+
 // Config holds all application configuration.
 // In production, secrets are loaded from AWS Secrets Manager.
 // In development, everything comes from environment variables.
 type Config struct {
 	// Database
 	DatabaseURL string
+	// DBQueryExecMode selects the pgx exec mode (see db.PoolOptions).
+	// Set to "simple_protocol" behind a transaction-mode pooler.
+	DBQueryExecMode string
+	// DBStatementCacheCapacity overrides the pgx statement cache size
+	// (0 = pgx default, negative = disabled).
+	DBStatementCacheCapacity int
+	// DBConnectAttempts and DBConnectTimeoutSeconds bound the retries when
+	// first connecting (0 = db package defaults).
+	DBConnectAttempts       int
+	DBConnectTimeoutSeconds int
 
 	// Discord
 	DiscordClientID     string
 	DiscordClientSecret string
 	DiscordBotToken     string
 	DiscordRedirectURI  string
+	// DiscordGuildEventsPublicKey (hex Ed25519) verifies GUILD_DELETE
+	// dispatches forwarded to /webhooks/discord/guild-removed; the route
+	// is disabled when unset.
+	DiscordGuildEventsPublicKey string
+	// DiscordPublicKey (hex Ed25519, the application's public key in the
+	// Developer Portal) verifies interactions sent to
+	// /webhooks/discord/interactions; the route is disabled when unset.
+	DiscordPublicKey string
 
 	// Twitch
 	TwitchClientID      string
 	TwitchClientSecret  string
 	TwitchWebhookSecret string
+	// TwitchTokenRefreshWindowSeconds is how early the app access token is
+	// refreshed before it expires (0 = client default of 5 minutes).
+	TwitchTokenRefreshWindowSeconds int
+	// TwitchWebhookIPCheck rejects webhooks from outside
+	// TwitchWebhookIPRanges (comma-separated CIDRs) before reading them.
+	TwitchWebhookIPCheck  bool
+	TwitchWebhookIPRanges string
+	// StreamPollEnabled turns on the polling fallback for stream.online
+	// events EventSub didn't deliver (POST /internal/poll-streams).
+	StreamPollEnabled bool
+	// StreamPollIntervalSeconds is how often the poll is scheduled to run
+	// (0 = 5 minutes); it also bounds how old a stream it announces can be.
+	StreamPollIntervalSeconds int
+	// WebhookDedupTTLSeconds is how long webhook message IDs are kept for
+	// duplicate detection (0 = 15 minutes).
+	WebhookDedupTTLSeconds int
+	// WebhookCleanupIntervalSeconds is how often expired message IDs are
+	// removed (0 = 1 minute).
+	WebhookCleanupIntervalSeconds int
 
 	// App (non-secret)
 	APIBaseURL  string
 	FrontendURL string
 	JWTSecret   string
-	Environment string
-	LogLevel    string
+	// JWTSecretPrevious is the JWT secret being rotated out (empty = none).
+	// Sessions signed with it stay valid; new ones use JWTSecret.
+	JWTSecretPrevious string
+	Environment       string
+	LogLevel          string
+	// APIRoutePrefix is the base path of the dashboard API routes
+	// (default "/api"). Health and the Twitch webhook stay at /api/health
+	// and /webhooks/twitch unless APIRoutePrefixAll moves them under it.
+	APIRoutePrefix    string
+	APIRoutePrefixAll bool
+	// APIStagePrefix is the API Gateway stage path (e.g. "/prod") stripped
+	// from request paths before routing; empty for the $default stage.
+	APIStagePrefix string
 
 	// AWS
 	KMSKeyID string
+	// KMSDecryptCacheSize is how many decrypted values are cached for a
+	// minute (0 = 256, negative = no cache).
+	KMSDecryptCacheSize int
+	// InternalServiceKey signs service tokens for calls between our own
+	// Lambdas (empty = internal endpoints disabled). Must differ from
+	// JWTSecret.
+	InternalServiceKey string
+	// CronSecret is the static X-Cron-Secret header the daily EventBridge
+	// schedule sends to /internal/cleanup (empty = service tokens only).
+	// At least 32 bytes, distinct from JWTSecret and InternalServiceKey.
+	CronSecret string
+	// AuditLogRetentionDays is how long audit_log entries are kept before
+	// the cleanup run deletes them (0 = 90 days).
+	AuditLogRetentionDays int
+	// RateLimitTable is the DynamoDB table for cross-instance rate limiting
+	// (empty = per-instance in-memory limiting).
+	RateLimitTable string
 }
 
 // Load reads all configuration from the appropriate source.
@@ -49,12 +124,41 @@ func Load() (*Config, error) {
 		LogLevel:    os.Getenv("LOG_LEVEL"),
 		KMSKeyID:    os.Getenv("KMS_KEY_ID"),
 		DatabaseURL: os.Getenv("DATABASE_URL"),
+
+		RateLimitTable:     os.Getenv("RATE_LIMIT_TABLE"),
+		InternalServiceKey: os.Getenv("INTERNAL_SERVICE_KEY"),
+		CronSecret:         os.Getenv("CRON_SECRET"),
+
+		AuditLogRetentionDays: getEnvInt("AUDIT_LOG_RETENTION_DAYS", 0),
+		KMSDecryptCacheSize:   getEnvInt("KMS_DECRYPT_CACHE_SIZE", 0),
+
+		DBQueryExecMode:          os.Getenv("DB_QUERY_EXEC_MODE"),
+		DBStatementCacheCapacity: getEnvInt("DB_STATEMENT_CACHE_CAPACITY", 0),
+		DBConnectAttempts:        getEnvInt("DB_CONNECT_ATTEMPTS", 0),
+		DBConnectTimeoutSeconds:  getEnvInt("DB_CONNECT_TIMEOUT_SECONDS", 0),
+
+		TwitchTokenRefreshWindowSeconds: getEnvInt("TWITCH_TOKEN_REFRESH_WINDOW_SECONDS", 0),
+		WebhookDedupTTLSeconds:          getEnvInt("WEBHOOK_DEDUP_TTL_SECONDS", 0),
+		WebhookCleanupIntervalSeconds:   getEnvInt("WEBHOOK_CLEANUP_INTERVAL_SECONDS", 0),
+
+		StreamPollEnabled:         os.Getenv("STREAM_POLL_ENABLED") == "true",
+		StreamPollIntervalSeconds: getEnvInt("STREAM_POLL_INTERVAL_SECONDS", 0),
+
+		TwitchWebhookIPCheck:  os.Getenv("TWITCH_WEBHOOK_IP_CHECK") == "true",
+		TwitchWebhookIPRanges: os.Getenv("TWITCH_WEBHOOK_IP_RANGES"),
+
+		DiscordGuildEventsPublicKey: os.Getenv("DISCORD_GUILD_EVENTS_PUBLIC_KEY"),
+		DiscordPublicKey:            os.Getenv("DISCORD_PUBLIC_KEY"),
+
+		APIStagePrefix:    os.Getenv("API_STAGE_PREFIX"),
+		APIRoutePrefix:    normalizeRoutePrefix(os.Getenv("API_ROUTE_PREFIX")),
+		APIRoutePrefixAll: os.Getenv("API_ROUTE_PREFIX_ALL") == "true",
 	}
 
 	// Construct Discord redirect URI
 	cfg.DiscordRedirectURI = os.Getenv("DISCORD_REDIRECT_URI")
 	if cfg.DiscordRedirectURI == "" && cfg.APIBaseURL != "" {
-		cfg.DiscordRedirectURI = cfg.APIBaseURL + "/api/auth/discord/callback"
+		cfg.DiscordRedirectURI = cfg.APIBaseURL + cfg.APIRoutePrefix + "/auth/discord/callback"
 	}
 
 	// Try loading secrets from Secrets Manager in production
@@ -78,6 +182,16 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// Validate checks for configuration the app can't serve requests without.
+// Load doesn't fail on these so the local/dev fallback can still start;
+// callers check Validate before connecting.
+func (c *Config) Validate() error {
+	if c.DatabaseURL == "" {
+		return ErrDatabaseNotConfigured
+	}
+	return nil
+}
+
 // validateJWTSecret checks that the JWT secret meets minimum security requirements.
 // A 256-bit (32-byte) secret is required for HS256 signing.
 func (c *Config) validateJWTSecret() error {
@@ -87,6 +201,9 @@ func (c *Config) validateJWTSecret() error {
 	if len(c.JWTSecret) < 32 {
 		return fmt.Errorf("JWT_SECRET is only %d bytes; minimum 32 bytes (256-bit) required for secure HS256 signing. Generate one with: openssl rand -base64 32", len(c.JWTSecret))
 	}
+	if c.JWTSecretPrevious != "" && len(c.JWTSecretPrevious) < 32 {
+		return fmt.Errorf("JWT_SECRET_PREVIOUS is only %d bytes; minimum 32 bytes (256-bit) required", len(c.JWTSecretPrevious))
+	}
 	return nil
 }
 
@@ -103,6 +220,9 @@ func (c *Config) loadFromSecretsManager() error {
 		return fmt.Errorf("failed to get JWT secret: %w", err)
 	}
 	c.JWTSecret = jwtSecret
+	if c.JWTSecretPrevious, err = mgr.GetPreviousJWTSecret(); err != nil {
+		return fmt.Errorf("failed to get previous JWT secret: %w", err)
+	}
 
 	// Discord OAuth
 	discordOAuth, err := mgr.GetDiscordOAuth()
@@ -135,6 +255,7 @@ func (c *Config) loadFromSecretsManager() error {
 // loadFromEnvVars loads all secrets from environment variables (development).
 func (c *Config) loadFromEnvVars() {
 	c.JWTSecret = os.Getenv("JWT_SECRET")
+	c.JWTSecretPrevious = os.Getenv("JWT_SECRET_PREVIOUS")
 	c.DiscordClientID = os.Getenv("DISCORD_CLIENT_ID")
 	c.DiscordClientSecret = os.Getenv("DISCORD_CLIENT_SECRET")
 	c.DiscordBotToken = os.Getenv("DISCORD_BOT_TOKEN")
@@ -149,3 +270,56 @@ func (c *Config) loadFromEnvVars() {
 func (c *Config) IsProduction() bool {
 	return c.Environment == "production"
 }
+
+// HealthPath is the route of the health check.
+func (c *Config) HealthPath() string {
+	if c.APIRoutePrefixAll {
+		return c.APIRoutePrefix + "/health"
+	}
+	return "/api/health"
+}
+
+// WebhookPath is the route Twitch delivers EventSub webhooks to.
+func (c *Config) WebhookPath() string {
+	if c.APIRoutePrefixAll {
+		return c.APIRoutePrefix + "/webhooks/twitch"
+	}
+	return "/webhooks/twitch"
+}
+
+// DiscordWebhookPath is the route of a Discord webhook, e.g.
+// "guild-removed" for /webhooks/discord/guild-removed.
+func (c *Config) DiscordWebhookPath(name string) string {
+	path := "/webhooks/discord/" + name
+	if c.APIRoutePrefixAll {
+		return c.APIRoutePrefix + path
+	}
+	return path
+}
+
+// normalizeRoutePrefix returns prefix with a leading and no trailing slash,
+// DefaultAPIRoutePrefix when unset. "/" means no prefix and returns "".
+func normalizeRoutePrefix(prefix string) string {
+	if prefix == "" {
+		return DefaultAPIRoutePrefix
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+// getEnvInt reads an integer env var, returning def when unset or invalid.
+func getEnvInt(key string, def int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("[CONFIG_WARN] Invalid integer for %s=%q, using default %d", key, raw, def)
+		return def
+	}
+	return v
+}
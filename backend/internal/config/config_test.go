@@ -0,0 +1,44 @@
+package config
+
+import "testing"
+
+func TestNormalizeRoutePrefix(t *testing.T) {
+	for prefix, want := range map[string]string{
+		"":       DefaultAPIRoutePrefix,
+		"/v3":    "/v3",
+		"v3":     "/v3",
+		"/v3/":   "/v3",
+		"/":      "",
+		"/a/b/":  "/a/b",
+		"//api/": "/api",
+	} {
+		if got := normalizeRoutePrefix(prefix); got != want {
+			t.Errorf("normalizeRoutePrefix(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
+
+func TestRoutePathsUnderPrefix(t *testing.T) {
+	tests := []struct {
+		name                     string
+		cfg                      Config
+		health, webhook, discord string
+	}{
+		{"default", Config{APIRoutePrefix: "/api"}, "/api/health", "/webhooks/twitch", "/webhooks/discord/guild-removed"},
+		{"custom prefix", Config{APIRoutePrefix: "/v3"}, "/api/health", "/webhooks/twitch", "/webhooks/discord/guild-removed"},
+		{"everything under prefix", Config{APIRoutePrefix: "/v3", APIRoutePrefixAll: true}, "/v3/health", "/v3/webhooks/twitch", "/v3/webhooks/discord/guild-removed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.HealthPath(); got != tt.health {
+				t.Errorf("HealthPath = %q, want %q", got, tt.health)
+			}
+			if got := tt.cfg.WebhookPath(); got != tt.webhook {
+				t.Errorf("WebhookPath = %q, want %q", got, tt.webhook)
+			}
+			if got := tt.cfg.DiscordWebhookPath("guild-removed"); got != tt.discord {
+				t.Errorf("DiscordWebhookPath = %q, want %q", got, tt.discord)
+			}
+		})
+	}
+}
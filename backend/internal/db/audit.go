@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"time"
 )
 
 // InsertAuditLog records a sensitive operation in the audit_log table.
@@ -29,3 +30,65 @@ func InsertAuditLog(ctx context.Context, userID, action, resourceType, resourceI
 		log.Printf("[AUDIT_WARN] Failed to insert audit log: %v", err)
 	}
 }
+
+// CleanupOldAuditLogs deletes audit entries older than olderThan and
+// returns how many were removed.
+func CleanupOldAuditLogs(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `DELETE FROM audit_log WHERE timestamp < $1`
+	result, err := Pool.Exec(ctx, query, time.Now().Add(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// guildAuditEntries selects a guild's audit entries: those naming it in
+// details.guild_id, plus guild-level entries keyed by its ID. The two are a
+// UNION ALL rather than an OR so each branch uses its own index
+// (idx_audit_log_guild, idx_audit_log_guild_resource); the second branch
+// skips rows the first already returned.
+const guildAuditEntries = `
+	(SELECT id, timestamp, user_id, action, resource_type, resource_id, details, success
+	 FROM audit_log
+	 WHERE details->>'guild_id' = $1 AND ($2 = '' OR action = $2)
+	 UNION ALL
+	 SELECT id, timestamp, user_id, action, resource_type, resource_id, details, success
+	 FROM audit_log
+	 WHERE resource_type IN ('guild', 'guild_config') AND resource_id = $1
+	   AND details->>'guild_id' IS DISTINCT FROM $1 AND ($2 = '' OR action = $2)) AS guild_audit
+`
+
+// GetGuildAuditLogs returns a guild's audit entries, newest first,
+// optionally only those with the given action. limit <= 0 means no limit.
+func GetGuildAuditLogs(ctx context.Context, guildID, action string, limit, offset int) ([]AuditLogEntry, error) {
+	query := `
+		SELECT id, timestamp, COALESCE(user_id, ''), action, resource_type, COALESCE(resource_id, ''),
+		       details, COALESCE(success, true)
+		FROM ` + guildAuditEntries + `
+		ORDER BY timestamp DESC, id
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := Pool.Query(ctx, query, guildID, action, nullableLimit(limit), offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditLogEntry
+	for rows.Next() {
+		var e AuditLogEntry
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.UserID, &e.Action, &e.ResourceType, &e.ResourceID, &e.Details, &e.Success); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// CountGuildAuditLogs returns the number of audit entries GetGuildAuditLogs
+// would return without a limit
+func CountGuildAuditLogs(ctx context.Context, guildID, action string) (int, error) {
+	var count int
+	err := Pool.QueryRow(ctx, `SELECT count(*) FROM `+guildAuditEntries, guildID, action).Scan(&count)
+	return count, err
+}
@@ -2,17 +2,89 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var Pool *pgxpool.Pool
+// Pool is the shared connection pool, set by Connect.
+var Pool *ConnPool
+
+// ErrEmptyDatabaseURL is returned by Connect when given no database URL.
+// The URL comes from config (DATABASE_URL); db never reads the environment.
+var ErrEmptyDatabaseURL = errors.New("database URL is empty")
+
+// connectMu serializes Connect so concurrent callers never build two pools.
+var connectMu sync.Mutex
+
+// PoolOptions tunes how pgx talks to the database.
+// The zero value keeps pgx defaults (prepared statement caching).
+type PoolOptions struct {
+	// QueryExecMode selects the pgx exec mode: "cache_statement" (default),
+	// "cache_describe", "describe_exec", "exec", or "simple_protocol".
+	// Use "simple_protocol" or "exec" behind a transaction-mode pooler
+	// (pgbouncer, RDS Proxy) where prepared statements cannot be reused.
+	QueryExecMode string
+
+	// StatementCacheCapacity overrides the per-connection prepared statement
+	// cache size. Zero keeps the pgx default; negative disables the cache.
+	StatementCacheCapacity int
+
+	// ConnectAttempts is how many times Connect tries to reach the database
+	// before giving up (default DefaultConnectAttempts). A serverless
+	// database resuming from pause refuses connections for a few seconds.
+	ConnectAttempts int
+
+	// ConnectTimeout bounds each attempt (default DefaultConnectTimeout).
+	ConnectTimeout time.Duration
+}
+
+// Connect retry defaults. Backoff between attempts starts at
+// connectBackoffBase and doubles up to connectBackoffMax.
+const (
+	DefaultConnectAttempts = 3
+	DefaultConnectTimeout  = 3 * time.Second
+	connectBackoffBase     = 250 * time.Millisecond
+	connectBackoffMax      = 2 * time.Second
+)
+
+// parseQueryExecMode maps a config string to a pgx exec mode.
+func parseQueryExecMode(mode string) (pgx.QueryExecMode, error) {
+	switch mode {
+	case "", "cache_statement":
+		return pgx.QueryExecModeCacheStatement, nil
+	case "cache_describe":
+		return pgx.QueryExecModeCacheDescribe, nil
+	case "describe_exec":
+		return pgx.QueryExecModeDescribeExec, nil
+	case "exec":
+		return pgx.QueryExecModeExec, nil
+	case "simple_protocol":
+		return pgx.QueryExecModeSimpleProtocol, nil
+	default:
+		return 0, fmt.Errorf("unknown query exec mode %q", mode)
+	}
+}
 
 // Connect establishes a connection pool to the database using the given URL.
-func Connect(databaseURL string) error {
+// It is a no-op if a pool has already been established, and returns
+// ErrEmptyDatabaseURL for an empty URL. ctx bounds the whole attempt,
+// retries included.
+func Connect(ctx context.Context, databaseURL string, opts PoolOptions) error {
+	connectMu.Lock()
+	defer connectMu.Unlock()
+
+	if Pool != nil {
+		return nil
+	}
+
 	if databaseURL == "" {
-		return fmt.Errorf("database URL is empty")
+		return ErrEmptyDatabaseURL
 	}
 
 	config, err := pgxpool.ParseConfig(databaseURL)
@@ -24,21 +96,121 @@ func Connect(databaseURL string) error {
 	config.MaxConns = 10
 	config.MinConns = 2
 
-	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	// Detect stale connections early. Idle connections are checked every
+	// 30s and closed before typical NAT idle timeouts (~350s); connections
+	// idle for over a second are pinged on acquire (pgx default) with a
+	// short timeout so a dead socket fails fast instead of hanging.
+	config.HealthCheckPeriod = 30 * time.Second
+	config.MaxConnIdleTime = 5 * time.Minute
+	config.PingTimeout = 2 * time.Second
+	config.PrepareConn = func(ctx context.Context, conn *pgx.Conn) (bool, error) {
+		return !conn.IsClosed(), nil
+	}
+
+	// Configure statement handling (matters behind RDS Proxy / pgbouncer)
+	execMode, err := parseQueryExecMode(opts.QueryExecMode)
+	if err != nil {
+		return err
+	}
+	config.ConnConfig.DefaultQueryExecMode = execMode
+	if opts.StatementCacheCapacity < 0 {
+		config.ConnConfig.StatementCacheCapacity = 0
+		config.ConnConfig.DescriptionCacheCapacity = 0
+	} else if opts.StatementCacheCapacity > 0 {
+		config.ConnConfig.StatementCacheCapacity = opts.StatementCacheCapacity
+		config.ConnConfig.DescriptionCacheCapacity = opts.StatementCacheCapacity
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		return fmt.Errorf("failed to create connection pool: %w", err)
 	}
 
-	// Test connection
-	if err := pool.Ping(context.Background()); err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
+	if err := pingWithRetry(ctx, pool, opts); err != nil {
+		pool.Close()
+		return err
+	}
+
+	Pool = &ConnPool{Pool: pool}
+	return nil
+}
+
+// pingWithRetry pings until the database answers, backing off between
+// attempts. pgxpool connects lazily, so the ping is the first real dial.
+// Gives up early once ctx is done, even with attempts left.
+func pingWithRetry(ctx context.Context, pool *pgxpool.Pool, opts PoolOptions) error {
+	attempts := opts.ConnectAttempts
+	if attempts <= 0 {
+		attempts = DefaultConnectAttempts
+	}
+	timeout := opts.ConnectTimeout
+	if timeout <= 0 {
+		timeout = DefaultConnectTimeout
 	}
 
-	Pool = pool
+	backoff := connectBackoffBase
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err = pool.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt == attempts || ctx.Err() != nil {
+			break
+		}
+
+		log.Printf("[DB_WARN] Database ping failed (attempt %d/%d), retrying in %v: %v", attempt, attempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up pinging database after %d attempts: %w", attempt, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, connectBackoffMax)
+	}
+	return fmt.Errorf("failed to ping database after %d attempts: %w", attempts, err)
+}
+
+// HealthCheck pings the database through the pool. pgxpool replaces broken
+// connections on its own, so an error here means the database itself is
+// unreachable (or Connect never succeeded); callers should report
+// unavailable and try again later rather than rebuild the pool.
+func HealthCheck(ctx context.Context) error {
+	if Pool == nil {
+		return fmt.Errorf("database not connected")
+	}
+	return Pool.Ping(ctx)
+}
+
+// WarmUp eagerly opens MinConns connections so the first requests after a
+// cold start don't pay connection setup. pgxpool otherwise fills MinConns in
+// a background goroutine, which Lambda may freeze before it finishes.
+func WarmUp(ctx context.Context) error {
+	if Pool == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	n := int(Pool.Config().MinConns)
+	conns := make([]*pgxpool.Conn, 0, n)
+	defer func() {
+		for _, c := range conns {
+			c.Release()
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		c, err := Pool.Acquire(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to warm connection %d/%d: %w", i+1, n, err)
+		}
+		conns = append(conns, c)
+	}
 	return nil
 }
 
-// Close closes the database connection pool
+// Close closes the pool opened by Connect, waiting for acquired connections
+// to be released. It does nothing if Connect never succeeded.
 func Close() {
 	if Pool != nil {
 		Pool.Close()
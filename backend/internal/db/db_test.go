@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// withoutPool clears Pool for the test so Connect doesn't no-op, closing
+// whatever pool the test opened and restoring the previous one after.
+func withoutPool(t *testing.T) {
+	t.Helper()
+	prev := Pool
+	Pool = nil
+	t.Cleanup(func() {
+		if Pool != nil {
+			Pool.Close()
+		}
+		Pool = prev
+	})
+}
+
+func TestConnectEmptyURL(t *testing.T) {
+	withoutPool(t)
+
+	if err := Connect(context.Background(), "", PoolOptions{}); !errors.Is(err, ErrEmptyDatabaseURL) {
+		t.Fatalf("Connect(\"\") = %v, want ErrEmptyDatabaseURL", err)
+	}
+	if Pool != nil {
+		t.Error("Pool set after a failed Connect")
+	}
+}
+
+func TestConnectInvalidOptions(t *testing.T) {
+	withoutPool(t)
+
+	tests := map[string]struct {
+		url  string
+		opts PoolOptions
+	}{
+		"malformed URL": {"postgres://%zz", PoolOptions{}},
+		"unknown mode":  {"postgres://user@127.0.0.1/db", PoolOptions{QueryExecMode: "fastest"}},
+		"unreachable":   {"postgres://user@127.0.0.1:1/db?sslmode=disable", PoolOptions{ConnectAttempts: 1, ConnectTimeout: time.Second}},
+	}
+	for name, tt := range tests {
+		if err := Connect(context.Background(), tt.url, tt.opts); err == nil {
+			t.Errorf("%s: Connect succeeded, want an error", name)
+		}
+		if Pool != nil {
+			t.Fatalf("%s: Pool set after a failed Connect", name)
+		}
+	}
+}
+
+func TestConnect(t *testing.T) {
+	withoutPool(t)
+	f := newFakePostgres(t, func(sql string) fakeResult {
+		return fakeResult{columns: textColumns("ok"), rows: [][]any{{"yes"}}}
+	})
+
+	url := fmt.Sprintf("postgres://test@%s/test?sslmode=disable", f.ln.Addr())
+	if err := Connect(context.Background(), url, PoolOptions{QueryExecMode: "simple_protocol"}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+	var ok string
+	if err := Pool.QueryRow(context.Background(), "SELECT 'yes'").Scan(&ok); err != nil || ok != "yes" {
+		t.Errorf("query = %q, %v", ok, err)
+	}
+}
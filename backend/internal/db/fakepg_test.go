@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// fakePostgres is a minimal PostgreSQL server for tests. It completes the
+// startup handshake and answers simple-protocol queries with respond, so
+// queries can run without a database. Clients must use
+// default_query_exec_mode=simple_protocol (see newFakePool).
+type fakePostgres struct {
+	t       *testing.T
+	ln      net.Listener
+	respond func(sql string) fakeResult
+
+	// dropConn, if set, is called with each connection's number (from 1)
+	// after its handshake; returning true resets that connection, the way a
+	// NAT gateway or failover kills an idle one.
+	dropConn func(n int) bool
+
+	mu      sync.Mutex
+	conns   int
+	queries []string
+}
+
+// fakeResult is the answer to one query: rows under columns, or an error.
+// Values are sent in text format via fmt.Sprint; nil is NULL.
+type fakeResult struct {
+	columns []fakeColumn
+	rows    [][]any
+	tag     string // defaults to "SELECT <rows>"
+	err     string
+}
+
+type fakeColumn struct {
+	name string
+	oid  uint32
+}
+
+// Column type OIDs used by the tests.
+const (
+	oidBool        = 16
+	oidInt4        = 23
+	oidText        = 25
+	oidJSONB       = 3802
+	oidTimestamptz = 1184
+)
+
+func textColumns(names ...string) []fakeColumn {
+	cols := make([]fakeColumn, len(names))
+	for i, name := range names {
+		cols[i] = fakeColumn{name, oidText}
+	}
+	return cols
+}
+
+func newFakePostgres(t *testing.T, respond func(sql string) fakeResult) *fakePostgres {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakePostgres{t: t, ln: ln, respond: respond}
+	t.Cleanup(func() { ln.Close() })
+	go f.serve()
+	return f
+}
+
+// newFakePool returns a pool of at most maxConns connections to f. The
+// pool never pings on acquire, so a dead connection is only found by the
+// query that uses it.
+func newFakePool(t *testing.T, f *fakePostgres, maxConns int32) *pgxpool.Pool {
+	t.Helper()
+	config, err := pgxpool.ParseConfig(fmt.Sprintf(
+		"postgres://test@%s/test?sslmode=disable&default_query_exec_mode=simple_protocol", f.ln.Addr()))
+	if err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	config.MaxConns = maxConns
+	config.ShouldPing = func(context.Context, pgxpool.ShouldPingParams) bool { return false }
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// useFakePool points the package Pool at f for the duration of the test.
+func useFakePool(t *testing.T, f *fakePostgres) {
+	t.Helper()
+	prev := Pool
+	Pool = &ConnPool{Pool: newFakePool(t, f, 1)}
+	t.Cleanup(func() { Pool = prev })
+}
+
+// connCount returns how many connections the server has accepted.
+func (f *fakePostgres) connCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conns
+}
+
+// sent returns the queries the server has received, pings excluded.
+func (f *fakePostgres) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.queries...)
+}
+
+func (f *fakePostgres) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns++
+		n := f.conns
+		f.mu.Unlock()
+		go f.handle(conn, n)
+	}
+}
+
+func (f *fakePostgres) handle(conn net.Conn, n int) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(conn, conn)
+
+	for {
+		msg, err := backend.ReceiveStartupMessage()
+		if err != nil {
+			return
+		}
+		if _, ok := msg.(*pgproto3.StartupMessage); ok {
+			break
+		}
+		// Decline SSL and GSS encryption requests
+		if _, err := conn.Write([]byte("N")); err != nil {
+			return
+		}
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"})
+	backend.Send(&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"})
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: uint32(n), SecretKey: 1})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if err := backend.Flush(); err != nil {
+		return
+	}
+
+	if f.dropConn != nil && f.dropConn(n) {
+		conn.(*net.TCPConn).SetLinger(0)
+		return
+	}
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			f.answer(backend, msg.String)
+		case *pgproto3.Terminate:
+			return
+		default:
+			f.t.Errorf("fake postgres: unexpected message %T", msg)
+			return
+		}
+		if err := backend.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakePostgres) answer(backend *pgproto3.Backend, sql string) {
+	defer backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+
+	if strings.HasPrefix(sql, "-- ping") {
+		backend.Send(&pgproto3.EmptyQueryResponse{})
+		return
+	}
+	f.mu.Lock()
+	f.queries = append(f.queries, sql)
+	f.mu.Unlock()
+
+	res := f.respond(sql)
+	if res.err != "" {
+		backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "XX000", Message: res.err})
+		return
+	}
+	if len(res.columns) > 0 {
+		fields := make([]pgproto3.FieldDescription, len(res.columns))
+		for i, col := range res.columns {
+			fields[i] = pgproto3.FieldDescription{Name: []byte(col.name), DataTypeOID: col.oid, DataTypeSize: -1, TypeModifier: -1}
+		}
+		backend.Send(&pgproto3.RowDescription{Fields: fields})
+	}
+	for _, row := range res.rows {
+		values := make([][]byte, len(row))
+		for i, v := range row {
+			if v != nil {
+				values[i] = []byte(fmt.Sprint(v))
+			}
+		}
+		backend.Send(&pgproto3.DataRow{Values: values})
+	}
+	tag := res.tag
+	if tag == "" {
+		tag = fmt.Sprintf("SELECT %d", len(res.rows))
+	}
+	backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+}
@@ -2,6 +2,8 @@ package db
 
 import (
 	"encoding/json"
+	"reflect"
+	"slices"
 	"time"
 )
 
@@ -30,7 +32,43 @@ type GuildConfig struct {
 	MentionRoleID   string          `json:"mention_role_id,omitempty"`
 	MessageTemplate json.RawMessage `json:"message_template"`
 	Enabled         bool            `json:"enabled"`
-	UpdatedAt       time.Time       `json:"updated_at"`
+	// AlertAdminsOnFailure posts a message to ChannelID when a streamer's
+	// link breaks (authorization revoked, subscription failed)
+	AlertAdminsOnFailure bool `json:"alert_admins_on_failure"`
+	// MentionEveryone lets {mention_everyone} expand to @everyone and ping
+	MentionEveryone bool `json:"mention_everyone"`
+	// UpdateOnChannelChange edits posted notifications when the streamer
+	// changes title or category (needs channel.update)
+	UpdateOnChannelChange bool `json:"update_on_channel_change"`
+	// DeleteOnOffline removes posted notifications when the stream ends
+	// (needs stream.offline)
+	DeleteOnOffline bool `json:"delete_on_offline"`
+	// RenotifyCooldownMinutes suppresses a new notification for a streamer
+	// notified (or whose stream ended) this recently, so a dropped and
+	// reconnected stream isn't announced twice; 0 disables it
+	RenotifyCooldownMinutes int `json:"renotify_cooldown_minutes"`
+	// ChannelIsForum posts each notification as a new thread in ChannelID,
+	// a forum channel. Derived from the channel when the config is saved.
+	ChannelIsForum bool `json:"channel_is_forum"`
+	// Tone selects a preset template (see Tones), used while MessageTemplate
+	// is still a preset
+	Tone string `json:"tone"`
+	// Quiet hours: daily "HH:MM" window in QuietHoursTimezone during which
+	// notifications are posted without pings ("silent") or not at all ("skip")
+	QuietHoursStart    string `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd      string `json:"quiet_hours_end,omitempty"`
+	QuietHoursTimezone string `json:"quiet_hours_timezone,omitempty"`
+	QuietHoursMode     string `json:"quiet_hours_mode,omitempty"`
+	// Timezone (IANA name) and TimeFormat (Go reference layout, empty =
+	// RFC 3339) render the {started_at} template variable
+	Timezone   string `json:"timezone"`
+	TimeFormat string `json:"time_format"`
+	// WebhookURL is the encrypted channel webhook notifications are posted
+	// through instead of the bot (empty = bot). Never serialized; set with
+	// SetGuildWebhookURL, not UpdateGuildConfig.
+	WebhookURL        string    `json:"-"`
+	WebhookConfigured bool      `json:"webhook_configured"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
 
 // Streamer represents a Twitch streamer
@@ -42,6 +80,7 @@ type Streamer struct {
 	TwitchAvatarURL      string    `json:"twitch_avatar_url,omitempty"`
 	TwitchAccessToken    string    `json:"-"` // Never serialize to JSON
 	TwitchRefreshToken   string    `json:"-"` // Never serialize to JSON
+	LinkedByUserID       string    `json:"-"` // Discord user who completed the Twitch OAuth
 	CreatedAt            time.Time `json:"created_at"`
 	LastUpdated          time.Time `json:"last_updated"`
 }
@@ -56,6 +95,17 @@ type GuildStreamer struct {
 	CustomContent string    `json:"custom_content,omitempty"`
 }
 
+// GuildDeletionImpact counts the rows that deleting a guild removes through
+// ON DELETE CASCADE
+type GuildDeletionImpact struct {
+	GuildConfig      int `json:"guild_config"`
+	StreamerLinks    int `json:"streamer_links"`
+	UserPreferences  int `json:"user_preferences"`
+	NotificationLogs int `json:"notification_logs"`
+	InviteLinks      int `json:"invite_links"`
+	Members          int `json:"members"`
+}
+
 // GuildWithRole represents a guild with the user's admin status
 type GuildWithRole struct {
 	Guild
@@ -74,6 +124,30 @@ type InviteLink struct {
 	CreatedAt time.Time  `json:"created_at"`
 }
 
+// UserSession is a session JWT issued to a user, identified by its JTI
+type UserSession struct {
+	JTI       string    `json:"id"`
+	UserID    string    `json:"-"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Current   bool      `json:"current"` // set by the handler for the caller's own session
+}
+
+// AuditLogEntry is a recorded sensitive operation, as shown to guild admins
+// (the caller's IP address is not exposed)
+type AuditLogEntry struct {
+	ID           string          `json:"id"`
+	Timestamp    time.Time       `json:"timestamp"`
+	UserID       string          `json:"user_id"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id,omitempty"`
+	Details      json.RawMessage `json:"details,omitempty"`
+	Success      bool            `json:"success"`
+}
+
 // UserPreference represents per-user notification settings
 type UserPreference struct {
 	UserID               string    `json:"user_id"`
@@ -88,6 +162,7 @@ type UserPreference struct {
 type EventSubSubscription struct {
 	StreamerID     string    `json:"streamer_id"`
 	SubscriptionID string    `json:"subscription_id"`
+	Type           string    `json:"subscription_type"`
 	Status         string    `json:"status"`
 	CreatedAt      time.Time `json:"created_at"`
 	LastVerified   time.Time `json:"last_verified"`
@@ -95,11 +170,71 @@ type EventSubSubscription struct {
 
 // NotificationLog represents a sent notification (for idempotency)
 type NotificationLog struct {
-	ID         string    `json:"id"`
-	GuildID    string    `json:"guild_id"`
-	StreamerID string    `json:"streamer_id"`
-	EventID    string    `json:"event_id"`
-	SentAt     time.Time `json:"sent_at"`
+	ID               string     `json:"id"`
+	GuildID          string     `json:"guild_id"`
+	StreamerID       string     `json:"streamer_id"`
+	EventID          string     `json:"event_id"`
+	SentAt           time.Time  `json:"sent_at"`
+	ChannelID        string     `json:"channel_id,omitempty"`
+	DiscordMessageID string     `json:"discord_message_id,omitempty"`
+	EndedAt          *time.Time `json:"ended_at,omitempty"`
+}
+
+// NotificationStats aggregates a guild's notifications over a time window
+type NotificationStats struct {
+	Since       time.Time                   `json:"since"`
+	TotalSent   int                         `json:"total_sent"`
+	PerStreamer []StreamerNotificationCount `json:"per_streamer"`
+	Daily       []DailyNotificationCount    `json:"daily"`
+}
+
+// StreamerNotificationCount is the number of notifications for one streamer
+type StreamerNotificationCount struct {
+	StreamerID        string `json:"streamer_id"`
+	TwitchLogin       string `json:"twitch_login"`
+	TwitchDisplayName string `json:"twitch_display_name,omitempty"`
+	Count             int    `json:"count"`
+}
+
+// DailyNotificationCount is the number of notifications on one day (UTC)
+type DailyNotificationCount struct {
+	Day   string `json:"day"` // YYYY-MM-DD
+	Count int    `json:"count"`
+}
+
+// Template variables, written {name} in message templates and custom content.
+// TemplateVariables is the allowlist shared by the renderer and the validator.
+const (
+	TemplateVarStreamerLogin       = "streamer_login"
+	TemplateVarStreamerDisplayName = "streamer_display_name"
+	TemplateVarStreamerAvatarURL   = "streamer_avatar_url"
+	TemplateVarStreamTitle         = "stream_title"
+	TemplateVarGameName            = "game_name"
+	TemplateVarViewerCount         = "viewer_count"
+	TemplateVarStreamThumbnailURL  = "stream_thumbnail_url"
+	TemplateVarStartedAt           = "started_at"
+	TemplateVarStartedAtDiscord    = "started_at_discord"
+	TemplateVarStartedAtRelative   = "started_at_relative"
+	TemplateVarStreamUptime        = "stream_uptime"
+	TemplateVarMentionRole         = "mention_role"
+	TemplateVarMentionEveryone     = "mention_everyone"
+)
+
+// TemplateVariables lists every known template variable name
+var TemplateVariables = []string{
+	TemplateVarStreamerLogin,
+	TemplateVarStreamerDisplayName,
+	TemplateVarStreamerAvatarURL,
+	TemplateVarStreamTitle,
+	TemplateVarGameName,
+	TemplateVarViewerCount,
+	TemplateVarStreamThumbnailURL,
+	TemplateVarStartedAt,
+	TemplateVarStartedAtDiscord,
+	TemplateVarStartedAtRelative,
+	TemplateVarStreamUptime,
+	TemplateVarMentionRole,
+	TemplateVarMentionEveryone,
 }
 
 // MessageTemplate represents the JSONB structure for notification templates
@@ -158,3 +293,66 @@ func DefaultMessageTemplate() MessageTemplate {
 		},
 	}
 }
+
+// Notification tone presets (guild_config.tone)
+const (
+	ToneDefault      = "default"
+	ToneHype         = "hype"
+	ToneMinimal      = "minimal"
+	ToneProfessional = "professional"
+)
+
+// Tones lists the tone presets in display order
+var Tones = []string{ToneDefault, ToneHype, ToneMinimal, ToneProfessional}
+
+// IsValidTone reports whether tone is one of Tones
+func IsValidTone(tone string) bool {
+	return slices.Contains(Tones, tone)
+}
+
+// MessageTemplateForTone returns the preset template for a tone, a variation
+// of DefaultMessageTemplate. Unknown tones get the default template.
+func MessageTemplateForTone(tone string) MessageTemplate {
+	tmpl := DefaultMessageTemplate()
+	switch tone {
+	case ToneHype:
+		tmpl.Content = "🔴 {streamer_display_name} is LIVE! Get in here! 🎉"
+		tmpl.Embed.Title = "🔥 {streamer_display_name} is streaming {game_name}!"
+		tmpl.Embed.Fields = []EmbedField{
+			{Name: "👀 Viewers", Value: "{viewer_count}", Inline: true},
+			{Name: "🎮 Game", Value: "{game_name}", Inline: true},
+		}
+		tmpl.Embed.Footer = &EmbedFooter{Text: "Don't miss it! 🚀"}
+	case ToneMinimal:
+		tmpl.Content = "{streamer_display_name} is live"
+		tmpl.Embed.Title = "{stream_title}"
+		tmpl.Embed.Description = "{game_name}"
+		tmpl.Embed.Image = nil
+		tmpl.Embed.Fields = nil
+		tmpl.Embed.Footer = nil
+	case ToneProfessional:
+		tmpl.Content = "{streamer_display_name} has started a live stream."
+		tmpl.Embed.Title = "{streamer_display_name} is now live on Twitch"
+		tmpl.Embed.Fields = []EmbedField{
+			{Name: "Category", Value: "{game_name}", Inline: true},
+			{Name: "Viewers", Value: "{viewer_count}", Inline: true},
+		}
+		tmpl.Embed.Footer = &EmbedFooter{Text: "Twitch"}
+	}
+	return tmpl
+}
+
+// IsPresetTemplate reports whether a stored template is one of the tone
+// presets unchanged, i.e. the guild hasn't written a template of its own.
+func IsPresetTemplate(templateJSON json.RawMessage) bool {
+	var tmpl MessageTemplate
+	if err := json.Unmarshal(templateJSON, &tmpl); err != nil {
+		return false
+	}
+	for _, tone := range Tones {
+		if reflect.DeepEqual(tmpl, MessageTemplateForTone(tone)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ConnPool wraps pgxpool.Pool and transparently retries a query once when it
+// fails on a stale connection. In Lambda, pooled connections can die between
+// invocations (NAT idle timeout, RDS failover); pgx discards the broken
+// connection, so a single retry lands on a fresh one.
+//
+// Only errors pgconn reports as SafeToRetry (nothing was sent to the server)
+// are retried, so writes are never applied twice.
+type ConnPool struct {
+	*pgxpool.Pool
+}
+
+// ErrPoolExhausted is returned by ReserveConn when every connection stays
+// in use for the whole wait.
+var ErrPoolExhausted = errors.New("database connection pool exhausted")
+
+// reservedConnKey is the context key of a connection held by ReserveConn.
+type reservedConnKey struct{}
+
+// connQuerier is what ConnPool runs statements on: the pool itself, or a
+// connection reserved for the request.
+type connQuerier interface {
+	querier
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// ReserveConn acquires a connection for the rest of a request and returns a
+// context carrying it: Exec, Query, QueryRow and Begin called with that
+// context run on the reserved connection instead of queuing for another.
+// While the pool has spare capacity it acquires normally; once every
+// connection is in use it waits at most wait and returns ErrPoolExhausted if
+// none frees up. Call release when the request is done. A reserved
+// connection serves one statement at a time, so the context must not be
+// shared with goroutines that query in parallel.
+func (p *ConnPool) ReserveConn(ctx context.Context, wait time.Duration) (context.Context, func(), error) {
+	acquireCtx := ctx
+	if stat := p.Stat(); stat.AcquiredConns() >= stat.MaxConns() {
+		var cancel context.CancelFunc
+		acquireCtx, cancel = context.WithTimeout(ctx, wait)
+		defer cancel()
+	}
+
+	conn, err := p.Pool.Acquire(acquireCtx)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return ctx, nil, ErrPoolExhausted
+		}
+		return ctx, nil, err
+	}
+	return context.WithValue(ctx, reservedConnKey{}, conn), conn.Release, nil
+}
+
+// on returns the connection reserved in ctx, or the pool.
+func (p *ConnPool) on(ctx context.Context) connQuerier {
+	if conn, ok := ctx.Value(reservedConnKey{}).(*pgxpool.Conn); ok {
+		return conn
+	}
+	return p.Pool
+}
+
+// isRetryableConnError reports whether err came from a dead connection
+// before the statement reached the server.
+func isRetryableConnError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	return pgconn.SafeToRetry(err)
+}
+
+// Exec executes a statement, retrying once on a stale connection.
+func (p *ConnPool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tag, err := p.on(ctx).Exec(ctx, sql, args...)
+	if isRetryableConnError(ctx, err) {
+		log.Printf("[DB_WARN] Stale connection on exec, retrying: %v", err)
+		tag, err = p.Pool.Exec(ctx, sql, args...)
+	}
+	return tag, err
+}
+
+// Query runs a query, retrying once on a stale connection.
+func (p *ConnPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := p.on(ctx).Query(ctx, sql, args...)
+	if isRetryableConnError(ctx, err) {
+		log.Printf("[DB_WARN] Stale connection on query, retrying: %v", err)
+		rows, err = p.Pool.Query(ctx, sql, args...)
+	}
+	return rows, err
+}
+
+// QueryRow runs a single-row query. The error surfaces at Scan, so the
+// retry happens there.
+func (p *ConnPool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &retryRow{
+		pool: p.Pool,
+		ctx:  ctx,
+		sql:  sql,
+		args: args,
+		row:  p.on(ctx).QueryRow(ctx, sql, args...),
+	}
+}
+
+// Begin starts a transaction, on the reserved connection if ctx has one.
+func (p *ConnPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	return p.on(ctx).Begin(ctx)
+}
+
+// retryRow re-runs its query once if Scan fails on a stale connection.
+type retryRow struct {
+	pool *pgxpool.Pool
+	ctx  context.Context
+	sql  string
+	args []any
+	row  pgx.Row
+}
+
+func (r *retryRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	if isRetryableConnError(r.ctx, err) {
+		log.Printf("[DB_WARN] Stale connection on query row, retrying: %v", err)
+		err = r.pool.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	}
+	return err
+}
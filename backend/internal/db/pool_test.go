@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newDeadConnPool returns a ConnPool whose single idle connection the server
+// has already reset, as a warm Lambda finds after a NAT idle timeout.
+func newDeadConnPool(t *testing.T, f *fakePostgres) *ConnPool {
+	t.Helper()
+	f.dropConn = func(n int) bool { return n == 1 }
+	pool := newFakePool(t, f, 1)
+
+	conn, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	conn.Release()
+	// Let the reset reach the client socket
+	time.Sleep(50 * time.Millisecond)
+	return &ConnPool{Pool: pool}
+}
+
+func TestConnPoolRecoversFromDeadConnection(t *testing.T) {
+	f := newFakePostgres(t, func(sql string) fakeResult {
+		return fakeResult{columns: textColumns("greeting"), rows: [][]any{{"hello"}}}
+	})
+	p := newDeadConnPool(t, f)
+
+	var greeting string
+	if err := p.QueryRow(context.Background(), "SELECT 'hello'").Scan(&greeting); err != nil {
+		t.Fatalf("QueryRow on a dead connection: %v", err)
+	}
+	if greeting != "hello" {
+		t.Errorf("greeting = %q, want hello", greeting)
+	}
+	if got := f.connCount(); got < 2 {
+		t.Errorf("server saw %d connections, want a fresh one after the dead one", got)
+	}
+}
+
+func TestConnPoolRetriesExecAndQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(*ConnPool) error
+	}{
+		{"exec", func(p *ConnPool) error {
+			_, err := p.Exec(context.Background(), "UPDATE streamers SET last_updated = now()")
+			return err
+		}},
+		{"query", func(p *ConnPool) error {
+			rows, err := p.Query(context.Background(), "SELECT 'hello'")
+			if err != nil {
+				return err
+			}
+			rows.Close()
+			return rows.Err()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakePostgres(t, func(sql string) fakeResult {
+				return fakeResult{columns: textColumns("greeting"), rows: [][]any{{"hello"}}}
+			})
+			if err := tt.run(newDeadConnPool(t, f)); err != nil {
+				t.Fatalf("%s on a dead connection: %v", tt.name, err)
+			}
+			if got := len(f.sent()); got != 1 {
+				t.Errorf("server ran %d statements, want 1", got)
+			}
+		})
+	}
+}
+
+func TestConnPoolDoesNotRetryServerErrors(t *testing.T) {
+	f := newFakePostgres(t, func(sql string) fakeResult {
+		return fakeResult{err: "relation does not exist"}
+	})
+	p := &ConnPool{Pool: newFakePool(t, f, 1)}
+
+	if _, err := p.Exec(context.Background(), "UPDATE missing SET x = 1"); err == nil {
+		t.Fatal("Exec succeeded, want the server error")
+	}
+	if got := len(f.sent()); got != 1 {
+		t.Errorf("server ran %d statements, want 1 (no retry)", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -38,6 +39,18 @@ func GetUser(ctx context.Context, userID string) (*User, error) {
 	return &user, nil
 }
 
+// EnsureUser inserts a user seen outside the dashboard (e.g. via a slash
+// command) without touching last_login of an existing row
+func EnsureUser(ctx context.Context, userID, username, avatar string) error {
+	query := `
+		INSERT INTO users (user_id, username, avatar)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+	_, err := Pool.Exec(ctx, query, userID, username, avatar)
+	return err
+}
+
 // Guild queries
 
 // CreateOrUpdateGuild inserts or updates a guild
@@ -72,6 +85,27 @@ func DeleteGuild(ctx context.Context, guildID string) error {
 	return err
 }
 
+// GetGuildDeletionImpact counts the dependent rows DeleteGuild would remove
+func GetGuildDeletionImpact(ctx context.Context, guildID string) (*GuildDeletionImpact, error) {
+	query := `
+		SELECT (SELECT count(*) FROM guild_config WHERE guild_id = $1),
+		       (SELECT count(*) FROM guild_streamers WHERE guild_id = $1),
+		       (SELECT count(*) FROM user_preferences WHERE guild_id = $1),
+		       (SELECT count(*) FROM notification_log WHERE guild_id = $1),
+		       (SELECT count(*) FROM invite_links WHERE guild_id = $1),
+		       (SELECT count(*) FROM user_guilds WHERE guild_id = $1)
+	`
+	var impact GuildDeletionImpact
+	err := Pool.QueryRow(ctx, query, guildID).Scan(
+		&impact.GuildConfig, &impact.StreamerLinks, &impact.UserPreferences,
+		&impact.NotificationLogs, &impact.InviteLinks, &impact.Members,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &impact, nil
+}
+
 // GuildConfig queries
 
 // CreateGuildConfig creates default guild configuration
@@ -94,7 +128,10 @@ func CreateGuildConfig(ctx context.Context, guildID, channelID string) error {
 // GetGuildConfig retrieves guild configuration, creating a default if none exists
 func GetGuildConfig(ctx context.Context, guildID string) (*GuildConfig, error) {
 	query := `
-		SELECT guild_id, channel_id, mention_role_id, message_template, enabled, updated_at
+		SELECT guild_id, channel_id, mention_role_id, message_template, enabled, alert_admins_on_failure, mention_everyone, tone,
+		       update_on_channel_change, delete_on_offline, renotify_cooldown_minutes, channel_is_forum,
+		       COALESCE(quiet_hours_start, ''), COALESCE(quiet_hours_end, ''), quiet_hours_timezone, quiet_hours_mode,
+		       timezone, time_format, COALESCE(webhook_url, ''), updated_at
 		FROM guild_config
 		WHERE guild_id = $1
 	`
@@ -102,18 +139,24 @@ func GetGuildConfig(ctx context.Context, guildID string) (*GuildConfig, error) {
 	var mentionRoleID *string
 	err := Pool.QueryRow(ctx, query, guildID).Scan(
 		&config.GuildID, &config.ChannelID, &mentionRoleID,
-		&config.MessageTemplate, &config.Enabled, &config.UpdatedAt,
+		&config.MessageTemplate, &config.Enabled, &config.AlertAdminsOnFailure, &config.MentionEveryone, &config.Tone,
+		&config.UpdateOnChannelChange, &config.DeleteOnOffline, &config.RenotifyCooldownMinutes, &config.ChannelIsForum,
+		&config.QuietHoursStart, &config.QuietHoursEnd, &config.QuietHoursTimezone, &config.QuietHoursMode,
+		&config.Timezone, &config.TimeFormat, &config.WebhookURL, &config.UpdatedAt,
 	)
 	if err != nil {
 		// If no config exists yet, create a default one
-		if err.Error() == "no rows in result set" {
+		if errors.Is(err, pgx.ErrNoRows) {
 			if createErr := CreateGuildConfig(ctx, guildID, ""); createErr != nil {
 				return nil, fmt.Errorf("failed to create default config: %w", createErr)
 			}
 			// Re-fetch after creation
 			err = Pool.QueryRow(ctx, query, guildID).Scan(
 				&config.GuildID, &config.ChannelID, &mentionRoleID,
-				&config.MessageTemplate, &config.Enabled, &config.UpdatedAt,
+				&config.MessageTemplate, &config.Enabled, &config.AlertAdminsOnFailure, &config.MentionEveryone, &config.Tone,
+				&config.UpdateOnChannelChange, &config.DeleteOnOffline, &config.RenotifyCooldownMinutes, &config.ChannelIsForum,
+				&config.QuietHoursStart, &config.QuietHoursEnd, &config.QuietHoursTimezone, &config.QuietHoursMode,
+				&config.Timezone, &config.TimeFormat, &config.WebhookURL, &config.UpdatedAt,
 			)
 			if err != nil {
 				return nil, err
@@ -125,18 +168,54 @@ func GetGuildConfig(ctx context.Context, guildID string) (*GuildConfig, error) {
 	if mentionRoleID != nil {
 		config.MentionRoleID = *mentionRoleID
 	}
+	config.WebhookConfigured = config.WebhookURL != ""
 	return &config, nil
 }
 
+// SetGuildWebhookURL sets the encrypted webhook URL a guild's notifications
+// are posted through; empty switches the guild back to the bot
+func SetGuildWebhookURL(ctx context.Context, guildID, encryptedURL string) error {
+	query := `UPDATE guild_config SET webhook_url = NULLIF($2, ''), updated_at = now() WHERE guild_id = $1`
+	tag, err := Pool.Exec(ctx, query, guildID, encryptedURL)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 && encryptedURL != "" {
+		// A guild that never had the bot may not have a config yet
+		if err := CreateGuildConfig(ctx, guildID, ""); err != nil {
+			return fmt.Errorf("failed to create default config: %w", err)
+		}
+		if tag, err = Pool.Exec(ctx, query, guildID, encryptedURL); err != nil {
+			return err
+		}
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
 // UpdateGuildConfig updates guild configuration
 func UpdateGuildConfig(ctx context.Context, config *GuildConfig) error {
 	query := `
 		UPDATE guild_config
-		SET channel_id = $2, mention_role_id = $3, message_template = $4, enabled = $5, updated_at = now()
+		SET channel_id = $2, mention_role_id = $3, message_template = $4, enabled = $5,
+		    alert_admins_on_failure = $6, mention_everyone = $11, tone = $12,
+		    update_on_channel_change = $13, delete_on_offline = $14, renotify_cooldown_minutes = $15,
+		    channel_is_forum = $16,
+		    quiet_hours_start = $7, quiet_hours_end = $8, quiet_hours_timezone = $9, quiet_hours_mode = $10,
+		    timezone = $17, time_format = $18,
+		    updated_at = now()
 		WHERE guild_id = $1
 	`
 	mentionRoleID := nullableString(config.MentionRoleID)
-	_, err := Pool.Exec(ctx, query, config.GuildID, config.ChannelID, mentionRoleID, config.MessageTemplate, config.Enabled)
+	_, err := Pool.Exec(ctx, query, config.GuildID, config.ChannelID, mentionRoleID, config.MessageTemplate, config.Enabled,
+		config.AlertAdminsOnFailure,
+		nullableString(config.QuietHoursStart), nullableString(config.QuietHoursEnd), config.QuietHoursTimezone, config.QuietHoursMode,
+		config.MentionEveryone, config.Tone,
+		config.UpdateOnChannelChange, config.DeleteOnOffline, config.RenotifyCooldownMinutes,
+		config.ChannelIsForum,
+		config.Timezone, config.TimeFormat)
 	return err
 }
 
@@ -144,19 +223,106 @@ func UpdateGuildConfig(ctx context.Context, config *GuildConfig) error {
 
 // CreateStreamer inserts a new streamer
 func CreateStreamer(ctx context.Context, streamer *Streamer) error {
+	return createStreamer(ctx, Pool, streamer)
+}
+
+// CreateStreamerTx is CreateStreamer within tx
+func CreateStreamerTx(ctx context.Context, tx pgx.Tx, streamer *Streamer) error {
+	return createStreamer(ctx, tx, streamer)
+}
+
+func createStreamer(ctx context.Context, q querier, streamer *Streamer) error {
 	query := `
-		INSERT INTO streamers (twitch_broadcaster_id, twitch_login, twitch_display_name, twitch_avatar_url, twitch_access_token, twitch_refresh_token)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO streamers (twitch_broadcaster_id, twitch_login, twitch_display_name, twitch_avatar_url, twitch_access_token, twitch_refresh_token, linked_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
 		ON CONFLICT (twitch_broadcaster_id)
-		DO UPDATE SET twitch_login = $2, twitch_display_name = $3, twitch_avatar_url = $4, twitch_access_token = $5, twitch_refresh_token = $6, last_updated = now()
+		DO UPDATE SET twitch_login = $2, twitch_display_name = $3, twitch_avatar_url = $4, twitch_access_token = $5, twitch_refresh_token = $6, needs_reauth = false,
+			linked_by_user_id = COALESCE(NULLIF($7, ''), streamers.linked_by_user_id), last_updated = now()
 		RETURNING id
 	`
-	return Pool.QueryRow(ctx, query,
+	return q.QueryRow(ctx, query,
 		streamer.TwitchBroadcasterID, streamer.TwitchLogin, streamer.TwitchDisplayName,
 		streamer.TwitchAvatarURL, streamer.TwitchAccessToken, streamer.TwitchRefreshToken,
+		streamer.LinkedByUserID,
 	).Scan(&streamer.ID)
 }
 
+// EnsureStreamerTx stores a streamer added without OAuth (no user tokens;
+// only app-token subscriptions such as stream.online work for it) within tx.
+// An existing streamer gets its profile refreshed but keeps its tokens.
+// streamer.ID is set either way.
+func EnsureStreamerTx(ctx context.Context, tx pgx.Tx, streamer *Streamer) error {
+	query := `
+		INSERT INTO streamers (twitch_broadcaster_id, twitch_login, twitch_display_name, twitch_avatar_url)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (twitch_broadcaster_id)
+		DO UPDATE SET twitch_login = $2, twitch_display_name = $3, twitch_avatar_url = $4, last_updated = now()
+		RETURNING id
+	`
+	return tx.QueryRow(ctx, query,
+		streamer.TwitchBroadcasterID, streamer.TwitchLogin, streamer.TwitchDisplayName, streamer.TwitchAvatarURL,
+	).Scan(&streamer.ID)
+}
+
+// UpdateStreamerProfileIfChanged updates a streamer's login and display name
+// if either differs from what is stored. Returns true if the row changed.
+func UpdateStreamerProfileIfChanged(ctx context.Context, streamerID, login, displayName string) (bool, error) {
+	query := `
+		UPDATE streamers
+		SET twitch_login = $2, twitch_display_name = $3, last_updated = now()
+		WHERE id = $1
+		  AND (twitch_login IS DISTINCT FROM $2 OR twitch_display_name IS DISTINCT FROM $3)
+	`
+	tag, err := Pool.Exec(ctx, query, streamerID, login, displayName)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetStreamerTokens returns a streamer's stored (encrypted) OAuth tokens.
+// Missing tokens are returned as empty strings.
+func GetStreamerTokens(ctx context.Context, streamerID string) (accessToken, refreshToken string, err error) {
+	query := `
+		SELECT COALESCE(twitch_access_token, ''), COALESCE(twitch_refresh_token, '')
+		FROM streamers
+		WHERE id = $1
+	`
+	err = Pool.QueryRow(ctx, query, streamerID).Scan(&accessToken, &refreshToken)
+	return accessToken, refreshToken, err
+}
+
+// UpdateStreamerTokens stores a refreshed (encrypted) token pair
+func UpdateStreamerTokens(ctx context.Context, streamerID, accessToken, refreshToken string) error {
+	query := `
+		UPDATE streamers
+		SET twitch_access_token = $2, twitch_refresh_token = $3, needs_reauth = false, last_updated = now()
+		WHERE id = $1
+	`
+	_, err := Pool.Exec(ctx, query, streamerID, accessToken, refreshToken)
+	return err
+}
+
+// MarkStreamerNeedsReauth flags a streamer whose tokens can no longer be
+// refreshed; the flag clears when they link their account again
+func MarkStreamerNeedsReauth(ctx context.Context, streamerID string) error {
+	query := `UPDATE streamers SET needs_reauth = true, last_updated = now() WHERE id = $1`
+	_, err := Pool.Exec(ctx, query, streamerID)
+	return err
+}
+
+// ClearStreamerTokens removes a streamer's stored OAuth tokens (used when
+// the streamer revokes our authorization)
+func ClearStreamerTokens(ctx context.Context, streamerID string) error {
+	query := `
+		UPDATE streamers
+		SET twitch_access_token = NULL, twitch_refresh_token = NULL, needs_reauth = true, last_updated = now()
+		WHERE id = $1
+	`
+	_, err := Pool.Exec(ctx, query, streamerID)
+	return err
+}
+
 // GetStreamerByID retrieves a streamer by internal ID
 func GetStreamerByID(ctx context.Context, id string) (*Streamer, error) {
 	query := `
@@ -176,6 +342,39 @@ func GetStreamerByID(ctx context.Context, id string) (*Streamer, error) {
 	return &streamer, nil
 }
 
+// GetStreamersByIDs retrieves streamers by internal ID, keyed by ID. IDs
+// with no streamer are left out of the map.
+func GetStreamersByIDs(ctx context.Context, ids []string) (map[string]*Streamer, error) {
+	streamers := make(map[string]*Streamer, len(ids))
+	if len(ids) == 0 {
+		return streamers, nil
+	}
+
+	query := `
+		SELECT id, twitch_broadcaster_id, twitch_login, twitch_display_name, twitch_avatar_url, created_at, last_updated
+		FROM streamers
+		WHERE id = ANY($1)
+	`
+	rows, err := Pool.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var streamer Streamer
+		if err := rows.Scan(
+			&streamer.ID, &streamer.TwitchBroadcasterID, &streamer.TwitchLogin,
+			&streamer.TwitchDisplayName, &streamer.TwitchAvatarURL,
+			&streamer.CreatedAt, &streamer.LastUpdated,
+		); err != nil {
+			return nil, err
+		}
+		streamers[streamer.ID] = &streamer
+	}
+	return streamers, rows.Err()
+}
+
 // GetStreamerByBroadcasterID retrieves a streamer by Twitch broadcaster ID
 func GetStreamerByBroadcasterID(ctx context.Context, broadcasterID string) (*Streamer, error) {
 	query := `
@@ -195,6 +394,37 @@ func GetStreamerByBroadcasterID(ctx context.Context, broadcasterID string) (*Str
 	return &streamer, nil
 }
 
+// GetStreamersLinkedByUser returns the streamers whose Twitch account the
+// given Discord user linked
+func GetStreamersLinkedByUser(ctx context.Context, userID string) ([]Streamer, error) {
+	query := `
+		SELECT id, twitch_broadcaster_id, twitch_login, twitch_display_name, twitch_avatar_url, created_at, last_updated
+		FROM streamers
+		WHERE linked_by_user_id = $1
+		ORDER BY twitch_login
+	`
+	rows, err := Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var streamers []Streamer
+	for rows.Next() {
+		var s Streamer
+		if err := rows.Scan(
+			&s.ID, &s.TwitchBroadcasterID, &s.TwitchLogin,
+			&s.TwitchDisplayName, &s.TwitchAvatarURL,
+			&s.CreatedAt, &s.LastUpdated,
+		); err != nil {
+			return nil, err
+		}
+		s.LinkedByUserID = userID
+		streamers = append(streamers, s)
+	}
+	return streamers, rows.Err()
+}
+
 // GetGuildStreamers retrieves all streamers for a guild
 func GetGuildStreamers(ctx context.Context, guildID string) ([]Streamer, error) {
 	query := `
@@ -225,12 +455,21 @@ func GetGuildStreamers(ctx context.Context, guildID string) ([]Streamer, error)
 // LinkStreamerToGuild links a streamer to a guild.
 // Returns (true, nil) if a new link was created, (false, nil) if the link already existed.
 func LinkStreamerToGuild(ctx context.Context, guildID, streamerID, addedBy string) (bool, error) {
+	return linkStreamerToGuild(ctx, Pool, guildID, streamerID, addedBy)
+}
+
+// LinkStreamerToGuildTx is LinkStreamerToGuild within tx
+func LinkStreamerToGuildTx(ctx context.Context, tx pgx.Tx, guildID, streamerID, addedBy string) (bool, error) {
+	return linkStreamerToGuild(ctx, tx, guildID, streamerID, addedBy)
+}
+
+func linkStreamerToGuild(ctx context.Context, q querier, guildID, streamerID, addedBy string) (bool, error) {
 	query := `
 		INSERT INTO guild_streamers (guild_id, streamer_id, added_by)
 		VALUES ($1, $2, $3)
 		ON CONFLICT (guild_id, streamer_id) DO NOTHING
 	`
-	result, err := Pool.Exec(ctx, query, guildID, streamerID, addedBy)
+	result, err := q.Exec(ctx, query, guildID, streamerID, addedBy)
 	if err != nil {
 		return false, err
 	}
@@ -269,36 +508,11 @@ func GetGuildsTrackingStreamer(ctx context.Context, streamerID string) ([]string
 
 // NotificationLog queries
 
-// CheckNotificationSent checks if a notification has already been sent
-func CheckNotificationSent(ctx context.Context, guildID, eventID string) (bool, error) {
-	query := `SELECT 1 FROM notification_log WHERE guild_id = $1 AND event_id = $2 LIMIT 1`
-	var exists int
-	err := Pool.QueryRow(ctx, query, guildID, eventID).Scan(&exists)
-	if err != nil {
-		if err.Error() == "no rows in result set" {
-			return false, nil
-		}
-		return false, err
-	}
-	return true, nil
-}
-
-// LogNotification logs a sent notification (idempotency)
-func LogNotification(ctx context.Context, guildID, streamerID, eventID string) error {
-	query := `
-		INSERT INTO notification_log (guild_id, streamer_id, event_id)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (guild_id, event_id) DO NOTHING
-	`
-	_, err := Pool.Exec(ctx, query, guildID, streamerID, eventID)
-	return err
-}
-
 // TryClaimNotification atomically claims the right to send a notification.
 // Returns true if we inserted a new row (we should send), false if another
 // Lambda instance already claimed it (skip sending).
-// This eliminates the TOCTOU race between CheckNotificationSent and LogNotification
-// that caused duplicate Discord messages.
+// This eliminates the TOCTOU race of a separate check-then-log, which caused
+// duplicate Discord messages.
 func TryClaimNotification(ctx context.Context, guildID, streamerID, eventID string) (bool, error) {
 	query := `
 		INSERT INTO notification_log (guild_id, streamer_id, event_id)
@@ -309,7 +523,7 @@ func TryClaimNotification(ctx context.Context, guildID, streamerID, eventID stri
 	var id string
 	err := Pool.QueryRow(ctx, query, guildID, streamerID, eventID).Scan(&id)
 	if err != nil {
-		if err == pgx.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			// Conflict: another instance already claimed this notification
 			return false, nil
 		}
@@ -319,35 +533,296 @@ func TryClaimNotification(ctx context.Context, guildID, streamerID, eventID stri
 	return true, nil
 }
 
+// GetFullyClaimedEventIDs returns which of eventIDs have a notification_log
+// entry in every guild currently tracking the event's streamer. An event
+// only some guilds were notified about (a fanout cut short by its
+// deadline, or sends that failed and were released) is not included.
+func GetFullyClaimedEventIDs(ctx context.Context, eventIDs []string) (map[string]bool, error) {
+	claimed := make(map[string]bool)
+	if len(eventIDs) == 0 {
+		return claimed, nil
+	}
+
+	query := `
+		SELECT DISTINCT nl.event_id FROM notification_log nl
+		WHERE nl.event_id = ANY($1)
+		  AND NOT EXISTS (
+			SELECT 1 FROM guild_streamers gs
+			WHERE gs.streamer_id = nl.streamer_id AND gs.enabled = true
+			  AND NOT EXISTS (
+				SELECT 1 FROM notification_log claim
+				WHERE claim.guild_id = gs.guild_id AND claim.event_id = nl.event_id
+			  )
+		  )
+	`
+	rows, err := Pool.Query(ctx, query, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eventID string
+		if err := rows.Scan(&eventID); err != nil {
+			return nil, err
+		}
+		claimed[eventID] = true
+	}
+	return claimed, rows.Err()
+}
+
+// ReleaseNotificationClaim deletes a claim whose notification could not be
+// sent, so the stream poller can claim and send it again.
+// Claims that already have a posted message are never released.
+func ReleaseNotificationClaim(ctx context.Context, guildID, eventID string) error {
+	query := `
+		DELETE FROM notification_log
+		WHERE guild_id = $1 AND event_id = $2 AND discord_message_id IS NULL
+	`
+	_, err := Pool.Exec(ctx, query, guildID, eventID)
+	return err
+}
+
+// SetNotificationMessageID records the Discord message posted for a claimed
+// notification. Returns true if the stream has already been marked ended
+// (stream.offline arrived while the notification was being sent), in which
+// case the caller should remove the message it just posted.
+func SetNotificationMessageID(ctx context.Context, guildID, eventID, channelID, messageID string) (bool, error) {
+	query := `
+		UPDATE notification_log
+		SET channel_id = $3, discord_message_id = $4
+		WHERE guild_id = $1 AND event_id = $2
+		RETURNING ended_at IS NOT NULL
+	`
+	var ended bool
+	err := Pool.QueryRow(ctx, query, guildID, eventID, channelID, messageID).Scan(&ended)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Claim row was removed (e.g. guild deleted) - nothing to track
+			return false, nil
+		}
+		return false, err
+	}
+	return ended, nil
+}
+
+// GetLastNotificationTime returns when a guild was last notified about a
+// streamer: the later of when the notification was posted and when that
+// stream ended. Claims whose message was never posted don't count. Returns
+// the zero time if there is none.
+func GetLastNotificationTime(ctx context.Context, guildID, streamerID string) (time.Time, error) {
+	query := `
+		SELECT max(GREATEST(sent_at, COALESCE(ended_at, sent_at)))
+		FROM notification_log
+		WHERE guild_id = $1 AND streamer_id = $2
+		  AND discord_message_id IS NOT NULL
+	`
+	var last *time.Time
+	if err := Pool.QueryRow(ctx, query, guildID, streamerID).Scan(&last); err != nil {
+		return time.Time{}, err
+	}
+	if last == nil {
+		return time.Time{}, nil
+	}
+	return *last, nil
+}
+
+// GetLiveNotifications returns the most recent posted, not yet ended
+// notification per guild for a streamer (last 48 hours).
+func GetLiveNotifications(ctx context.Context, streamerID string) ([]NotificationLog, error) {
+	query := `
+		SELECT DISTINCT ON (guild_id)
+		       id, guild_id, streamer_id, event_id, sent_at, channel_id, discord_message_id
+		FROM notification_log
+		WHERE streamer_id = $1
+		  AND ended_at IS NULL
+		  AND discord_message_id IS NOT NULL
+		  AND sent_at > now() - interval '48 hours'
+		ORDER BY guild_id, sent_at DESC
+	`
+	rows, err := Pool.Query(ctx, query, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []NotificationLog
+	for rows.Next() {
+		var n NotificationLog
+		if err := rows.Scan(
+			&n.ID, &n.GuildID, &n.StreamerID, &n.EventID, &n.SentAt, &n.ChannelID, &n.DiscordMessageID,
+		); err != nil {
+			return nil, err
+		}
+		logs = append(logs, n)
+	}
+	return logs, rows.Err()
+}
+
+// MarkStreamNotificationsEnded marks a streamer's recent notifications as
+// ended and returns them. Rows whose message has not been posted yet are
+// marked too, so an in-flight fanout sees the flag when it stores its message
+// ID. Only the last 48 hours are considered (no stream runs longer).
+func MarkStreamNotificationsEnded(ctx context.Context, streamerID string) ([]NotificationLog, error) {
+	query := `
+		UPDATE notification_log
+		SET ended_at = now()
+		WHERE streamer_id = $1
+		  AND ended_at IS NULL
+		  AND sent_at > now() - interval '48 hours'
+		RETURNING id, guild_id, streamer_id, event_id, sent_at,
+		          COALESCE(channel_id, ''), COALESCE(discord_message_id, ''), ended_at
+	`
+	rows, err := Pool.Query(ctx, query, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []NotificationLog
+	for rows.Next() {
+		var n NotificationLog
+		if err := rows.Scan(
+			&n.ID, &n.GuildID, &n.StreamerID, &n.EventID, &n.SentAt,
+			&n.ChannelID, &n.DiscordMessageID, &n.EndedAt,
+		); err != nil {
+			return nil, err
+		}
+		logs = append(logs, n)
+	}
+	return logs, rows.Err()
+}
+
+// GetStreamerNotificationHistory returns a streamer's most recent posted
+// notifications in a guild, newest first. Claims for skipped guilds and
+// claims still being sent have no message ID and are left out.
+func GetStreamerNotificationHistory(ctx context.Context, guildID, streamerID string, limit int) ([]NotificationLog, error) {
+	query := `
+		SELECT id, guild_id, streamer_id, event_id, sent_at, ended_at
+		FROM notification_log
+		WHERE guild_id = $1 AND streamer_id = $2
+		  AND discord_message_id IS NOT NULL
+		ORDER BY sent_at DESC
+		LIMIT $3
+	`
+	rows, err := Pool.Query(ctx, query, guildID, streamerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []NotificationLog{}
+	for rows.Next() {
+		var n NotificationLog
+		if err := rows.Scan(&n.ID, &n.GuildID, &n.StreamerID, &n.EventID, &n.SentAt, &n.EndedAt); err != nil {
+			return nil, err
+		}
+		logs = append(logs, n)
+	}
+	return logs, rows.Err()
+}
+
+// GetNotificationStats aggregates a guild's notifications since the given
+// time into a total, a per-streamer breakdown, and a daily (UTC) series.
+// Only posted notifications count, not claims for skipped guilds or claims
+// still being sent. Slices are empty, never nil, when nothing was sent.
+func GetNotificationStats(ctx context.Context, guildID string, since time.Time) (*NotificationStats, error) {
+	stats := &NotificationStats{
+		Since:       since,
+		PerStreamer: []StreamerNotificationCount{},
+		Daily:       []DailyNotificationCount{},
+	}
+
+	streamerQuery := `
+		SELECT s.id, s.twitch_login, COALESCE(s.twitch_display_name, ''), count(*)
+		FROM notification_log nl
+		JOIN streamers s ON s.id = nl.streamer_id
+		WHERE nl.guild_id = $1 AND nl.sent_at >= $2
+		  AND nl.discord_message_id IS NOT NULL
+		GROUP BY s.id, s.twitch_login, s.twitch_display_name
+		ORDER BY count(*) DESC, s.twitch_login
+	`
+	rows, err := Pool.Query(ctx, streamerQuery, guildID, since)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var c StreamerNotificationCount
+		if err := rows.Scan(&c.StreamerID, &c.TwitchLogin, &c.TwitchDisplayName, &c.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.PerStreamer = append(stats.PerStreamer, c)
+		stats.TotalSent += c.Count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	dailyQuery := `
+		SELECT to_char(date_trunc('day', sent_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD') AS day, count(*)
+		FROM notification_log
+		WHERE guild_id = $1 AND sent_at >= $2
+		  AND discord_message_id IS NOT NULL
+		GROUP BY day
+		ORDER BY day
+	`
+	rows, err = Pool.Query(ctx, dailyQuery, guildID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d DailyNotificationCount
+		if err := rows.Scan(&d.Day, &d.Count); err != nil {
+			return nil, err
+		}
+		stats.Daily = append(stats.Daily, d)
+	}
+	return stats, rows.Err()
+}
+
 // EventSub subscription queries
 
 // CreateEventSubSubscription creates or updates an EventSub subscription record
-func CreateEventSubSubscription(ctx context.Context, streamerID, subscriptionID, status string) error {
+func CreateEventSubSubscription(ctx context.Context, streamerID, subscriptionID, subscriptionType, status string) error {
 	query := `
-		INSERT INTO eventsub_subscriptions (streamer_id, subscription_id, status, last_verified)
-		VALUES ($1, $2, $3, now())
+		INSERT INTO eventsub_subscriptions (streamer_id, subscription_id, subscription_type, status, last_verified)
+		VALUES ($1, $2, $3, $4, now())
 		ON CONFLICT (subscription_id)
-		DO UPDATE SET status = $3, last_verified = now()
+		DO UPDATE SET status = $4, last_verified = now()
 	`
-	_, err := Pool.Exec(ctx, query, streamerID, subscriptionID, status)
+	_, err := Pool.Exec(ctx, query, streamerID, subscriptionID, subscriptionType, status)
 	return err
 }
 
-// GetEventSubSubscription retrieves a subscription by streamer ID
-func GetEventSubSubscription(ctx context.Context, streamerID string) (*EventSubSubscription, error) {
+// GetEventSubSubscriptions retrieves all subscriptions for a streamer
+// (one per EventSub type)
+func GetEventSubSubscriptions(ctx context.Context, streamerID string) ([]EventSubSubscription, error) {
 	query := `
-		SELECT streamer_id, subscription_id, status, created_at, last_verified
+		SELECT streamer_id, subscription_id, subscription_type, status, created_at, last_verified
 		FROM eventsub_subscriptions
 		WHERE streamer_id = $1
+		ORDER BY created_at
 	`
-	var sub EventSubSubscription
-	err := Pool.QueryRow(ctx, query, streamerID).Scan(
-		&sub.StreamerID, &sub.SubscriptionID, &sub.Status, &sub.CreatedAt, &sub.LastVerified,
-	)
+	rows, err := Pool.Query(ctx, query, streamerID)
 	if err != nil {
 		return nil, err
 	}
-	return &sub, nil
+	defer rows.Close()
+
+	var subs []EventSubSubscription
+	for rows.Next() {
+		var sub EventSubSubscription
+		if err := rows.Scan(
+			&sub.StreamerID, &sub.SubscriptionID, &sub.Type, &sub.Status, &sub.CreatedAt, &sub.LastVerified,
+		); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
 }
 
 // DeleteEventSubSubscription deletes a subscription record
@@ -384,6 +859,49 @@ func GetUserPreferences(ctx context.Context, userID string) ([]UserPreference, e
 	return prefs, rows.Err()
 }
 
+// GetUserNotificationsEnabled reports whether a user receives notifications
+// for a streamer in a guild. No preference row means enabled.
+func GetUserNotificationsEnabled(ctx context.Context, userID, guildID, streamerID string) (bool, error) {
+	query := `
+		SELECT notifications_enabled FROM user_preferences
+		WHERE user_id = $1 AND guild_id = $2 AND streamer_id = $3
+	`
+	var enabled bool
+	err := Pool.QueryRow(ctx, query, userID, guildID, streamerID).Scan(&enabled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// GetUserDisabledStreamers returns the IDs of the streamers a user has
+// turned notifications off for in a guild, in one query. Streamers without a
+// preference row are enabled and absent from the set.
+func GetUserDisabledStreamers(ctx context.Context, userID, guildID string) (map[string]bool, error) {
+	query := `
+		SELECT streamer_id FROM user_preferences
+		WHERE user_id = $1 AND guild_id = $2 AND notifications_enabled = false
+	`
+	rows, err := Pool.Query(ctx, query, userID, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	disabled := make(map[string]bool)
+	for rows.Next() {
+		var streamerID string
+		if err := rows.Scan(&streamerID); err != nil {
+			return nil, err
+		}
+		disabled[streamerID] = true
+	}
+	return disabled, rows.Err()
+}
+
 // SetUserPreference creates or updates a user notification preference
 func SetUserPreference(ctx context.Context, userID, guildID, streamerID string, enabled bool) error {
 	query := `
@@ -436,6 +954,74 @@ func CleanupOldNotificationLogs(ctx context.Context) (int64, error) {
 	return result.RowsAffected(), nil
 }
 
+// EventSub types a streamer can need; the values are Twitch's subscription
+// type names (see the twitch package's SubscriptionType constants)
+const (
+	EventTypeStreamOnline  = "stream.online"
+	EventTypeStreamOffline = "stream.offline"
+	EventTypeChannelUpdate = "channel.update"
+)
+
+// GetRequiredEventTypesForStreamer returns the EventSub types the guilds
+// tracking a streamer need: stream.online always, channel.update if any guild
+// updates posted notifications, stream.offline if any guild deletes them.
+// Guilds that disabled notifications, or paused this streamer, need nothing.
+// Returns nil if no enabled guild tracks the streamer.
+func GetRequiredEventTypesForStreamer(ctx context.Context, streamerID string) ([]string, error) {
+	query := `
+		SELECT count(*),
+		       COALESCE(bool_or(COALESCE(gc.update_on_channel_change, true)), false),
+		       COALESCE(bool_or(COALESCE(gc.delete_on_offline, true)), false)
+		FROM guild_streamers gs
+		LEFT JOIN guild_config gc ON gc.guild_id = gs.guild_id
+		WHERE gs.streamer_id = $1
+		  AND COALESCE(gs.enabled, true)
+		  AND COALESCE(gc.enabled, true)
+	`
+	var guilds int
+	var wantUpdate, wantOffline bool
+	if err := Pool.QueryRow(ctx, query, streamerID).Scan(&guilds, &wantUpdate, &wantOffline); err != nil {
+		return nil, err
+	}
+	if guilds == 0 {
+		return nil, nil
+	}
+
+	types := []string{EventTypeStreamOnline}
+	if wantUpdate {
+		types = append(types, EventTypeChannelUpdate)
+	}
+	if wantOffline {
+		types = append(types, EventTypeStreamOffline)
+	}
+	return types, nil
+}
+
+// GetLinkedStreamers returns every streamer linked to at least one guild
+func GetLinkedStreamers(ctx context.Context) ([]Streamer, error) {
+	query := `
+		SELECT id, twitch_broadcaster_id, twitch_login
+		FROM streamers
+		WHERE id IN (SELECT streamer_id FROM guild_streamers)
+		ORDER BY twitch_login
+	`
+	rows, err := Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var streamers []Streamer
+	for rows.Next() {
+		var s Streamer
+		if err := rows.Scan(&s.ID, &s.TwitchBroadcasterID, &s.TwitchLogin); err != nil {
+			return nil, err
+		}
+		streamers = append(streamers, s)
+	}
+	return streamers, rows.Err()
+}
+
 // GetOrphanedStreamers returns streamer IDs not linked to any guilds
 func GetOrphanedStreamers(ctx context.Context) ([]string, error) {
 	query := `
@@ -465,13 +1051,22 @@ func GetOrphanedStreamers(ctx context.Context) ([]string, error) {
 
 // UpsertUserGuild inserts or updates a user-guild membership
 func UpsertUserGuild(ctx context.Context, userID, guildID string, isAdmin bool) error {
+	return upsertUserGuild(ctx, Pool, userID, guildID, isAdmin)
+}
+
+// UpsertUserGuildTx is UpsertUserGuild within tx
+func UpsertUserGuildTx(ctx context.Context, tx pgx.Tx, userID, guildID string, isAdmin bool) error {
+	return upsertUserGuild(ctx, tx, userID, guildID, isAdmin)
+}
+
+func upsertUserGuild(ctx context.Context, q querier, userID, guildID string, isAdmin bool) error {
 	query := `
 		INSERT INTO user_guilds (user_id, guild_id, is_admin, updated_at)
 		VALUES ($1, $2, $3, now())
 		ON CONFLICT (user_id, guild_id)
 		DO UPDATE SET is_admin = $3, updated_at = now()
 	`
-	_, err := Pool.Exec(ctx, query, userID, guildID, isAdmin)
+	_, err := q.Exec(ctx, query, userID, guildID, isAdmin)
 	return err
 }
 
@@ -507,7 +1102,7 @@ func IsUserGuildAdmin(ctx context.Context, userID, guildID string) (bool, error)
 	var isAdmin bool
 	err := Pool.QueryRow(ctx, query, userID, guildID).Scan(&isAdmin)
 	if err != nil {
-		if err.Error() == "no rows in result set" {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return false, nil
 		}
 		return false, err
@@ -521,7 +1116,7 @@ func IsUserGuildMember(ctx context.Context, userID, guildID string) (bool, error
 	var exists int
 	err := Pool.QueryRow(ctx, query, userID, guildID).Scan(&exists)
 	if err != nil {
-		if err.Error() == "no rows in result set" {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return false, nil
 		}
 		return false, err
@@ -599,20 +1194,31 @@ func GetInviteLink(ctx context.Context, code string) (*InviteLink, error) {
 
 // IncrementInviteUse increments the use count of an invite link
 func IncrementInviteUse(ctx context.Context, code string) error {
+	return incrementInviteUse(ctx, Pool, code)
+}
+
+// IncrementInviteUseTx is IncrementInviteUse within tx
+func IncrementInviteUseTx(ctx context.Context, tx pgx.Tx, code string) error {
+	return incrementInviteUse(ctx, tx, code)
+}
+
+func incrementInviteUse(ctx context.Context, q querier, code string) error {
 	query := `UPDATE invite_links SET use_count = use_count + 1 WHERE code = $1`
-	_, err := Pool.Exec(ctx, query, code)
+	_, err := q.Exec(ctx, query, code)
 	return err
 }
 
-// GetGuildInviteLinks returns all invite links for a guild
-func GetGuildInviteLinks(ctx context.Context, guildID string) ([]InviteLink, error) {
+// GetGuildInviteLinks returns a guild's invite links, newest first. A limit
+// of 0 or less returns all links from offset on.
+func GetGuildInviteLinks(ctx context.Context, guildID string, limit, offset int) ([]InviteLink, error) {
 	query := `
 		SELECT id, guild_id, code, created_by, expires_at, max_uses, use_count, created_at
 		FROM invite_links
 		WHERE guild_id = $1
-		ORDER BY created_at DESC
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
 	`
-	rows, err := Pool.Query(ctx, query, guildID)
+	rows, err := Pool.Query(ctx, query, guildID, nullableLimit(limit), offset)
 	if err != nil {
 		return nil, err
 	}
@@ -632,6 +1238,13 @@ func GetGuildInviteLinks(ctx context.Context, guildID string) ([]InviteLink, err
 	return links, rows.Err()
 }
 
+// CountGuildInviteLinks returns the number of invite links for a guild
+func CountGuildInviteLinks(ctx context.Context, guildID string) (int, error) {
+	var count int
+	err := Pool.QueryRow(ctx, `SELECT count(*) FROM invite_links WHERE guild_id = $1`, guildID).Scan(&count)
+	return count, err
+}
+
 // DeleteInviteLink deletes an invite link
 func DeleteInviteLink(ctx context.Context, id string) error {
 	query := `DELETE FROM invite_links WHERE id = $1`
@@ -662,6 +1275,52 @@ func UpdateStreamerCustomContent(ctx context.Context, guildID, streamerID, conte
 	return err
 }
 
+// GetStreamerCategoryFilter returns the categories (game names) a guild wants
+// notifications for from a streamer. Empty means every category.
+func GetStreamerCategoryFilter(ctx context.Context, guildID, streamerID string) ([]string, error) {
+	query := `SELECT COALESCE(category_filter, '{}') FROM guild_streamers WHERE guild_id = $1 AND streamer_id = $2`
+	var categories []string
+	err := Pool.QueryRow(ctx, query, guildID, streamerID).Scan(&categories)
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// UpdateStreamerCategoryFilter sets the category filter for a streamer in a
+// guild. An empty list clears it. Returns pgx.ErrNoRows if the streamer is
+// not linked to the guild.
+func UpdateStreamerCategoryFilter(ctx context.Context, guildID, streamerID string, categories []string) error {
+	query := `UPDATE guild_streamers SET category_filter = $3 WHERE guild_id = $1 AND streamer_id = $2`
+	var c []string
+	if len(categories) > 0 {
+		c = categories
+	}
+	tag, err := Pool.Exec(ctx, query, guildID, streamerID, c)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// SetGuildStreamerEnabled pauses or resumes notifications for a streamer in
+// a guild, keeping its custom content and filter. Returns pgx.ErrNoRows if
+// the streamer is not linked to the guild.
+func SetGuildStreamerEnabled(ctx context.Context, guildID, streamerID string, enabled bool) error {
+	query := `UPDATE guild_streamers SET enabled = $3 WHERE guild_id = $1 AND streamer_id = $2`
+	tag, err := Pool.Exec(ctx, query, guildID, streamerID, enabled)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
 // GetGuildStreamerAddedBy retrieves who added a streamer to a guild
 func GetGuildStreamerAddedBy(ctx context.Context, guildID, streamerID string) (string, error) {
 	query := `SELECT COALESCE(added_by, '') FROM guild_streamers WHERE guild_id = $1 AND streamer_id = $2`
@@ -673,17 +1332,30 @@ func GetGuildStreamerAddedBy(ctx context.Context, guildID, streamerID string) (s
 	return addedBy, nil
 }
 
-// GetGuildStreamersWithContent retrieves all streamers for a guild including custom content and added_by
-func GetGuildStreamersWithContent(ctx context.Context, guildID string) ([]map[string]interface{}, error) {
+// GetGuildStreamersWithContent retrieves streamers for a guild including custom content, added_by,
+// whether notifications are enabled and the stored status of the streamer's stream.online
+// subscription ("missing" if there is none), ordered by display name. last_notified_at is the
+// latest posted notification, ignoring skipped or unposted claims. A limit of 0 or less
+// returns all streamers from offset on.
+func GetGuildStreamersWithContent(ctx context.Context, guildID string, limit, offset int) ([]map[string]interface{}, error) {
 	query := `
 		SELECT s.id, s.twitch_broadcaster_id, s.twitch_login, s.twitch_display_name, s.twitch_avatar_url,
-		       s.created_at, s.last_updated, COALESCE(gs.custom_content, '') as custom_content, COALESCE(gs.added_by, '') as added_by
+		       s.created_at, s.last_updated, COALESCE(gs.custom_content, '') as custom_content, COALESCE(gs.added_by, '') as added_by,
+		       COALESCE(gs.enabled, true), s.needs_reauth,
+		       (SELECT max(nl.sent_at) FROM notification_log nl
+		        WHERE nl.guild_id = gs.guild_id AND nl.streamer_id = s.id
+		          AND nl.discord_message_id IS NOT NULL) as last_notified_at,
+		       COALESCE((SELECT es.status FROM eventsub_subscriptions es
+		                 WHERE es.streamer_id = s.id AND es.subscription_type = $4
+		                 ORDER BY es.status = 'enabled' DESC, es.last_verified DESC
+		                 LIMIT 1), 'missing') as subscription_status
 		FROM streamers s
 		JOIN guild_streamers gs ON s.id = gs.streamer_id
 		WHERE gs.guild_id = $1
-		ORDER BY s.twitch_display_name
+		ORDER BY s.twitch_display_name, s.id
+		LIMIT $2 OFFSET $3
 	`
-	rows, err := Pool.Query(ctx, query, guildID)
+	rows, err := Pool.Query(ctx, query, guildID, nullableLimit(limit), offset, EventTypeStreamOnline)
 	if err != nil {
 		return nil, err
 	}
@@ -692,9 +1364,11 @@ func GetGuildStreamersWithContent(ctx context.Context, guildID string) ([]map[st
 	var results []map[string]interface{}
 	for rows.Next() {
 		var s Streamer
-		var customContent, addedBy string
+		var customContent, addedBy, subscriptionStatus string
+		var enabled, needsReauth bool
+		var lastNotifiedAt *time.Time
 		err := rows.Scan(&s.ID, &s.TwitchBroadcasterID, &s.TwitchLogin, &s.TwitchDisplayName,
-			&s.TwitchAvatarURL, &s.CreatedAt, &s.LastUpdated, &customContent, &addedBy)
+			&s.TwitchAvatarURL, &s.CreatedAt, &s.LastUpdated, &customContent, &addedBy, &enabled, &needsReauth, &lastNotifiedAt, &subscriptionStatus)
 		if err != nil {
 			return nil, err
 		}
@@ -706,13 +1380,33 @@ func GetGuildStreamersWithContent(ctx context.Context, guildID string) ([]map[st
 			"twitch_avatar_url":     s.TwitchAvatarURL,
 			"custom_content":        customContent,
 			"added_by":              addedBy,
+			"enabled":               enabled,
+			"needs_reauth":          needsReauth,
+			"last_notified_at":      lastNotifiedAt,
+			"subscription_status":   subscriptionStatus,
 		})
 	}
 	return results, rows.Err()
 }
 
+// CountGuildStreamers returns the number of streamers linked to a guild
+func CountGuildStreamers(ctx context.Context, guildID string) (int, error) {
+	var count int
+	err := Pool.QueryRow(ctx, `SELECT count(*) FROM guild_streamers WHERE guild_id = $1`, guildID).Scan(&count)
+	return count, err
+}
+
 // Helper functions
 
+// nullableLimit converts a non-positive limit to nil, which Postgres treats
+// as LIMIT ALL
+func nullableLimit(limit int) *int {
+	if limit <= 0 {
+		return nil
+	}
+	return &limit
+}
+
 // nullableString converts empty string to nil for SQL
 func nullableString(s string) *string {
 	if s == "" {
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGetStreamersByIDs(t *testing.T) {
+	stored := map[string][]any{
+		"streamer-a": {"streamer-a", "1001", "alpha", "Alpha", "https://example.com/a.png", "2026-01-02 03:04:05+00", "2026-01-03 03:04:05+00"},
+		"streamer-c": {"streamer-c", "1003", "charlie", "Charlie", "", "2026-01-02 03:04:05+00", "2026-01-02 03:04:05+00"},
+	}
+	f := newFakePostgres(t, func(sql string) fakeResult {
+		res := fakeResult{columns: append(
+			textColumns("id", "twitch_broadcaster_id", "twitch_login", "twitch_display_name", "twitch_avatar_url"),
+			fakeColumn{"created_at", oidTimestamptz}, fakeColumn{"last_updated", oidTimestamptz},
+		)}
+		for id, row := range stored {
+			if strings.Contains(sql, id) {
+				res.rows = append(res.rows, row)
+			}
+		}
+		return res
+	})
+	useFakePool(t, f)
+
+	streamers, err := GetStreamersByIDs(context.Background(), []string{"streamer-a", "streamer-b", "streamer-c"})
+	if err != nil {
+		t.Fatalf("GetStreamersByIDs: %v", err)
+	}
+	if len(streamers) != 2 {
+		t.Fatalf("got %d streamers, want 2: %v", len(streamers), streamers)
+	}
+	if _, ok := streamers["streamer-b"]; ok {
+		t.Error("missing streamer-b is in the map")
+	}
+	a := streamers["streamer-a"]
+	if a == nil || a.TwitchLogin != "alpha" || a.TwitchBroadcasterID != "1001" || a.TwitchAvatarURL != "https://example.com/a.png" {
+		t.Errorf("streamer-a = %+v", a)
+	}
+	if c := streamers["streamer-c"]; c == nil || c.TwitchDisplayName != "Charlie" || c.CreatedAt.IsZero() {
+		t.Errorf("streamer-c = %+v", c)
+	}
+	if got := f.sent(); len(got) != 1 {
+		t.Errorf("sent %d queries, want 1", len(got))
+	}
+}
+
+func TestGetStreamersByIDsEmpty(t *testing.T) {
+	f := newFakePostgres(t, func(sql string) fakeResult {
+		t.Errorf("unexpected query %q", sql)
+		return fakeResult{}
+	})
+	useFakePool(t, f)
+
+	streamers, err := GetStreamersByIDs(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("GetStreamersByIDs: %v", err)
+	}
+	if streamers == nil || len(streamers) != 0 {
+		t.Errorf("streamers = %v, want an empty map", streamers)
+	}
+}
+
+func TestGetGuildConfigCreatesDefault(t *testing.T) {
+	var created bool
+	f := newFakePostgres(t, func(sql string) fakeResult {
+		if strings.Contains(sql, "INSERT INTO guild_config") {
+			created = true
+			return fakeResult{tag: "INSERT 0 1"}
+		}
+		res := fakeResult{columns: []fakeColumn{
+			{"guild_id", oidText}, {"channel_id", oidText}, {"mention_role_id", oidText},
+			{"message_template", oidJSONB}, {"enabled", oidBool}, {"alert_admins_on_failure", oidBool},
+			{"mention_everyone", oidBool}, {"tone", oidText}, {"update_on_channel_change", oidBool},
+			{"delete_on_offline", oidBool}, {"renotify_cooldown_minutes", oidInt4}, {"channel_is_forum", oidBool},
+			{"quiet_hours_start", oidText}, {"quiet_hours_end", oidText}, {"quiet_hours_timezone", oidText},
+			{"quiet_hours_mode", oidText}, {"timezone", oidText}, {"time_format", oidText},
+			{"webhook_url", oidText}, {"updated_at", oidTimestamptz},
+		}}
+		if created {
+			res.rows = [][]any{{
+				"guild-1", "", nil, `{"content":"live"}`, true, true, false, "default", false,
+				false, 0, false, "", "", "UTC", "silent", "UTC", "", "", "2026-01-02 03:04:05+00",
+			}}
+		}
+		return res
+	})
+	useFakePool(t, f)
+
+	config, err := GetGuildConfig(context.Background(), "guild-1")
+	if err != nil {
+		t.Fatalf("GetGuildConfig: %v", err)
+	}
+	if !created {
+		t.Fatal("no default config was created for a missing guild")
+	}
+	if config.GuildID != "guild-1" || !config.Enabled || config.MentionRoleID != "" || string(config.MessageTemplate) != `{"content":"live"}` {
+		t.Errorf("config = %+v", config)
+	}
+	if got := len(f.sent()); got != 3 {
+		t.Errorf("sent %d queries, want 3 (select, insert, select)", got)
+	}
+}
+
+func TestNoRowsResults(t *testing.T) {
+	f := newFakePostgres(t, func(sql string) fakeResult {
+		return fakeResult{columns: textColumns("value")}
+	})
+	useFakePool(t, f)
+	ctx := context.Background()
+
+	if admin, err := IsUserGuildAdmin(ctx, "user-1", "guild-1"); admin || err != nil {
+		t.Errorf("IsUserGuildAdmin = %v, %v; want false, nil", admin, err)
+	}
+	if member, err := IsUserGuildMember(ctx, "user-1", "guild-1"); member || err != nil {
+		t.Errorf("IsUserGuildMember = %v, %v; want false, nil", member, err)
+	}
+	if claimed, err := TryClaimNotification(ctx, "guild-1", "streamer-1", "event-1"); claimed || err != nil {
+		t.Errorf("TryClaimNotification = %v, %v; want false, nil (already claimed)", claimed, err)
+	}
+	if enabled, err := GetUserNotificationsEnabled(ctx, "user-1", "guild-1", "streamer-1"); !enabled || err != nil {
+		t.Errorf("GetUserNotificationsEnabled = %v, %v; want true, nil (no preference)", enabled, err)
+	}
+}
+
+func TestQueryErrorsAreNotNoRows(t *testing.T) {
+	f := newFakePostgres(t, func(sql string) fakeResult {
+		return fakeResult{err: "permission denied for table user_guilds"}
+	})
+	useFakePool(t, f)
+
+	if _, err := IsUserGuildMember(context.Background(), "user-1", "guild-1"); err == nil {
+		t.Error("IsUserGuildMember swallowed a query error")
+	}
+}
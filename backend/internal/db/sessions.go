@@ -2,6 +2,8 @@ package db
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -33,7 +35,7 @@ func (s *SessionDB) IsSessionValid(ctx context.Context, jti string) (bool, error
 
 	var exists int
 	err := Pool.QueryRow(ctx, query, jti).Scan(&exists)
-	if err == pgx.ErrNoRows {
+	if errors.Is(err, pgx.ErrNoRows) {
 		return true, nil // Not revoked = valid
 	}
 	if err != nil {
@@ -42,10 +44,97 @@ func (s *SessionDB) IsSessionValid(ctx context.Context, jti string) (bool, error
 	return false, nil // Found in revoked table = invalid
 }
 
-// CleanupExpiredSessions removes revoked sessions that have expired.
+// CleanupExpiredSessions removes revoked sessions that have expired and
+// returns how many were removed.
 // Should be called periodically (e.g., daily) to keep the table small.
-func (s *SessionDB) CleanupExpiredSessions(ctx context.Context) error {
+func (s *SessionDB) CleanupExpiredSessions(ctx context.Context) (int64, error) {
 	query := `DELETE FROM revoked_sessions WHERE expires_at < now()`
-	_, err := Pool.Exec(ctx, query)
+	result, err := Pool.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// ServiceNonceDB records used service token nonces in the database, shared
+// by every instance. This implements the auth.NonceStore interface.
+type ServiceNonceDB struct{}
+
+// NewServiceNonceDB creates a new service token nonce accessor.
+func NewServiceNonceDB() *ServiceNonceDB {
+	return &ServiceNonceDB{}
+}
+
+// UseNonce records a nonce until expiresAt, reporting false if it was
+// already recorded.
+func (s *ServiceNonceDB) UseNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO service_token_nonces (nonce, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (nonce) DO NOTHING
+	`
+	result, err := Pool.Exec(ctx, query, nonce, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// CleanupExpiredServiceNonces removes nonces of expired service tokens and
+// returns how many were removed.
+func CleanupExpiredServiceNonces(ctx context.Context) (int64, error) {
+	result, err := Pool.Exec(ctx, `DELETE FROM service_token_nonces WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// RecordUserSession records a session issued to a user, for listing.
+func RecordUserSession(ctx context.Context, session *UserSession) error {
+	query := `
+		INSERT INTO user_sessions (jti, user_id, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	_, err := Pool.Exec(ctx, query, session.JTI, session.UserID, session.UserAgent, session.IPAddress, session.ExpiresAt)
 	return err
 }
+
+// GetActiveUserSessions returns a user's sessions that have neither expired
+// nor been revoked, newest first.
+func GetActiveUserSessions(ctx context.Context, userID string) ([]UserSession, error) {
+	query := `
+		SELECT s.jti, s.user_id, COALESCE(s.user_agent, ''), COALESCE(s.ip_address, ''), s.created_at, s.expires_at
+		FROM user_sessions s
+		WHERE s.user_id = $1
+		  AND s.expires_at > now()
+		  AND NOT EXISTS (SELECT 1 FROM revoked_sessions r WHERE r.jti = s.jti)
+		ORDER BY s.created_at DESC
+	`
+	rows, err := Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []UserSession
+	for rows.Next() {
+		var us UserSession
+		if err := rows.Scan(&us.JTI, &us.UserID, &us.UserAgent, &us.IPAddress, &us.CreatedAt, &us.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, us)
+	}
+	return sessions, rows.Err()
+}
+
+// CleanupExpiredUserSessions deletes issued-session rows past their expiry.
+func CleanupExpiredUserSessions(ctx context.Context) (int64, error) {
+	query := `DELETE FROM user_sessions WHERE expires_at < now()`
+	result, err := Pool.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
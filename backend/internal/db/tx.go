@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// querier is what queries need from a connection; both Pool and pgx.Tx
+// provide it, so a query written against it serves the plain function and
+// its ...Tx variant.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// WithTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. Statements in fn go through tx (the ...Tx query variants),
+// not Pool. Unlike Pool calls, nothing is retried on a stale connection.
+func WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	if Pool == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	tx, err := Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after Commit
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
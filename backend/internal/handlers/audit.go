@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// auditChanges returns the JSON fields that differ between before and
+// after as {"field": {"before": ..., "after": ...}}, for audit details.
+// Fields in skip are ignored.
+func auditChanges(before, after interface{}, skip ...string) map[string]interface{} {
+	b, a := auditFields(before), auditFields(after)
+	for _, field := range skip {
+		delete(b, field)
+		delete(a, field)
+	}
+
+	changes := make(map[string]interface{})
+	for field, av := range a {
+		if bv, ok := b[field]; !ok || !reflect.DeepEqual(bv, av) {
+			changes[field] = map[string]interface{}{"before": b[field], "after": av}
+		}
+	}
+	for field, bv := range b {
+		if _, ok := a[field]; !ok {
+			changes[field] = map[string]interface{}{"before": bv, "after": nil}
+		}
+	}
+	return changes
+}
+
+// auditFields flattens v to its top-level JSON fields.
+func auditFields(v interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
@@ -4,8 +4,10 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/yourusername/streammaxing/internal/db"
 	"github.com/yourusername/streammaxing/internal/middleware"
@@ -146,7 +148,7 @@ func (h *AuthHandler) DiscordCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenResp, err := h.oauth.ExchangeCode(code)
+	tokenResp, err := h.oauth.ExchangeCode(ctx, code)
 	if err != nil {
 		log.Printf("[AUTH_ERROR] Failed to exchange code: %v", err)
 		http.Error(w, "Failed to exchange authorization code", http.StatusInternalServerError)
@@ -155,7 +157,7 @@ func (h *AuthHandler) DiscordCallback(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[AUTH_DEBUG] Token exchange successful, scopes: %s", tokenResp.Scope)
 
 	// Fetch user info
-	user, err := h.oauth.GetUser(tokenResp.AccessToken)
+	user, err := h.oauth.GetUser(ctx, tokenResp.AccessToken)
 	if err != nil {
 		log.Printf("[AUTH_ERROR] Failed to fetch user: %v", err)
 		http.Error(w, "Failed to fetch user information", http.StatusInternalServerError)
@@ -165,7 +167,7 @@ func (h *AuthHandler) DiscordCallback(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch user guilds
 	log.Printf("[AUTH_DEBUG] Attempting to fetch guilds with access token...")
-	guilds, err := h.oauth.GetUserGuilds(tokenResp.AccessToken)
+	guilds, err := h.oauth.GetUserGuilds(ctx, tokenResp.AccessToken)
 	if err != nil {
 		log.Printf("[AUTH_ERROR] Failed to fetch guilds: %v", err)
 		http.Error(w, "Failed to fetch guilds", http.StatusInternalServerError)
@@ -231,6 +233,8 @@ func (h *AuthHandler) DiscordCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recordSession(r, user.ID, jti, auth.SessionExpiry(time.Now()))
+
 	// Set hardened session cookie (24h, Strict SameSite)
 	setSessionCookie(w, jwtToken)
 
@@ -270,7 +274,7 @@ func (h *AuthHandler) DiscordExchange(w http.ResponseWriter, r *http.Request) {
 	// Exchange authorization code for access token.
 	// We must use the same redirect_uri that the frontend used in the authorize
 	// request, otherwise Discord rejects the exchange.
-	tokenResp, err := h.oauth.ExchangeCodeWithURI(body.Code, body.RedirectURI)
+	tokenResp, err := h.oauth.ExchangeCodeWithURI(ctx, body.Code, body.RedirectURI)
 	if err != nil {
 		log.Printf("[AUTH_ERROR] Failed to exchange code: %v", err)
 		http.Error(w, "Failed to exchange authorization code", http.StatusInternalServerError)
@@ -281,7 +285,7 @@ func (h *AuthHandler) DiscordExchange(w http.ResponseWriter, r *http.Request) {
 	// From here the logic is identical to DiscordCallback: fetch user/guilds,
 	// upsert DB rows, create JWT, set session cookie.
 
-	user, err := h.oauth.GetUser(tokenResp.AccessToken)
+	user, err := h.oauth.GetUser(ctx, tokenResp.AccessToken)
 	if err != nil {
 		log.Printf("[AUTH_ERROR] Failed to fetch user: %v", err)
 		http.Error(w, "Failed to fetch user information", http.StatusInternalServerError)
@@ -289,7 +293,7 @@ func (h *AuthHandler) DiscordExchange(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("[AUTH_DEBUG] User fetched: %s (%s)", user.Username, user.ID)
 
-	guilds, err := h.oauth.GetUserGuilds(tokenResp.AccessToken)
+	guilds, err := h.oauth.GetUserGuilds(ctx, tokenResp.AccessToken)
 	if err != nil {
 		log.Printf("[AUTH_ERROR] Failed to fetch guilds: %v", err)
 		http.Error(w, "Failed to fetch guilds", http.StatusInternalServerError)
@@ -346,6 +350,7 @@ func (h *AuthHandler) DiscordExchange(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recordSession(r, user.ID, jti, auth.SessionExpiry(time.Now()))
 	setSessionCookie(w, jwtToken)
 
 	log.Printf("[AUTH] User %s (%s) logged in via frontend flow (jti: %s)", user.Username, user.ID, jti)
@@ -359,6 +364,128 @@ func (h *AuthHandler) DiscordExchange(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// maxSessionUserAgentLength caps the User-Agent stored per session, in
+// characters
+const maxSessionUserAgentLength = 256
+
+// recordSession stores a newly issued session so the user can list and
+// revoke it. A failure only costs the listing, so it is logged, not returned.
+func (h *AuthHandler) recordSession(r *http.Request, userID, jti string, expiresAt time.Time) {
+	userAgent := r.UserAgent()
+	if ua := []rune(userAgent); len(ua) > maxSessionUserAgentLength {
+		userAgent = string(ua[:maxSessionUserAgentLength])
+	}
+	err := db.RecordUserSession(r.Context(), &db.UserSession{
+		JTI:       jti,
+		UserID:    userID,
+		UserAgent: userAgent,
+		IPAddress: r.RemoteAddr,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Printf("[AUTH_WARN] Failed to record session for %s: %v", userID, err)
+	}
+}
+
+// RefreshSession swaps the caller's still-valid session for a new token and
+// JTI, revoking the old one. Renewal stops auth.MaxSessionLifetime after the
+// original login, after which the user has to log in with Discord again.
+func (h *AuthHandler) RefreshSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims := middleware.GetClaims(r)
+	if claims == nil || claims.JTI == "" {
+		http.Error(w, "Unauthorized: session cannot be renewed", http.StatusUnauthorized)
+		return
+	}
+
+	jwtToken, jti, err := h.sessionService.RenewSession(ctx, claims)
+	if errors.Is(err, auth.ErrSessionRenewalExpired) {
+		http.Error(w, "Unauthorized: session expired, please log in again", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("[AUTH_ERROR] Failed to renew session for %s: %v", claims.UserID, err)
+		http.Error(w, "Failed to renew session", http.StatusInternalServerError)
+		return
+	}
+
+	h.securityLogger.LogSessionRevoked(ctx, claims.UserID, claims.JTI)
+	expiresAt := auth.SessionExpiry(claims.LoginTime())
+	h.recordSession(r, claims.UserID, jti, expiresAt)
+	setSessionCookie(w, jwtToken)
+
+	log.Printf("[AUTH] User %s renewed session (jti: %s -> %s)", claims.UserID, claims.JTI, jti)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Session renewed",
+		"expires_at": expiresAt,
+	})
+}
+
+// ListSessions returns the caller's active sessions, marking the one the
+// request was made with
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	currentJTI := middleware.GetJTI(r)
+
+	sessions, err := db.GetActiveUserSessions(r.Context(), userID)
+	if err != nil {
+		log.Printf("[AUTH_ERROR] Failed to list sessions for %s: %v", userID, err)
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+	if sessions == nil {
+		sessions = []db.UserSession{}
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].JTI == currentJTI
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeOtherSessions revokes every active session of the caller except
+// the one the request was made with, e.g. to cut off a stolen token
+func (h *AuthHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := middleware.GetUserID(r)
+	currentJTI := middleware.GetJTI(r)
+
+	sessions, err := db.GetActiveUserSessions(ctx, userID)
+	if err != nil {
+		log.Printf("[AUTH_ERROR] Failed to list sessions for %s: %v", userID, err)
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if session.JTI == currentJTI {
+			continue
+		}
+		if err := h.sessionService.RevokeSession(ctx, session.JTI); err != nil {
+			log.Printf("[AUTH_ERROR] Failed to revoke session for %s: %v", userID, err)
+			http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+		h.securityLogger.LogSessionRevoked(ctx, userID, session.JTI)
+		revoked++
+	}
+
+	log.Printf("[AUTH] User %s revoked %d other session(s)", userID, revoked)
+	db.InsertAuditLog(ctx, userID, "revoke_other_sessions", "user", userID, map[string]interface{}{
+		"revoked": revoked,
+	}, r.RemoteAddr, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Other sessions revoked",
+		"revoked": revoked,
+	})
+}
+
 // Logout clears the session cookie and revokes the session server-side
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
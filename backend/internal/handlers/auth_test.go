@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/yourusername/streammaxing/internal/services/auth"
+	"github.com/yourusername/streammaxing/internal/services/authorization"
+	"github.com/yourusername/streammaxing/internal/services/discord"
+	"github.com/yourusername/streammaxing/internal/services/logging"
+)
+
+func TestNewAuthHandler(t *testing.T) {
+	oauth := discord.NewOAuthService("client-id", "client-secret", "https://example.com/callback")
+	sessions := auth.NewSessionService(nil, nil)
+	guildAuth := authorization.NewGuildAuthService()
+	securityLogger := logging.NewSecurityLogger()
+
+	h := NewAuthHandler(oauth, sessions, guildAuth, securityLogger)
+
+	if h.oauth != oauth {
+		t.Error("oauth is not the injected OAuthService")
+	}
+	if h.sessionService != sessions {
+		t.Error("sessionService is not the injected SessionService")
+	}
+	if h.guildAuth != guildAuth {
+		t.Error("guildAuth is not the injected GuildAuthService")
+	}
+	if h.securityLogger != securityLogger {
+		t.Error("securityLogger is not the injected SecurityLogger")
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yourusername/streammaxing/internal/db"
+	"github.com/yourusername/streammaxing/internal/middleware"
+)
+
+// maxBulkStreamerLogins caps one bulk import. Each new streamer needs its
+// own EventSub subscriptions, so the request has to stay well inside the
+// Lambda timeout.
+const maxBulkStreamerLogins = 25
+
+// Per-login outcomes of a bulk import
+const (
+	bulkStatusLinked        = "linked"
+	bulkStatusAlreadyLinked = "already_linked"
+	bulkStatusFailed        = "failed"
+)
+
+// bulkStreamerResult is one login's entry in the AddStreamersBulk response
+type bulkStreamerResult struct {
+	Login      string `json:"login"`
+	Status     string `json:"status"`
+	StreamerID string `json:"streamer_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// AddStreamersBulk links streamers to a guild by Twitch login, without each
+// streamer going through OAuth. Logins are looked up with the app token and
+// the streamers stored without user tokens, which is enough for the
+// app-token stream.online subscription. Every login gets its own result;
+// one failing doesn't stop the rest.
+func (h *GuildHandler) AddStreamersBulk(w http.ResponseWriter, r *http.Request, guildID string) {
+	// Validate guild ID
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify admin permission
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "bulk_add_streamers")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 16*1024) // 16KB max
+
+	var body struct {
+		Logins []string `json:"logins"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Trim, lowercase (logins are case-insensitive) and drop blanks and duplicates
+	logins := make([]string, 0, len(body.Logins))
+	for _, login := range body.Logins {
+		login = strings.ToLower(strings.TrimSpace(login))
+		if login == "" || slices.Contains(logins, login) {
+			continue
+		}
+		logins = append(logins, login)
+	}
+	if len(logins) == 0 {
+		http.Error(w, "No Twitch logins given", http.StatusBadRequest)
+		return
+	}
+	if len(logins) > maxBulkStreamerLogins {
+		http.Error(w, fmt.Sprintf("Too many logins (max %d)", maxBulkStreamerLogins), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]bulkStreamerResult, len(logins))
+	var valid []string
+	for i, login := range logins {
+		results[i] = bulkStreamerResult{Login: login}
+		if err := h.validator.ValidateTwitchLogin(login); err != nil {
+			results[i].Status = bulkStatusFailed
+			results[i].Error = "invalid Twitch login"
+			continue
+		}
+		valid = append(valid, login)
+	}
+
+	users, err := h.twitchAPI.GetUsersByLogin(r.Context(), valid)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to look up Twitch users for guild %s: %v", guildID, err)
+		http.Error(w, "Failed to look up Twitch users", http.StatusBadGateway)
+		return
+	}
+
+	var linked, failed []string
+	for i := range results {
+		result := &results[i]
+		if result.Status == bulkStatusFailed {
+			failed = append(failed, result.Login)
+			continue
+		}
+		user, ok := users[result.Login]
+		if !ok {
+			result.Status = bulkStatusFailed
+			result.Error = "Twitch user not found"
+			failed = append(failed, result.Login)
+			continue
+		}
+
+		streamer := &db.Streamer{
+			TwitchBroadcasterID: user.ID,
+			TwitchLogin:         user.Login,
+			TwitchDisplayName:   user.DisplayName,
+			TwitchAvatarURL:     user.ProfileImageURL,
+		}
+		var isNew bool
+		err := db.WithTx(r.Context(), func(tx pgx.Tx) error {
+			if err := db.EnsureStreamerTx(r.Context(), tx, streamer); err != nil {
+				return fmt.Errorf("store streamer: %w", err)
+			}
+			created, err := db.LinkStreamerToGuildTx(r.Context(), tx, guildID, streamer.ID, userID)
+			if err != nil {
+				return fmt.Errorf("link streamer to guild: %w", err)
+			}
+			isNew = created
+			return nil
+		})
+		if err != nil {
+			log.Printf("[GUILD_ERROR] Failed to link streamer %s to guild %s: %v", user.Login, guildID, err)
+			result.Status = bulkStatusFailed
+			result.Error = "failed to link streamer"
+			failed = append(failed, result.Login)
+			continue
+		}
+
+		result.StreamerID = streamer.ID
+		if !isNew {
+			result.Status = bulkStatusAlreadyLinked
+			continue
+		}
+		setUpSubscriptions(r.Context(), h.eventsub, streamer.ID, user)
+		result.Status = bulkStatusLinked
+		linked = append(linked, result.Login)
+	}
+
+	log.Printf("[GUILD] Bulk added streamers to guild %s by user %s: linked=%d failed=%d of %d",
+		guildID, userID, len(linked), len(failed), len(logins))
+	db.InsertAuditLog(r.Context(), userID, "bulk_add_streamers", "guild", guildID, map[string]interface{}{
+		"guild_id": guildID,
+		"linked":   linked,
+		"failed":   failed,
+	}, r.RemoteAddr, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
@@ -5,25 +5,40 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/yourusername/streammaxing/internal/db"
 	"github.com/yourusername/streammaxing/internal/services/twitch"
 )
 
+// DefaultAuditLogRetention is how long audit entries are kept when no
+// retention is configured
+const DefaultAuditLogRetention = 90 * 24 * time.Hour
+
 // CleanupHandler handles database and subscription cleanup
 type CleanupHandler struct {
-	eventsubService *twitch.EventSubService
+	eventsubService   *twitch.EventSubService
+	sessions          *db.SessionDB
+	auditLogRetention time.Duration
 }
 
 // NewCleanupHandler creates a new cleanup handler.
 // The EventSub service is injected from the centralized config.
-func NewCleanupHandler(eventsubService *twitch.EventSubService) *CleanupHandler {
+// auditLogRetention <= 0 uses DefaultAuditLogRetention.
+func NewCleanupHandler(eventsubService *twitch.EventSubService, auditLogRetention time.Duration) *CleanupHandler {
+	if auditLogRetention <= 0 {
+		auditLogRetention = DefaultAuditLogRetention
+	}
 	return &CleanupHandler{
-		eventsubService: eventsubService,
+		eventsubService:   eventsubService,
+		sessions:          db.NewSessionDB(),
+		auditLogRetention: auditLogRetention,
 	}
 }
 
-// RunCleanup runs all cleanup tasks (triggered manually or via cron)
+// RunCleanup runs all cleanup tasks (triggered manually or via cron) and
+// returns per-task results
 func (h *CleanupHandler) RunCleanup(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	results := make(map[string]interface{})
@@ -55,7 +70,53 @@ func (h *CleanupHandler) RunCleanup(w http.ResponseWriter, r *http.Request) {
 		results["subscription_sync"] = map[string]interface{}{"checked": syncCount}
 	}
 
-	log.Printf("[CLEANUP] Completed: orphans=%d, logs=%d, subs=%d", orphanedCount, logCount, syncCount)
+	// 4. Reconcile subscriptions with the event types guilds need
+	reconciled, err := h.reconcileSubscriptions(ctx)
+	if err != nil {
+		log.Printf("[CLEANUP_ERROR] Subscription reconcile: %v", err)
+		results["subscription_reconcile"] = map[string]interface{}{"error": err.Error()}
+	} else {
+		results["subscription_reconcile"] = map[string]interface{}{"streamers": reconciled}
+	}
+
+	// 5. Prune expired session records
+	sessionCount, err := db.CleanupExpiredUserSessions(ctx)
+	if err != nil {
+		log.Printf("[CLEANUP_ERROR] User sessions: %v", err)
+		results["user_sessions"] = map[string]interface{}{"error": err.Error()}
+	} else {
+		results["user_sessions"] = map[string]interface{}{"deleted": sessionCount}
+	}
+
+	// 6. Prune expired revocations (revoked tokens past their expiry)
+	revokedCount, err := h.sessions.CleanupExpiredSessions(ctx)
+	if err != nil {
+		log.Printf("[CLEANUP_ERROR] Revoked sessions: %v", err)
+		results["revoked_sessions"] = map[string]interface{}{"error": err.Error()}
+	} else {
+		results["revoked_sessions"] = map[string]interface{}{"deleted": revokedCount}
+	}
+
+	// 7. Prune audit entries past the retention window
+	auditCount, err := db.CleanupOldAuditLogs(ctx, h.auditLogRetention)
+	if err != nil {
+		log.Printf("[CLEANUP_ERROR] Audit logs: %v", err)
+		results["audit_logs"] = map[string]interface{}{"error": err.Error()}
+	} else {
+		results["audit_logs"] = map[string]interface{}{"deleted": auditCount}
+	}
+
+	// 8. Prune nonces of expired service tokens
+	nonceCount, err := db.CleanupExpiredServiceNonces(ctx)
+	if err != nil {
+		log.Printf("[CLEANUP_ERROR] Service token nonces: %v", err)
+		results["service_token_nonces"] = map[string]interface{}{"error": err.Error()}
+	} else {
+		results["service_token_nonces"] = map[string]interface{}{"deleted": nonceCount}
+	}
+
+	log.Printf("[CLEANUP] Completed: orphans=%d, logs=%d, subs=%d, reconciled=%d, sessions=%d, revoked=%d, audit=%d, nonces=%d",
+		orphanedCount, logCount, syncCount, reconciled, sessionCount, revokedCount, auditCount, nonceCount)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(results)
@@ -70,10 +131,13 @@ func (h *CleanupHandler) cleanupOrphanedStreamers(ctx context.Context) (int, err
 
 	deleted := 0
 	for _, streamerID := range orphanedIDs {
-		// Delete EventSub subscription if exists
-		sub, err := db.GetEventSubSubscription(ctx, streamerID)
-		if err == nil && sub != nil {
-			if delErr := h.eventsubService.DeleteSubscription(sub.SubscriptionID); delErr != nil {
+		// Delete EventSub subscriptions if any exist
+		subs, err := db.GetEventSubSubscriptions(ctx, streamerID)
+		if err != nil {
+			log.Printf("[CLEANUP_WARN] Failed to fetch EventSub subs for streamer %s: %v", streamerID, err)
+		}
+		for _, sub := range subs {
+			if delErr := h.eventsubService.DeleteSubscription(ctx, sub.SubscriptionID); delErr != nil {
 				log.Printf("[CLEANUP_WARN] Failed to delete EventSub sub %s: %v", sub.SubscriptionID, delErr)
 			} else {
 				db.DeleteEventSubSubscription(ctx, sub.SubscriptionID)
@@ -94,7 +158,7 @@ func (h *CleanupHandler) cleanupOrphanedStreamers(ctx context.Context) (int, err
 
 // syncSubscriptionHealth checks EventSub subscriptions against Twitch API
 func (h *CleanupHandler) syncSubscriptionHealth(ctx context.Context) (int, error) {
-	subs, err := h.eventsubService.ListSubscriptions()
+	subs, err := h.eventsubService.ListSubscriptions(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -111,21 +175,63 @@ func (h *CleanupHandler) syncSubscriptionHealth(ctx context.Context) (int, error
 		if err != nil {
 			// Streamer not in our DB - this subscription is orphaned at Twitch
 			log.Printf("[CLEANUP] Orphaned Twitch sub %s for broadcaster %s", sub.ID, broadcasterID)
-			h.eventsubService.DeleteSubscription(sub.ID)
+			h.eventsubService.DeleteSubscription(ctx, sub.ID)
 			continue
 		}
 
-		db.CreateEventSubSubscription(ctx, streamer.ID, sub.ID, sub.Status)
+		db.CreateEventSubSubscription(ctx, streamer.ID, sub.ID, sub.Type, sub.Status)
 		checked++
 	}
 
 	return checked, nil
 }
 
+// reconcileTimeout bounds the subscription reconcile step. The cleanup run
+// is one HTTP request under API Gateway's 29s limit and the other steps
+// need time too; streamers not reached are reconciled on the next run.
+const reconcileTimeout = 15 * time.Second
+
+// reconcileSubscriptions brings every linked streamer's EventSub
+// subscriptions in line with what their guilds' configs need
+func (h *CleanupHandler) reconcileSubscriptions(ctx context.Context) (int, error) {
+	streamers, err := db.GetLinkedStreamers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	var reconciled atomic.Int64
+	forEachConcurrently(len(streamers), twitchCallConcurrency, func(i int) {
+		s := &streamers[i]
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := syncStreamerSubscriptions(ctx, h.eventsubService, s.ID, s.TwitchBroadcasterID); err != nil {
+			log.Printf("[CLEANUP_WARN] Failed to reconcile subscriptions for %s: %v", s.TwitchLogin, err)
+			return
+		}
+		reconciled.Add(1)
+	})
+	if ctx.Err() != nil {
+		log.Printf("[CLEANUP_WARN] Subscription reconcile stopped after %v: %d/%d streamers reconciled",
+			reconcileTimeout, reconciled.Load(), len(streamers))
+	}
+
+	return int(reconciled.Load()), nil
+}
+
 // HandleBotRemoved handles cleanup when the bot is removed from a guild
 func (h *CleanupHandler) HandleBotRemoved(ctx context.Context, guildID string) error {
 	log.Printf("[CLEANUP] Bot removed from guild: %s", guildID)
 
+	if impact, err := db.GetGuildDeletionImpact(ctx, guildID); err != nil {
+		log.Printf("[CLEANUP_WARN] Failed to count data for guild %s: %v", guildID, err)
+	} else {
+		log.Printf("[CLEANUP] Removing guild %s: %+v", guildID, *impact)
+	}
+
 	// Delete guild (CASCADE handles guild_config, guild_streamers, user_preferences, notification_log)
 	if err := db.DeleteGuild(ctx, guildID); err != nil {
 		return err
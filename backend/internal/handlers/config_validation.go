@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/yourusername/streammaxing/internal/db"
+	"github.com/yourusername/streammaxing/internal/services/discord"
+	"github.com/yourusername/streammaxing/internal/services/notifications"
+)
+
+// configValidation is the outcome of validating a guild config change.
+// Errors block saving; warnings flag settings that are allowed but likely
+// won't work as intended.
+type configValidation struct {
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+	// SizeEstimate is the template's worst-case rendered size, when the
+	// template parses
+	SizeEstimate *notifications.SizeEstimate `json:"size_estimate,omitempty"`
+}
+
+// maxRenotifyCooldownMinutes caps GuildConfig.RenotifyCooldownMinutes.
+// Longer would start swallowing genuinely new streams.
+const maxRenotifyCooldownMinutes = 24 * 60
+
+func (v *configValidation) fail(format string, args ...interface{}) {
+	v.Errors = append(v.Errors, fmt.Sprintf(format, args...))
+}
+
+func (v *configValidation) warn(format string, args ...interface{}) {
+	v.Warnings = append(v.Warnings, fmt.Sprintf(format, args...))
+}
+
+// validateGuildConfig runs every check applied before saving a guild config
+// and fills in defaults (tone, quiet hours timezone and mode, timezone) on
+// config.
+// current is the stored config, for checks that depend on what changed.
+// UpdateGuildConfig uses it both to save and for ?validate_only=true.
+func (h *GuildHandler) validateGuildConfig(ctx context.Context, config, current *db.GuildConfig) configValidation {
+	v := configValidation{Errors: []string{}, Warnings: []string{}}
+	guildID := config.GuildID
+
+	// Channel: format, then that it is a text or forum channel in this
+	// guild. Whether it is a forum comes from the channel itself; the
+	// submitted flag only stands if the channel can't be looked up.
+	if err := h.validator.ValidateChannelID(config.ChannelID); err != nil {
+		v.fail("Invalid channel ID")
+	} else if config.ChannelID == "" {
+		config.ChannelIsForum = false
+	} else {
+		channels, err := h.discordAPI.GetGuildChannels(ctx, guildID)
+		if err != nil {
+			log.Printf("[GUILD_WARN] Failed to fetch channels for %s: %v", guildID, err)
+			v.warn("Could not verify the notification channel")
+		} else if i := slices.IndexFunc(channels, func(c discord.Channel) bool {
+			return c.ID == config.ChannelID && (c.IsText() || c.IsForum())
+		}); i < 0 {
+			v.fail("Notification channel not found in this server")
+		} else {
+			config.ChannelIsForum = channels[i].IsForum()
+		}
+	}
+
+	// Mention role: format, existence, and whether it will actually ping
+	if err := h.validator.ValidateRoleID(config.MentionRoleID); err != nil {
+		v.fail("Invalid mention role ID")
+	} else if config.MentionRoleID != "" {
+		h.validateMentionRole(ctx, guildID, config.MentionRoleID, &v)
+	}
+
+	// Message template
+	if config.MessageTemplate != nil {
+		if err := h.validator.ValidateTemplateContent(string(config.MessageTemplate)); err != nil {
+			v.fail("Invalid template content")
+		} else if err := h.validator.ValidateEmbedTemplate(config.MessageTemplate); err != nil {
+			v.fail("Invalid template: %v", err)
+		} else if err := h.validator.ValidateTemplateVariables(string(config.MessageTemplate)); err != nil {
+			v.fail("Invalid template: %v", err)
+		}
+
+		var tmpl db.MessageTemplate
+		if json.Unmarshal(config.MessageTemplate, &tmpl) == nil {
+			v.SizeEstimate = h.templateSvc.EstimateSize(&tmpl)
+			v.Warnings = append(v.Warnings, v.SizeEstimate.Warnings...)
+		}
+	}
+
+	// Tone preset
+	if config.Tone == "" {
+		config.Tone = db.ToneDefault
+	}
+	if !db.IsValidTone(config.Tone) {
+		v.fail("Invalid tone")
+	}
+
+	// Quiet hours
+	quiet, err := notifications.ParseQuietHours(config.QuietHoursStart, config.QuietHoursEnd,
+		config.QuietHoursTimezone, config.QuietHoursMode)
+	if err != nil {
+		v.fail("Invalid quiet hours: %v", err)
+	} else {
+		config.QuietHoursTimezone = "UTC"
+		config.QuietHoursMode = notifications.QuietHoursSilent
+		if quiet != nil {
+			config.QuietHoursTimezone = quiet.Timezone()
+			config.QuietHoursMode = quiet.Mode
+		}
+	}
+
+	// Template timestamps
+	if timezone, err := notifications.ValidateTimeSettings(config.Timezone, config.TimeFormat); err != nil {
+		v.fail("Invalid time settings: %v", err)
+	} else {
+		config.Timezone = timezone
+	}
+
+	// Re-notify cooldown: up to a day
+	if config.RenotifyCooldownMinutes < 0 || config.RenotifyCooldownMinutes > maxRenotifyCooldownMinutes {
+		v.fail("Re-notify cooldown must be between 0 and %d minutes", maxRenotifyCooldownMinutes)
+	}
+
+	// @everyone only pings if the bot holds Mention Everyone; refuse to turn
+	// it on when it would silently do nothing
+	if config.MentionEveryone && !current.MentionEveryone {
+		canMention, err := h.discordAPI.BotHasPermission(ctx, guildID, discord.PermissionMentionEveryone)
+		if err != nil {
+			log.Printf("[GUILD_ERROR] Failed to check bot permissions in %s: %v", guildID, err)
+			v.fail("Could not verify the bot's Mention @everyone permission, please try again")
+		} else if !canMention {
+			v.fail("The bot needs the Mention @everyone permission in this server")
+		}
+	}
+
+	v.Valid = len(v.Errors) == 0
+	return v
+}
+
+// validateMentionRole checks that the mention role exists and can be pinged
+// by the bot.
+func (h *GuildHandler) validateMentionRole(ctx context.Context, guildID, roleID string, v *configValidation) {
+	roles, err := h.discordAPI.GetGuildRoles(ctx, guildID)
+	if err != nil {
+		log.Printf("[GUILD_WARN] Failed to fetch roles for %s: %v", guildID, err)
+		v.warn("Could not verify the mention role")
+		return
+	}
+
+	i := slices.IndexFunc(roles, func(role discord.Role) bool { return role.ID == roleID })
+	if i < 0 {
+		v.fail("Mention role not found in this server")
+		return
+	}
+	if roles[i].Mentionable {
+		return
+	}
+
+	// Non-mentionable roles only ping if the bot has Mention Everyone
+	canMention, err := h.discordAPI.BotHasPermission(ctx, guildID, discord.PermissionMentionEveryone)
+	if err != nil {
+		log.Printf("[GUILD_WARN] Failed to check bot permissions in %s: %v", guildID, err)
+		v.warn("Could not verify that the bot can mention @%s", roles[i].Name)
+	} else if !canMention {
+		v.warn("@%s is not mentionable, so notifications won't ping it", roles[i].Name)
+	}
+}
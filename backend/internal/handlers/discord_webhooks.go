@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yourusername/streammaxing/internal/db"
+	"github.com/yourusername/streammaxing/internal/services/discord"
+	"github.com/yourusername/streammaxing/internal/services/logging"
+	"github.com/yourusername/streammaxing/internal/validation"
+)
+
+// DiscordWebhookHandler handles signed requests about Discord events
+type DiscordWebhookHandler struct {
+	guildEventsKey ed25519.PublicKey
+	appPublicKey   ed25519.PublicKey
+	cleanup        *CleanupHandler
+	securityLogger *logging.SecurityLogger
+	validator      *validation.Validator
+}
+
+// NewDiscordWebhookHandler creates a Discord webhook handler. guildEventsKey
+// verifies guild-removed notifications and appPublicKey verifies
+// interactions; either may be nil when its route is not served.
+func NewDiscordWebhookHandler(guildEventsKey, appPublicKey ed25519.PublicKey, cleanup *CleanupHandler, securityLogger *logging.SecurityLogger) *DiscordWebhookHandler {
+	return &DiscordWebhookHandler{
+		guildEventsKey: guildEventsKey,
+		appPublicKey:   appPublicKey,
+		cleanup:        cleanup,
+		securityLogger: securityLogger,
+		validator:      validation.NewValidator(),
+	}
+}
+
+// gatewayDispatch is a Discord Gateway dispatch event (op 0), as forwarded
+// by the process holding the Gateway connection
+type gatewayDispatch struct {
+	Type string          `json:"t"`
+	Data json.RawMessage `json:"d"`
+}
+
+// guildDeleteEvent is the GUILD_DELETE payload: an unavailable guild
+type guildDeleteEvent struct {
+	ID string `json:"id"`
+	// Unavailable is true during a Discord outage; the bot is still in the
+	// guild. Absent when the bot was removed.
+	Unavailable bool `json:"unavailable"`
+}
+
+// readSignedBody reads the request body and checks its Ed25519 signature
+// against key, writing the error response if it doesn't verify.
+func (h *DiscordWebhookHandler) readSignedBody(w http.ResponseWriter, r *http.Request, key ed25519.PublicKey) ([]byte, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	signature := r.Header.Get(discord.SignatureHeader)
+	timestamp := r.Header.Get(discord.SignatureTimestampHeader)
+	if !discord.VerifySignature(key, signature, timestamp, body) {
+		log.Printf("[DISCORD_WEBHOOK_ERROR] Invalid signature on %s from %s", r.URL.Path, r.RemoteAddr)
+		if h.securityLogger != nil {
+			h.securityLogger.LogWebhookSignatureFailure(r.Context(), r.RemoteAddr)
+		}
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+	return body, true
+}
+
+// HandleGuildRemoved receives a GUILD_DELETE dispatch, forwarded and signed
+// by whatever holds the bot's Gateway connection (Discord sends it nowhere
+// else), and deletes the guild's data. GUILD_DELETE for a guild that is
+// only unavailable (outage) is acknowledged and ignored.
+func (h *DiscordWebhookHandler) HandleGuildRemoved(w http.ResponseWriter, r *http.Request) {
+	body, ok := h.readSignedBody(w, r, h.guildEventsKey)
+	if !ok {
+		return
+	}
+
+	var dispatch gatewayDispatch
+	if err := json.Unmarshal(body, &dispatch); err != nil || dispatch.Type != "GUILD_DELETE" {
+		http.Error(w, "Expected a GUILD_DELETE dispatch", http.StatusBadRequest)
+		return
+	}
+	var event guildDeleteEvent
+	if err := json.Unmarshal(dispatch.Data, &event); err != nil {
+		http.Error(w, "Invalid GUILD_DELETE payload", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.ValidateGuildID(event.ID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	if event.Unavailable {
+		log.Printf("[DISCORD_WEBHOOK] Guild %s unavailable (outage), keeping its data", event.ID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := h.cleanup.HandleBotRemoved(r.Context(), event.ID); err != nil {
+		log.Printf("[DISCORD_WEBHOOK_ERROR] Cleanup for removed guild %s failed: %v", event.ID, err)
+		http.Error(w, "Cleanup failed", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StreamersCommand is the /streamers slash command served by
+// HandleInteraction, registered with `go run ./cmd/lambda register-commands`
+var StreamersCommand = discord.ApplicationCommand{
+	Name:         "streamers",
+	Description:  "Streamers tracked in this server",
+	DMPermission: false,
+	Options: []discord.CommandOption{
+		{
+			Type:        discord.CommandOptionSubCommand,
+			Name:        "list",
+			Description: "List tracked streamers and whether you get their notifications",
+		},
+		{
+			Type:        discord.CommandOptionSubCommand,
+			Name:        "toggle",
+			Description: "Turn your notifications for a streamer on or off",
+			Options: []discord.CommandOption{
+				{
+					Type:        discord.CommandOptionString,
+					Name:        "streamer",
+					Description: "Twitch login of the streamer",
+					Required:    true,
+				},
+			},
+		},
+	},
+}
+
+// HandleInteraction receives Discord interactions (slash commands): PING is
+// answered with PONG, and /streamers list|toggle reply ephemerally to the
+// invoking user.
+func (h *DiscordWebhookHandler) HandleInteraction(w http.ResponseWriter, r *http.Request) {
+	body, ok := h.readSignedBody(w, r, h.appPublicKey)
+	if !ok {
+		return
+	}
+
+	var interaction discord.Interaction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		http.Error(w, "Invalid interaction", http.StatusBadRequest)
+		return
+	}
+
+	switch interaction.Type {
+	case discord.InteractionTypePing:
+		writeInteractionResponse(w, discord.InteractionResponse{Type: discord.InteractionResponsePong})
+	case discord.InteractionTypeApplicationCommand:
+		writeInteractionResponse(w, discord.EphemeralReply(h.runCommand(r.Context(), &interaction)))
+	default:
+		http.Error(w, "Unsupported interaction type", http.StatusBadRequest)
+	}
+}
+
+// runCommand executes an application command and returns the reply text.
+// Errors are logged and answered with a generic message, since Discord
+// shows anything other than an interaction response as a failure.
+func (h *DiscordWebhookHandler) runCommand(ctx context.Context, interaction *discord.Interaction) string {
+	user := interaction.Invoker()
+	if interaction.Data.Name != StreamersCommand.Name || len(interaction.Data.Options) != 1 || user == nil {
+		return "Unknown command."
+	}
+	if h.validator.ValidateGuildID(interaction.GuildID) != nil {
+		return "This command can only be used in a server."
+	}
+
+	sub := interaction.Data.Options[0]
+	var reply string
+	var err error
+	switch sub.Name {
+	case "list":
+		reply, err = h.listStreamers(ctx, interaction.GuildID, user.ID)
+	case "toggle":
+		login, _ := discord.StringOption(sub.Options, "streamer")
+		reply, err = h.toggleStreamer(ctx, interaction.GuildID, user, login)
+	default:
+		return "Unknown command."
+	}
+	if err != nil {
+		log.Printf("[DISCORD_WEBHOOK_ERROR] /%s %s in guild %s failed: %v", interaction.Data.Name, sub.Name, interaction.GuildID, err)
+		return "Something went wrong, please try again later."
+	}
+	return reply
+}
+
+// listStreamers lists the guild's streamers with the user's notification
+// setting for each.
+func (h *DiscordWebhookHandler) listStreamers(ctx context.Context, guildID, userID string) (string, error) {
+	streamers, err := db.GetGuildStreamers(ctx, guildID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get streamers: %w", err)
+	}
+	if len(streamers) == 0 {
+		return "No streamers are tracked in this server.", nil
+	}
+
+	disabled, err := db.GetUserDisabledStreamers(ctx, userID, guildID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get preferences: %w", err)
+	}
+
+	lines := make([]string, len(streamers))
+	for i, s := range streamers {
+		state := "on"
+		if disabled[s.ID] {
+			state = "off"
+		}
+		lines[i] = fmt.Sprintf("- %s (%s) — notifications %s\n", displayName(&s), s.TwitchLogin, state)
+	}
+	return truncateLines("Tracked streamers:\n", lines, discord.MaxMessageContent), nil
+}
+
+// truncateLines joins header and lines, dropping trailing lines with an
+// "…and N more" note so the result stays within limit characters.
+func truncateLines(header string, lines []string, limit int) string {
+	var b strings.Builder
+	b.WriteString(header)
+	length := utf8.RuneCountInString(header)
+	for i, line := range lines {
+		n := utf8.RuneCountInString(line)
+		// Keep room for the note unless this is the last line
+		reserve := 0
+		if i < len(lines)-1 {
+			reserve = utf8.RuneCountInString(moreLine(len(lines) - i - 1))
+		}
+		if length+n+reserve > limit {
+			b.WriteString(moreLine(len(lines) - i))
+			return b.String()
+		}
+		b.WriteString(line)
+		length += n
+	}
+	return b.String()
+}
+
+func moreLine(n int) string {
+	return fmt.Sprintf("…and %d more\n", n)
+}
+
+// toggleStreamer flips the user's notification preference for the guild
+// streamer with the given Twitch login.
+func (h *DiscordWebhookHandler) toggleStreamer(ctx context.Context, guildID string, user *discord.InteractionUser, login string) (string, error) {
+	login = strings.ToLower(strings.TrimSpace(login))
+	if login == "" {
+		return "Please name a streamer.", nil
+	}
+
+	streamers, err := db.GetGuildStreamers(ctx, guildID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get streamers: %w", err)
+	}
+	var streamer *db.Streamer
+	for i := range streamers {
+		if strings.EqualFold(streamers[i].TwitchLogin, login) {
+			streamer = &streamers[i]
+			break
+		}
+	}
+	if streamer == nil {
+		return fmt.Sprintf("%s is not tracked in this server.", login), nil
+	}
+
+	// user_preferences references users; the user may never have logged in
+	if err := db.EnsureUser(ctx, user.ID, user.Username, user.Avatar); err != nil {
+		return "", fmt.Errorf("failed to record user: %w", err)
+	}
+	enabled, err := db.GetUserNotificationsEnabled(ctx, user.ID, guildID, streamer.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get preference: %w", err)
+	}
+	if err := db.SetUserPreference(ctx, user.ID, guildID, streamer.ID, !enabled); err != nil {
+		return "", fmt.Errorf("failed to set preference: %w", err)
+	}
+
+	if enabled {
+		return fmt.Sprintf("Notifications for %s are now off.", displayName(streamer)), nil
+	}
+	return fmt.Sprintf("Notifications for %s are now on.", displayName(streamer)), nil
+}
+
+// displayName returns the streamer's Twitch display name, or login if unset.
+func displayName(s *db.Streamer) string {
+	if s.TwitchDisplayName != "" {
+		return s.TwitchDisplayName
+	}
+	return s.TwitchLogin
+}
+
+// writeInteractionResponse writes an interaction response as JSON.
+func writeInteractionResponse(w http.ResponseWriter, resp discord.InteractionResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("[DISCORD_WEBHOOK_ERROR] Failed to write interaction response: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/yourusername/streammaxing/internal/services/discord"
+)
+
+func TestTruncateLines(t *testing.T) {
+	lines := make([]string, 60)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("- Streamer %02d (streamer_with_a_long_login_%02d) — notifications on\n", i, i)
+	}
+
+	got := truncateLines("Tracked streamers:\n", lines, discord.MaxMessageContent)
+	if n := utf8.RuneCountInString(got); n > discord.MaxMessageContent {
+		t.Fatalf("reply is %d characters, over the %d limit", n, discord.MaxMessageContent)
+	}
+	shown := strings.Count(got, "- Streamer ")
+	if shown == 0 || shown == len(lines) {
+		t.Fatalf("showed %d of %d lines, want a truncated list", shown, len(lines))
+	}
+	if want := fmt.Sprintf("…and %d more\n", len(lines)-shown); !strings.HasSuffix(got, want) {
+		t.Errorf("reply ends %q, want %q", got[len(got)-40:], want)
+	}
+
+	short := truncateLines("Tracked streamers:\n", lines[:3], discord.MaxMessageContent)
+	if want := "Tracked streamers:\n" + strings.Join(lines[:3], ""); short != want {
+		t.Errorf("short list = %q, want it untouched", short)
+	}
+}
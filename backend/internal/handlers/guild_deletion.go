@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/yourusername/streammaxing/internal/db"
+	"github.com/yourusername/streammaxing/internal/middleware"
+)
+
+// GetGuildDeletionImpact reports what deleting the guild's data would remove,
+// so the dashboard can show it before asking for confirmation
+func (h *GuildHandler) GetGuildDeletionImpact(w http.ResponseWriter, r *http.Request, guildID string) {
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "view_deletion_impact")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	impact, err := db.GetGuildDeletionImpact(r.Context(), guildID)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to count data for guild %s: %v", guildID, err)
+		http.Error(w, "Failed to fetch deletion impact", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(impact)
+}
+
+// DeleteGuild deletes all of a guild's data (config, streamer links,
+// preferences, notification history, invites). It requires ?confirm= set to
+// the guild ID and returns what was removed.
+func (h *GuildHandler) DeleteGuild(w http.ResponseWriter, r *http.Request, guildID string) {
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "delete_guild")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	if r.URL.Query().Get("confirm") != guildID {
+		http.Error(w, "Confirmation required: set confirm to the guild ID", http.StatusBadRequest)
+		return
+	}
+
+	impact, err := db.GetGuildDeletionImpact(r.Context(), guildID)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to count data for guild %s: %v", guildID, err)
+		http.Error(w, "Failed to delete guild", http.StatusInternalServerError)
+		return
+	}
+
+	if err := db.DeleteGuild(r.Context(), guildID); err != nil {
+		log.Printf("[GUILD_ERROR] Failed to delete guild %s: %v", guildID, err)
+		http.Error(w, "Failed to delete guild", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[GUILD] Deleted guild %s by user %s: %+v", guildID, userID, *impact)
+	db.InsertAuditLog(r.Context(), userID, "delete_guild", "guild", guildID, map[string]interface{}{
+		"impact": impact,
+	}, r.RemoteAddr, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Guild deleted",
+		"deleted": impact,
+	})
+}
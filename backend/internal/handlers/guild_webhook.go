@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yourusername/streammaxing/internal/db"
+	"github.com/yourusername/streammaxing/internal/middleware"
+	"github.com/yourusername/streammaxing/internal/services/discord"
+)
+
+// setGuildWebhookRequest is the body of PUT /guilds/:guild_id/webhook
+type setGuildWebhookRequest struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+// SetGuildWebhook makes a guild's notifications post through a channel
+// webhook instead of as the bot. The webhook must belong to the guild. The
+// URL is stored encrypted and never returned or logged; the webhook's
+// channel stands in for it in the response and the audit log.
+func (h *GuildHandler) SetGuildWebhook(w http.ResponseWriter, r *http.Request, guildID string) {
+	// Validate guild ID
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify admin permission
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "set_webhook")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	if h.encryptionSvc == nil {
+		log.Printf("[GUILD_ERROR] Cannot store webhook for %s: no encryption service", guildID)
+		http.Error(w, "Webhook delivery is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req setGuildWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	webhookURL, err := discord.ParseWebhookURL(req.WebhookURL)
+	if err != nil {
+		http.Error(w, "Invalid Discord webhook URL", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.discordAPI.GetWebhook(r.Context(), webhookURL)
+	if err != nil {
+		log.Printf("[GUILD_WARN] Failed to fetch webhook for guild %s: %v", guildID, err)
+		http.Error(w, "Webhook not found or not usable", http.StatusBadRequest)
+		return
+	}
+	if webhook.GuildID != guildID {
+		http.Error(w, "Webhook does not belong to this server", http.StatusBadRequest)
+		return
+	}
+
+	encrypted, err := h.encryptionSvc.Encrypt(r.Context(), webhookURL)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to encrypt webhook URL for %s: %v", guildID, err)
+		h.securityLogger.LogTokenEncryptionFailure(r.Context(), userID, err)
+		http.Error(w, "Internal security error", http.StatusInternalServerError)
+		return
+	}
+	if err := db.SetGuildWebhookURL(r.Context(), guildID, encrypted); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Guild not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("[GUILD_ERROR] Failed to store webhook for %s: %v", guildID, err)
+		http.Error(w, "Failed to save webhook", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[GUILD] Webhook set for guild %s (channel %s) by user %s", guildID, webhook.ChannelID, userID)
+	db.InsertAuditLog(r.Context(), userID, "set_webhook", "guild_config", guildID, map[string]interface{}{
+		"guild_id":   guildID,
+		"channel_id": webhook.ChannelID,
+	}, r.RemoteAddr, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhook_configured": true,
+		"channel_id":         webhook.ChannelID,
+	})
+}
+
+// DeleteGuildWebhook removes a guild's webhook, so its notifications are
+// posted by the bot again.
+func (h *GuildHandler) DeleteGuildWebhook(w http.ResponseWriter, r *http.Request, guildID string) {
+	// Validate guild ID
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify admin permission
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "delete_webhook")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	if err := db.SetGuildWebhookURL(r.Context(), guildID, ""); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Guild not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("[GUILD_ERROR] Failed to clear webhook for %s: %v", guildID, err)
+		http.Error(w, "Failed to remove webhook", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[GUILD] Webhook removed for guild %s by user %s", guildID, userID)
+	db.InsertAuditLog(r.Context(), userID, "delete_webhook", "guild_config", guildID, map[string]interface{}{
+		"guild_id": guildID,
+	}, r.RemoteAddr, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook removed"})
+}
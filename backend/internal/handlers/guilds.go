@@ -1,15 +1,27 @@
 package handlers
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/yourusername/streammaxing/internal/db"
 	"github.com/yourusername/streammaxing/internal/middleware"
 	"github.com/yourusername/streammaxing/internal/services/authorization"
 	"github.com/yourusername/streammaxing/internal/services/discord"
+	"github.com/yourusername/streammaxing/internal/services/encryption"
 	"github.com/yourusername/streammaxing/internal/services/logging"
+	"github.com/yourusername/streammaxing/internal/services/notifications"
+	"github.com/yourusername/streammaxing/internal/services/twitch"
 	"github.com/yourusername/streammaxing/internal/validation"
 )
 
@@ -17,25 +29,36 @@ import (
 type GuildHandler struct {
 	discordAPI     *discord.APIClient
 	oauth          *discord.OAuthService
+	twitchAPI      *twitch.APIClient
+	eventsub       *twitch.EventSubService
 	guildAuth      *authorization.GuildAuthService
 	securityLogger *logging.SecurityLogger
 	validator      *validation.Validator
+	templateSvc    *notifications.TemplateService
+	encryptionSvc  *encryption.Service
 }
 
 // NewGuildHandler creates a new guild handler.
-// Discord clients are injected from the centralized config.
+// Discord and Twitch clients are injected from the centralized config.
 func NewGuildHandler(
 	discordAPI *discord.APIClient,
 	discordOAuth *discord.OAuthService,
+	twitchAPI *twitch.APIClient,
+	eventsub *twitch.EventSubService,
 	guildAuth *authorization.GuildAuthService,
 	securityLogger *logging.SecurityLogger,
+	encryptionSvc *encryption.Service,
 ) *GuildHandler {
 	return &GuildHandler{
 		discordAPI:     discordAPI,
 		oauth:          discordOAuth,
+		twitchAPI:      twitchAPI,
+		eventsub:       eventsub,
 		guildAuth:      guildAuth,
 		securityLogger: securityLogger,
 		validator:      validation.NewValidator(),
+		templateSvc:    notifications.NewTemplateService(),
+		encryptionSvc:  encryptionSvc,
 	}
 }
 
@@ -62,7 +85,8 @@ func (h *GuildHandler) GetUserGuilds(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(guilds)
 }
 
-// GetGuildChannels returns text channels for a guild
+// GetGuildChannels returns a guild's text channels and the categories
+// they are grouped under
 func (h *GuildHandler) GetGuildChannels(w http.ResponseWriter, r *http.Request, guildID string) {
 	// Validate guild ID
 	if err := h.validator.ValidateGuildID(guildID); err != nil {
@@ -78,7 +102,7 @@ func (h *GuildHandler) GetGuildChannels(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	channels, err := h.discordAPI.GetGuildChannels(guildID)
+	channels, err := h.discordAPI.GetGuildChannels(r.Context(), guildID)
 	if err != nil {
 		log.Printf("[GUILD_ERROR] Failed to fetch channels for %s: %v", guildID, err)
 		http.Error(w, "Failed to fetch channels", http.StatusInternalServerError)
@@ -105,7 +129,7 @@ func (h *GuildHandler) GetGuildRoles(w http.ResponseWriter, r *http.Request, gui
 		return
 	}
 
-	roles, err := h.discordAPI.GetGuildRoles(guildID)
+	roles, err := h.discordAPI.GetGuildRoles(r.Context(), guildID)
 	if err != nil {
 		log.Printf("[GUILD_ERROR] Failed to fetch roles for %s: %v", guildID, err)
 		http.Error(w, "Failed to fetch roles", http.StatusInternalServerError)
@@ -116,7 +140,8 @@ func (h *GuildHandler) GetGuildRoles(w http.ResponseWriter, r *http.Request, gui
 	json.NewEncoder(w).Encode(roles)
 }
 
-// GetGuildStreamers returns streamers linked to a guild (with custom_content and added_by)
+// GetGuildStreamers returns streamers linked to a guild (with custom_content and added_by).
+// With ?paginated=true it returns one page (?limit=, ?offset=) in a pageResponse.
 func (h *GuildHandler) GetGuildStreamers(w http.ResponseWriter, r *http.Request, guildID string) {
 	// Validate guild ID
 	if err := h.validator.ValidateGuildID(guildID); err != nil {
@@ -132,7 +157,17 @@ func (h *GuildHandler) GetGuildStreamers(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	streamers, err := db.GetGuildStreamersWithContent(r.Context(), guildID)
+	paginated := wantsPagination(r)
+	var page pageParams
+	if paginated {
+		var err error
+		if page, err = parsePageParams(r); err != nil {
+			http.Error(w, "Invalid pagination: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	streamers, err := db.GetGuildStreamersWithContent(r.Context(), guildID, page.Limit, page.Offset)
 	if err != nil {
 		log.Printf("[GUILD_ERROR] Failed to fetch streamers for %s: %v", guildID, err)
 		http.Error(w, "Failed to fetch streamers", http.StatusInternalServerError)
@@ -143,8 +178,205 @@ func (h *GuildHandler) GetGuildStreamers(w http.ResponseWriter, r *http.Request,
 		streamers = []map[string]interface{}{}
 	}
 
+	// Live status needs a Twitch call, so it is only included on request
+	if r.URL.Query().Get("live") == "true" {
+		h.addLiveStatus(r.Context(), streamers)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(streamers)
+	if !paginated {
+		json.NewEncoder(w).Encode(streamers)
+		return
+	}
+
+	total, err := db.CountGuildStreamers(r.Context(), guildID)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to count streamers for %s: %v", guildID, err)
+		http.Error(w, "Failed to fetch streamers", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(newPageResponse(streamers, len(streamers), total, page))
+}
+
+// addLiveStatus sets "is_live" on each streamer. If Twitch can't be reached
+// the flag is left out rather than failing the listing.
+func (h *GuildHandler) addLiveStatus(ctx context.Context, streamers []map[string]interface{}) {
+	broadcasterIDs := make([]string, 0, len(streamers))
+	for _, s := range streamers {
+		if id, ok := s["twitch_broadcaster_id"].(string); ok {
+			broadcasterIDs = append(broadcasterIDs, id)
+		}
+	}
+
+	live, err := h.twitchAPI.GetLiveStatus(ctx, broadcasterIDs)
+	if err != nil {
+		log.Printf("[GUILD_WARN] Failed to fetch live status: %v", err)
+		return
+	}
+
+	for _, s := range streamers {
+		if id, ok := s["twitch_broadcaster_id"].(string); ok {
+			s["is_live"] = live[id]
+		}
+	}
+}
+
+// Notification history page size bounds
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// GetStreamerHistory returns recent notifications sent for a streamer in a guild
+func (h *GuildHandler) GetStreamerHistory(w http.ResponseWriter, r *http.Request, guildID, streamerID string) {
+	// Validate inputs
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.ValidateStreamerID(streamerID); err != nil {
+		http.Error(w, "Invalid streamer ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify guild membership
+	userID := middleware.GetUserID(r)
+	if isMember, _ := h.guildAuth.CheckGuildMember(r.Context(), userID, guildID); !isMember {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "get_streamer_history")
+		http.Error(w, "Forbidden: guild membership required", http.StatusForbidden)
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxHistoryLimit)
+	}
+
+	history, err := db.GetStreamerNotificationHistory(r.Context(), guildID, streamerID, limit)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to fetch history for streamer %s in %s: %v", streamerID, guildID, err)
+		http.Error(w, "Failed to fetch notification history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// Notification stats window bounds (days)
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 90
+)
+
+// GetGuildStats returns notification counts for a guild over the last ?days=
+func (h *GuildHandler) GetGuildStats(w http.ResponseWriter, r *http.Request, guildID string) {
+	// Validate guild ID
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify admin permission
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "get_stats")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	days := defaultStatsDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+		days = max(1, min(n, maxStatsDays))
+	}
+
+	// Whole UTC days, so the first bucket of the daily series is complete
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+
+	stats, err := db.GetNotificationStats(r.Context(), guildID, since)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to fetch stats for %s: %v", guildID, err)
+		http.Error(w, "Failed to fetch notification stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetGuildAuditLog returns the guild's audit entries, newest first, as a
+// paginated envelope (?limit=, ?offset=), optionally filtered by ?action=
+func (h *GuildHandler) GetGuildAuditLog(w http.ResponseWriter, r *http.Request, guildID string) {
+	// Validate guild ID
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify admin permission
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "get_audit_log")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	page, err := parsePageParams(r)
+	if err != nil {
+		http.Error(w, "Invalid pagination: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := r.URL.Query().Get("action")
+	if !isAuditAction(action) {
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := db.GetGuildAuditLogs(r.Context(), guildID, action, page.Limit, page.Offset)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to fetch audit log for %s: %v", guildID, err)
+		http.Error(w, "Failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []db.AuditLogEntry{}
+	}
+
+	total, err := db.CountGuildAuditLogs(r.Context(), guildID, action)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to count audit log for %s: %v", guildID, err)
+		http.Error(w, "Failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newPageResponse(entries, len(entries), total, page))
+}
+
+// isAuditAction reports whether s is empty or shaped like an audit action
+// name (lowercase snake_case)
+func isAuditAction(s string) bool {
+	if len(s) > 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && c != '_' {
+			return false
+		}
+	}
+	return true
 }
 
 // GetStreamerMessage returns the custom notification text for a streamer
@@ -208,8 +440,15 @@ func (h *GuildHandler) UpdateStreamerMessage(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Validate custom content for XSS and size limits
-	if err := h.validator.ValidateCustomContent(body.CustomContent); err != nil {
+	// Validate custom content for XSS, size limits and mentions beyond the
+	// guild's policy
+	config, err := db.GetGuildConfig(r.Context(), guildID)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to fetch config for %s: %v", guildID, err)
+		http.Error(w, "Failed to update message", http.StatusInternalServerError)
+		return
+	}
+	if err := h.validator.ValidateCustomContent(body.CustomContent, config.MentionEveryone); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -217,6 +456,9 @@ func (h *GuildHandler) UpdateStreamerMessage(w http.ResponseWriter, r *http.Requ
 	// Sanitize input
 	body.CustomContent = h.validator.SanitizeInput(body.CustomContent)
 
+	// Previous text for the audit trail (empty if it can't be read)
+	previous, _ := db.GetStreamerCustomContent(r.Context(), guildID, streamerID)
+
 	if err := db.UpdateStreamerCustomContent(r.Context(), guildID, streamerID, body.CustomContent); err != nil {
 		log.Printf("[GUILD_ERROR] Failed to update custom content: %v", err)
 		http.Error(w, "Failed to update message", http.StatusInternalServerError)
@@ -224,12 +466,189 @@ func (h *GuildHandler) UpdateStreamerMessage(w http.ResponseWriter, r *http.Requ
 	}
 
 	log.Printf("[GUILD] Updated custom content: guild=%s streamer=%s by=%s", guildID, streamerID, userID)
-	db.InsertAuditLog(r.Context(), userID, "update_streamer_message", "streamer", streamerID, map[string]interface{}{"guild_id": guildID}, r.RemoteAddr, true)
+	db.InsertAuditLog(r.Context(), userID, "update_streamer_message", "streamer", streamerID, map[string]interface{}{
+		"guild_id": guildID,
+		"before":   previous,
+		"after":    body.CustomContent,
+	}, r.RemoteAddr, true)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Message updated"})
 }
 
+// Category filter limits (name length in characters)
+const (
+	maxFilterCategories   = 25
+	maxCategoryNameLength = 100
+)
+
+// GetStreamerFilter returns the category filter for a streamer in a guild
+func (h *GuildHandler) GetStreamerFilter(w http.ResponseWriter, r *http.Request, guildID, streamerID string) {
+	// Validate inputs
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.ValidateStreamerID(streamerID); err != nil {
+		http.Error(w, "Invalid streamer ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify guild membership
+	userID := middleware.GetUserID(r)
+	if isMember, _ := h.guildAuth.CheckGuildMember(r.Context(), userID, guildID); !isMember {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "get_streamer_filter")
+		http.Error(w, "Forbidden: guild membership required", http.StatusForbidden)
+		return
+	}
+
+	categories, err := db.GetStreamerCategoryFilter(r.Context(), guildID, streamerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Streamer not linked to this guild", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to fetch category filter: %v", err)
+		http.Error(w, "Failed to fetch filter", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"categories": categories})
+}
+
+// UpdateStreamerFilter sets which categories (games) a guild is notified for
+// when a streamer goes live. An empty list notifies for every category.
+func (h *GuildHandler) UpdateStreamerFilter(w http.ResponseWriter, r *http.Request, guildID, streamerID string) {
+	// Validate inputs
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.ValidateStreamerID(streamerID); err != nil {
+		http.Error(w, "Invalid streamer ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify admin permission
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "update_streamer_filter")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024) // 64KB max
+
+	var body struct {
+		Categories []string `json:"categories"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Trim, drop blanks and case-insensitive duplicates
+	categories := make([]string, 0, len(body.Categories))
+	for _, c := range body.Categories {
+		c = h.validator.SanitizeInput(c)
+		if c == "" || slices.ContainsFunc(categories, func(seen string) bool { return strings.EqualFold(seen, c) }) {
+			continue
+		}
+		if utf8.RuneCountInString(c) > maxCategoryNameLength {
+			http.Error(w, "Category name too long", http.StatusBadRequest)
+			return
+		}
+		categories = append(categories, c)
+	}
+	if len(categories) > maxFilterCategories {
+		http.Error(w, "Too many categories (max 25)", http.StatusBadRequest)
+		return
+	}
+
+	err = db.UpdateStreamerCategoryFilter(r.Context(), guildID, streamerID, categories)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Streamer not linked to this guild", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to update category filter: %v", err)
+		http.Error(w, "Failed to update filter", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[GUILD] Updated category filter: guild=%s streamer=%s by=%s", guildID, streamerID, userID)
+	db.InsertAuditLog(r.Context(), userID, "update_streamer_filter", "streamer", streamerID, map[string]interface{}{
+		"guild_id":   guildID,
+		"categories": categories,
+	}, r.RemoteAddr, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"categories": categories})
+}
+
+// UpdateStreamerEnabled pauses or resumes a streamer's notifications in a
+// guild without unlinking it, so its custom content and filter are kept.
+func (h *GuildHandler) UpdateStreamerEnabled(w http.ResponseWriter, r *http.Request, guildID, streamerID string) {
+	// Validate inputs
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.ValidateStreamerID(streamerID); err != nil {
+		http.Error(w, "Invalid streamer ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify admin permission
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "update_streamer_enabled")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1024)
+
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	enabled := *body.Enabled
+
+	err = db.SetGuildStreamerEnabled(r.Context(), guildID, streamerID, enabled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Streamer not linked to this guild", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to set streamer enabled: %v", err)
+		http.Error(w, "Failed to update streamer", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[GUILD] Set streamer enabled=%t: guild=%s streamer=%s by=%s", enabled, guildID, streamerID, userID)
+
+	// A paused streamer may no longer need subscriptions, or need them back
+	if streamer, err := db.GetStreamerByID(r.Context(), streamerID); err != nil {
+		log.Printf("[GUILD_WARN] Failed to fetch streamer %s for subscription sync: %v", streamerID, err)
+	} else if _, err := syncStreamerSubscriptions(r.Context(), h.eventsub, streamer.ID, streamer.TwitchBroadcasterID); err != nil {
+		log.Printf("[GUILD_WARN] Failed to sync EventSub subscriptions for %s: %v", streamer.TwitchLogin, err)
+	}
+	db.InsertAuditLog(r.Context(), userID, "update_streamer_enabled", "streamer", streamerID, map[string]interface{}{
+		"guild_id": guildID,
+		"enabled":  enabled,
+	}, r.RemoteAddr, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"enabled": enabled})
+}
+
 // UnlinkStreamer removes a streamer from a guild
 func (h *GuildHandler) UnlinkStreamer(w http.ResponseWriter, r *http.Request, guildID, streamerID string) {
 	// Validate guild ID
@@ -247,6 +666,15 @@ func (h *GuildHandler) UnlinkStreamer(w http.ResponseWriter, r *http.Request, gu
 		return
 	}
 
+	// Snapshot the link for the audit trail before it is gone
+	details := map[string]interface{}{"guild_id": guildID}
+	if streamer, err := db.GetStreamerByID(r.Context(), streamerID); err == nil {
+		details["twitch_login"] = streamer.TwitchLogin
+	}
+	if addedBy, err := db.GetGuildStreamerAddedBy(r.Context(), guildID, streamerID); err == nil {
+		details["added_by"] = addedBy
+	}
+
 	if err := db.UnlinkStreamerFromGuild(r.Context(), guildID, streamerID); err != nil {
 		log.Printf("[GUILD_ERROR] Failed to unlink streamer %s from %s: %v", streamerID, guildID, err)
 		http.Error(w, "Failed to unlink streamer", http.StatusInternalServerError)
@@ -254,7 +682,7 @@ func (h *GuildHandler) UnlinkStreamer(w http.ResponseWriter, r *http.Request, gu
 	}
 
 	log.Printf("[GUILD] Unlinked streamer %s from guild %s by user %s", streamerID, guildID, userID)
-	db.InsertAuditLog(r.Context(), userID, "unlink_streamer", "streamer", streamerID, map[string]interface{}{"guild_id": guildID}, r.RemoteAddr, true)
+	db.InsertAuditLog(r.Context(), userID, "unlink_streamer", "streamer", streamerID, details, r.RemoteAddr, true)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Streamer unlinked"})
@@ -287,7 +715,9 @@ func (h *GuildHandler) GetGuildConfig(w http.ResponseWriter, r *http.Request, gu
 	json.NewEncoder(w).Encode(config)
 }
 
-// UpdateGuildConfig updates the guild notification configuration
+// UpdateGuildConfig updates the guild notification configuration. Fields
+// missing from the body are left as they are. With ?validate_only=true it runs the same validation and returns a
+// configValidation without saving.
 func (h *GuildHandler) UpdateGuildConfig(w http.ResponseWriter, r *http.Request, guildID string) {
 	// Validate guild ID
 	if err := h.validator.ValidateGuildID(guildID); err != nil {
@@ -307,27 +737,36 @@ func (h *GuildHandler) UpdateGuildConfig(w http.ResponseWriter, r *http.Request,
 	// Limit request body size
 	r.Body = http.MaxBytesReader(w, r.Body, 1024*1024) // 1MB max
 
-	var config db.GuildConfig
+	current, err := db.GetGuildConfig(r.Context(), guildID)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to fetch config for %s: %v", guildID, err)
+		http.Error(w, "Failed to update configuration", http.StatusInternalServerError)
+		return
+	}
+
+	// Decode onto a copy of the stored config, so fields the client leaves
+	// out keep their values instead of being reset to zero. The template is
+	// cloned because decoding a json.RawMessage reuses its backing array.
+	config := *current
+	config.MessageTemplate = slices.Clone(current.MessageTemplate)
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 	config.GuildID = guildID
 
-	// Validate channel ID if provided
-	if config.ChannelID != "" {
-		if err := h.validator.ValidateChannelID(config.ChannelID); err != nil {
-			http.Error(w, "Invalid channel ID", http.StatusBadRequest)
-			return
-		}
+	result := h.validateGuildConfig(r.Context(), &config, current)
+
+	// Dry run: report what saving would find, without writing
+	if r.URL.Query().Get("validate_only") == "true" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
 	}
 
-	// Validate message template content
-	if config.MessageTemplate != nil {
-		if err := h.validator.ValidateTemplateContent(string(config.MessageTemplate)); err != nil {
-			http.Error(w, "Invalid template content", http.StatusBadRequest)
-			return
-		}
+	if !result.Valid {
+		http.Error(w, strings.Join(result.Errors, "; "), http.StatusBadRequest)
+		return
 	}
 
 	if err := db.UpdateGuildConfig(r.Context(), &config); err != nil {
@@ -337,12 +776,246 @@ func (h *GuildHandler) UpdateGuildConfig(w http.ResponseWriter, r *http.Request,
 	}
 
 	log.Printf("[GUILD] Updated config for guild %s by user %s", guildID, userID)
-	db.InsertAuditLog(r.Context(), userID, "update_config", "guild_config", guildID, nil, r.RemoteAddr, true)
+	db.InsertAuditLog(r.Context(), userID, "update_config", "guild_config", guildID, map[string]interface{}{
+		"guild_id": guildID,
+		"changes":  auditChanges(current, &config, "guild_id", "updated_at"),
+	}, r.RemoteAddr, true)
+	if config.MentionEveryone != current.MentionEveryone {
+		log.Printf("[GUILD] mention_everyone=%v for guild %s by user %s", config.MentionEveryone, guildID, userID)
+		db.InsertAuditLog(r.Context(), userID, "update_mention_everyone", "guild_config", guildID, map[string]interface{}{
+			"guild_id": guildID,
+			"enabled":  config.MentionEveryone,
+		}, r.RemoteAddr, true)
+	}
+
+	// Event-type toggles and disabling the guild change which EventSub
+	// subscriptions this guild's streamers need
+	if config.UpdateOnChannelChange != current.UpdateOnChannelChange || config.DeleteOnOffline != current.DeleteOnOffline ||
+		config.Enabled != current.Enabled {
+		h.syncGuildSubscriptions(r.Context(), guildID)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "Configuration updated"})
 }
 
+// maxInlineSubscriptionSyncs caps how many streamers syncGuildSubscriptions
+// syncs within the config update request. Each one is several Twitch calls;
+// the cleanup job reconciles the rest.
+const maxInlineSubscriptionSyncs = 10
+
+// syncGuildSubscriptions re-syncs the EventSub subscriptions of the
+// streamers a guild tracks, up to maxInlineSubscriptionSyncs. Failures and
+// streamers past the cap are logged; the cleanup job reconciles anything
+// left over.
+func (h *GuildHandler) syncGuildSubscriptions(ctx context.Context, guildID string) {
+	streamers, err := db.GetGuildStreamers(ctx, guildID)
+	if err != nil {
+		log.Printf("[GUILD_WARN] Failed to fetch streamers for subscription sync in guild %s: %v", guildID, err)
+		return
+	}
+	if len(streamers) > maxInlineSubscriptionSyncs {
+		log.Printf("[GUILD] Guild %s tracks %d streamers, syncing %d now; cleanup will reconcile the rest",
+			guildID, len(streamers), maxInlineSubscriptionSyncs)
+		streamers = streamers[:maxInlineSubscriptionSyncs]
+	}
+	for _, s := range streamers {
+		if _, err := syncStreamerSubscriptions(ctx, h.eventsub, s.ID, s.TwitchBroadcasterID); err != nil {
+			log.Printf("[GUILD_WARN] Failed to sync EventSub subscriptions for %s: %v", s.TwitchLogin, err)
+		}
+	}
+}
+
+// toneInfo describes a tone preset for ListTones
+type toneInfo struct {
+	Tone     string             `json:"tone"`
+	Template db.MessageTemplate `json:"template"`
+}
+
+// ListTones returns the available notification tone presets and their templates
+func (h *GuildHandler) ListTones(w http.ResponseWriter, r *http.Request) {
+	tones := make([]toneInfo, 0, len(db.Tones))
+	for _, tone := range db.Tones {
+		tones = append(tones, toneInfo{Tone: tone, Template: db.MessageTemplateForTone(tone)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tones)
+}
+
+// templatePreviewRequest is the body of PreviewTemplate. Stream fields are
+// optional mock data; unset ones fall back to sample values.
+type templatePreviewRequest struct {
+	Template json.RawMessage `json:"template"`
+	Stream   mockStream      `json:"stream"`
+}
+
+// mockStream is optional sample stream data for previews and test
+// notifications
+type mockStream struct {
+	StreamerLogin       string `json:"streamer_login"`
+	StreamerDisplayName string `json:"streamer_display_name"`
+	StreamerAvatarURL   string `json:"streamer_avatar_url"`
+	Title               string `json:"stream_title"`
+	GameName            string `json:"game_name"`
+	ViewerCount         *int   `json:"viewer_count"`
+	// UptimeMinutes is how long ago the sample stream started (default 5,
+	// at most a week)
+	UptimeMinutes *int `json:"stream_uptime_minutes"`
+}
+
+// build returns a streamer and stream filled from m, with sample values for
+// anything unset.
+func (m mockStream) build() (*db.Streamer, *twitch.StreamData) {
+	streamer := &db.Streamer{
+		TwitchLogin:       cmp.Or(m.StreamerLogin, "examplestreamer"),
+		TwitchDisplayName: cmp.Or(m.StreamerDisplayName, "ExampleStreamer"),
+		TwitchAvatarURL:   m.StreamerAvatarURL,
+	}
+	viewerCount := 1234
+	if m.ViewerCount != nil {
+		viewerCount = *m.ViewerCount
+	}
+	uptime := 5 * time.Minute
+	if m.UptimeMinutes != nil && *m.UptimeMinutes >= 0 {
+		uptime = time.Duration(min(*m.UptimeMinutes, 7*24*60)) * time.Minute
+	}
+	streamData := &twitch.StreamData{
+		UserLogin:    streamer.TwitchLogin,
+		UserName:     streamer.TwitchDisplayName,
+		Title:        cmp.Or(m.Title, "Sample stream title"),
+		GameName:     cmp.Or(m.GameName, "Just Chatting"),
+		ViewerCount:  viewerCount,
+		ThumbnailURL: "https://static-cdn.jtvnw.net/previews-ttv/live_user_" + streamer.TwitchLogin + "-{width}x{height}.jpg",
+		StartedAt:    time.Now().UTC().Add(-uptime),
+	}
+	return streamer, streamData
+}
+
+// templatePreviewResponse is the rendered message plus its worst-case size
+type templatePreviewResponse struct {
+	*discord.DiscordMessage
+	SizeEstimate *notifications.SizeEstimate `json:"size_estimate"`
+}
+
+// PreviewTemplate renders a message template against sample stream data and
+// returns the Discord message that would be sent, without sending it, along
+// with a worst-case size estimate. Unknown {variables} are left as-is so
+// typos show up in the preview.
+func (h *GuildHandler) PreviewTemplate(w http.ResponseWriter, r *http.Request, guildID string) {
+	// Validate guild ID
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify admin permission, as for config changes
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "preview_template")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024) // 64KB max
+
+	var req templatePreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Template == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.ValidateTemplateContent(string(req.Template)); err != nil {
+		http.Error(w, "Invalid template content", http.StatusBadRequest)
+		return
+	}
+
+	// Render mentions and times the way the guild's notifications would
+	config, err := db.GetGuildConfig(r.Context(), guildID)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to fetch config for %s: %v", guildID, err)
+		http.Error(w, "Failed to fetch configuration", http.StatusInternalServerError)
+		return
+	}
+
+	streamer, streamData := req.Stream.build()
+	message, err := h.templateSvc.RenderTemplate(req.Template, streamer, streamData, notifications.RenderOptionsFor(config))
+	if err != nil {
+		http.Error(w, "Invalid template: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// RenderTemplate already parsed it, so this can't fail
+	var tmpl db.MessageTemplate
+	json.Unmarshal(req.Template, &tmpl)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templatePreviewResponse{
+		DiscordMessage: message,
+		SizeEstimate:   h.templateSvc.EstimateSize(&tmpl),
+	})
+}
+
+// SendTestNotification renders the guild's configured template with sample
+// stream data and posts it to the configured channel, so admins can check
+// the bot can post there. Mentions don't ping. Discord errors are returned
+// verbatim with 502.
+func (h *GuildHandler) SendTestNotification(w http.ResponseWriter, r *http.Request, guildID string) {
+	// Validate guild ID
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	// Verify admin permission
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "test_notification")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	config, err := db.GetGuildConfig(r.Context(), guildID)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to fetch config for %s: %v", guildID, err)
+		http.Error(w, "Failed to fetch configuration", http.StatusInternalServerError)
+		return
+	}
+	if config.ChannelID == "" && !config.WebhookConfigured {
+		http.Error(w, "No notification channel configured", http.StatusBadRequest)
+		return
+	}
+
+	streamer, streamData := mockStream{}.build()
+	message, err := h.templateSvc.RenderTemplate(notifications.TemplateForGuild(config), streamer, streamData,
+		notifications.RenderOptionsFor(config))
+	if err != nil {
+		http.Error(w, "Invalid template: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	message.Content = strings.TrimSpace("🧪 Test notification " + message.Content)
+	message.AllowedMentions = &discord.AllowedMentions{Parse: []string{}}
+
+	channelID, messageID, err := notifications.PostToGuild(r.Context(), h.discordAPI, config, "Test notification", message)
+	if err != nil {
+		log.Printf("[GUILD_WARN] Test notification failed for guild %s: %v", guildID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	log.Printf("[GUILD] Sent test notification to guild %s channel %s by user %s", guildID, channelID, userID)
+	db.InsertAuditLog(r.Context(), userID, "test_notification", "guild_config", guildID, map[string]interface{}{
+		"channel_id": channelID,
+		"message_id": messageID,
+	}, r.RemoteAddr, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message_id": messageID, "channel_id": channelID})
+}
+
 // GetBotInstallURL returns the bot installation URL for a guild
 func (h *GuildHandler) GetBotInstallURL(w http.ResponseWriter, r *http.Request, guildID string) {
 	// Validate guild ID
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/yourusername/streammaxing/internal/db"
 	"github.com/yourusername/streammaxing/internal/middleware"
 	"github.com/yourusername/streammaxing/internal/services/authorization"
@@ -86,7 +88,8 @@ func (h *InviteHandler) CreateInvite(w http.ResponseWriter, r *http.Request, gui
 	json.NewEncoder(w).Encode(link)
 }
 
-// ListInvites returns all invite links for a guild (admin only)
+// ListInvites returns all invite links for a guild (admin only).
+// With ?paginated=true it returns one page (?limit=, ?offset=) in a pageResponse.
 func (h *InviteHandler) ListInvites(w http.ResponseWriter, r *http.Request, guildID string) {
 	userID := middleware.GetUserID(r)
 	if userID == "" {
@@ -108,7 +111,16 @@ func (h *InviteHandler) ListInvites(w http.ResponseWriter, r *http.Request, guil
 		return
 	}
 
-	links, err := db.GetGuildInviteLinks(r.Context(), guildID)
+	paginated := wantsPagination(r)
+	var page pageParams
+	if paginated {
+		if page, err = parsePageParams(r); err != nil {
+			http.Error(w, "Invalid pagination: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	links, err := db.GetGuildInviteLinks(r.Context(), guildID, page.Limit, page.Offset)
 	if err != nil {
 		log.Printf("[INVITE_ERROR] Failed to list invites: %v", err)
 		http.Error(w, "Failed to list invites", http.StatusInternalServerError)
@@ -120,7 +132,18 @@ func (h *InviteHandler) ListInvites(w http.ResponseWriter, r *http.Request, guil
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(links)
+	if !paginated {
+		json.NewEncoder(w).Encode(links)
+		return
+	}
+
+	total, err := db.CountGuildInviteLinks(r.Context(), guildID)
+	if err != nil {
+		log.Printf("[INVITE_ERROR] Failed to count invites: %v", err)
+		http.Error(w, "Failed to list invites", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(newPageResponse(links, len(links), total, page))
 }
 
 // DeleteInvite deletes an invite link (admin only)
@@ -231,18 +254,23 @@ func (h *InviteHandler) AcceptInvite(w http.ResponseWriter, r *http.Request, cod
 		return
 	}
 
-	// Add user to guild as non-admin member
-	if err := db.UpsertUserGuild(r.Context(), userID, link.GuildID, false); err != nil {
+	// Add user to guild as non-admin member and count the use, together so
+	// membership and use count can't diverge
+	err = db.WithTx(r.Context(), func(tx pgx.Tx) error {
+		if err := db.UpsertUserGuildTx(r.Context(), tx, userID, link.GuildID, false); err != nil {
+			return fmt.Errorf("add member: %w", err)
+		}
+		if err := db.IncrementInviteUseTx(r.Context(), tx, code); err != nil {
+			return fmt.Errorf("increment use: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
 		log.Printf("[INVITE_ERROR] Failed to add user %s to guild %s: %v", userID, link.GuildID, err)
 		http.Error(w, "Failed to accept invite", http.StatusInternalServerError)
 		return
 	}
 
-	// Increment use count
-	if err := db.IncrementInviteUse(r.Context(), code); err != nil {
-		log.Printf("[INVITE_WARN] Failed to increment invite use: %v", err)
-	}
-
 	// Fetch guild info for response
 	guild, err := db.GetGuild(r.Context(), link.GuildID)
 	if err != nil {
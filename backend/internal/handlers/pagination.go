@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// List page size bounds for paginated endpoints
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// pageParams holds the parsed ?limit= and ?offset= of a list request.
+type pageParams struct {
+	Limit  int
+	Offset int
+}
+
+// pageResponse is the envelope returned by paginated list endpoints.
+// NextOffset is nil on the last page.
+type pageResponse struct {
+	Items      interface{} `json:"items"`
+	Total      int         `json:"total"`
+	NextOffset *int        `json:"next_offset"`
+}
+
+// wantsPagination reports whether the client opted into the paginated
+// envelope with ?paginated=true. Without it, list endpoints keep returning a
+// bare array of every item.
+func wantsPagination(r *http.Request) bool {
+	return r.URL.Query().Get("paginated") == "true"
+}
+
+// parsePageParams reads ?limit= (default 50, capped at 200) and ?offset=.
+func parsePageParams(r *http.Request) (pageParams, error) {
+	p := pageParams{Limit: defaultPageLimit}
+	q := r.URL.Query()
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("invalid limit")
+		}
+		p.Limit = min(n, maxPageLimit)
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid offset")
+		}
+		p.Offset = n
+	}
+	return p, nil
+}
+
+// newPageResponse wraps one page of items, returned for p, out of total.
+func newPageResponse(items interface{}, count, total int, p pageParams) pageResponse {
+	resp := pageResponse{Items: items, Total: total}
+	if next := p.Offset + count; count > 0 && next < total {
+		resp.NextOffset = &next
+	}
+	return resp
+}
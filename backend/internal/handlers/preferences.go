@@ -17,7 +17,14 @@ func NewPreferencesHandler() *PreferencesHandler {
 	return &PreferencesHandler{}
 }
 
-// GetUserPreferences returns the current user's notification preferences
+// detailedPreference is a preference with its streamer resolved
+type detailedPreference struct {
+	db.UserPreference
+	Streamer *db.Streamer `json:"streamer,omitempty"`
+}
+
+// GetUserPreferences returns the current user's notification preferences.
+// With ?detailed=true each preference includes its streamer.
 func (h *PreferencesHandler) GetUserPreferences(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 	if userID == "" {
@@ -36,6 +43,28 @@ func (h *PreferencesHandler) GetUserPreferences(w http.ResponseWriter, r *http.R
 		prefs = []db.UserPreference{}
 	}
 
+	if r.URL.Query().Get("detailed") == "true" {
+		ids := make([]string, len(prefs))
+		for i, p := range prefs {
+			ids[i] = p.StreamerID
+		}
+		streamers, err := db.GetStreamersByIDs(r.Context(), ids)
+		if err != nil {
+			log.Printf("[PREF_ERROR] Failed to fetch streamers for user %s: %v", userID, err)
+			http.Error(w, "Failed to fetch preferences", http.StatusInternalServerError)
+			return
+		}
+
+		detailed := make([]detailedPreference, len(prefs))
+		for i, p := range prefs {
+			detailed[i] = detailedPreference{UserPreference: p, Streamer: streamers[p.StreamerID]}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detailed)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(prefs)
 }
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/yourusername/streammaxing/internal/services/notifications"
+)
+
+// StreamPollHandler runs the EventSub polling fallback
+type StreamPollHandler struct {
+	poller *notifications.StreamPoller
+}
+
+// NewStreamPollHandler creates a new stream poll handler
+func NewStreamPollHandler(poller *notifications.StreamPoller) *StreamPollHandler {
+	return &StreamPollHandler{poller: poller}
+}
+
+// RunPoll runs one PollStreamStatus pass (triggered by the EventBridge
+// schedule) and returns its result
+func (h *StreamPollHandler) RunPoll(w http.ResponseWriter, r *http.Request) {
+	result, err := h.poller.PollStreamStatus(r.Context())
+	if err != nil {
+		log.Printf("[POLL_ERROR] %v", err)
+		http.Error(w, "Stream poll failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yourusername/streammaxing/internal/db"
+	"github.com/yourusername/streammaxing/internal/middleware"
+	"github.com/yourusername/streammaxing/internal/services/twitch"
+)
+
+// syncStreamerSubscriptions reconciles a streamer's EventSub subscriptions
+// with the event types their guilds need (db.GetRequiredEventTypesForStreamer)
+// and records the result. A streamer no guild tracks ends up with none.
+// The changes are returned even when some of them failed.
+func syncStreamerSubscriptions(ctx context.Context, eventsub *twitch.EventSubService, streamerID, broadcasterID string) (*twitch.SubscriptionChanges, error) {
+	types, err := db.GetRequiredEventTypesForStreamer(ctx, streamerID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := eventsub.EnsureSubscriptions(ctx, broadcasterID, types)
+	if changes != nil {
+		storeSubscriptionChanges(ctx, streamerID, changes)
+	}
+	return changes, err
+}
+
+// setUpSubscriptions gives a newly linked streamer the EventSub
+// subscriptions their guilds need. A streamer with none yet gets the
+// required types (db.GetRequiredEventTypesForStreamer) created in one
+// parallel batch; otherwise the existing set is reconciled. Failures are logged, not returned: the cleanup job retries them.
+func setUpSubscriptions(ctx context.Context, eventsub *twitch.EventSubService, streamerID string, user *twitch.TwitchUser) {
+	stored, err := db.GetEventSubSubscriptions(ctx, streamerID)
+	if err != nil || len(stored) > 0 {
+		if _, err := syncStreamerSubscriptions(ctx, eventsub, streamerID, user.ID); err != nil {
+			log.Printf("[EVENTSUB_WARN] Failed to sync EventSub subscriptions for %s: %v", user.Login, err)
+		}
+		return
+	}
+
+	types, err := db.GetRequiredEventTypesForStreamer(ctx, streamerID)
+	if err != nil {
+		log.Printf("[EVENTSUB_WARN] Failed to resolve EventSub types for %s, cleanup will retry: %v", user.Login, err)
+		return
+	}
+
+	created, err := eventsub.CreateBroadcasterSubscriptions(ctx, user.ID, types)
+	for _, sub := range created {
+		if err := db.CreateEventSubSubscription(ctx, streamerID, sub.ID, sub.Type, sub.Status); err != nil {
+			log.Printf("[EVENTSUB] Failed to store subscription %s: %v", sub.ID, err)
+		}
+	}
+	if err != nil {
+		log.Printf("[EVENTSUB_WARN] Created %d/%d EventSub subscriptions for %s, cleanup will retry: %v",
+			len(created), len(types), user.Login, err)
+	}
+}
+
+// storeSubscriptionChanges mirrors the result of EnsureSubscriptions into
+// eventsub_subscriptions.
+func storeSubscriptionChanges(ctx context.Context, streamerID string, changes *twitch.SubscriptionChanges) {
+	for _, sub := range append(changes.Kept, changes.Created...) {
+		if err := db.CreateEventSubSubscription(ctx, streamerID, sub.ID, sub.Type, sub.Status); err != nil {
+			log.Printf("[EVENTSUB] Failed to store subscription %s: %v", sub.ID, err)
+		}
+	}
+	for _, sub := range changes.Deleted {
+		if err := db.DeleteEventSubSubscription(ctx, sub.ID); err != nil {
+			log.Printf("[EVENTSUB] Failed to remove subscription record %s: %v", sub.ID, err)
+		}
+	}
+}
+
+// twitchCallConcurrency bounds how many streamers a handler checks against
+// Twitch at once.
+const twitchCallConcurrency = 8
+
+// subscriptionReportTimeout bounds GetGuildSubscriptions' live checks.
+const subscriptionReportTimeout = 20 * time.Second
+
+// forEachConcurrently calls fn(i) for every i in [0, n), at most limit at
+// a time, and returns once all calls have.
+func forEachConcurrently(n, limit int, fn func(i int)) {
+	sem := make(chan struct{}, max(limit, 1))
+	var wg sync.WaitGroup
+	for i := range n {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+// subscriptionHealth is one EventSub subscription in GetGuildSubscriptions.
+// Status is Twitch's live status when the live check succeeded, otherwise
+// the stored one; "not_found" means Twitch no longer has it.
+type subscriptionHealth struct {
+	SubscriptionID string     `json:"subscription_id"`
+	Type           string     `json:"type"`
+	Status         string     `json:"status"`
+	StoredStatus   string     `json:"stored_status,omitempty"`
+	LastVerified   *time.Time `json:"last_verified,omitempty"`
+}
+
+// streamerSubscriptionHealth is a streamer's entry in GetGuildSubscriptions
+type streamerSubscriptionHealth struct {
+	StreamerID    string               `json:"streamer_id"`
+	TwitchLogin   string               `json:"twitch_login"`
+	Healthy       bool                 `json:"healthy"`
+	Subscriptions []subscriptionHealth `json:"subscriptions"`
+	// Missing lists required event types with no healthy subscription
+	Missing   []string `json:"missing"`
+	LiveError string   `json:"live_error,omitempty"`
+}
+
+// GetGuildSubscriptions reports the EventSub subscription health of every
+// streamer linked to the guild: stored subscriptions checked against Twitch,
+// and required event types that have no healthy subscription (meaning those
+// notifications won't fire).
+func (h *GuildHandler) GetGuildSubscriptions(w http.ResponseWriter, r *http.Request, guildID string) {
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "view_subscriptions")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	streamers, err := db.GetGuildStreamers(r.Context(), guildID)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to fetch streamers for guild %s: %v", guildID, err)
+		http.Error(w, "Failed to fetch subscriptions", http.StatusInternalServerError)
+		return
+	}
+
+	// Check streamers in parallel, finishing well inside API Gateway's 29s
+	// limit; live checks still running at the deadline report its error
+	ctx, cancel := context.WithTimeout(r.Context(), subscriptionReportTimeout)
+	defer cancel()
+	report := make([]streamerSubscriptionHealth, len(streamers))
+	errs := make([]error, len(streamers))
+	forEachConcurrently(len(streamers), twitchCallConcurrency, func(i int) {
+		health, err := h.streamerSubscriptionHealth(ctx, &streamers[i])
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		report[i] = *health
+	})
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("[GUILD_ERROR] Failed to check subscriptions for %s: %v", streamers[i].TwitchLogin, err)
+			http.Error(w, "Failed to fetch subscriptions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// streamerSubscriptionHealth merges a streamer's stored subscriptions with
+// a live listing from Twitch. A failed live check is reported, not returned.
+func (h *GuildHandler) streamerSubscriptionHealth(ctx context.Context, streamer *db.Streamer) (*streamerSubscriptionHealth, error) {
+	stored, err := db.GetEventSubSubscriptions(ctx, streamer.ID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := db.GetRequiredEventTypesForStreamer(ctx, streamer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	health := &streamerSubscriptionHealth{
+		StreamerID:    streamer.ID,
+		TwitchLogin:   streamer.TwitchLogin,
+		Subscriptions: []subscriptionHealth{},
+		Missing:       []string{},
+	}
+
+	live, liveErr := h.eventsub.ListBroadcasterSubscriptions(ctx, streamer.TwitchBroadcasterID)
+	if liveErr != nil {
+		health.LiveError = liveErr.Error()
+	}
+	liveByID := make(map[string]twitch.Subscription, len(live))
+	for _, sub := range live {
+		liveByID[sub.ID] = sub
+	}
+
+	for _, sub := range stored {
+		entry := subscriptionHealth{
+			SubscriptionID: sub.SubscriptionID,
+			Type:           sub.Type,
+			Status:         sub.Status,
+			StoredStatus:   sub.Status,
+			LastVerified:   &sub.LastVerified,
+		}
+		if liveErr == nil {
+			entry.Status = "not_found"
+			if l, ok := liveByID[sub.SubscriptionID]; ok {
+				entry.Status = l.Status
+				delete(liveByID, sub.SubscriptionID)
+			}
+		}
+		health.Subscriptions = append(health.Subscriptions, entry)
+	}
+	// Subscriptions Twitch has that we never stored
+	for _, sub := range live {
+		if _, ok := liveByID[sub.ID]; ok {
+			health.Subscriptions = append(health.Subscriptions, subscriptionHealth{
+				SubscriptionID: sub.ID,
+				Type:           sub.Type,
+				Status:         sub.Status,
+			})
+		}
+	}
+
+	for _, t := range required {
+		if !slices.ContainsFunc(health.Subscriptions, func(sub subscriptionHealth) bool {
+			return sub.Type == t && twitch.IsHealthyStatus(sub.Status)
+		}) {
+			health.Missing = append(health.Missing, t)
+		}
+	}
+	health.Healthy = len(health.Missing) == 0 && liveErr == nil
+	return health, nil
+}
+
+// Resubscribe recreates a streamer's EventSub subscriptions: failed or
+// revoked ones are deleted at Twitch and the required types created again
+// (existing subscriptions are listed first, so Twitch's "already exists"
+// conflict can't occur). Healthy subscriptions are kept.
+func (h *GuildHandler) Resubscribe(w http.ResponseWriter, r *http.Request, guildID, streamerID string) {
+	if err := h.validator.ValidateGuildID(guildID); err != nil {
+		http.Error(w, "Invalid guild ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.validator.ValidateStreamerID(streamerID); err != nil {
+		http.Error(w, "Invalid streamer ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	isAdmin, err := h.guildAuth.CheckGuildAdmin(r.Context(), userID, guildID)
+	if err != nil || !isAdmin {
+		h.securityLogger.LogPermissionDenied(r.Context(), userID, guildID, "resubscribe")
+		http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
+		return
+	}
+
+	if _, err := db.GetGuildStreamerAddedBy(r.Context(), guildID, streamerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Streamer not linked to this guild", http.StatusNotFound)
+			return
+		}
+		log.Printf("[GUILD_ERROR] Failed to check streamer %s in guild %s: %v", streamerID, guildID, err)
+		http.Error(w, "Failed to resubscribe", http.StatusInternalServerError)
+		return
+	}
+
+	streamer, err := db.GetStreamerByID(r.Context(), streamerID)
+	if err != nil {
+		log.Printf("[GUILD_ERROR] Failed to fetch streamer %s: %v", streamerID, err)
+		http.Error(w, "Failed to resubscribe", http.StatusInternalServerError)
+		return
+	}
+
+	changes, err := syncStreamerSubscriptions(r.Context(), h.eventsub, streamer.ID, streamer.TwitchBroadcasterID)
+	if changes == nil {
+		changes = &twitch.SubscriptionChanges{}
+	}
+	details := map[string]interface{}{
+		"guild_id": guildID,
+		"kept":     len(changes.Kept),
+		"created":  len(changes.Created),
+		"deleted":  len(changes.Deleted),
+	}
+	if err != nil {
+		// The error can quote Twitch's response bodies; it stays in the log
+		log.Printf("[GUILD_ERROR] Failed to resubscribe %s: %v", streamer.TwitchLogin, err)
+		details["failed"] = failureCount(err)
+		db.InsertAuditLog(r.Context(), userID, "resubscribe", "streamer", streamerID, details, r.RemoteAddr, false)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "Failed to recreate some subscriptions",
+			"kept":    len(changes.Kept),
+			"created": len(changes.Created),
+			"deleted": len(changes.Deleted),
+			"failed":  failureCount(err),
+		})
+		return
+	}
+
+	log.Printf("[GUILD] Resubscribed %s in guild %s by user %s: %+v", streamer.TwitchLogin, guildID, userID, details)
+	db.InsertAuditLog(r.Context(), userID, "resubscribe", "streamer", streamerID, details, r.RemoteAddr, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Subscriptions recreated",
+		"kept":    len(changes.Kept),
+		"created": len(changes.Created),
+		"deleted": len(changes.Deleted),
+	})
+}
+
+// failureCount returns how many failures err holds: the joined errors of
+// EnsureSubscriptions, or 1 for a single error.
+func failureCount(err error) int {
+	if err == nil {
+		return 0
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return len(joined.Unwrap())
+	}
+	return 1
+}
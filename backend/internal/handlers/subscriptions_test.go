@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEachConcurrently(t *testing.T) {
+	const n, limit = 50, 4
+	var running, peak atomic.Int32
+	var mu sync.Mutex
+	seen := make(map[int]int)
+
+	forEachConcurrently(n, limit, func(i int) {
+		cur := running.Add(1)
+		for {
+			p := peak.Load()
+			if cur <= p || peak.CompareAndSwap(p, cur) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+
+		mu.Lock()
+		seen[i]++
+		mu.Unlock()
+	})
+
+	if len(seen) != n {
+		t.Errorf("visited %d indexes, want %d", len(seen), n)
+	}
+	for i, count := range seen {
+		if count != 1 {
+			t.Errorf("index %d visited %d times", i, count)
+		}
+	}
+	if got := peak.Load(); got > limit {
+		t.Errorf("%d calls ran at once, want at most %d", got, limit)
+	}
+}
+
+func TestFailureCount(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{errors.New("list failed"), 1},
+		{errors.Join(errors.New("create stream.online"), errors.New("delete 123")), 2},
+		{fmt.Errorf("sync: %w", errors.New("list failed")), 1},
+	}
+	for _, tt := range tests {
+		if got := failureCount(tt.err); got != tt.want {
+			t.Errorf("failureCount(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
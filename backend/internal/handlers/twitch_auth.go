@@ -1,17 +1,20 @@
 package handlers
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/yourusername/streammaxing/internal/db"
 	"github.com/yourusername/streammaxing/internal/middleware"
 	"github.com/yourusername/streammaxing/internal/services/encryption"
@@ -148,7 +151,7 @@ func (h *TwitchAuthHandler) TwitchCallback(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	tokenResp, err := h.oauth.ExchangeCode(code)
+	tokenResp, err := h.oauth.ExchangeCode(ctx, code)
 	if err != nil {
 		log.Printf("[TWITCH_AUTH_ERROR] Failed to exchange code: %v", err)
 		http.Error(w, "Failed to exchange authorization code", http.StatusInternalServerError)
@@ -156,7 +159,7 @@ func (h *TwitchAuthHandler) TwitchCallback(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Fetch streamer info
-	user, err := h.oauth.GetUser(tokenResp.AccessToken)
+	user, err := h.oauth.GetUser(ctx, tokenResp.AccessToken)
 	if err != nil {
 		log.Printf("[TWITCH_AUTH_ERROR] Failed to fetch Twitch user: %v", err)
 		http.Error(w, "Failed to fetch Twitch user", http.StatusInternalServerError)
@@ -167,7 +170,7 @@ func (h *TwitchAuthHandler) TwitchCallback(w http.ResponseWriter, r *http.Reques
 	encryptedAccessToken := tokenResp.AccessToken
 	encryptedRefreshToken := tokenResp.RefreshToken
 	if h.encryptionSvc != nil {
-		encryptedAccessToken, err = h.encryptionSvc.Encrypt(tokenResp.AccessToken)
+		encryptedAccessToken, err = h.encryptionSvc.EncryptEnvelope(ctx, tokenResp.AccessToken)
 		if err != nil {
 			log.Printf("[TWITCH_AUTH_ERROR] Failed to encrypt access token: %v", err)
 			h.securityLogger.LogTokenEncryptionFailure(ctx, user.ID, err)
@@ -175,7 +178,7 @@ func (h *TwitchAuthHandler) TwitchCallback(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
-		encryptedRefreshToken, err = h.encryptionSvc.Encrypt(tokenResp.RefreshToken)
+		encryptedRefreshToken, err = h.encryptionSvc.EncryptEnvelope(ctx, tokenResp.RefreshToken)
 		if err != nil {
 			log.Printf("[TWITCH_AUTH_ERROR] Failed to encrypt refresh token: %v", err)
 			h.securityLogger.LogTokenEncryptionFailure(ctx, user.ID, err)
@@ -184,6 +187,14 @@ func (h *TwitchAuthHandler) TwitchCallback(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
+	// Use user_id from the state parameter (embedded during initiation)
+	// to avoid depending on the session cookie surviving the Twitch redirect.
+	userID := stateUserID
+	if userID == "" {
+		// Fallback: try session cookie if available
+		userID = middleware.GetUserID(r)
+	}
+
 	// Store streamer in database with encrypted tokens
 	streamer := &db.Streamer{
 		TwitchBroadcasterID: user.ID,
@@ -192,42 +203,38 @@ func (h *TwitchAuthHandler) TwitchCallback(w http.ResponseWriter, r *http.Reques
 		TwitchAvatarURL:     user.ProfileImageURL,
 		TwitchAccessToken:   encryptedAccessToken,
 		TwitchRefreshToken:  encryptedRefreshToken,
+		LinkedByUserID:      userID,
 	}
 
-	if err := db.CreateStreamer(ctx, streamer); err != nil {
-		log.Printf("[TWITCH_AUTH_ERROR] Failed to store streamer: %v", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
-	}
-
-	// Create EventSub subscription
-	subscription, err := h.eventsub.CreateStreamOnlineSubscription(user.ID)
-	if err != nil {
-		// Log error but don't fail - can retry later
-		log.Printf("[TWITCH_AUTH_WARN] Failed to create EventSub subscription for %s: %v", user.Login, err)
-	} else {
-		// Store subscription in database
-		if err := db.CreateEventSubSubscription(ctx, streamer.ID, subscription.ID, subscription.Status); err != nil {
-			log.Printf("[TWITCH_AUTH_WARN] Failed to store subscription: %v", err)
+	// Store the streamer and link it to the guild in one transaction, so a
+	// failed link doesn't leave fresh tokens for a streamer no guild tracks
+	var isNew bool
+	err = db.WithTx(ctx, func(tx pgx.Tx) error {
+		if err := db.CreateStreamerTx(ctx, tx, streamer); err != nil {
+			return fmt.Errorf("store streamer: %w", err)
 		}
-	}
-
-	// Link streamer to guild
-	// Use user_id from the state parameter (embedded during initiation)
-	// to avoid depending on the session cookie surviving the Twitch redirect.
-	userID := stateUserID
-	if userID == "" {
-		// Fallback: try session cookie if available
-		userID = middleware.GetUserID(r)
-	}
-	isNew, err := db.LinkStreamerToGuild(ctx, guildID, streamer.ID, userID)
+		created, err := db.LinkStreamerToGuildTx(ctx, tx, guildID, streamer.ID, userID)
+		if err != nil {
+			return fmt.Errorf("link streamer to guild: %w", err)
+		}
+		isNew = created
+		return nil
+	})
 	if err != nil {
-		log.Printf("[TWITCH_AUTH_ERROR] Failed to link streamer to guild: %v", err)
+		log.Printf("[TWITCH_AUTH_ERROR] Failed to link streamer: %v", err)
 		http.Error(w, "Failed to link streamer", http.StatusInternalServerError)
 		return
 	}
 
+	// Make sure we hear about revoked authorizations (one app-wide subscription)
+	if _, err := h.eventsub.CreateAuthRevokeSubscription(ctx); err != nil && !errors.Is(err, twitch.ErrSubscriptionExists) {
+		log.Printf("[TWITCH_AUTH_WARN] Failed to create authorization revoke subscription: %v", err)
+	}
+
 	log.Printf("[TWITCH_AUTH] Linked streamer %s (%s) to guild %s (new=%v)", user.DisplayName, user.ID, guildID, isNew)
+
+	setUpSubscriptions(ctx, h.eventsub, streamer.ID, user)
+
 	db.InsertAuditLog(ctx, userID, "link_streamer", "streamer", user.ID, map[string]interface{}{
 		"guild_id":     guildID,
 		"twitch_login": user.Login,
@@ -241,3 +248,78 @@ func (h *TwitchAuthHandler) TwitchCallback(w http.ResponseWriter, r *http.Reques
 	}
 	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 }
+
+// UnlinkMyStreamers removes the Twitch accounts the signed-in user linked:
+// their EventSub subscriptions, their stored tokens (revoked with Twitch
+// first, best effort), and the streamer records, which takes them off
+// every guild tracking them.
+func (h *TwitchAuthHandler) UnlinkMyStreamers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := middleware.GetUserID(r)
+
+	streamers, err := db.GetStreamersLinkedByUser(ctx, userID)
+	if err != nil {
+		log.Printf("[TWITCH_AUTH_ERROR] Failed to fetch streamers linked by %s: %v", userID, err)
+		http.Error(w, "Failed to unlink Twitch account", http.StatusInternalServerError)
+		return
+	}
+
+	removed := make([]string, 0, len(streamers))
+	for _, streamer := range streamers {
+		h.deleteStreamerSubscriptions(ctx, streamer.ID)
+		h.revokeStreamerToken(ctx, streamer.ID)
+
+		if err := db.DeleteStreamer(ctx, streamer.ID); err != nil {
+			log.Printf("[TWITCH_AUTH_ERROR] Failed to delete streamer %s: %v", streamer.ID, err)
+			http.Error(w, "Failed to unlink Twitch account", http.StatusInternalServerError)
+			return
+		}
+
+		h.securityLogger.LogStreamerTokensDeleted(ctx, userID, streamer.ID, r.RemoteAddr)
+		db.InsertAuditLog(ctx, userID, "delete_streamer", "streamer", streamer.TwitchBroadcasterID, map[string]interface{}{
+			"twitch_login": streamer.TwitchLogin,
+		}, r.RemoteAddr, true)
+		log.Printf("[TWITCH_AUTH] User %s unlinked Twitch account %s (%s)", userID, streamer.TwitchLogin, streamer.TwitchBroadcasterID)
+		removed = append(removed, streamer.TwitchLogin)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Twitch account unlinked",
+		"removed": removed,
+	})
+}
+
+// deleteStreamerSubscriptions deletes a streamer's EventSub subscriptions
+// from Twitch. The records go with the streamer row; a subscription Twitch
+// couldn't delete is left for the cleanup job's orphan check.
+func (h *TwitchAuthHandler) deleteStreamerSubscriptions(ctx context.Context, streamerID string) {
+	subs, err := db.GetEventSubSubscriptions(ctx, streamerID)
+	if err != nil {
+		log.Printf("[TWITCH_AUTH_WARN] Failed to fetch subscriptions for streamer %s: %v", streamerID, err)
+		return
+	}
+	for _, sub := range subs {
+		if err := h.eventsub.DeleteSubscription(ctx, sub.SubscriptionID); err != nil {
+			log.Printf("[TWITCH_AUTH_WARN] Failed to delete EventSub sub %s: %v", sub.SubscriptionID, err)
+		}
+	}
+}
+
+// revokeStreamerToken revokes a streamer's stored access token with Twitch
+// so it is dead even outside our database.
+func (h *TwitchAuthHandler) revokeStreamerToken(ctx context.Context, streamerID string) {
+	accessToken, _, err := db.GetStreamerTokens(ctx, streamerID)
+	if err != nil || accessToken == "" {
+		return
+	}
+	if h.encryptionSvc != nil {
+		if accessToken, err = h.encryptionSvc.DecryptEnvelope(ctx, accessToken); err != nil {
+			log.Printf("[TWITCH_AUTH_WARN] Failed to decrypt token for streamer %s, not revoked: %v", streamerID, err)
+			return
+		}
+	}
+	if err := h.oauth.RevokeToken(ctx, accessToken); err != nil {
+		log.Printf("[TWITCH_AUTH_WARN] Failed to revoke token for streamer %s: %v", streamerID, err)
+	}
+}
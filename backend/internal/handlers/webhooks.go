@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/yourusername/streammaxing/internal/db"
 	"github.com/yourusername/streammaxing/internal/services/logging"
 	"github.com/yourusername/streammaxing/internal/services/notifications"
 	"github.com/yourusername/streammaxing/internal/services/twitch"
@@ -14,22 +18,25 @@ import (
 // WebhookHandler handles incoming webhook events
 type WebhookHandler struct {
 	FanoutService  *notifications.FanoutService
+	eventsub       *twitch.EventSubService
 	securityLogger *logging.SecurityLogger
 }
 
 // NewWebhookHandler creates a new webhook handler
-func NewWebhookHandler(fanoutService *notifications.FanoutService, securityLogger *logging.SecurityLogger) *WebhookHandler {
+func NewWebhookHandler(fanoutService *notifications.FanoutService, eventsub *twitch.EventSubService, securityLogger *logging.SecurityLogger) *WebhookHandler {
 	return &WebhookHandler{
 		FanoutService:  fanoutService,
+		eventsub:       eventsub,
 		securityLogger: securityLogger,
 	}
 }
 
-// WebhookPayload represents the Twitch EventSub webhook payload
+// WebhookPayload represents the Twitch EventSub webhook payload. Event is
+// decoded by handleNotification into the type for Subscription.Type.
 type WebhookPayload struct {
-	Subscription WebhookSubscription    `json:"subscription"`
-	Challenge    string                 `json:"challenge,omitempty"`
-	Event        map[string]interface{} `json:"event,omitempty"`
+	Subscription WebhookSubscription `json:"subscription"`
+	Challenge    string              `json:"challenge,omitempty"`
+	Event        json.RawMessage     `json:"event,omitempty"`
 }
 
 // WebhookSubscription represents the subscription info in a webhook payload
@@ -77,25 +84,62 @@ func (h *WebhookHandler) HandleTwitchWebhook(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Handle challenge response (subscription verification)
-	if payload.Challenge != "" {
+	// Route on the message type header; reject bodies that don't match it
+	messageType := r.Header.Get(twitch.MessageTypeHeader)
+	switch messageType {
+	case twitch.MessageTypeVerification:
+		// Subscription verification: echo the challenge
+		if payload.Challenge == "" {
+			log.Printf("[WEBHOOK_ERROR] Verification message %s without challenge", messageID)
+			http.Error(w, "Missing challenge", http.StatusBadRequest)
+			return
+		}
 		log.Printf("[WEBHOOK] Challenge response for subscription %s", payload.Subscription.ID)
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(payload.Challenge))
-		return
+
+	case twitch.MessageTypeRevocation:
+		// Twitch stopped delivering this subscription
+		log.Printf("[WEBHOOK] Subscription %s (%s) revoked: %s",
+			payload.Subscription.ID, payload.Subscription.Type, payload.Subscription.Status)
+		if err := h.handleSubscriptionRevoked(r.Context(), payload.Subscription); err != nil {
+			log.Printf("[WEBHOOK_ERROR] Revocation handling failed for %s: %v", payload.Subscription.ID, err)
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case twitch.MessageTypeNotification:
+		if len(payload.Event) == 0 || string(payload.Event) == "null" || payload.Challenge != "" {
+			log.Printf("[WEBHOOK_ERROR] Malformed notification %s for %s", messageID, payload.Subscription.Type)
+			http.Error(w, "Malformed notification", http.StatusBadRequest)
+			return
+		}
+		h.handleNotification(r.Context(), messageID, payload)
+
+		// Always return 200 OK to Twitch for notifications
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		log.Printf("[WEBHOOK_ERROR] Unknown message type %q for message %s", messageType, messageID)
+		http.Error(w, "Unknown message type", http.StatusBadRequest)
 	}
+}
+
+// handleNotification decodes a notification's event into the type for its
+// subscription and dispatches it. Errors are logged, not returned: Twitch
+// gets 200 either way. A malformed event (wrong shape, or no valid Twitch
+// user ID) is acknowledged too, since a retry would be just as malformed,
+// but never dispatched, rather than failing deep inside the fanout.
+func (h *WebhookHandler) handleNotification(ctx context.Context, messageID string, payload WebhookPayload) {
+	subType := payload.Subscription.Type
 
-	// Handle stream.online notification
-	if payload.Subscription.Type == "stream.online" && payload.Event != nil {
-		event := notifications.StreamOnlineEvent{
-			BroadcasterUserID:    getStringFromMap(payload.Event, "broadcaster_user_id"),
-			BroadcasterUserLogin: getStringFromMap(payload.Event, "broadcaster_user_login"),
-			BroadcasterUserName:  getStringFromMap(payload.Event, "broadcaster_user_name"),
-			Type:                 getStringFromMap(payload.Event, "type"),
-			StartedAt:            getStringFromMap(payload.Event, "started_at"),
+	switch subType {
+	case twitch.SubscriptionTypeStreamOnline:
+		var event notifications.StreamOnlineEvent
+		if !decodeEvent(messageID, subType, payload.Event, &event) || !validEventUserID(messageID, subType, event.BroadcasterUserID) {
+			return
 		}
-		eventID := getStringFromMap(payload.Event, "id")
+		eventID := event.ID
 		if eventID == "" {
 			eventID = messageID // fallback to message ID
 		}
@@ -105,15 +149,175 @@ func (h *WebhookHandler) HandleTwitchWebhook(w http.ResponseWriter, r *http.Requ
 		// Process notification fanout synchronously.
 		// In Lambda, goroutines get frozen after the handler returns,
 		// so we must complete the fanout before returning 200 to Twitch.
-		// Twitch allows 10s for a response; fanout typically takes 1-3s.
-		ctx := r.Context()
-		if err := h.FanoutService.HandleStreamOnline(ctx, eventID, event); err != nil {
+		// Twitch allows 10s for a response; fanout typically takes 1-3s
+		// and is cut off at notifications.FanoutDeadline.
+		if _, err := h.FanoutService.HandleStreamOnline(ctx, eventID, event); err != nil {
 			log.Printf("[WEBHOOK_ERROR] Fanout failed: %v", err)
 		}
+
+	case twitch.SubscriptionTypeChannelUpdate:
+		var event notifications.ChannelUpdateEvent
+		if !decodeEvent(messageID, subType, payload.Event, &event) || !validEventUserID(messageID, subType, event.BroadcasterUserID) {
+			return
+		}
+
+		log.Printf("[WEBHOOK] channel.update: %s (%s)", event.BroadcasterUserName, event.BroadcasterUserID)
+
+		if err := h.FanoutService.HandleChannelUpdate(ctx, event); err != nil {
+			log.Printf("[WEBHOOK_ERROR] Channel update handling failed: %v", err)
+		}
+
+	case twitch.SubscriptionTypeStreamOffline:
+		var event notifications.StreamOfflineEvent
+		if !decodeEvent(messageID, subType, payload.Event, &event) || !validEventUserID(messageID, subType, event.BroadcasterUserID) {
+			return
+		}
+
+		log.Printf("[WEBHOOK] stream.offline: %s (%s)", event.BroadcasterUserName, event.BroadcasterUserID)
+
+		if err := h.FanoutService.HandleStreamOffline(ctx, event); err != nil {
+			log.Printf("[WEBHOOK_ERROR] Offline handling failed: %v", err)
+		}
+
+	case twitch.SubscriptionTypeAuthRevoke:
+		var event AuthRevokeEvent
+		if !decodeEvent(messageID, subType, payload.Event, &event) || !validEventUserID(messageID, subType, event.UserID) {
+			return
+		}
+
+		log.Printf("[WEBHOOK] user.authorization.revoke: %s (%s)", event.UserLogin, event.UserID)
+
+		if err := h.handleAuthorizationRevoke(ctx, event.UserID); err != nil {
+			log.Printf("[WEBHOOK_ERROR] Revoke handling failed for %s: %v", event.UserID, err)
+		}
+
+	default:
+		log.Printf("[WEBHOOK_WARN] Ignoring notification for unhandled type %s", subType)
+	}
+}
+
+// handleAuthorizationRevoke drops everything we hold on behalf of a streamer
+// who revoked our app: their encrypted tokens and their EventSub
+// subscriptions. The streamer stays linked to its guilds so a re-link picks
+// up where it left off.
+func (h *WebhookHandler) handleAuthorizationRevoke(ctx context.Context, broadcasterID string) error {
+	streamer, err := db.GetStreamerByBroadcasterID(ctx, broadcasterID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Not a streamer we track (e.g. a dashboard-only Twitch login)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := db.ClearStreamerTokens(ctx, streamer.ID); err != nil {
+		return err
+	}
+
+	subs, err := db.GetEventSubSubscriptions(ctx, streamer.ID)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if err := h.eventsub.DeleteSubscription(ctx, sub.SubscriptionID); err != nil {
+			log.Printf("[WEBHOOK_WARN] Failed to delete EventSub sub %s: %v", sub.SubscriptionID, err)
+			continue
+		}
+		if err := db.DeleteEventSubSubscription(ctx, sub.SubscriptionID); err != nil {
+			log.Printf("[WEBHOOK_WARN] Failed to delete subscription record %s: %v", sub.SubscriptionID, err)
+		}
+	}
+
+	log.Printf("[WEBHOOK] Authorization revoked by %s (%s): cleared tokens, removed %d subscriptions",
+		streamer.TwitchLogin, broadcasterID, len(subs))
+
+	h.FanoutService.AlertLinkBroken(ctx, streamer, notifications.LinkBrokenAuthRevoked)
+	return nil
+}
+
+// handleSubscriptionRevoked removes the record of a subscription Twitch
+// revoked. Losing stream.online means notifications have stopped, so guilds
+// are alerted; the alert is skipped if we had already dropped the record
+// ourselves (e.g. after user.authorization.revoke).
+func (h *WebhookHandler) handleSubscriptionRevoked(ctx context.Context, sub WebhookSubscription) error {
+	broadcasterID := getStringFromMap(sub.Condition, "broadcaster_user_id")
+	if broadcasterID == "" {
+		return nil
+	}
+
+	streamer, err := db.GetStreamerByBroadcasterID(ctx, broadcasterID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	subs, err := db.GetEventSubSubscriptions(ctx, streamer.ID)
+	if err != nil {
+		return err
+	}
+	tracked := false
+	for _, s := range subs {
+		if s.SubscriptionID == sub.ID {
+			tracked = true
+			break
+		}
+	}
+	if !tracked {
+		return nil
+	}
+
+	if err := db.DeleteEventSubSubscription(ctx, sub.ID); err != nil {
+		return err
+	}
+
+	if sub.Type == twitch.SubscriptionTypeStreamOnline {
+		h.FanoutService.AlertLinkBroken(ctx, streamer, notifications.LinkBrokenSubscriptionFailed)
+	}
+	return nil
+}
+
+// AuthRevokeEvent is the event of a user.authorization.revoke notification
+type AuthRevokeEvent struct {
+	ClientID  string `json:"client_id"`
+	UserID    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+}
+
+// decodeEvent unmarshals a notification's event into v, logging a
+// malformed-event warning if it doesn't fit.
+func decodeEvent(messageID, subType string, raw json.RawMessage, v interface{}) bool {
+	if err := json.Unmarshal(raw, v); err != nil {
+		log.Printf("[WEBHOOK_WARN] Malformed %s event in message %s: %v", subType, messageID, err)
+		return false
 	}
+	return true
+}
 
-	// Always return 200 OK to Twitch
-	w.WriteHeader(http.StatusOK)
+// validEventUserID checks the Twitch user ID an event is about, logging a
+// malformed-event warning if it isn't one.
+func validEventUserID(messageID, subType, id string) bool {
+	if !isTwitchUserID(id) {
+		log.Printf("[WEBHOOK_WARN] Malformed %s event in message %s: invalid user ID %q", subType, messageID, id)
+		return false
+	}
+	return true
+}
+
+// isTwitchUserID reports whether id looks like a Twitch user ID: a
+// non-empty string of digits.
+func isTwitchUserID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 // getStringFromMap safely extracts a string value from a map
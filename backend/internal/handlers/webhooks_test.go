@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/streammaxing/internal/services/notifications"
+	"github.com/yourusername/streammaxing/internal/services/twitch"
+)
+
+const testWebhookSecret = "test-webhook-secret"
+
+// twitchWebhook builds a signed EventSub webhook request.
+func twitchWebhook(t *testing.T, messageType, body string) *http.Request {
+	t.Helper()
+	twitch.SetWebhookSecret(testWebhookSecret)
+	t.Cleanup(func() { twitch.SetWebhookSecret("") })
+
+	messageID := "msg-" + messageType
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+	mac.Write([]byte(messageID + timestamp + body))
+
+	req := httptest.NewRequest("POST", "/webhooks/twitch", strings.NewReader(body))
+	req.Header.Set("Twitch-Eventsub-Message-Id", messageID)
+	req.Header.Set("Twitch-Eventsub-Message-Timestamp", timestamp)
+	req.Header.Set("Twitch-Eventsub-Message-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set(twitch.MessageTypeHeader, messageType)
+	return req
+}
+
+func TestHandleTwitchWebhookMessageTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		messageType string
+		body        string
+		wantCode    int
+		wantBody    string
+	}{
+		{
+			name:        "verification",
+			messageType: twitch.MessageTypeVerification,
+			body:        `{"challenge":"pogchamp-kappa-360noscope-vohiyo","subscription":{"id":"sub-1","type":"stream.online"}}`,
+			wantCode:    http.StatusOK,
+			wantBody:    "pogchamp-kappa-360noscope-vohiyo",
+		},
+		{
+			name:        "verification without challenge",
+			messageType: twitch.MessageTypeVerification,
+			body:        `{"subscription":{"id":"sub-1","type":"stream.online"}}`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			// Not one of ours (no broadcaster), so nothing to clean up
+			name:        "revocation",
+			messageType: twitch.MessageTypeRevocation,
+			body:        `{"subscription":{"id":"sub-1","type":"user.authorization.revoke","status":"authorization_revoked","condition":{"client_id":"abc"}}}`,
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "notification for an unhandled type",
+			messageType: twitch.MessageTypeNotification,
+			body:        `{"subscription":{"id":"sub-1","type":"channel.follow"},"event":{"user_id":"1234"}}`,
+			wantCode:    http.StatusOK,
+		},
+		{
+			// Acknowledged but never dispatched to the fanout
+			name:        "notification with a malformed event",
+			messageType: twitch.MessageTypeNotification,
+			body:        `{"subscription":{"id":"sub-1","type":"stream.online"},"event":{"broadcaster_user_id":"not-a-number"}}`,
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "notification without an event",
+			messageType: twitch.MessageTypeNotification,
+			body:        `{"subscription":{"id":"sub-1","type":"stream.online"}}`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "notification carrying a challenge",
+			messageType: twitch.MessageTypeNotification,
+			body:        `{"challenge":"abc","subscription":{"id":"sub-1","type":"stream.online"},"event":{"broadcaster_user_id":"1234"}}`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "unknown type",
+			messageType: "something_else",
+			body:        `{"subscription":{"id":"sub-1","type":"stream.online"}}`,
+			wantCode:    http.StatusBadRequest,
+		},
+	}
+
+	h := NewWebhookHandler(nil, nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.HandleTwitchWebhook(rec, twitchWebhook(t, tt.messageType, tt.body))
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %q)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleTwitchWebhookRejectsBadSignature(t *testing.T) {
+	req := twitchWebhook(t, twitch.MessageTypeVerification, `{"challenge":"abc"}`)
+	req.Header.Set("Twitch-Eventsub-Message-Signature", "sha256=00")
+
+	rec := httptest.NewRecorder()
+	NewWebhookHandler(nil, nil, nil).HandleTwitchWebhook(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+}
+
+// Sample notification payloads from the Twitch EventSub reference.
+const (
+	sampleStreamOnline = `{
+		"subscription": {"id": "f1c2a387-161a-49f9-a165-0f21d7a4e1c4", "type": "stream.online", "version": "1", "status": "enabled", "cost": 0,
+			"condition": {"broadcaster_user_id": "1337"},
+			"transport": {"method": "webhook", "callback": "https://example.com/webhooks/callback"},
+			"created_at": "2019-11-16T10:11:12.634234626Z"},
+		"event": {"id": "9001", "broadcaster_user_id": "1337", "broadcaster_user_login": "cool_user", "broadcaster_user_name": "Cool_User",
+			"type": "live", "started_at": "2020-10-11T10:11:12.123Z"}
+	}`
+	sampleStreamOffline = `{
+		"subscription": {"id": "f1c2a387-161a-49f9-a165-0f21d7a4e1c4", "type": "stream.offline", "version": "1", "status": "enabled", "cost": 0,
+			"condition": {"broadcaster_user_id": "1337"},
+			"transport": {"method": "webhook", "callback": "https://example.com/webhooks/callback"},
+			"created_at": "2019-11-16T10:11:12.634234626Z"},
+		"event": {"broadcaster_user_id": "1337", "broadcaster_user_login": "cool_user", "broadcaster_user_name": "Cool_User"}
+	}`
+	sampleChannelUpdate = `{
+		"subscription": {"id": "f1c2a387-161a-49f9-a165-0f21d7a4e1c4", "type": "channel.update", "version": "2", "status": "enabled", "cost": 0,
+			"condition": {"broadcaster_user_id": "1337"},
+			"transport": {"method": "webhook", "callback": "https://example.com/webhooks/callback"},
+			"created_at": "2023-06-29T17:20:33.860897266Z"},
+		"event": {"broadcaster_user_id": "1337", "broadcaster_user_login": "cool_user", "broadcaster_user_name": "Cool_User",
+			"title": "Best Stream Ever", "language": "en", "category_id": "12453", "category_name": "Grand Theft Auto",
+			"content_classification_labels": ["MatureGame"]}
+	}`
+	sampleAuthRevoke = `{
+		"subscription": {"id": "f1c2a387-161a-49f9-a165-0f21d7a4e1c4", "type": "user.authorization.revoke", "version": "1", "status": "enabled", "cost": 1,
+			"condition": {"client_id": "crq72vsaoijkc83xx42hz6i37"},
+			"transport": {"method": "webhook", "callback": "https://example.com/webhooks/callback"},
+			"created_at": "2019-11-16T10:11:12.634234626Z"},
+		"event": {"client_id": "crq72vsaoijkc83xx42hz6i37", "user_id": "1337", "user_login": "cool_user", "user_name": "Cool_User"}
+	}`
+)
+
+func TestDecodeSampleEvents(t *testing.T) {
+	decode := func(t *testing.T, body string, v interface{}) WebhookPayload {
+		t.Helper()
+		var payload WebhookPayload
+		if err := json.Unmarshal([]byte(body), &payload); err != nil {
+			t.Fatalf("unmarshal payload: %v", err)
+		}
+		if !decodeEvent("msg-1", payload.Subscription.Type, payload.Event, v) {
+			t.Fatalf("decodeEvent(%s) failed", payload.Subscription.Type)
+		}
+		return payload
+	}
+
+	t.Run("stream.online", func(t *testing.T) {
+		var event notifications.StreamOnlineEvent
+		payload := decode(t, sampleStreamOnline, &event)
+		want := notifications.StreamOnlineEvent{ID: "9001", BroadcasterUserID: "1337", BroadcasterUserLogin: "cool_user",
+			BroadcasterUserName: "Cool_User", Type: "live", StartedAt: "2020-10-11T10:11:12.123Z"}
+		if event != want {
+			t.Errorf("event = %+v, want %+v", event, want)
+		}
+		if payload.Subscription.Type != twitch.SubscriptionTypeStreamOnline || getStringFromMap(payload.Subscription.Condition, "broadcaster_user_id") != "1337" {
+			t.Errorf("subscription = %+v", payload.Subscription)
+		}
+	})
+
+	t.Run("stream.offline", func(t *testing.T) {
+		var event notifications.StreamOfflineEvent
+		decode(t, sampleStreamOffline, &event)
+		want := notifications.StreamOfflineEvent{BroadcasterUserID: "1337", BroadcasterUserLogin: "cool_user", BroadcasterUserName: "Cool_User"}
+		if event != want {
+			t.Errorf("event = %+v, want %+v", event, want)
+		}
+	})
+
+	t.Run("channel.update", func(t *testing.T) {
+		var event notifications.ChannelUpdateEvent
+		decode(t, sampleChannelUpdate, &event)
+		want := notifications.ChannelUpdateEvent{BroadcasterUserID: "1337", BroadcasterUserLogin: "cool_user", BroadcasterUserName: "Cool_User",
+			Title: "Best Stream Ever", CategoryID: "12453", CategoryName: "Grand Theft Auto"}
+		if event != want {
+			t.Errorf("event = %+v, want %+v", event, want)
+		}
+	})
+
+	t.Run("user.authorization.revoke", func(t *testing.T) {
+		var event AuthRevokeEvent
+		decode(t, sampleAuthRevoke, &event)
+		want := AuthRevokeEvent{ClientID: "crq72vsaoijkc83xx42hz6i37", UserID: "1337", UserLogin: "cool_user", UserName: "Cool_User"}
+		if event != want {
+			t.Errorf("event = %+v, want %+v", event, want)
+		}
+	})
+}
+
+func TestDecodeEventSchemaMismatch(t *testing.T) {
+	var event notifications.StreamOnlineEvent
+	if decodeEvent("msg-1", "stream.online", json.RawMessage(`{"broadcaster_user_id": 1337}`), &event) {
+		t.Error("decoded a numeric broadcaster_user_id")
+	}
+	if decodeEvent("msg-1", "stream.online", json.RawMessage(`["not", "an", "object"]`), &event) {
+		t.Error("decoded an array event")
+	}
+}
+
+func TestIsTwitchUserID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"1337":  true,
+		"0":     true,
+		"":      false,
+		"12a4":  false,
+		"-1":    false,
+		"1337 ": false,
+		"１２３":   false,
+	} {
+		if got := isTwitchUserID(id); got != want {
+			t.Errorf("isTwitchUserID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -17,6 +17,7 @@ const (
 	UserIDKey   ContextKey = "user_id"
 	UsernameKey ContextKey = "username"
 	JTIKey      ContextKey = "jti"
+	ClaimsKey   ContextKey = "claims"
 )
 
 // sessionService is the shared session service used by AuthMiddleware.
@@ -89,6 +90,7 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			ctx = context.WithValue(ctx, UserIDKey, claims.UserID)
 			ctx = context.WithValue(ctx, UsernameKey, claims.Username)
 			ctx = context.WithValue(ctx, JTIKey, claims.JTI)
+			ctx = context.WithValue(ctx, ClaimsKey, claims)
 			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
@@ -145,3 +147,10 @@ func GetJTI(r *http.Request) string {
 	}
 	return jti
 }
+
+// GetClaims returns the validated session claims, or nil when the request
+// was authenticated by the legacy fallback
+func GetClaims(r *http.Request) *auth.Claims {
+	claims, _ := r.Context().Value(ClaimsKey).(*auth.Claims)
+	return claims
+}
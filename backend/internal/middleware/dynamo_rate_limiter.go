@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// dynamoLimiterTimeout bounds each DynamoDB call so a slow table doesn't
+// hold up requests; on timeout the in-memory fallback decides.
+const dynamoLimiterTimeout = 500 * time.Millisecond
+
+// DynamoRateLimiter provides per-key rate limiting shared across Lambda
+// instances, using a sliding window over DynamoDB atomic counters.
+//
+// Each key gets one item per fixed window ("<name>:<key>#<window start
+// unix>", so several limiters can share a table) with
+// a "count" attribute and an "expires_at" TTL attribute. A request is allowed
+// while the current window's count plus the previous window's count,
+// weighted by how much of it still overlaps the sliding window, stays within
+// the limit.
+//
+// The table needs a string partition key named "key" and TTL enabled on
+// "expires_at". If DynamoDB fails, the in-memory fallback limiter decides.
+type DynamoRateLimiter struct {
+	client    *dynamodb.Client
+	tableName string
+	name      string
+	limit     int
+	window    time.Duration
+	fallback  *RateLimiter
+}
+
+// NewDynamoRateLimiter creates a limiter allowing limit requests per key per
+// sliding window, stored in tableName under name. fallback is used when
+// DynamoDB is unreachable.
+func NewDynamoRateLimiter(tableName, name string, limit int, window time.Duration, fallback *RateLimiter) (*DynamoRateLimiter, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &DynamoRateLimiter{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+		name:      name,
+		limit:     limit,
+		window:    window,
+		fallback:  fallback,
+	}, nil
+}
+
+// UserRateLimitMiddleware applies per-user rate limiting.
+// Uses user_id from context if available, falls back to IP address.
+func (rl *DynamoRateLimiter) UserRateLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := rateLimitKey(r)
+
+		allowed, err := rl.allow(r.Context(), key)
+		if err != nil {
+			log.Printf("[RATE_LIMIT_WARN] DynamoDB limiter failed, using in-memory limiter: %v", err)
+			allowed = rl.fallback.getLimiter(key).Allow()
+		}
+
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rl.window.Seconds()))))
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// allow counts a request against key and reports whether it is within the
+// sliding window limit.
+func (rl *DynamoRateLimiter) allow(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, dynamoLimiterTimeout)
+	defer cancel()
+
+	now := time.Now()
+	windowStart := now.Truncate(rl.window)
+
+	current, err := rl.increment(ctx, key, windowStart)
+	if err != nil {
+		return false, err
+	}
+	if current > rl.limit {
+		return false, nil
+	}
+
+	previous, err := rl.count(ctx, key, windowStart.Add(-rl.window))
+	if err != nil {
+		return false, err
+	}
+
+	overlap := 1 - float64(now.Sub(windowStart))/float64(rl.window)
+	return float64(previous)*overlap+float64(current) <= float64(rl.limit), nil
+}
+
+// itemKey is the partition key of key's counter for the window starting at
+// windowStart.
+func (rl *DynamoRateLimiter) itemKey(key string, windowStart time.Time) string {
+	return rl.name + ":" + key + "#" + strconv.FormatInt(windowStart.Unix(), 10)
+}
+
+// increment atomically adds one to key's counter for the window and returns
+// the new count. The item expires once it can no longer be a previous window.
+func (rl *DynamoRateLimiter) increment(ctx context.Context, key string, windowStart time.Time) (int, error) {
+	expiresAt := windowStart.Add(2 * rl.window).Unix()
+	out, err := rl.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(rl.tableName),
+		Key: map[string]types.AttributeValue{
+			"key": &types.AttributeValueMemberS{Value: rl.itemKey(key, windowStart)},
+		},
+		UpdateExpression: aws.String("ADD #count :one SET #expires_at = if_not_exists(#expires_at, :expires_at)"),
+		ExpressionAttributeNames: map[string]string{
+			"#count":      "count",
+			"#expires_at": "expires_at",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":        &types.AttributeValueMemberN{Value: "1"},
+			":expires_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt, 10)},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment rate limit counter: %w", err)
+	}
+	return countAttribute(out.Attributes)
+}
+
+// count returns key's counter for the window, 0 if there is none.
+func (rl *DynamoRateLimiter) count(ctx context.Context, key string, windowStart time.Time) (int, error) {
+	out, err := rl.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(rl.tableName),
+		Key: map[string]types.AttributeValue{
+			"key": &types.AttributeValueMemberS{Value: rl.itemKey(key, windowStart)},
+		},
+		ProjectionExpression:     aws.String("#count"),
+		ExpressionAttributeNames: map[string]string{"#count": "count"},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read rate limit counter: %w", err)
+	}
+	if out.Item == nil {
+		return 0, nil
+	}
+	return countAttribute(out.Item)
+}
+
+// countAttribute reads the numeric "count" attribute of an item.
+func countAttribute(item map[string]types.AttributeValue) (int, error) {
+	n, ok := item["count"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("rate limit counter has no numeric count")
+	}
+	return strconv.Atoi(n.Value)
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/yourusername/streammaxing/internal/db"
+	"github.com/yourusername/streammaxing/internal/services/auth"
+)
+
+// ServiceNameKey holds the calling service's name in the request context.
+const ServiceNameKey ContextKey = "service_name"
+
+// serviceTokens validates service tokens for InternalAuthMiddleware.
+// Set via SetServiceTokenService at startup.
+var serviceTokens *auth.ServiceTokenService
+
+// SetServiceTokenService configures the service token validator used by
+// InternalAuthMiddleware. Without one, internal endpoints reject every call.
+func SetServiceTokenService(s *auth.ServiceTokenService) {
+	serviceTokens = s
+}
+
+// InternalAuthMiddleware admits only calls from our own services, carrying a
+// service token in auth.ServiceTokenHeader. User sessions are not accepted. Every
+// call is audit-logged with the service name as the actor.
+func InternalAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if serviceTokens == nil {
+			log.Printf("[INTERNAL_AUTH] Service tokens not configured, rejecting %s", r.URL.Path)
+			http.Error(w, "Internal endpoints not configured", http.StatusServiceUnavailable)
+			return
+		}
+
+		claims, err := serviceTokens.Validate(r.Context(), r.Header.Get(auth.ServiceTokenHeader))
+		if err != nil {
+			reason := "invalid_service_token"
+			if errors.Is(err, auth.ErrServiceTokenReplayed) {
+				reason = "replayed_service_token"
+			}
+			if securityLogger != nil {
+				securityLogger.LogAuthFailure(r.Context(), "", r.RemoteAddr, reason)
+			}
+			db.InsertAuditLog(r.Context(), "", "internal_call", "endpoint", r.URL.Path, map[string]interface{}{
+				"method": r.Method,
+				"reason": reason,
+			}, r.RemoteAddr, false)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		service := claims.Subject
+		db.InsertAuditLog(r.Context(), "service:"+service, "internal_call", "endpoint", r.URL.Path, map[string]interface{}{
+			"method": r.Method,
+			"jti":    claims.ID,
+		}, r.RemoteAddr, true)
+
+		ctx := context.WithValue(r.Context(), ServiceNameKey, service)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// CronSecretHeader carries the shared secret of scheduled callers
+// (EventBridge API destinations), which send a fixed header and so can't
+// present single-use service tokens
+const CronSecretHeader = "X-Cron-Secret"
+
+// cronSecret is accepted by CronAuthMiddleware. Set via SetCronSecret at
+// startup; empty disables cron access.
+var cronSecret string
+
+// SetCronSecret configures the shared secret accepted by CronAuthMiddleware.
+func SetCronSecret(secret string) {
+	cronSecret = secret
+}
+
+// CronAuthMiddleware admits scheduled calls carrying the cron secret in
+// CronSecretHeader, as service "cron", and otherwise falls back to
+// InternalAuthMiddleware. Only for routes safe to run on a schedule.
+func CronAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	internal := InternalAuthMiddleware(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		provided := r.Header.Get(CronSecretHeader)
+		if provided == "" {
+			internal(w, r)
+			return
+		}
+
+		if cronSecret == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(cronSecret)) != 1 {
+			if securityLogger != nil {
+				securityLogger.LogAuthFailure(r.Context(), "", r.RemoteAddr, "invalid_cron_secret")
+			}
+			db.InsertAuditLog(r.Context(), "", "internal_call", "endpoint", r.URL.Path, map[string]interface{}{
+				"method": r.Method,
+				"reason": "invalid_cron_secret",
+			}, r.RemoteAddr, false)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		db.InsertAuditLog(r.Context(), "service:cron", "internal_call", "endpoint", r.URL.Path, map[string]interface{}{
+			"method": r.Method,
+		}, r.RemoteAddr, true)
+
+		ctx := context.WithValue(r.Context(), ServiceNameKey, "cron")
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// GetServiceName returns the calling service's name, set by
+// InternalAuthMiddleware.
+func GetServiceName(r *http.Request) string {
+	if v, ok := r.Context().Value(ServiceNameKey).(string); ok {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourusername/streammaxing/internal/services/auth"
+)
+
+func useServiceTokens(t *testing.T) *auth.ServiceTokenService {
+	t.Helper()
+	s, err := auth.NewServiceTokenService("test-internal-service-key-0123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := serviceTokens
+	SetServiceTokenService(s)
+	t.Cleanup(func() { SetServiceTokenService(prev) })
+	return s
+}
+
+func internalCall(h http.HandlerFunc, token string) int {
+	req := httptest.NewRequest("POST", "/internal/cleanup", nil)
+	req.Header.Set(auth.ServiceTokenHeader, token)
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec.Code
+}
+
+func TestInternalAuthRejectsReplayedToken(t *testing.T) {
+	tokens := useServiceTokens(t)
+	var services []string
+	h := InternalAuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		services = append(services, GetServiceName(r))
+	})
+
+	token, err := tokens.Mint("cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := internalCall(h, token); code != http.StatusOK {
+		t.Fatalf("first call status = %d, want 200", code)
+	}
+	if code := internalCall(h, token); code != http.StatusUnauthorized {
+		t.Errorf("replayed call status = %d, want 401", code)
+	}
+	if len(services) != 1 || services[0] != "cleanup" {
+		t.Errorf("handler saw services %v, want [cleanup]", services)
+	}
+}
+
+func TestInternalAuthRejectsExpiredToken(t *testing.T) {
+	tokens := useServiceTokens(t)
+	tokens.TTL = -time.Second
+	h := InternalAuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler ran for an expired token")
+	})
+
+	token, err := tokens.Mint("cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := internalCall(h, token); code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", code)
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/streammaxing/internal/db"
+)
+
+// loadShedAcquireWait is how long a read request may wait for a database
+// connection when the pool is fully in use before it is turned away.
+const loadShedAcquireWait = 250 * time.Millisecond
+
+// LoadShedMiddleware reserves the database connection a GET/HEAD request's
+// queries run on (db.ConnPool.ReserveConn). When the pool is exhausted and
+// none frees up within loadShedAcquireWait it answers 503 with Retry-After,
+// instead of letting the request queue behind a notification burst. Writes
+// pass through untouched; routes that must always get a connection (the
+// Twitch webhook) are simply not wrapped.
+func LoadShedMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if db.Pool != nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+			ctx, release, err := db.Pool.ReserveConn(r.Context(), loadShedAcquireWait)
+			if errors.Is(err, db.ErrPoolExhausted) {
+				log.Printf("[LOAD_SHED] Pool exhausted, rejecting %s %s", r.Method, r.URL.Path)
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			if err == nil {
+				defer release()
+				r = r.WithContext(ctx)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
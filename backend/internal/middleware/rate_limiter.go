@@ -8,9 +8,15 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// Limiter is per-user rate limiting middleware. RateLimiter keeps its state
+// per Lambda instance; DynamoRateLimiter shares it across instances.
+type Limiter interface {
+	UserRateLimitMiddleware(next http.HandlerFunc) http.HandlerFunc
+}
+
 // RateLimiter provides per-key rate limiting using the token bucket algorithm.
 // NOTE: In AWS Lambda, each instance maintains its own rate limiter state.
-// For stricter cross-instance rate limiting, consider using DynamoDB or ElastiCache.
+// For stricter cross-instance rate limiting, use DynamoRateLimiter.
 type RateLimiter struct {
 	limiters map[string]*rateLimiterEntry
 	mu       sync.RWMutex
@@ -37,6 +43,20 @@ func NewRateLimiter(requestsPerSecond int, burst int) *RateLimiter {
 	return rl
 }
 
+// NewRateLimiterEvery creates a rate limiter allowing one request per interval
+// with the given burst, for routes that need limits below one per second.
+func NewRateLimiterEvery(interval time.Duration, burst int) *RateLimiter {
+	rl := &RateLimiter{
+		limiters: make(map[string]*rateLimiterEntry),
+		rps:      rate.Every(interval),
+		burst:    burst,
+	}
+
+	go rl.cleanupLoop()
+
+	return rl
+}
+
 // getLimiter returns (or creates) a rate limiter for the given key.
 func (rl *RateLimiter) getLimiter(key string) *rate.Limiter {
 	rl.mu.RLock()
@@ -67,16 +87,20 @@ func (rl *RateLimiter) getLimiter(key string) *rate.Limiter {
 	return limiter
 }
 
+// rateLimitKey returns the per-user limiter key: "user:<id>" when
+// authenticated, "ip:<addr>" otherwise.
+func rateLimitKey(r *http.Request) string {
+	if userID := GetUserID(r); userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + r.RemoteAddr // Fallback to IP for unauthenticated requests
+}
+
 // UserRateLimitMiddleware applies per-user rate limiting.
 // Uses user_id from context if available, falls back to IP address.
 func (rl *RateLimiter) UserRateLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		key := GetUserID(r)
-		if key == "" {
-			key = r.RemoteAddr // Fallback to IP for unauthenticated requests
-		}
-
-		limiter := rl.getLimiter("user:" + key)
+		limiter := rl.getLimiter(rateLimitKey(r))
 
 		if !limiter.Allow() {
 			w.Header().Set("Retry-After", "60")
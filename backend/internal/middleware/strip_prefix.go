@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// StripPrefixMiddleware removes an API Gateway stage prefix (e.g. "/prod")
+// from the request path before routing, so "/prod/api/health" matches the
+// "/api/health" route. Paths without the prefix pass through unchanged, and
+// an empty prefix makes it a no-op.
+func StripPrefixMiddleware(prefix string, next http.HandlerFunc) http.HandlerFunc {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		path, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok || (path != "" && path[0] != '/') {
+			// Not prefixed, or only sharing a leading substring ("/production")
+			next.ServeHTTP(w, r)
+			return
+		}
+		if path == "" {
+			path = "/"
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = path
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	}
+}
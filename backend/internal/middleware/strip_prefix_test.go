@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStripPrefixMiddleware(t *testing.T) {
+	tests := []struct {
+		prefix, path, want string
+	}{
+		{"/prod", "/prod/api/health", "/api/health"},
+		{"prod", "/prod/api/health", "/api/health"},
+		{"/prod/", "/prod/api/health", "/api/health"},
+		{"/prod", "/prod", "/"},
+		{"/prod", "/api/health", "/api/health"},
+		{"/prod", "/production/api/health", "/production/api/health"},
+		{"", "/prod/api/health", "/prod/api/health"},
+		{"/", "/api/health", "/api/health"},
+	}
+	for _, tt := range tests {
+		var got string
+		h := StripPrefixMiddleware(tt.prefix, func(w http.ResponseWriter, r *http.Request) {
+			got = r.URL.Path
+		})
+		h(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+		if got != tt.want {
+			t.Errorf("prefix %q: %s routed as %q, want %q", tt.prefix, tt.path, got, tt.want)
+		}
+	}
+}
@@ -1,29 +1,86 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
 )
 
+// Defaults for WebhookProtection's soft rate limiting.
+const (
+	// DefaultWebhookMaxQueueDelay keeps queued webhooks well inside the few
+	// seconds Twitch waits for a response.
+	DefaultWebhookMaxQueueDelay = 2 * time.Second
+	// DefaultWebhookMaxQueued bounds how many webhooks wait at once.
+	DefaultWebhookMaxQueued = 500
+
+	// DefaultWebhookDedupTTL is how long processed message IDs are kept
+	// for duplicate detection.
+	DefaultWebhookDedupTTL = 15 * time.Minute
+	// DefaultWebhookCleanupInterval is how often expired message IDs are
+	// removed.
+	DefaultWebhookCleanupInterval = 1 * time.Minute
+)
+
 // WebhookProtection provides rate limiting and idempotency for webhook endpoints.
+//
+// Rate limiting is soft: Twitch counts a non-2xx response as a failed
+// delivery toward disabling the subscription, so webhooks over the rate are
+// delayed until the limiter has room rather than rejected. Only when the wait
+// would exceed MaxQueueDelay, or MaxQueued webhooks are already waiting, is
+// a webhook shed with 429.
 type WebhookProtection struct {
 	messageIDs  map[string]time.Time // messageID -> processedAt
 	mu          sync.RWMutex
 	rateLimiter *rate.Limiter
+	queued      atomic.Int64
+	dedupTTL    time.Duration
+
+	// Dedup counters since cold start. A rising duplicate share means
+	// Twitch is retrying because we respond too slowly.
+	fresh      atomic.Int64
+	duplicates atomic.Int64
+
+	// MaxQueueDelay is the longest a webhook is delayed before being shed
+	MaxQueueDelay time.Duration
+	// MaxQueued is the most webhooks delayed at once
+	MaxQueued int
+
+	// SourceAllowed, if set, is checked against the client IP before
+	// anything else; requests it rejects get 403 without their body read.
+	SourceAllowed func(ip string) bool
 }
 
 // NewWebhookProtection creates a new webhook protection handler.
 func NewWebhookProtection() *WebhookProtection {
+	return NewWebhookProtectionWithTTL(DefaultWebhookDedupTTL, DefaultWebhookCleanupInterval)
+}
+
+// NewWebhookProtectionWithTTL creates a webhook protection handler that
+// remembers message IDs for dedupTTL, removing expired ones every
+// cleanupInterval. Non-positive values use the defaults.
+func NewWebhookProtectionWithTTL(dedupTTL, cleanupInterval time.Duration) *WebhookProtection {
+	if dedupTTL <= 0 {
+		dedupTTL = DefaultWebhookDedupTTL
+	}
+	if cleanupInterval <= 0 {
+		cleanupInterval = DefaultWebhookCleanupInterval
+	}
+
 	wp := &WebhookProtection{
-		messageIDs:  make(map[string]time.Time),
-		rateLimiter: rate.NewLimiter(rate.Limit(100), 200), // 100 webhooks/sec, burst 200
+		messageIDs:    make(map[string]time.Time),
+		rateLimiter:   rate.NewLimiter(rate.Limit(100), 200), // 100 webhooks/sec, burst 200
+		dedupTTL:      dedupTTL,
+		MaxQueueDelay: DefaultWebhookMaxQueueDelay,
+		MaxQueued:     DefaultWebhookMaxQueued,
 	}
 
 	// Cleanup old message IDs periodically
-	go wp.cleanupLoop()
+	go wp.cleanupLoop(cleanupInterval)
 
 	return wp
 }
@@ -31,8 +88,16 @@ func NewWebhookProtection() *WebhookProtection {
 // Middleware applies webhook rate limiting and idempotency checking.
 func (wp *WebhookProtection) Middleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Rate limiting
-		if !wp.rateLimiter.Allow() {
+		// Source IP check (defense in depth; signatures are still verified)
+		if wp.SourceAllowed != nil && !wp.SourceAllowed(r.RemoteAddr) {
+			log.Printf("[WEBHOOK_WARN] Rejected webhook from non-Twitch IP %s", r.RemoteAddr)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		// Rate limiting (delays bursts, sheds only when overwhelmed)
+		if !wp.wait(r) {
+			log.Printf("[WEBHOOK_WARN] Webhook queue full, shedding message %s", r.Header.Get("Twitch-Eventsub-Message-Id"))
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
@@ -41,10 +106,12 @@ func (wp *WebhookProtection) Middleware(next http.HandlerFunc) http.HandlerFunc
 		messageID := r.Header.Get("Twitch-Eventsub-Message-Id")
 		if messageID != "" {
 			if wp.isDuplicate(messageID) {
+				wp.duplicates.Add(1)
 				// Already processed, return success to prevent Twitch from retrying
 				w.WriteHeader(http.StatusOK)
 				return
 			}
+			wp.fresh.Add(1)
 			// Mark as being processed
 			wp.markProcessed(messageID)
 		}
@@ -53,6 +120,58 @@ func (wp *WebhookProtection) Middleware(next http.HandlerFunc) http.HandlerFunc
 	}
 }
 
+// wait blocks until the rate limiter admits the request. It reports false,
+// without waiting, when the delay would exceed MaxQueueDelay or the queue is
+// full, and false if the request is canceled while waiting.
+func (wp *WebhookProtection) wait(r *http.Request) bool {
+	res := wp.rateLimiter.Reserve()
+	delay := res.Delay()
+	if delay == 0 {
+		return true
+	}
+	if delay > wp.MaxQueueDelay {
+		res.Cancel()
+		return false
+	}
+	if wp.queued.Add(1) > int64(wp.MaxQueued) {
+		wp.queued.Add(-1)
+		res.Cancel()
+		return false
+	}
+	defer wp.queued.Add(-1)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		res.Cancel()
+		return false
+	}
+}
+
+// WebhookStats counts webhooks seen by WebhookProtection since cold start
+type WebhookStats struct {
+	Fresh      int64 `json:"fresh"`
+	Duplicates int64 `json:"duplicates"`
+	// DedupRate is Duplicates / (Fresh + Duplicates), 0 with no traffic
+	DedupRate float64 `json:"dedup_rate"`
+}
+
+// Stats returns the dedup counters of this instance.
+func (wp *WebhookProtection) Stats() WebhookStats {
+	return newWebhookStats(wp.fresh.Load(), wp.duplicates.Load())
+}
+
+func newWebhookStats(fresh, duplicates int64) WebhookStats {
+	stats := WebhookStats{Fresh: fresh, Duplicates: duplicates}
+	if total := fresh + duplicates; total > 0 {
+		stats.DedupRate = float64(duplicates) / float64(total)
+	}
+	return stats
+}
+
 // isDuplicate checks if a message has already been processed.
 func (wp *WebhookProtection) isDuplicate(messageID string) bool {
 	wp.mu.RLock()
@@ -68,19 +187,32 @@ func (wp *WebhookProtection) markProcessed(messageID string) {
 	wp.messageIDs[messageID] = time.Now()
 }
 
-// cleanupLoop removes old message IDs every minute to prevent memory leaks.
-func (wp *WebhookProtection) cleanupLoop() {
-	ticker := time.NewTicker(1 * time.Minute)
+// cleanupLoop removes expired message IDs every interval to prevent memory
+// leaks, and logs the dedup rate over the past interval.
+func (wp *WebhookProtection) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	var last WebhookStats
 	for range ticker.C {
-		wp.mu.Lock()
-		cutoff := time.Now().Add(-15 * time.Minute)
-		for id, timestamp := range wp.messageIDs {
-			if timestamp.Before(cutoff) {
-				delete(wp.messageIDs, id)
-			}
+		now := wp.Stats()
+		if delta := newWebhookStats(now.Fresh-last.Fresh, now.Duplicates-last.Duplicates); delta.Duplicates > 0 {
+			log.Printf("[WEBHOOK] Last %v: fresh=%d duplicates=%d dedup_rate=%.1f%%", interval, delta.Fresh, delta.Duplicates, delta.DedupRate*100)
+		}
+		last = now
+		wp.removeExpired(time.Now())
+	}
+}
+
+// removeExpired forgets message IDs processed more than the dedup TTL
+// before now.
+func (wp *WebhookProtection) removeExpired(now time.Time) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	cutoff := now.Add(-wp.dedupTTL)
+	for id, timestamp := range wp.messageIDs {
+		if timestamp.Before(cutoff) {
+			delete(wp.messageIDs, id)
 		}
-		wp.mu.Unlock()
 	}
 }
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// burst sends n concurrent webhooks through wp and counts the responses by
+// status code.
+func burst(wp *WebhookProtection, n int) map[int]int {
+	var handled atomic.Int64
+	h := wp.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		handled.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h(rec, httptest.NewRequest("POST", "/webhooks/twitch", nil))
+			codes <- rec.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	counts := make(map[int]int)
+	for code := range codes {
+		counts[code]++
+	}
+	return counts
+}
+
+func TestWebhookProtectionQueuesBursts(t *testing.T) {
+	wp := NewWebhookProtection()
+
+	// 300 at once: 200 pass on the burst allowance, the rest wait under a
+	// second for the 100/s refill, within MaxQueueDelay
+	counts := burst(wp, 300)
+	if counts[http.StatusOK] != 300 {
+		t.Errorf("responses = %v, want 300 OK", counts)
+	}
+}
+
+func TestWebhookProtectionShedsWhenQueueFull(t *testing.T) {
+	wp := NewWebhookProtection()
+	wp.MaxQueued = 0
+
+	counts := burst(wp, 300)
+	if counts[http.StatusOK] < 200 || counts[http.StatusTooManyRequests] == 0 {
+		t.Errorf("responses = %v, want the burst allowance OK and the rest 429", counts)
+	}
+	if counts[http.StatusOK]+counts[http.StatusTooManyRequests] != 300 {
+		t.Errorf("responses = %v, want only 200s and 429s", counts)
+	}
+}
+
+func TestWebhookProtectionRemovesExpiredIDs(t *testing.T) {
+	wp := NewWebhookProtectionWithTTL(10*time.Minute, time.Hour)
+	now := time.Now()
+	wp.messageIDs["old"] = now.Add(-11 * time.Minute)
+	wp.messageIDs["fresh"] = now.Add(-9 * time.Minute)
+	wp.messageIDs["new"] = now
+
+	wp.removeExpired(now)
+
+	for id, want := range map[string]bool{"old": false, "fresh": true, "new": true} {
+		if got := wp.isDuplicate(id); got != want {
+			t.Errorf("isDuplicate(%q) = %v after cleanup, want %v", id, got, want)
+		}
+	}
+}
+
+func TestWebhookProtectionTTLDefaults(t *testing.T) {
+	if wp := NewWebhookProtectionWithTTL(0, 0); wp.dedupTTL != DefaultWebhookDedupTTL {
+		t.Errorf("dedupTTL = %v, want %v", wp.dedupTTL, DefaultWebhookDedupTTL)
+	}
+}
+
+func TestWebhookProtectionSkipsDuplicates(t *testing.T) {
+	wp := NewWebhookProtection()
+	var handled int
+	h := wp.Middleware(func(w http.ResponseWriter, r *http.Request) { handled++ })
+
+	for range 2 {
+		req := httptest.NewRequest("POST", "/webhooks/twitch", nil)
+		req.Header.Set("Twitch-Eventsub-Message-Id", "msg-1")
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200", rec.Code)
+		}
+	}
+	if handled != 1 {
+		t.Errorf("handler ran %d times, want 1", handled)
+	}
+	if stats := wp.Stats(); stats.Fresh != 1 || stats.Duplicates != 1 {
+		t.Errorf("stats = %+v, want 1 fresh and 1 duplicate", stats)
+	}
+}
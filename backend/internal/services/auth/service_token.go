@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Service tokens authenticate calls between our own Lambdas (cleanup cron,
+// async worker) to internal endpoints. They are signed with a dedicated key,
+// never the user session secret, so a leaked user secret can't mint them and
+// a user session can't pass InternalAuthMiddleware.
+const (
+	// ServiceTokenIssuer and ServiceTokenAudience mark a token as a service
+	// token rather than a user session
+	ServiceTokenIssuer   = "streammaxing-internal"
+	ServiceTokenAudience = "streammaxing-internal-api"
+	// DefaultServiceTokenTTL keeps tokens short-lived; callers mint one per call
+	DefaultServiceTokenTTL = 1 * time.Minute
+	// MaxServiceTokenTTL is the longest lifetime Validate accepts, however
+	// the token was minted
+	MaxServiceTokenTTL = 5 * time.Minute
+	// minServiceKeyLength matches the 256-bit minimum for HS256 keys
+	minServiceKeyLength = 32
+	// ServiceTokenHeader carries service tokens, kept apart from the
+	// session cookie user auth uses
+	ServiceTokenHeader = "X-Internal-Token"
+)
+
+// ErrInvalidServiceToken is returned for any service token that fails
+// validation; ErrServiceTokenReplayed wraps it for a token already used.
+var (
+	ErrInvalidServiceToken  = errors.New("invalid service token")
+	ErrServiceTokenReplayed = fmt.Errorf("%w: token already used", ErrInvalidServiceToken)
+)
+
+// ServiceClaims are the claims of a service token. Subject is the calling
+// service's name (e.g. "cleanup", "worker").
+type ServiceClaims struct {
+	jwt.RegisteredClaims
+}
+
+// NonceStore records used service token nonces where every instance sees
+// them, so a token accepted by one Lambda instance is rejected by the rest.
+type NonceStore interface {
+	// UseNonce records nonce until expiresAt, reporting false if it was
+	// already recorded
+	UseNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// ServiceTokenService mints and validates service tokens. Each token's jti
+// is a single-use nonce: Validate records it in NonceStore until the token
+// expires and rejects it if seen again. Without a NonceStore, or while it
+// fails, nonces are only tracked in this instance's memory, and a captured
+// token could be replayed against another warm instance until it expires.
+type ServiceTokenService struct {
+	key []byte
+	// TTL is how long minted tokens are valid
+	TTL time.Duration
+	// NonceStore shares used nonces across instances; nil keeps them local
+	NonceStore NonceStore
+
+	mu         sync.Mutex
+	usedNonces map[string]time.Time // jti -> token expiry
+}
+
+// NewServiceTokenService creates a service token service signing with key,
+// which must be at least 32 bytes.
+func NewServiceTokenService(key string) (*ServiceTokenService, error) {
+	if len(key) < minServiceKeyLength {
+		return nil, fmt.Errorf("internal service key must be at least %d bytes", minServiceKeyLength)
+	}
+	return &ServiceTokenService{
+		key:        []byte(key),
+		TTL:        DefaultServiceTokenTTL,
+		usedNonces: make(map[string]time.Time),
+	}, nil
+}
+
+// Mint returns a signed token identifying the calling service.
+func (s *ServiceTokenService) Mint(service string) (string, error) {
+	jtiBytes := make([]byte, 16)
+	if _, err := rand.Read(jtiBytes); err != nil {
+		return "", fmt.Errorf("failed to generate JTI: %w", err)
+	}
+
+	now := time.Now()
+	claims := ServiceClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    ServiceTokenIssuer,
+			Subject:   service,
+			Audience:  jwt.ClaimStrings{ServiceTokenAudience},
+			ID:        hex.EncodeToString(jtiBytes),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.TTL)),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign service token: %w", err)
+	}
+	return token, nil
+}
+
+// Validate checks a service token's signature, issuer, audience, expiry and
+// nonce and returns its claims. A token validates only once.
+func (s *ServiceTokenService) Validate(ctx context.Context, tokenString string) (*ServiceClaims, error) {
+	var claims ServiceClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return s.key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(ServiceTokenIssuer),
+		jwt.WithAudience(ServiceTokenAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidServiceToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing service name", ErrInvalidServiceToken)
+	}
+	if claims.ID == "" {
+		return nil, fmt.Errorf("%w: missing nonce", ErrInvalidServiceToken)
+	}
+	if claims.IssuedAt == nil || claims.ExpiresAt.Sub(claims.IssuedAt.Time) > MaxServiceTokenTTL {
+		return nil, fmt.Errorf("%w: lifetime exceeds %v", ErrInvalidServiceToken, MaxServiceTokenTTL)
+	}
+
+	if !s.recordNonce(ctx, claims.ID, claims.ExpiresAt.Time) {
+		return nil, ErrServiceTokenReplayed
+	}
+	return &claims, nil
+}
+
+// recordNonce records a nonce in the NonceStore, falling back to this
+// instance's memory if there is none or it fails, and reports false if the
+// nonce was already used. The fallback keeps internal calls working while
+// the store is unreachable (or, for /internal/migrate, not yet created).
+func (s *ServiceTokenService) recordNonce(ctx context.Context, nonce string, expiresAt time.Time) bool {
+	if s.NonceStore != nil {
+		fresh, err := s.NonceStore.UseNonce(ctx, nonce, expiresAt)
+		if err == nil {
+			// Track it locally too, in case the store fails on a replay
+			return s.useNonce(nonce, expiresAt) && fresh
+		}
+		log.Printf("[SERVICE_TOKEN_WARN] Nonce store failed, checking this instance only: %v", err)
+	}
+	return s.useNonce(nonce, expiresAt)
+}
+
+// useNonce records a nonce until expiresAt, reporting false if it was
+// already recorded. Expired nonces are pruned on the way; their tokens fail
+// the expiry check anyway.
+func (s *ServiceTokenService) useNonce(nonce string, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for n, exp := range s.usedNonces {
+		if now.After(exp) {
+			delete(s.usedNonces, n)
+		}
+	}
+
+	if _, used := s.usedNonces[nonce]; used {
+		return false
+	}
+	s.usedNonces[nonce] = expiresAt
+	return true
+}
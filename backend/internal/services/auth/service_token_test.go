@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testServiceKey = "test-internal-service-key-0123456789"
+
+func newTestServiceTokenService(t *testing.T) *ServiceTokenService {
+	t.Helper()
+	s, err := NewServiceTokenService(testServiceKey)
+	if err != nil {
+		t.Fatalf("NewServiceTokenService: %v", err)
+	}
+	return s
+}
+
+func TestServiceTokenValidatesOnce(t *testing.T) {
+	s := newTestServiceTokenService(t)
+	token, err := s.Mint("cleanup")
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+
+	claims, err := s.Validate(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if claims.Subject != "cleanup" || claims.ID == "" {
+		t.Errorf("claims = %+v, want subject cleanup and a nonce", claims)
+	}
+
+	if _, err := s.Validate(context.Background(), token); !errors.Is(err, ErrServiceTokenReplayed) {
+		t.Errorf("replayed token: err = %v, want ErrServiceTokenReplayed", err)
+	}
+}
+
+func TestServiceTokenExpired(t *testing.T) {
+	s := newTestServiceTokenService(t)
+	s.TTL = -time.Minute
+	token, err := s.Mint("worker")
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+
+	_, err = s.Validate(context.Background(), token)
+	if !errors.Is(err, ErrInvalidServiceToken) || errors.Is(err, ErrServiceTokenReplayed) {
+		t.Fatalf("expired token: err = %v, want ErrInvalidServiceToken", err)
+	}
+	if !strings.Contains(err.Error(), "expired") {
+		t.Errorf("err = %v, want it to say the token expired", err)
+	}
+}
+
+func TestServiceTokenRejected(t *testing.T) {
+	s := newTestServiceTokenService(t)
+
+	other, err := NewServiceTokenService(strings.Repeat("x", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, _ := other.Mint("cleanup")
+
+	long := newTestServiceTokenService(t)
+	long.TTL = MaxServiceTokenTTL + time.Minute
+	tooLong, _ := long.Mint("cleanup")
+
+	for name, token := range map[string]string{
+		"wrong key":     foreign,
+		"long lifetime": tooLong,
+		"garbage":       "not-a-token",
+		"empty":         "",
+	} {
+		if _, err := s.Validate(context.Background(), token); !errors.Is(err, ErrInvalidServiceToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidServiceToken", name, err)
+		}
+	}
+}
+
+func TestNewServiceTokenServiceShortKey(t *testing.T) {
+	if _, err := NewServiceTokenService("too-short"); err == nil {
+		t.Error("accepted a short key")
+	}
+}
+
+// memNonceStore is a NonceStore shared by several ServiceTokenServices,
+// standing in for the database two Lambda instances share.
+type memNonceStore struct {
+	mu    sync.Mutex
+	used  map[string]bool
+	err   error
+	calls int
+}
+
+func (m *memNonceStore) UseNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.err != nil {
+		return false, m.err
+	}
+	if m.used[nonce] {
+		return false, nil
+	}
+	m.used[nonce] = true
+	return true, nil
+}
+
+func TestServiceTokenReplayAcrossInstances(t *testing.T) {
+	store := &memNonceStore{used: make(map[string]bool)}
+	first, second := newTestServiceTokenService(t), newTestServiceTokenService(t)
+	first.NonceStore, second.NonceStore = store, store
+
+	token, err := first.Mint("cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Validate(context.Background(), token); err != nil {
+		t.Fatalf("Validate on the first instance: %v", err)
+	}
+	if _, err := second.Validate(context.Background(), token); !errors.Is(err, ErrServiceTokenReplayed) {
+		t.Errorf("replay on another instance: err = %v, want ErrServiceTokenReplayed", err)
+	}
+}
+
+func TestServiceTokenNonceStoreFailure(t *testing.T) {
+	store := &memNonceStore{err: errors.New("relation \"service_token_nonces\" does not exist")}
+	s := newTestServiceTokenService(t)
+	s.NonceStore = store
+
+	token, err := s.Mint("deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Falls back to this instance's memory rather than locking out calls
+	if _, err := s.Validate(context.Background(), token); err != nil {
+		t.Fatalf("Validate with a failing store: %v", err)
+	}
+	if _, err := s.Validate(context.Background(), token); !errors.Is(err, ErrServiceTokenReplayed) {
+		t.Errorf("replay with a failing store: err = %v, want ErrServiceTokenReplayed", err)
+	}
+	if store.calls != 2 {
+		t.Errorf("store called %d times, want 2", store.calls)
+	}
+}
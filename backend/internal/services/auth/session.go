@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,6 +12,17 @@ import (
 	"github.com/yourusername/streammaxing/internal/services/secrets"
 )
 
+// SessionTTL is how long a session token is valid.
+const SessionTTL = 24 * time.Hour
+
+// MaxSessionLifetime caps how long renewals can keep a session alive,
+// counted from the login it descends from.
+const MaxSessionLifetime = 30 * 24 * time.Hour
+
+// ErrSessionRenewalExpired is returned by RenewSession once a session has
+// reached MaxSessionLifetime; the user must log in again.
+var ErrSessionRenewalExpired = errors.New("session can no longer be renewed")
+
 // SessionService manages JWT session creation, validation, and revocation.
 type SessionService struct {
 	secretsManager *secrets.Manager
@@ -21,7 +33,7 @@ type SessionService struct {
 type SessionStore interface {
 	InvalidateSession(ctx context.Context, jti string) error
 	IsSessionValid(ctx context.Context, jti string) (bool, error)
-	CleanupExpiredSessions(ctx context.Context) error
+	CleanupExpiredSessions(ctx context.Context) (int64, error)
 }
 
 // Claims represents the JWT claims for a user session.
@@ -29,6 +41,8 @@ type Claims struct {
 	UserID   string `json:"user_id"`
 	Username string `json:"username"`
 	JTI      string `json:"jti"` // JWT ID for revocation
+	// OriginalIssuedAt is the login time, carried over by RenewSession
+	OriginalIssuedAt *jwt.NumericDate `json:"orig_iat,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -40,8 +54,55 @@ func NewSessionService(secretsManager *secrets.Manager, sessionStore SessionStor
 	}
 }
 
-// CreateSession generates a new JWT session token with a 24-hour expiration.
+// CreateSession generates a new JWT session token that expires after SessionTTL.
 func (s *SessionService) CreateSession(userID, username string) (string, string, error) {
+	return s.issueSession(userID, username, time.Now())
+}
+
+// RenewSession replaces a valid session with a fresh token and JTI and
+// revokes the old JTI. The new token keeps the original login time and
+// never expires past MaxSessionLifetime from it.
+func (s *SessionService) RenewSession(ctx context.Context, claims *Claims) (string, string, error) {
+	loginAt := claims.LoginTime()
+	if time.Since(loginAt) >= MaxSessionLifetime {
+		return "", "", ErrSessionRenewalExpired
+	}
+
+	token, jti, err := s.issueSession(claims.UserID, claims.Username, loginAt)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.RevokeSession(ctx, claims.JTI); err != nil {
+		return "", "", fmt.Errorf("failed to revoke previous session: %w", err)
+	}
+	return token, jti, nil
+}
+
+// SessionExpiry returns when a session token issued now expires, for a
+// session whose login was at loginAt.
+func SessionExpiry(loginAt time.Time) time.Time {
+	expiry := time.Now().Add(SessionTTL)
+	if limit := loginAt.Add(MaxSessionLifetime); limit.Before(expiry) {
+		return limit
+	}
+	return expiry
+}
+
+// LoginTime returns the session's original login time. Tokens issued
+// before renewal existed have no orig_iat; their iat stands in.
+func (c *Claims) LoginTime() time.Time {
+	if c.OriginalIssuedAt != nil {
+		return c.OriginalIssuedAt.Time
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time
+	}
+	return time.Now()
+}
+
+// issueSession signs a new session token descending from the login at
+// loginAt.
+func (s *SessionService) issueSession(userID, username string, loginAt time.Time) (string, string, error) {
 	jwtSecret, err := s.secretsManager.GetJWTSecret()
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get JWT secret: %w", err)
@@ -55,11 +116,12 @@ func (s *SessionService) CreateSession(userID, username string) (string, string,
 	jti := hex.EncodeToString(jtiBytes)
 
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		JTI:      jti,
+		UserID:           userID,
+		Username:         username,
+		JTI:              jti,
+		OriginalIssuedAt: jwt.NewNumericDate(loginAt),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Reduced from 7 days
+			ExpiresAt: jwt.NewNumericDate(SessionExpiry(loginAt)), // Reduced from 7 days
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
@@ -75,18 +137,24 @@ func (s *SessionService) CreateSession(userID, username string) (string, string,
 }
 
 // ValidateSession parses and validates a JWT token, including revocation check.
+// Tokens signed with the previous JWT secret are accepted while one is
+// configured, so rotating the secret doesn't log everyone out.
 func (s *SessionService) ValidateSession(ctx context.Context, tokenString string) (*Claims, error) {
 	jwtSecret, err := s.secretsManager.GetJWTSecret()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get JWT secret: %w", err)
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
+	token, err := parseSession(tokenString, jwtSecret)
+	if errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		previous, prevErr := s.secretsManager.GetPreviousJWTSecret()
+		if prevErr != nil {
+			return nil, fmt.Errorf("failed to get previous JWT secret: %w", prevErr)
 		}
-		return []byte(jwtSecret), nil
-	})
+		if previous != "" && previous != jwtSecret {
+			token, err = parseSession(tokenString, previous)
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
@@ -110,6 +178,16 @@ func (s *SessionService) ValidateSession(ctx context.Context, tokenString string
 	return claims, nil
 }
 
+// parseSession parses a session token signed with secret.
+func parseSession(tokenString, secret string) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(secret), nil
+	})
+}
+
 // RevokeSession invalidates a session by its JTI.
 func (s *SessionService) RevokeSession(ctx context.Context, jti string) error {
 	if jti == "" {
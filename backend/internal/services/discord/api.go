@@ -2,12 +2,17 @@ package discord
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -15,38 +20,228 @@ import (
 type APIClient struct {
 	BotToken   string
 	httpClient *http.Client
+
+	// MaxRateLimitAttempts caps how many times a request is sent while
+	// Discord keeps answering 429. When exhausted, the last 429 response is
+	// returned to the caller.
+	MaxRateLimitAttempts int
+
+	// RetryPolicy controls retries of server errors (5xx) and network
+	// failures. These are counted separately from 429 retries. A network
+	// failure of a non-idempotent request (POST) is only retried when the
+	// connection was never established, so a message can't be sent twice.
+	RetryPolicy RetryPolicy
+
+	// limiter holds per-bucket budgets from X-RateLimit-* headers
+	limiter *rateLimiter
+
+	// botUserID caches the bot's own user ID (see BotHasPermission)
+	botUserMu sync.Mutex
+	botUserID string
 }
 
 // NewAPIClient creates a new Discord API client with the given bot token.
 func NewAPIClient(botToken string) *APIClient {
 	return &APIClient{
-		BotToken:   botToken,
-		httpClient: &http.Client{Timeout: 10 * time.Second},
+		BotToken:             botToken,
+		httpClient:           &http.Client{Timeout: 10 * time.Second},
+		MaxRateLimitAttempts: defaultMaxRateLimitAttempts,
+		RetryPolicy:          DefaultRetryPolicy,
+		limiter:              newRateLimiter(),
+	}
+}
+
+// RetryPolicy describes how transient Discord failures are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of sends, including the first.
+	MaxAttempts int
+	// BaseBackoff is the delay before the first retry; it doubles on each
+	// further retry and gets up to 50% jitter added. Zero retries at once.
+	BaseBackoff time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy of clients built by NewAPIClient.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseBackoff: 500 * time.Millisecond}
+
+// canRetrySend reports whether a send that failed with a network error
+// can be repeated without risking a duplicate: the method is idempotent,
+// or the connection was never established so Discord never saw it.
+func canRetrySend(req *http.Request, err error) bool {
+	if isIdempotent(req.Method) {
+		return true
 	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
 }
 
-// doRequest executes a Discord API request with rate limit handling
+// canRetryStatus reports whether a request answered with a 5xx status can
+// be repeated without risking a duplicate. A 500 on a POST may come after
+// Discord already created the message, so non-idempotent requests only
+// retry the gateway errors, which the proxy in front of Discord returns
+// when the request didn't get through.
+func canRetryStatus(req *http.Request, status int) bool {
+	if isIdempotent(req.Method) {
+		return true
+	}
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before retry number n (starting at 1).
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseBackoff << (n - 1)
+	if d <= 0 {
+		return 0
+	}
+	return d + rand.N(d/2+1)
+}
+
+// defaultMaxRateLimitAttempts is the default value of MaxRateLimitAttempts.
+const defaultMaxRateLimitAttempts = 5
+
+// defaultRetryAfter is used when a 429 carries no usable Retry-After header.
+const defaultRetryAfter = 1 * time.Second
+
+// rateLimitJitter is the upper bound of random delay added to Retry-After so
+// concurrent requests to the same bucket don't wake up in lockstep.
+const rateLimitJitter = 500 * time.Millisecond
+
+// doRequest executes a Discord API request, retrying 429s (per
+// MaxRateLimitAttempts) and 5xx/network failures (per RetryPolicy). Waits
+// between attempts end early, with the context's error, once the
+// request's context is done.
 func (c *APIClient) doRequest(req *http.Request) (*http.Response, error) {
-	req.Header.Set("Authorization", "Bot "+c.BotToken)
+	// Webhook routes authenticate with the token in their URL
+	if !isWebhookPath(req.URL.Path) {
+		req.Header.Set("Authorization", "Bot "+c.BotToken)
+	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	maxAttempts := c.MaxRateLimitAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	// The body reader is consumed by each send, so make sure retries can
+	// rebuild it. Requests built from a bytes.Reader already have GetBody;
+	// anything else is buffered once here.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		buf, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to buffer request body: %w", err)
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(buf)), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+
+	route := routeKey(req)
+
+	maxServerAttempts := c.RetryPolicy.MaxAttempts
+	if maxServerAttempts < 1 {
+		maxServerAttempts = 1
 	}
 
-	// Handle rate limiting
-	if resp.StatusCode == http.StatusTooManyRequests {
-		retryAfter := resp.Header.Get("Retry-After")
-		if retryAfter != "" {
-			wait, _ := strconv.Atoi(retryAfter)
-			log.Printf("[DISCORD_API] Rate limited, retrying after %d seconds", wait)
-			time.Sleep(time.Duration(wait) * time.Second)
-			resp.Body.Close()
-			return c.doRequest(req)
+	rateAttempts, serverAttempts := 0, 0
+	for {
+		if rateAttempts+serverAttempts > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		// Wait out an exhausted bucket (or a global limit) before sending
+		if err := c.limiter.wait(req.Context(), route); err != nil {
+			return nil, fmt.Errorf("waiting for rate limit: %w", err)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			// Transport errors quote the URL, which for webhook routes
+			// holds the token; redact before it reaches a log or caller
+			err = redactWebhookError(err)
 		}
+		if err != nil || resp.StatusCode >= 500 {
+			// Network failure or Discord-side error: back off and retry
+			serverAttempts++
+			if err == nil {
+				if !canRetryStatus(req, resp.StatusCode) {
+					respBody, _ := io.ReadAll(resp.Body)
+					resp.Body.Close()
+					return nil, fmt.Errorf("discord server error (%d): %s", resp.StatusCode, respBody)
+				}
+				if serverAttempts >= maxServerAttempts {
+					respBody, _ := io.ReadAll(resp.Body)
+					resp.Body.Close()
+					return nil, fmt.Errorf("discord server error (%d) after %d attempts: %s", resp.StatusCode, serverAttempts, respBody)
+				}
+				err = fmt.Errorf("status %d", resp.StatusCode)
+				resp.Body.Close()
+			} else if serverAttempts >= maxServerAttempts {
+				return nil, fmt.Errorf("request failed after %d attempts: %w", serverAttempts, err)
+			} else if !canRetrySend(req, err) {
+				return nil, fmt.Errorf("request failed: %w", err)
+			}
+
+			delay := c.RetryPolicy.backoff(serverAttempts)
+			log.Printf("[DISCORD_API] %s %s failed (%v), retrying after %v (attempt %d/%d)",
+				req.Method, route, err, delay, serverAttempts, maxServerAttempts)
+			if err := sleepCtx(req.Context(), delay); err != nil {
+				return nil, fmt.Errorf("request abandoned during retry backoff: %w", err)
+			}
+			continue
+		}
+		c.limiter.update(route, resp.Header)
+
+		// Handle rate limiting
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+
+		rateAttempts++
+		global := resp.Header.Get("X-RateLimit-Global") == "true"
+		delay := rateLimitDelay(resp.Header.Get("Retry-After"))
+		c.limiter.limited(route, global, delay)
+
+		if rateAttempts >= maxAttempts {
+			log.Printf("[DISCORD_API] Rate limited, giving up after %d attempts", rateAttempts)
+			return resp, nil
+		}
+
+		log.Printf("[DISCORD_API] Rate limited (global=%v), retrying after %v (attempt %d/%d)", global, delay, rateAttempts, maxAttempts)
+		resp.Body.Close()
 	}
+}
 
-	return resp, nil
+// rateLimitDelay is how long to wait before resending a 429'd request: the
+// Retry-After header plus up to rateLimitJitter of random delay.
+func rateLimitDelay(retryAfter string) time.Duration {
+	return parseRetryAfter(retryAfter) + rand.N(rateLimitJitter)
+}
+
+// parseRetryAfter converts a Retry-After header (seconds, possibly
+// fractional) to a duration, falling back to defaultRetryAfter when the
+// header is missing, malformed, or zero.
+func parseRetryAfter(header string) time.Duration {
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		return defaultRetryAfter
+	}
+	return time.Duration(seconds * float64(time.Second))
 }
 
 // Channel represents a Discord channel
@@ -55,12 +250,37 @@ type Channel struct {
 	Type     int    `json:"type"`
 	Name     string `json:"name"`
 	Position int    `json:"position"`
+	// ParentID is the category the channel sits under, empty if none
+	ParentID string `json:"parent_id,omitempty"`
+}
+
+// Channel types (Discord's channel "type" field) GetGuildChannels returns
+const (
+	ChannelTypeText         = 0
+	ChannelTypeCategory     = 4
+	ChannelTypeAnnouncement = 5
+	ChannelTypeForum        = 15
+)
+
+// IsText reports whether notifications can be posted in the channel as
+// plain messages (text and announcement channels).
+func (ch Channel) IsText() bool {
+	return ch.Type == ChannelTypeText || ch.Type == ChannelTypeAnnouncement
 }
 
-// GetGuildChannels fetches text channels from a guild
-func (c *APIClient) GetGuildChannels(guildID string) ([]Channel, error) {
+// IsForum reports whether the channel is a forum, where notifications are
+// posted as new threads (see CreateForumPost).
+func (ch Channel) IsForum() bool {
+	return ch.Type == ChannelTypeForum
+}
+
+// GetGuildChannels fetches a guild's text, announcement, forum and category
+// channels; ParentID groups the others under their category. Discord returns
+// every channel in one response (this endpoint doesn't paginate), so there
+// is nothing to follow.
+func (c *APIClient) GetGuildChannels(ctx context.Context, guildID string) ([]Channel, error) {
 	reqURL := fmt.Sprintf("https://discord.com/api/guilds/%s/channels", guildID)
-	req, err := http.NewRequest("GET", reqURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -81,29 +301,31 @@ func (c *APIClient) GetGuildChannels(guildID string) ([]Channel, error) {
 		return nil, err
 	}
 
-	// Filter to text (0) and announcement (5) channels
-	var textChannels []Channel
+	// Filter to text, announcement, forum and category channels
+	var channels []Channel
 	for _, ch := range allChannels {
-		if ch.Type == 0 || ch.Type == 5 {
-			textChannels = append(textChannels, ch)
+		if ch.IsText() || ch.IsForum() || ch.Type == ChannelTypeCategory {
+			channels = append(channels, ch)
 		}
 	}
 
-	return textChannels, nil
+	return channels, nil
 }
 
 // Role represents a Discord role
 type Role struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Color    int    `json:"color"`
-	Position int    `json:"position"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Color       int    `json:"color"`
+	Position    int    `json:"position"`
+	Permissions string `json:"permissions"`
+	Mentionable bool   `json:"mentionable"`
 }
 
 // GetGuildRoles fetches roles from a guild
-func (c *APIClient) GetGuildRoles(guildID string) ([]Role, error) {
+func (c *APIClient) GetGuildRoles(ctx context.Context, guildID string) ([]Role, error) {
 	reqURL := fmt.Sprintf("https://discord.com/api/guilds/%s/roles", guildID)
-	req, err := http.NewRequest("GET", reqURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -128,9 +350,9 @@ func (c *APIClient) GetGuildRoles(guildID string) ([]Role, error) {
 }
 
 // CheckGuildMembership checks if a user is a member of a guild
-func (c *APIClient) CheckGuildMembership(guildID, userID string) (bool, error) {
+func (c *APIClient) CheckGuildMembership(ctx context.Context, guildID, userID string) (bool, error) {
 	reqURL := fmt.Sprintf("https://discord.com/api/guilds/%s/members/%s", guildID, userID)
-	req, err := http.NewRequest("GET", reqURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
 		return false, err
 	}
@@ -146,8 +368,17 @@ func (c *APIClient) CheckGuildMembership(guildID, userID string) (bool, error) {
 
 // DiscordMessage represents a message to send via the Discord API
 type DiscordMessage struct {
-	Content string          `json:"content,omitempty"`
-	Embeds  []*DiscordEmbed `json:"embeds,omitempty"`
+	Content         string           `json:"content,omitempty"`
+	Embeds          []*DiscordEmbed  `json:"embeds,omitempty"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+}
+
+// AllowedMentions controls which mentions in a message actually ping.
+// An empty Parse with no Roles/Users suppresses every ping.
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+	Roles []string `json:"roles,omitempty"`
+	Users []string `json:"users,omitempty"`
 }
 
 // DiscordEmbed represents a Discord embed
@@ -180,16 +411,143 @@ type DiscordFooter struct {
 	Text string `json:"text"`
 }
 
-// SendMessage sends a message to a Discord channel
-func (c *APIClient) SendMessage(channelID string, message *DiscordMessage) error {
+// SendMessage sends a message to a Discord channel and returns the ID of the
+// created message.
+func (c *APIClient) SendMessage(ctx context.Context, channelID string, message *DiscordMessage) (string, error) {
 	reqURL := fmt.Sprintf("https://discord.com/api/channels/%s/messages", channelID)
 
+	body, err := json.Marshal(message)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("discord send error (%d): %s", resp.StatusCode, respBody)
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("failed to decode message response: %w", err)
+	}
+
+	return created.ID, nil
+}
+
+// maxThreadNameLen is Discord's limit on a thread name, in characters.
+const maxThreadNameLen = 100
+
+// CreateForumPost starts a thread in a forum channel with message as its
+// first post. Returns the new thread's ID (the channel to edit the post in)
+// and the post's message ID. Discord gives a forum post's starter message
+// the same ID as its thread. Names longer than Discord allows are truncated.
+func (c *APIClient) CreateForumPost(ctx context.Context, channelID, threadName string, message *DiscordMessage) (threadID, messageID string, err error) {
+	reqURL := fmt.Sprintf("https://discord.com/api/channels/%s/threads", channelID)
+
+	if name := []rune(threadName); len(name) > maxThreadNameLen {
+		threadName = string(name[:maxThreadNameLen-1]) + "…"
+	}
+	body, err := json.Marshal(struct {
+		Name    string          `json:"name"`
+		Message *DiscordMessage `json:"message"`
+	}{threadName, message})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal forum post: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create forum post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", "", fmt.Errorf("discord forum post error (%d): %s", resp.StatusCode, respBody)
+	}
+
+	// The response is the thread channel, with the starter message nested
+	var created struct {
+		ID      string `json:"id"`
+		Message struct {
+			ID string `json:"id"`
+		} `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", "", fmt.Errorf("failed to decode forum post response: %w", err)
+	}
+
+	messageID = created.Message.ID
+	if messageID == "" {
+		messageID = created.ID
+	}
+	return created.ID, messageID, nil
+}
+
+// DeleteChannel deletes a channel or thread. One that is already gone is
+// not an error.
+func (c *APIClient) DeleteChannel(ctx context.Context, channelID string) error {
+	reqURL := fmt.Sprintf("https://discord.com/api/channels/%s", channelID)
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", reqURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete channel: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("discord channel delete error (%d): %s", resp.StatusCode, respBody)
+	}
+
+	return nil
+}
+
+// ErrMessageNotFound is returned by EditMessage when the message no longer
+// exists (deleted by a moderator, or the channel was removed).
+var ErrMessageNotFound = errors.New("discord message not found")
+
+// EditMessage replaces the content and embeds of an existing message.
+// Returns ErrMessageNotFound if the message is gone, so the caller can post
+// a fresh one instead.
+func (c *APIClient) EditMessage(ctx context.Context, channelID, messageID string, message *DiscordMessage) error {
+	reqURL := fmt.Sprintf("https://discord.com/api/channels/%s/messages/%s", channelID, messageID)
+
 	body, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", reqURL, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "PATCH", reqURL, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -197,13 +555,45 @@ func (c *APIClient) SendMessage(channelID string, message *DiscordMessage) error
 
 	resp, err := c.doRequest(req)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return fmt.Errorf("failed to edit message: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrMessageNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("discord edit error (%d): %s", resp.StatusCode, respBody)
+	}
+
+	return nil
+}
+
+// DeleteMessage deletes a message from a Discord channel. A message that is
+// already gone (deleted by a moderator, or the channel removed) is not an error.
+func (c *APIClient) DeleteMessage(ctx context.Context, channelID, messageID string) error {
+	reqURL := fmt.Sprintf("https://discord.com/api/channels/%s/messages/%s", channelID, messageID)
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", reqURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("discord send error (%d): %s", resp.StatusCode, respBody)
+		return fmt.Errorf("discord delete error (%d): %s", resp.StatusCode, respBody)
 	}
 
 	return nil
@@ -0,0 +1,240 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestAPIClient returns an APIClient talking to srv that retries server
+// errors without backing off.
+func newTestAPIClient(srv *httptest.Server) *APIClient {
+	c := NewAPIClient("test-bot-token")
+	c.httpClient = &http.Client{Transport: serverClient{srv}}
+	c.RetryPolicy.BaseBackoff = 0
+	return c
+}
+
+// rateLimited answers 429 with the given Retry-After ("" for none).
+func rateLimited(w http.ResponseWriter, retryAfter string) {
+	if retryAfter != "" {
+		w.Header().Set("Retry-After", retryAfter)
+	}
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(`{"message":"You are being rate limited.","global":false}`))
+}
+
+func TestRateLimitRetriesAreCapped(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		rateLimited(w, "0.001")
+	}))
+	defer srv.Close()
+
+	c := newTestAPIClient(srv)
+	c.MaxRateLimitAttempts = 3
+
+	_, err := c.SendMessage(context.Background(), "123", &DiscordMessage{Content: "live"})
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("err = %v, want the final 429", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("sent %d requests, want 3", got)
+	}
+}
+
+func TestRateLimitDelayIsJittered(t *testing.T) {
+	seen := make(map[time.Duration]bool)
+	for range 50 {
+		d := rateLimitDelay("2")
+		if d < 2*time.Second || d >= 2*time.Second+rateLimitJitter {
+			t.Fatalf("rateLimitDelay(2) = %v, want within [2s, 2s+%v)", d, rateLimitJitter)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Error("every delay was the same, want jitter")
+	}
+}
+
+func TestDoRequestReturnsLastRateLimitResponse(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		rateLimited(w, "0.001")
+	}))
+	defer srv.Close()
+
+	c := newTestAPIClient(srv)
+	c.MaxRateLimitAttempts = 2
+
+	req, _ := http.NewRequest(http.MethodGet, "https://discord.com/api/guilds/1/roles", nil)
+	resp, err := c.doRequest(req)
+	if err != nil {
+		t.Fatalf("doRequest: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", resp.StatusCode)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("sent %d requests, want 2", got)
+	}
+}
+
+func TestRateLimitWithoutRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			rateLimited(w, "")
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	roles, err := newTestAPIClient(srv).GetGuildRoles(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("GetGuildRoles: %v", err)
+	}
+	if len(roles) != 0 {
+		t.Errorf("roles = %+v, want none", roles)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("sent %d requests, want 2 (429 retried)", got)
+	}
+	if elapsed := time.Since(start); elapsed < defaultRetryAfter {
+		t.Errorf("retried after %v, want at least the %v default", elapsed, defaultRetryAfter)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"2", 2 * time.Second},
+		{"0.25", 250 * time.Millisecond},
+		{"", defaultRetryAfter},
+		{"0", defaultRetryAfter},
+		{"-1", defaultRetryAfter},
+		{"soon", defaultRetryAfter},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRetriedRequestKeepsBody(t *testing.T) {
+	const body = `{"content":"Streamer is live!"}`
+	tests := []struct {
+		name    string
+		newBody func() io.Reader
+	}{
+		// http.NewRequest sets GetBody for these
+		{"bytes reader", func() io.Reader { return bytes.NewReader([]byte(body)) }},
+		// no GetBody: doRequest buffers it
+		{"plain reader", func() io.Reader { return io.MultiReader(strings.NewReader(body)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ := io.ReadAll(r.Body)
+				if string(got) != body {
+					t.Errorf("attempt %d body = %q, want %q", calls.Load()+1, got, body)
+				}
+				if calls.Add(1) == 1 {
+					rateLimited(w, "0.001")
+					return
+				}
+				w.Write([]byte(`{"id":"msg-1"}`))
+			}))
+			defer srv.Close()
+
+			req, _ := http.NewRequest(http.MethodPost, "https://discord.com/api/channels/123/messages", tt.newBody())
+			resp, err := newTestAPIClient(srv).doRequest(req)
+			if err != nil {
+				t.Fatalf("doRequest: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+			if got := calls.Load(); got != 2 {
+				t.Errorf("sent %d requests, want 2", got)
+			}
+		})
+	}
+}
+
+func TestSendMessageRetriesWithBody(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg DiscordMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.Content != "live" {
+			t.Errorf("attempt %d message = %+v (err %v), want content live", calls.Load()+1, msg, err)
+		}
+		if calls.Add(1) == 1 {
+			rateLimited(w, "0.001")
+			return
+		}
+		w.Write([]byte(`{"id":"msg-1"}`))
+	}))
+	defer srv.Close()
+
+	id, err := newTestAPIClient(srv).SendMessage(context.Background(), "123", &DiscordMessage{Content: "live"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if id != "msg-1" {
+		t.Errorf("message ID = %q, want msg-1", id)
+	}
+}
+
+func TestServerErrorRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		status    int
+		wantCalls int32
+	}{
+		// Discord may have created the message before failing
+		{"post 500", http.MethodPost, http.StatusInternalServerError, 1},
+		{"post 502", http.MethodPost, http.StatusBadGateway, 3},
+		{"post 503", http.MethodPost, http.StatusServiceUnavailable, 3},
+		{"patch 500", http.MethodPatch, http.StatusInternalServerError, 1},
+		{"get 500", http.MethodGet, http.StatusInternalServerError, 3},
+		{"delete 500", http.MethodDelete, http.StatusInternalServerError, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			req, _ := http.NewRequest(tt.method, "https://discord.com/api/v10/channels/123/messages", strings.NewReader(`{"content":"live"}`))
+			if _, err := newTestAPIClient(srv).doRequest(req); err == nil {
+				t.Error("doRequest succeeded, want the server error")
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("server saw %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
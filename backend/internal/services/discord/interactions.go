@@ -0,0 +1,162 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Interaction types (https://discord.com/developers/docs/interactions/receiving-and-responding)
+const (
+	InteractionTypePing               = 1
+	InteractionTypeApplicationCommand = 2
+)
+
+// Interaction response types
+const (
+	InteractionResponsePong                     = 1
+	InteractionResponseChannelMessageWithSource = 4
+)
+
+// Application command option types
+const (
+	CommandOptionSubCommand = 1
+	CommandOptionString     = 3
+)
+
+// MessageFlagEphemeral shows an interaction reply only to the invoking user
+const MessageFlagEphemeral = 1 << 6
+
+// Interaction is an incoming interaction. Member is set in guilds, User in
+// DMs.
+type Interaction struct {
+	ID      string                 `json:"id"`
+	Type    int                    `json:"type"`
+	GuildID string                 `json:"guild_id,omitempty"`
+	Member  *InteractionMember     `json:"member,omitempty"`
+	User    *InteractionUser       `json:"user,omitempty"`
+	Data    ApplicationCommandData `json:"data"`
+}
+
+// InteractionMember is the guild member who invoked an interaction
+type InteractionMember struct {
+	User InteractionUser `json:"user"`
+}
+
+// InteractionUser is the Discord user who invoked an interaction
+type InteractionUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar,omitempty"`
+}
+
+// Invoker returns the user who invoked the interaction, or nil.
+func (i *Interaction) Invoker() *InteractionUser {
+	if i.Member != nil {
+		return &i.Member.User
+	}
+	return i.User
+}
+
+// ApplicationCommandData is the invoked command and its options
+type ApplicationCommandData struct {
+	Name    string              `json:"name"`
+	Options []InteractionOption `json:"options,omitempty"`
+}
+
+// InteractionOption is a subcommand or an option value
+type InteractionOption struct {
+	Name    string              `json:"name"`
+	Type    int                 `json:"type"`
+	Value   json.RawMessage     `json:"value,omitempty"`
+	Options []InteractionOption `json:"options,omitempty"`
+}
+
+// StringOption returns the string value of the named option, if present.
+func StringOption(options []InteractionOption, name string) (string, bool) {
+	for _, opt := range options {
+		if opt.Name == name && opt.Type == CommandOptionString {
+			var s string
+			if err := json.Unmarshal(opt.Value, &s); err == nil {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
+
+// InteractionResponse is the reply to an interaction
+type InteractionResponse struct {
+	Type int                      `json:"type"`
+	Data *InteractionResponseData `json:"data,omitempty"`
+}
+
+// InteractionResponseData is the message sent as an interaction reply
+type InteractionResponseData struct {
+	Content         string           `json:"content"`
+	Flags           int              `json:"flags,omitempty"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+}
+
+// EphemeralReply builds a reply only the invoking user sees, with pings
+// disabled.
+func EphemeralReply(content string) InteractionResponse {
+	return InteractionResponse{
+		Type: InteractionResponseChannelMessageWithSource,
+		Data: &InteractionResponseData{
+			Content:         content,
+			Flags:           MessageFlagEphemeral,
+			AllowedMentions: &AllowedMentions{Parse: []string{}},
+		},
+	}
+}
+
+// ApplicationCommand is a slash command definition for registration
+type ApplicationCommand struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Options     []CommandOption `json:"options,omitempty"`
+	// DMPermission false keeps the command to guilds
+	DMPermission bool `json:"dm_permission"`
+}
+
+// CommandOption is a subcommand or option in an ApplicationCommand
+type CommandOption struct {
+	Type        int             `json:"type"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Required    bool            `json:"required,omitempty"`
+	Options     []CommandOption `json:"options,omitempty"`
+}
+
+// OverwriteGlobalCommands replaces the application's global slash commands
+// with commands.
+func (c *APIClient) OverwriteGlobalCommands(ctx context.Context, applicationID string, commands []ApplicationCommand) error {
+	reqURL := fmt.Sprintf("https://discord.com/api/applications/%s/commands", applicationID)
+
+	body, err := json.Marshal(commands)
+	if err != nil {
+		return fmt.Errorf("failed to marshal commands: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", reqURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to register commands: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("discord command registration error (%d): %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package discord
+
+// Discord message limits, in characters
+const (
+	MaxMessageContent   = 2000
+	MaxEmbedTitle       = 256
+	MaxEmbedDescription = 4096
+	MaxEmbedFields      = 25
+	MaxEmbedFieldName   = 256
+	MaxEmbedFieldValue  = 1024
+	MaxEmbedFooter      = 2048
+	// MaxEmbedTotal caps the combined title, description, field and footer
+	// text of all embeds in a message
+	MaxEmbedTotal = 6000
+)
@@ -1,18 +1,34 @@
 package discord
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
+// HTTPClient sends HTTP requests. *http.Client satisfies it; tests can
+// substitute one backed by an httptest.Server.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// newHTTPClient returns the default HTTPClient for OAuthService.
+func newHTTPClient() HTTPClient {
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
 // OAuthService handles Discord OAuth 2.0 flows
 type OAuthService struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURI  string
+	// HTTPClient sends all requests (default: 10s timeout)
+	HTTPClient HTTPClient
 }
 
 // NewOAuthService creates a new Discord OAuth service with the given credentials.
@@ -21,6 +37,7 @@ func NewOAuthService(clientID, clientSecret, redirectURI string) *OAuthService {
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		RedirectURI:  redirectURI,
+		HTTPClient:   newHTTPClient(),
 	}
 }
 
@@ -47,14 +64,14 @@ type TokenResponse struct {
 
 // ExchangeCode exchanges an authorization code for an access token
 // using the configured redirect URI.
-func (s *OAuthService) ExchangeCode(code string) (*TokenResponse, error) {
-	return s.ExchangeCodeWithURI(code, s.RedirectURI)
+func (s *OAuthService) ExchangeCode(ctx context.Context, code string) (*TokenResponse, error) {
+	return s.ExchangeCodeWithURI(ctx, code, s.RedirectURI)
 }
 
 // ExchangeCodeWithURI exchanges an authorization code for an access token
 // using the provided redirect URI. The redirect_uri must match exactly what was
 // used in the authorize request, or Discord will reject the exchange.
-func (s *OAuthService) ExchangeCodeWithURI(code, redirectURI string) (*TokenResponse, error) {
+func (s *OAuthService) ExchangeCodeWithURI(ctx context.Context, code, redirectURI string) (*TokenResponse, error) {
 	data := url.Values{
 		"client_id":     {s.ClientID},
 		"client_secret": {s.ClientSecret},
@@ -63,7 +80,7 @@ func (s *OAuthService) ExchangeCodeWithURI(code, redirectURI string) (*TokenResp
 		"redirect_uri":  {redirectURI},
 	}
 
-	resp, err := http.PostForm("https://discord.com/api/oauth2/token", data)
+	resp, err := s.postForm(ctx, "https://discord.com/api/oauth2/token", data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
@@ -90,14 +107,14 @@ type DiscordUser struct {
 }
 
 // GetUser fetches the authenticated user's information
-func (s *OAuthService) GetUser(accessToken string) (*DiscordUser, error) {
-	req, err := http.NewRequest("GET", "https://discord.com/api/users/@me", nil)
+func (s *OAuthService) GetUser(ctx context.Context, accessToken string) (*DiscordUser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://discord.com/api/users/@me", nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch user: %w", err)
 	}
@@ -126,14 +143,14 @@ type DiscordGuild struct {
 }
 
 // GetUserGuilds fetches the authenticated user's guilds
-func (s *OAuthService) GetUserGuilds(accessToken string) ([]DiscordGuild, error) {
-	req, err := http.NewRequest("GET", "https://discord.com/api/users/@me/guilds", nil)
+func (s *OAuthService) GetUserGuilds(ctx context.Context, accessToken string) ([]DiscordGuild, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://discord.com/api/users/@me/guilds", nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch guilds: %w", err)
 	}
@@ -159,3 +176,13 @@ func (s *OAuthService) GetBotInstallURL(guildID string) string {
 		s.ClientID, guildID,
 	)
 }
+
+// postForm posts form-encoded data through the service's HTTPClient.
+func (s *OAuthService) postForm(ctx context.Context, reqURL string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.HTTPClient.Do(req)
+}
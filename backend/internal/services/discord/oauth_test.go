@@ -0,0 +1,141 @@
+package discord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// serverClient is an HTTPClient (and, for APIClient, an http.RoundTripper)
+// that sends every request to an httptest.Server instead of the Discord
+// host in its URL, keeping the path and query.
+type serverClient struct {
+	srv *httptest.Server
+}
+
+func (c serverClient) Do(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(c.srv.URL)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.Host = ""
+	return c.srv.Client().Do(req)
+}
+
+func (c serverClient) RoundTrip(req *http.Request) (*http.Response, error) {
+	return c.Do(req)
+}
+
+func newTestOAuthService(srv *httptest.Server) *OAuthService {
+	s := NewOAuthService("test-client-id", "test-client-secret", "https://example.com/callback")
+	s.HTTPClient = serverClient{srv}
+	return s
+}
+
+func TestExchangeCodeWithURI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/oauth2/token" {
+			t.Errorf("request = %s %s, want POST /api/oauth2/token", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		for field, want := range map[string]string{
+			"grant_type":   "authorization_code",
+			"code":         "auth-code",
+			"client_id":    "test-client-id",
+			"redirect_uri": "https://other.example.com/callback",
+		} {
+			if got := r.PostForm.Get(field); got != want {
+				t.Errorf("%s = %q, want %q", field, got, want)
+			}
+		}
+		w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":604800,
+			"refresh_token":"refresh","scope":"identify guilds"}`))
+	}))
+	defer srv.Close()
+
+	tok, err := newTestOAuthService(srv).ExchangeCodeWithURI(context.Background(), "auth-code", "https://other.example.com/callback")
+	if err != nil {
+		t.Fatalf("ExchangeCodeWithURI: %v", err)
+	}
+	want := TokenResponse{AccessToken: "access", TokenType: "Bearer", ExpiresIn: 604800, RefreshToken: "refresh", Scope: "identify guilds"}
+	if *tok != want {
+		t.Errorf("token = %+v, want %+v", *tok, want)
+	}
+}
+
+func TestExchangeCodeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+	}))
+	defer srv.Close()
+
+	_, err := newTestOAuthService(srv).ExchangeCode(context.Background(), "bad-code")
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("err = %v, want the 400 invalid_grant error", err)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/users/@me" {
+			t.Errorf("path = %s, want /api/users/@me", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer user-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		w.Write([]byte(`{"id":"80351110224678912","username":"nelly","avatar":"8342729096ea3675442027381ff50dfe"}`))
+	}))
+	defer srv.Close()
+
+	user, err := newTestOAuthService(srv).GetUser(context.Background(), "user-token")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	want := DiscordUser{ID: "80351110224678912", Username: "nelly", Avatar: "8342729096ea3675442027381ff50dfe"}
+	if *user != want {
+		t.Errorf("user = %+v, want %+v", *user, want)
+	}
+}
+
+func TestGetUserGuilds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/users/@me/guilds" {
+			t.Errorf("path = %s, want /api/users/@me/guilds", r.URL.Path)
+		}
+		w.Write([]byte(`[{"id":"1","name":"Guild One","owner":true,"permissions":8},{"id":"2","name":"Guild Two","permissions":0}]`))
+	}))
+	defer srv.Close()
+
+	guilds, err := newTestOAuthService(srv).GetUserGuilds(context.Background(), "user-token")
+	if err != nil {
+		t.Fatalf("GetUserGuilds: %v", err)
+	}
+	if len(guilds) != 2 || guilds[0].Name != "Guild One" || !guilds[0].Owner || guilds[0].Permissions != 8 || guilds[1].Owner {
+		t.Errorf("guilds = %+v", guilds)
+	}
+}
+
+func TestGetUserGuildsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"401: Unauthorized","code":0}`))
+	}))
+	defer srv.Close()
+
+	_, err := newTestOAuthService(srv).GetUserGuilds(context.Background(), "expired")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("err = %v, want a 401 error", err)
+	}
+}
@@ -1,10 +1,22 @@
 package discord
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
 const (
 	// PermissionAdministrator is the Discord ADMINISTRATOR permission bit
 	PermissionAdministrator int64 = 0x8
 	// PermissionManageGuild is the MANAGE_GUILD permission bit
 	PermissionManageGuild int64 = 0x20
+	// PermissionMentionEveryone is the MENTION_EVERYONE permission bit
+	// (lets @everyone and @here ping)
+	PermissionMentionEveryone int64 = 0x20000
 )
 
 // HasAdminPermission checks if the permissions integer includes ADMINISTRATOR
@@ -26,3 +38,108 @@ func IsAdminInGuild(guilds []DiscordGuild, guildID string) bool {
 	}
 	return false
 }
+
+// BotHasPermission reports whether the bot holds perm in the guild through
+// its roles (including @everyone) or Administrator. Channel permission
+// overwrites are not considered.
+func (c *APIClient) BotHasPermission(ctx context.Context, guildID string, perm int64) (bool, error) {
+	botID, err := c.getBotUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	memberRoles, err := c.getMemberRoles(ctx, guildID, botID)
+	if err != nil {
+		return false, err
+	}
+	roles, err := c.GetGuildRoles(ctx, guildID)
+	if err != nil {
+		return false, err
+	}
+
+	// The @everyone role shares the guild's ID and applies to every member
+	held := map[string]bool{guildID: true}
+	for _, id := range memberRoles {
+		held[id] = true
+	}
+
+	var perms int64
+	for _, role := range roles {
+		if !held[role.ID] {
+			continue
+		}
+		p, err := strconv.ParseInt(role.Permissions, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid permissions on role %s: %w", role.ID, err)
+		}
+		perms |= p
+	}
+
+	if HasAdminPermission(perms) {
+		return true, nil
+	}
+	return perms&perm == perm, nil
+}
+
+// getBotUserID returns the bot's user ID, fetching it once.
+func (c *APIClient) getBotUserID(ctx context.Context) (string, error) {
+	c.botUserMu.Lock()
+	defer c.botUserMu.Unlock()
+	if c.botUserID != "" {
+		return c.botUserID, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://discord.com/api/users/@me", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch bot user: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to fetch bot user (%d): %s", resp.StatusCode, body)
+	}
+
+	var user struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", err
+	}
+
+	c.botUserID = user.ID
+	return c.botUserID, nil
+}
+
+// getMemberRoles returns the role IDs of a guild member.
+func (c *APIClient) getMemberRoles(ctx context.Context, guildID, userID string) ([]string, error) {
+	reqURL := fmt.Sprintf("https://discord.com/api/guilds/%s/members/%s", guildID, userID)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch member: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch member (%d): %s", resp.StatusCode, body)
+	}
+
+	var member struct {
+		Roles []string `json:"roles"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&member); err != nil {
+		return nil, err
+	}
+	return member.Roles, nil
+}
@@ -0,0 +1,215 @@
+package discord
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter tracks Discord's per-bucket rate limits from the X-RateLimit-*
+// response headers so requests wait for a bucket to reset instead of running
+// into a 429. Discord maps each route to a bucket hash (X-RateLimit-Bucket);
+// limits apply per bucket and major parameter (channel, guild, or webhook).
+type rateLimiter struct {
+	mu sync.Mutex
+
+	// routeBuckets maps a route key to the bucket hash Discord reported for it
+	routeBuckets map[string]string
+	// buckets holds the remaining budget per bucket hash and major parameter
+	buckets map[string]*bucketState
+	// globalUntil pauses every request after a global 429
+	globalUntil time.Time
+	// lastPrune is when expired buckets were last dropped
+	lastPrune time.Time
+}
+
+// bucketPruneInterval is how often update drops buckets whose reset has
+// passed, so per-channel and per-webhook entries don't pile up in a warm
+// instance.
+const bucketPruneInterval = time.Minute
+
+// bucketState is the last known budget of a bucket.
+type bucketState struct {
+	remaining int
+	resetAt   time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		routeBuckets: make(map[string]string),
+		buckets:      make(map[string]*bucketState),
+	}
+}
+
+// wait blocks until a request on the route may be sent and reserves one
+// unit of the bucket's budget, so concurrent requests to the same bucket
+// queue behind each other instead of all going out at once. Returns ctx's
+// error if it is done first.
+func (l *rateLimiter) wait(ctx context.Context, route string) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		var delay time.Duration
+		if now.Before(l.globalUntil) {
+			delay = l.globalUntil.Sub(now)
+		} else if b := l.bucketFor(route); b != nil {
+			switch {
+			case !now.Before(b.resetAt):
+				// Reset passed; budget is unknown until the next response
+			case b.remaining > 0:
+				b.remaining--
+			default:
+				delay = b.resetAt.Sub(now)
+			}
+		}
+		l.mu.Unlock()
+
+		if delay <= 0 {
+			return nil
+		}
+		if err := sleepCtx(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepCtx sleeps for d, or until ctx is done, returning ctx's error.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// update records the rate limit headers of a response on the route.
+func (l *rateLimiter) update(route string, h http.Header) {
+	hash := h.Get("X-RateLimit-Bucket")
+	remaining, errRemaining := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	resetAfter, errReset := strconv.ParseFloat(h.Get("X-RateLimit-Reset-After"), 64)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pruneExpired(time.Now())
+	if hash != "" {
+		l.routeBuckets[route] = hash
+	}
+	if hash == "" || errRemaining != nil || errReset != nil {
+		return
+	}
+
+	key := hash + ":" + majorParameter(route)
+	l.buckets[key] = &bucketState{
+		remaining: remaining,
+		resetAt:   time.Now().Add(time.Duration(resetAfter * float64(time.Second))),
+	}
+}
+
+// pruneExpired drops buckets whose reset has passed, and the routes that
+// point at no remaining bucket, at most once per bucketPruneInterval. An
+// expired bucket says nothing wait would act on, and a dropped route is
+// learned again from its next response. Callers must hold l.mu.
+func (l *rateLimiter) pruneExpired(now time.Time) {
+	if now.Sub(l.lastPrune) < bucketPruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	for key, b := range l.buckets {
+		if !now.Before(b.resetAt) {
+			delete(l.buckets, key)
+		}
+	}
+	for route, hash := range l.routeBuckets {
+		if _, ok := l.buckets[hash+":"+majorParameter(route)]; !ok {
+			delete(l.routeBuckets, route)
+		}
+	}
+}
+
+// limited pauses the route's bucket, or every bucket for a global limit,
+// for the given delay after a 429.
+func (l *rateLimiter) limited(route string, global bool, delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(delay)
+	if global {
+		if until.After(l.globalUntil) {
+			l.globalUntil = until
+		}
+		return
+	}
+
+	hash, ok := l.routeBuckets[route]
+	if !ok {
+		// No bucket reported yet: key the pause by the route itself
+		hash = route
+		l.routeBuckets[route] = hash
+	}
+	l.buckets[hash+":"+majorParameter(route)] = &bucketState{remaining: 0, resetAt: until}
+}
+
+// bucketFor returns the known state of the route's bucket, or nil.
+// Callers must hold l.mu.
+func (l *rateLimiter) bucketFor(route string) *bucketState {
+	hash, ok := l.routeBuckets[route]
+	if !ok {
+		return nil
+	}
+	return l.buckets[hash+":"+majorParameter(route)]
+}
+
+// routeKey identifies a request's route: the method and path with minor IDs
+// (anything other than the major parameter) replaced, since Discord assigns
+// buckets per route rather than per message.
+func routeKey(req *http.Request) string {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/api"), "/")
+	for i := 1; i < len(parts); i++ {
+		if isSnowflake(parts[i]) && !isMajorResource(parts[i-1]) {
+			parts[i] = ":id"
+		}
+		// Webhook tokens are credentials; keep them out of keys and logs
+		if i >= 2 && parts[i-2] == "webhooks" && isSnowflake(parts[i-1]) {
+			parts[i] = ":token"
+		}
+	}
+	return req.Method + " " + strings.Join(parts, "/")
+}
+
+// majorParameter extracts the major parameter ID from a route key.
+func majorParameter(route string) string {
+	parts := strings.Split(route, "/")
+	for i := 1; i < len(parts); i++ {
+		if isMajorResource(parts[i-1]) {
+			return parts[i]
+		}
+	}
+	return ""
+}
+
+// isMajorResource reports whether IDs following this path segment are
+// major parameters.
+func isMajorResource(segment string) bool {
+	return segment == "channels" || segment == "guilds" || segment == "webhooks"
+}
+
+// isSnowflake reports whether a path segment is a numeric Discord ID.
+func isSnowflake(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for _, r := range segment {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
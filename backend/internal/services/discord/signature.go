@@ -0,0 +1,60 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Signed request headers. Discord signs interactions this way; the
+// guild-removed webhook uses the same scheme.
+const (
+	SignatureHeader          = "X-Signature-Ed25519"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+)
+
+// maxSignatureAge rejects signed requests whose timestamp is further than
+// this from now, so a captured request can't be replayed later
+const maxSignatureAge = 5 * time.Minute
+
+// ParsePublicKey decodes a hex-encoded Ed25519 public key, the format the
+// Discord developer portal shows it in.
+func ParsePublicKey(hexKey string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("public key is not hex: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key is %d bytes, want %d", len(key), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// VerifySignature checks a hex Ed25519 signature over timestamp + body, the
+// scheme Discord uses for interactions. timestamp is Unix seconds and must
+// be recent.
+func VerifySignature(publicKey ed25519.PublicKey, signature, timestamp string, body []byte) bool {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return false
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+
+	message := make([]byte, 0, len(timestamp)+len(body))
+	message = append(message, timestamp...)
+	message = append(message, body...)
+	return ed25519.Verify(publicKey, message, sig)
+}
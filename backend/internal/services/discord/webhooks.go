@@ -0,0 +1,228 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidWebhookURL is returned by ParseWebhookURL for anything but a
+// Discord channel webhook URL
+var ErrInvalidWebhookURL = errors.New("not a Discord webhook URL")
+
+// webhookHosts are the hosts Discord issues webhook URLs on
+var webhookHosts = map[string]bool{
+	"discord.com":        true,
+	"ptb.discord.com":    true,
+	"canary.discord.com": true,
+	"discordapp.com":     true,
+}
+
+// Webhook is a channel webhook, as returned when fetched with its token
+type Webhook struct {
+	ID        string `json:"id"`
+	GuildID   string `json:"guild_id"`
+	ChannelID string `json:"channel_id"`
+}
+
+// ParseWebhookURL checks that raw is an https Discord webhook URL
+// (https://discord.com/api/webhooks/{id}/{token}, optionally with a
+// /v{n} API version) and returns it in canonical form.
+func ParseWebhookURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme != "https" || !webhookHosts[u.Host] || u.User != nil || u.Port() != "" {
+		return "", ErrInvalidWebhookURL
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) > 0 && parts[0] == "api" {
+		parts = parts[1:]
+	} else {
+		return "", ErrInvalidWebhookURL
+	}
+	if len(parts) > 0 && len(parts[0]) > 1 && parts[0][0] == 'v' {
+		parts = parts[1:]
+	}
+	if len(parts) != 3 || parts[0] != "webhooks" || !isSnowflake(parts[1]) || !isWebhookToken(parts[2]) {
+		return "", ErrInvalidWebhookURL
+	}
+	return fmt.Sprintf("https://discord.com/api/webhooks/%s/%s", parts[1], parts[2]), nil
+}
+
+// isWebhookToken reports whether s looks like a webhook token (URL-safe
+// base64 characters).
+func isWebhookToken(s string) bool {
+	if len(s) < 32 || len(s) > 128 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// isWebhookPath reports whether path is a token-authenticated webhook
+// route, which must not carry the bot token.
+func isWebhookPath(path string) bool {
+	return strings.HasPrefix(path, "/api/webhooks/")
+}
+
+// webhookPathToken returns the token in a webhook route's path
+// (/api/webhooks/{id}/{token}/...), or "" for any other path.
+func webhookPathToken(path string) string {
+	parts := strings.Split(strings.TrimPrefix(path, "/api"), "/")
+	for i := 2; i < len(parts); i++ {
+		if parts[i-2] == "webhooks" && isSnowflake(parts[i-1]) {
+			return parts[i]
+		}
+	}
+	return ""
+}
+
+// redactWebhookError replaces the token of a webhook URL quoted in a
+// request error (the HTTP client's *url.Error) with ":token", so the
+// error can be logged. Other errors are returned as is.
+func redactWebhookError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		return err
+	}
+	token := webhookPathToken(u.Path)
+	if token == "" {
+		return err
+	}
+	return &url.Error{Op: urlErr.Op, URL: strings.ReplaceAll(urlErr.URL, token, ":token"), Err: urlErr.Err}
+}
+
+// GetWebhook fetches the webhook behind a webhook URL from ParseWebhookURL,
+// to check which guild and channel it posts to.
+func (c *APIClient) GetWebhook(ctx context.Context, webhookURL string) (*Webhook, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", webhookURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("webhook does not exist or was deleted")
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("discord webhook error (%d): %s", resp.StatusCode, respBody)
+	}
+
+	var webhook Webhook
+	if err := json.NewDecoder(resp.Body).Decode(&webhook); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+// SendViaWebhook posts message through a channel webhook instead of the
+// bot, so guilds can receive notifications without installing it. Returns
+// the channel posted in and the message ID, for editing or deleting it
+// with EditWebhookMessage and DeleteWebhookMessage.
+func (c *APIClient) SendViaWebhook(ctx context.Context, webhookURL string, message *DiscordMessage) (channelID, messageID string, err error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	// wait=true makes Discord return the created message
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL+"?wait=true", bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to send via webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", "", fmt.Errorf("discord webhook send error (%d): %s", resp.StatusCode, respBody)
+	}
+
+	var created struct {
+		ID        string `json:"id"`
+		ChannelID string `json:"channel_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", "", fmt.Errorf("failed to decode message response: %w", err)
+	}
+	return created.ChannelID, created.ID, nil
+}
+
+// EditWebhookMessage replaces the content and embeds of a message sent by
+// SendViaWebhook. Returns ErrMessageNotFound if the message is gone.
+func (c *APIClient) EditWebhookMessage(ctx context.Context, webhookURL, messageID string, message *DiscordMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PATCH", webhookURL+"/messages/"+messageID, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to edit webhook message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrMessageNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("discord webhook edit error (%d): %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+// DeleteWebhookMessage deletes a message sent by SendViaWebhook. A message
+// that is already gone is not an error.
+func (c *APIClient) DeleteWebhookMessage(ctx context.Context, webhookURL, messageID string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", webhookURL+"/messages/"+messageID, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("discord webhook delete error (%d): %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
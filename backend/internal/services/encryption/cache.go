@@ -0,0 +1,102 @@
+package encryption
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultDecryptCacheSize is how many KMS plaintexts are cached by default.
+const DefaultDecryptCacheSize = 256
+
+// DecryptCacheTTL is how long a cached plaintext is reused. Long enough to
+// cover one fanout, short enough that plaintexts don't linger in memory.
+const DecryptCacheTTL = time.Minute
+
+// decryptCache is an LRU of KMS plaintexts keyed by ciphertext, which is
+// stable for a stored value. Entries are never logged.
+type decryptCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+}
+
+type decryptCacheEntry struct {
+	ciphertext string
+	plaintext  string
+	expiresAt  time.Time
+}
+
+func newDecryptCache(size int, ttl time.Duration) *decryptCache {
+	return &decryptCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached plaintext for ciphertext, if still fresh.
+func (c *decryptCache) get(ciphertext string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[ciphertext]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*decryptCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, ciphertext)
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return entry.plaintext, true
+}
+
+// put caches plaintext for ciphertext, evicting the least recently used
+// entry when full.
+func (c *decryptCache) put(ciphertext, plaintext string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+	expiresAt := time.Now().Add(c.ttl)
+	if elem, ok := c.entries[ciphertext]; ok {
+		entry := elem.Value.(*decryptCacheEntry)
+		entry.plaintext = plaintext
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[ciphertext] = c.order.PushFront(&decryptCacheEntry{
+		ciphertext: ciphertext,
+		plaintext:  plaintext,
+		expiresAt:  expiresAt,
+	})
+	c.evict()
+}
+
+// resize changes how many entries the cache holds; 0 disables it.
+func (c *decryptCache) resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = max(size, 0)
+	c.evict()
+}
+
+// evict drops least recently used entries past the size limit. Caller
+// holds c.mu.
+func (c *decryptCache) evict() {
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*decryptCacheEntry).ciphertext)
+	}
+}
@@ -0,0 +1,133 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// envelopePrefix marks values written by EncryptEnvelope:
+// "kmsv2:" + base64(KMS-encrypted data key) + ":" + base64(nonce || AES-GCM ciphertext)
+const envelopePrefix = "kmsv2:"
+
+// EncryptEnvelope encrypts plaintext with a fresh AES-256 data key from KMS
+// GenerateDataKey and stores the KMS-encrypted data key alongside the
+// ciphertext, so the value can later be decrypted or re-encrypted without
+// sending the plaintext to KMS. In dev mode it is the same as Encrypt.
+func (s *Service) EncryptEnvelope(ctx context.Context, plaintext string) (string, error) {
+	if s.isDev {
+		return s.Encrypt(ctx, plaintext)
+	}
+
+	dataKey, err := s.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   &s.keyID,
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return "", fmt.Errorf("KMS data key generation failed: %w", err)
+	}
+	defer clear(dataKey.Plaintext)
+
+	sealed, err := sealAESGCM(dataKey.Plaintext, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return envelopePrefix + base64.StdEncoding.EncodeToString(dataKey.CiphertextBlob) +
+		":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptEnvelope decrypts a value written by EncryptEnvelope. Anything
+// else (kms:, dev: or legacy plaintext) goes through Decrypt, so stored
+// values don't need migrating.
+func (s *Service) DecryptEnvelope(ctx context.Context, ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, envelopePrefix) {
+		return s.Decrypt(ctx, ciphertext)
+	}
+	return s.decryptEnvelope(ctx, ciphertext[len(envelopePrefix):])
+}
+
+// decryptEnvelope decrypts the part of an envelope value after its
+// prefix. The data key is unwrapped by KMS, through the decrypt cache.
+func (s *Service) decryptEnvelope(ctx context.Context, value string) (string, error) {
+	encodedKey, encodedData, ok := strings.Cut(value, ":")
+	if !ok {
+		return "", fmt.Errorf("malformed envelope ciphertext")
+	}
+	encryptedKey, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode envelope data key: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encodedData)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode envelope ciphertext: %w", err)
+	}
+
+	if s.isDev {
+		return "", fmt.Errorf("envelope ciphertext requires KMS")
+	}
+
+	dataKey, ok := s.cache.get(encodedKey)
+	if !ok {
+		result, err := s.client.Decrypt(ctx, &kms.DecryptInput{
+			CiphertextBlob: encryptedKey,
+		})
+		if err != nil {
+			return "", fmt.Errorf("KMS data key decryption failed: %w", err)
+		}
+		dataKey = string(result.Plaintext)
+		clear(result.Plaintext)
+		s.cache.put(encodedKey, dataKey)
+	}
+
+	plaintext, err := openAESGCM([]byte(dataKey), sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// sealAESGCM encrypts plaintext with key, returning nonce || ciphertext.
+func sealAESGCM(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// openAESGCM decrypts nonce || ciphertext produced by sealAESGCM.
+func openAESGCM(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("envelope ciphertext too short")
+	}
+	nonce, data := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("envelope decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
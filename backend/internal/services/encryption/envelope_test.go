@@ -0,0 +1,192 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// fakeKMS stands in for AWS KMS: a "ciphertext blob" is the plaintext
+// behind a marker, and GenerateDataKey hands out random AES-256 keys.
+type fakeKMS struct {
+	decryptCalls int
+}
+
+var fakeKMSMarker = []byte("fake-kms:")
+
+func (f *fakeKMS) Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	return &kms.EncryptOutput{CiphertextBlob: append(bytes.Clone(fakeKMSMarker), params.Plaintext...)}, nil
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	f.decryptCalls++
+	plaintext, ok := bytes.CutPrefix(params.CiphertextBlob, fakeKMSMarker)
+	if !ok {
+		return nil, errInvalidCiphertext
+	}
+	return &kms.DecryptOutput{Plaintext: bytes.Clone(plaintext)}, nil
+}
+
+func (f *fakeKMS) GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &kms.GenerateDataKeyOutput{
+		Plaintext:      key,
+		CiphertextBlob: append(bytes.Clone(fakeKMSMarker), key...),
+	}, nil
+}
+
+var errInvalidCiphertext = errors.New("InvalidCiphertextException")
+
+func newTestService(client *fakeKMS) *Service {
+	return &Service{
+		client: client,
+		keyID:  "test-key",
+		cache:  newDecryptCache(DefaultDecryptCacheSize, DecryptCacheTTL),
+	}
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(&fakeKMS{})
+
+	const token = "twitch-access-token-123"
+	ciphertext, err := svc.EncryptEnvelope(ctx, token)
+	if err != nil {
+		t.Fatalf("EncryptEnvelope: %v", err)
+	}
+	if !strings.HasPrefix(ciphertext, envelopePrefix) {
+		t.Errorf("ciphertext %q lacks the %q prefix", ciphertext, envelopePrefix)
+	}
+	if strings.Contains(ciphertext, token) {
+		t.Errorf("ciphertext contains the plaintext")
+	}
+	if !IsEncrypted(ciphertext) {
+		t.Errorf("IsEncrypted(%q) = false", ciphertext)
+	}
+
+	for name, decrypt := range map[string]func(context.Context, string) (string, error){
+		"DecryptEnvelope": svc.DecryptEnvelope,
+		"Decrypt":         svc.Decrypt,
+	} {
+		got, err := decrypt(ctx, ciphertext)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got != token {
+			t.Errorf("%s = %q, want %q", name, got, token)
+		}
+	}
+}
+
+func TestEnvelopeUsesFreshDataKeys(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(&fakeKMS{})
+
+	a, err := svc.EncryptEnvelope(ctx, "same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := svc.EncryptEnvelope(ctx, "same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Error("two encryptions of the same plaintext produced the same ciphertext")
+	}
+}
+
+func TestDecryptEnvelopeLegacyValues(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeKMS{}
+	svc := newTestService(client)
+
+	legacyKMS, err := svc.Encrypt(ctx, "legacy-kms-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(legacyKMS, "kms:") {
+		t.Fatalf("Encrypt = %q, want a kms: value", legacyKMS)
+	}
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		want       string
+	}{
+		{"direct KMS", legacyKMS, "legacy-kms-token"},
+		{"dev mode", "dev:" + base64.StdEncoding.EncodeToString([]byte("dev-token")), "dev-token"},
+		{"plaintext", "not base64!", "not base64!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.DecryptEnvelope(ctx, tt.ciphertext)
+			if err != nil {
+				t.Fatalf("DecryptEnvelope: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DecryptEnvelope = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The direct KMS value was sent to KMS once; a repeat is cached
+	calls := client.decryptCalls
+	if _, err := svc.DecryptEnvelope(ctx, legacyKMS); err != nil {
+		t.Fatal(err)
+	}
+	if client.decryptCalls != calls {
+		t.Errorf("repeat decrypt called KMS again (%d calls, want %d)", client.decryptCalls, calls)
+	}
+}
+
+func TestDecryptEnvelopeRejectsTampering(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(&fakeKMS{})
+
+	ciphertext, err := svc.EncryptEnvelope(ctx, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedKey, encodedData, _ := strings.Cut(strings.TrimPrefix(ciphertext, envelopePrefix), ":")
+	sealed, err := base64.StdEncoding.DecodeString(encodedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed[len(sealed)-1] ^= 0xff
+	tampered := envelopePrefix + encodedKey + ":" + base64.StdEncoding.EncodeToString(sealed)
+
+	if got, err := svc.DecryptEnvelope(ctx, tampered); err == nil {
+		t.Errorf("DecryptEnvelope of a tampered value = %q, want an error", got)
+	}
+
+	for _, malformed := range []string{
+		envelopePrefix + "no-separator",
+		envelopePrefix + "!!!:" + encodedData,
+		envelopePrefix + encodedKey + ":" + base64.StdEncoding.EncodeToString([]byte("short")),
+	} {
+		if _, err := svc.DecryptEnvelope(ctx, malformed); err == nil {
+			t.Errorf("DecryptEnvelope(%q) succeeded, want an error", malformed)
+		}
+	}
+}
+
+func TestDecryptEnvelopeRequiresKMS(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(&fakeKMS{})
+	ciphertext, err := svc.EncryptEnvelope(ctx, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev := &Service{isDev: true}
+	if _, err := dev.DecryptEnvelope(ctx, ciphertext); err == nil {
+		t.Error("dev mode decrypted an envelope value")
+	}
+}
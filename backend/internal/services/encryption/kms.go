@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"strings"
 	"sync"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
@@ -14,9 +15,19 @@ import (
 // In development mode (no KMS key ID), it falls back to base64 encoding
 // which is NOT secure but allows local testing without AWS infrastructure.
 type Service struct {
-	client *kms.Client
+	client kmsAPI
 	keyID  string
 	isDev  bool
+	// cache holds recent KMS plaintexts so a fanout decrypting the same
+	// token for many guilds calls KMS once
+	cache *decryptCache
+}
+
+// kmsAPI is the part of the KMS client the service uses.
+type kmsAPI interface {
+	Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
 }
 
 var (
@@ -45,6 +56,7 @@ func NewService(kmsKeyID string) (*Service, error) {
 			client: kms.NewFromConfig(cfg),
 			keyID:  kmsKeyID,
 			isDev:  false,
+			cache:  newDecryptCache(DefaultDecryptCacheSize, DecryptCacheTTL),
 		}
 	})
 
@@ -54,14 +66,22 @@ func NewService(kmsKeyID string) (*Service, error) {
 	return instance, nil
 }
 
+// SetDecryptCacheSize sets how many KMS plaintexts Decrypt keeps for
+// DecryptCacheTTL (0 or less disables the cache). Dev mode has no cache.
+func (s *Service) SetDecryptCacheSize(size int) {
+	if s.cache != nil {
+		s.cache.resize(size)
+	}
+}
+
 // Encrypt encrypts plaintext using AWS KMS and returns a base64-encoded ciphertext.
-func (s *Service) Encrypt(plaintext string) (string, error) {
+func (s *Service) Encrypt(ctx context.Context, plaintext string) (string, error) {
 	if s.isDev {
 		// Development fallback: base64 encode with a prefix to identify encrypted values
 		return "dev:" + base64.StdEncoding.EncodeToString([]byte(plaintext)), nil
 	}
 
-	result, err := s.client.Encrypt(context.Background(), &kms.EncryptInput{
+	result, err := s.client.Encrypt(ctx, &kms.EncryptInput{
 		KeyId:     &s.keyID,
 		Plaintext: []byte(plaintext),
 	})
@@ -73,7 +93,7 @@ func (s *Service) Encrypt(plaintext string) (string, error) {
 }
 
 // Decrypt decrypts a base64-encoded ciphertext using AWS KMS.
-func (s *Service) Decrypt(ciphertext string) (string, error) {
+func (s *Service) Decrypt(ctx context.Context, ciphertext string) (string, error) {
 	// Handle dev-mode encoded values
 	if len(ciphertext) > 4 && ciphertext[:4] == "dev:" {
 		decoded, err := base64.StdEncoding.DecodeString(ciphertext[4:])
@@ -83,6 +103,11 @@ func (s *Service) Decrypt(ciphertext string) (string, error) {
 		return string(decoded), nil
 	}
 
+	// Handle envelope-encrypted values (EncryptEnvelope)
+	if strings.HasPrefix(ciphertext, envelopePrefix) {
+		return s.decryptEnvelope(ctx, ciphertext[len(envelopePrefix):])
+	}
+
 	// Handle KMS-encrypted values
 	if len(ciphertext) > 4 && ciphertext[:4] == "kms:" {
 		ciphertext = ciphertext[4:]
@@ -101,14 +126,20 @@ func (s *Service) Decrypt(ciphertext string) (string, error) {
 		return string(ciphertextBlob), nil
 	}
 
-	result, err := s.client.Decrypt(context.Background(), &kms.DecryptInput{
+	if plaintext, ok := s.cache.get(ciphertext); ok {
+		return plaintext, nil
+	}
+
+	result, err := s.client.Decrypt(ctx, &kms.DecryptInput{
 		CiphertextBlob: ciphertextBlob,
 	})
 	if err != nil {
 		return "", fmt.Errorf("KMS decryption failed: %w", err)
 	}
 
-	return string(result.Plaintext), nil
+	plaintext := string(result.Plaintext)
+	s.cache.put(ciphertext, plaintext)
+	return plaintext, nil
 }
 
 // IsEncrypted checks if a token value appears to be encrypted (has a prefix).
@@ -116,5 +147,5 @@ func IsEncrypted(value string) bool {
 	if len(value) < 4 {
 		return false
 	}
-	return value[:4] == "kms:" || value[:4] == "dev:"
+	return value[:4] == "kms:" || value[:4] == "dev:" || strings.HasPrefix(value, envelopePrefix)
 }
@@ -8,8 +8,19 @@ import (
 )
 
 // SecurityLogger provides structured security event logging.
-// Events are logged as JSON to stdout (captured by CloudWatch Logs in Lambda).
-type SecurityLogger struct{}
+// Events are logged as JSON to stdout (captured by CloudWatch Logs in Lambda)
+// and, once SetMetrics is called, also counted as metrics.
+type SecurityLogger struct {
+	metrics MetricPublisher
+}
+
+// MetricPublisher receives a metric per security event, with the event's
+// string details as candidate dimensions. The publisher decides which
+// details are safe to use as dimensions.
+// Implemented by monitoring.CloudWatchMonitor.
+type MetricPublisher interface {
+	PublishSecurityMetric(eventType string, success bool, dimensions map[string]string)
+}
 
 // SecurityEvent represents a structured security event.
 type SecurityEvent struct {
@@ -27,6 +38,12 @@ func NewSecurityLogger() *SecurityLogger {
 	return &SecurityLogger{}
 }
 
+// SetMetrics makes every logged event also publish a metric. Call during
+// init, before events are logged.
+func (sl *SecurityLogger) SetMetrics(metrics MetricPublisher) {
+	sl.metrics = metrics
+}
+
 // LogEvent logs a security event as structured JSON.
 func (sl *SecurityLogger) LogEvent(_ context.Context, event SecurityEvent) {
 	event.Timestamp = time.Now()
@@ -36,6 +53,16 @@ func (sl *SecurityLogger) LogEvent(_ context.Context, event SecurityEvent) {
 		return
 	}
 	log.Printf("[SECURITY] %s", string(eventJSON))
+
+	if sl.metrics != nil {
+		dimensions := make(map[string]string)
+		for k, v := range event.Details {
+			if s, ok := v.(string); ok {
+				dimensions[k] = s
+			}
+		}
+		sl.metrics.PublishSecurityMetric(event.EventType, event.Success, dimensions)
+	}
 }
 
 // LogAuthSuccess logs a successful authentication.
@@ -127,6 +154,21 @@ func (sl *SecurityLogger) LogSessionRevoked(ctx context.Context, userID, jti str
 	})
 }
 
+// LogStreamerTokensDeleted logs a user deleting the Twitch tokens stored
+// for a streamer they linked.
+func (sl *SecurityLogger) LogStreamerTokensDeleted(ctx context.Context, userID, streamerID, ipAddress string) {
+	sl.LogEvent(ctx, SecurityEvent{
+		EventType: "streamer_tokens_deleted",
+		Severity:  "INFO",
+		UserID:    userID,
+		IPAddress: ipAddress,
+		Success:   true,
+		Details: map[string]interface{}{
+			"streamer_id": streamerID,
+		},
+	})
+}
+
 // LogAnomalousActivity logs suspected anomalous activity.
 func (sl *SecurityLogger) LogAnomalousActivity(ctx context.Context, userID, description string) {
 	sl.LogEvent(ctx, SecurityEvent{
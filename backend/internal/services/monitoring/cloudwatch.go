@@ -2,7 +2,10 @@ package monitoring
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,11 +15,34 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 )
 
+// maxDatumsPerPut is the most metric data PutMetricData accepts in one call.
+const maxDatumsPerPut = 1000
+
+// DefaultFlushThreshold is how many metrics a namespace buffers before they
+// are sent without waiting for Flush.
+const DefaultFlushThreshold = maxDatumsPerPut
+
 // CloudWatchMonitor publishes custom security metrics to CloudWatch.
 // In development mode, metrics are logged to stdout instead.
+//
+// Metrics are buffered and sent in batches by Flush, which callers run at
+// the end of each Lambda invocation (buffered data would otherwise sit in a
+// frozen instance) and on local server shutdown. A namespace that reaches
+// FlushThreshold during a burst is sent right away in the background.
 type CloudWatchMonitor struct {
-	client *cloudwatch.Client
+	client cloudwatchAPI
 	isDev  bool
+
+	// FlushThreshold caps a namespace's buffer, at most maxDatumsPerPut
+	FlushThreshold int
+
+	mu      sync.Mutex
+	pending map[string][]types.MetricDatum // namespace -> buffered data
+}
+
+// cloudwatchAPI is the part of the CloudWatch client the monitor uses.
+type cloudwatchAPI interface {
+	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
 }
 
 var (
@@ -41,8 +67,11 @@ func NewCloudWatchMonitor(isProduction bool) (*CloudWatchMonitor, error) {
 		}
 
 		instance = &CloudWatchMonitor{
-			client: cloudwatch.NewFromConfig(cfg),
-			isDev:  false,
+			client:  cloudwatch.NewFromConfig(cfg),
+			isDev:   false,
+			pending: make(map[string][]types.MetricDatum),
+
+			FlushThreshold: DefaultFlushThreshold,
 		}
 	})
 
@@ -52,58 +81,116 @@ func NewCloudWatchMonitor(isProduction bool) (*CloudWatchMonitor, error) {
 	return instance, nil
 }
 
-// PublishSecurityMetric publishes a security event metric to CloudWatch.
-func (m *CloudWatchMonitor) PublishSecurityMetric(eventType string, success bool) {
+// PublishSecurityMetric publishes a security event metric to CloudWatch,
+// dimensioned by Success plus any of the given dimensions that are allowed
+// (see allowedDimensions); the rest are dropped to keep cardinality down.
+func (m *CloudWatchMonitor) PublishSecurityMetric(eventType string, success bool, dimensions map[string]string) {
+	dims := append([]types.Dimension{
+		{
+			Name:  aws.String("Success"),
+			Value: aws.String(boolToString(success)),
+		},
+	}, metricDimensions(dimensions)...)
+
 	if m.isDev {
-		log.Printf("[CLOUDWATCH_DEV] Security metric: %s success=%v", eventType, success)
+		log.Printf("[CLOUDWATCH_DEV] Security metric: %s %s", eventType, formatDimensions(dims))
 		return
 	}
 
-	value := 1.0
-
-	_, err := m.client.PutMetricData(context.Background(), &cloudwatch.PutMetricDataInput{
-		Namespace: aws.String("StreamMaxing/Security"),
-		MetricData: []types.MetricDatum{
-			{
-				MetricName: aws.String(eventType),
-				Value:      aws.Float64(value),
-				Unit:       types.StandardUnitCount,
-				Timestamp:  aws.Time(time.Now()),
-				Dimensions: []types.Dimension{
-					{
-						Name:  aws.String("Success"),
-						Value: aws.String(boolToString(success)),
-					},
-				},
-			},
-		},
+	m.buffer("StreamMaxing/Security", types.MetricDatum{
+		MetricName: aws.String(eventType),
+		Value:      aws.Float64(1.0),
+		Unit:       types.StandardUnitCount,
+		Timestamp:  aws.Time(time.Now()),
+		Dimensions: dims,
 	})
-	if err != nil {
-		log.Printf("[CLOUDWATCH_ERROR] Failed to publish metric %s: %v", eventType, err)
-	}
 }
 
-// PublishRateLimitMetric publishes a rate limit event metric.
-func (m *CloudWatchMonitor) PublishRateLimitMetric() {
+// PublishRateLimitMetric publishes a rate limit event metric, dimensioned
+// by the (normalized) endpoint when one is given.
+func (m *CloudWatchMonitor) PublishRateLimitMetric(endpoint string) {
+	dims := metricDimensions(map[string]string{"endpoint": endpoint})
+
 	if m.isDev {
-		log.Printf("[CLOUDWATCH_DEV] Rate limit exceeded metric")
+		log.Printf("[CLOUDWATCH_DEV] Rate limit exceeded metric %s", formatDimensions(dims))
 		return
 	}
 
-	_, err := m.client.PutMetricData(context.Background(), &cloudwatch.PutMetricDataInput{
-		Namespace: aws.String("StreamMaxing/RateLimit"),
-		MetricData: []types.MetricDatum{
-			{
-				MetricName: aws.String("RateLimitExceeded"),
-				Value:      aws.Float64(1.0),
-				Unit:       types.StandardUnitCount,
-				Timestamp:  aws.Time(time.Now()),
-			},
-		},
+	m.buffer("StreamMaxing/RateLimit", types.MetricDatum{
+		MetricName: aws.String("RateLimitExceeded"),
+		Value:      aws.Float64(1.0),
+		Unit:       types.StandardUnitCount,
+		Timestamp:  aws.Time(time.Now()),
+		Dimensions: dims,
+	})
+}
+
+// buffer queues a datum for the next Flush, sending the namespace's batch
+// early once it reaches FlushThreshold.
+func (m *CloudWatchMonitor) buffer(namespace string, datum types.MetricDatum) {
+	m.mu.Lock()
+	m.pending[namespace] = append(m.pending[namespace], datum)
+	var batch []types.MetricDatum
+	if len(m.pending[namespace]) >= min(m.FlushThreshold, maxDatumsPerPut) {
+		batch = m.pending[namespace]
+		delete(m.pending, namespace)
+	}
+	m.mu.Unlock()
+
+	if batch != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := m.put(ctx, namespace, batch); err != nil {
+				log.Printf("[CLOUDWATCH_ERROR] %v", err)
+			}
+		}()
+	}
+}
+
+// Flush sends all buffered metrics, up to maxDatumsPerPut per
+// PutMetricData call. Data from failed calls is dropped and the errors
+// returned; metrics are best-effort. Safe to call with nothing buffered.
+func (m *CloudWatchMonitor) Flush(ctx context.Context) error {
+	if m == nil || m.isDev {
+		return nil
+	}
+
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[string][]types.MetricDatum)
+	m.mu.Unlock()
+
+	var errs []error
+	for namespace, data := range pending {
+		for start := 0; start < len(data); start += maxDatumsPerPut {
+			if err := m.put(ctx, namespace, data[start:min(start+maxDatumsPerPut, len(data))]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// put sends one batch of at most maxDatumsPerPut metrics.
+func (m *CloudWatchMonitor) put(ctx context.Context, namespace string, batch []types.MetricDatum) error {
+	_, err := m.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(namespace),
+		MetricData: batch,
 	})
 	if err != nil {
-		log.Printf("[CLOUDWATCH_ERROR] Failed to publish rate limit metric: %v", err)
+		return fmt.Errorf("failed to publish %d metrics to %s: %w", len(batch), namespace, err)
+	}
+	return nil
+}
+
+// formatDimensions renders dimensions as "Name=Value ..." for dev logging.
+func formatDimensions(dims []types.Dimension) string {
+	parts := make([]string, len(dims))
+	for i, d := range dims {
+		parts[i] = aws.ToString(d.Name) + "=" + aws.ToString(d.Value)
 	}
+	return strings.Join(parts, " ")
 }
 
 func boolToString(b bool) string {
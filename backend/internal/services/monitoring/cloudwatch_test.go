@@ -0,0 +1,115 @@
+package monitoring
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// fakeCloudWatch records PutMetricData calls.
+type fakeCloudWatch struct {
+	mu    sync.Mutex
+	calls []*cloudwatch.PutMetricDataInput
+}
+
+func (f *fakeCloudWatch) PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, params)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func (f *fakeCloudWatch) putCalls() []*cloudwatch.PutMetricDataInput {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*cloudwatch.PutMetricDataInput(nil), f.calls...)
+}
+
+func newTestMonitor(client *fakeCloudWatch) *CloudWatchMonitor {
+	return &CloudWatchMonitor{
+		client:         client,
+		pending:        make(map[string][]types.MetricDatum),
+		FlushThreshold: DefaultFlushThreshold,
+	}
+}
+
+func TestFlushBatchesMetrics(t *testing.T) {
+	client := &fakeCloudWatch{}
+	m := newTestMonitor(client)
+
+	for range 50 {
+		m.PublishSecurityMetric("LoginFailure", false, nil)
+	}
+	if got := len(client.putCalls()); got != 0 {
+		t.Fatalf("%d PutMetricData calls before Flush, want 0", got)
+	}
+
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	calls := client.putCalls()
+	if len(calls) != 1 {
+		t.Fatalf("%d PutMetricData calls, want 1", len(calls))
+	}
+	if ns := aws.ToString(calls[0].Namespace); ns != "StreamMaxing/Security" {
+		t.Errorf("namespace = %q", ns)
+	}
+	if got := len(calls[0].MetricData); got != 50 {
+		t.Errorf("batch has %d metrics, want 50", got)
+	}
+
+	// Nothing left to send
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(client.putCalls()); got != 1 {
+		t.Errorf("%d PutMetricData calls after a second Flush, want 1", got)
+	}
+}
+
+func TestFlushSplitsNamespacesAndLargeBatches(t *testing.T) {
+	client := &fakeCloudWatch{}
+	m := newTestMonitor(client)
+	// Over one call's worth, seeded directly since buffer would send it early
+	m.pending["StreamMaxing/Security"] = make([]types.MetricDatum, maxDatumsPerPut+1)
+	m.PublishRateLimitMetric("/api/guilds")
+
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	sizes := make(map[string][]int)
+	for _, call := range client.putCalls() {
+		ns := aws.ToString(call.Namespace)
+		sizes[ns] = append(sizes[ns], len(call.MetricData))
+	}
+	if got := sizes["StreamMaxing/Security"]; len(got) != 2 || got[0]+got[1] != maxDatumsPerPut+1 {
+		t.Errorf("security batches = %v, want %d metrics over 2 calls", got, maxDatumsPerPut+1)
+	}
+	if got := sizes["StreamMaxing/RateLimit"]; len(got) != 1 || got[0] != 1 {
+		t.Errorf("rate limit batches = %v, want one call of 1", got)
+	}
+}
+
+func TestBufferSendsAtThreshold(t *testing.T) {
+	client := &fakeCloudWatch{}
+	m := newTestMonitor(client)
+	m.FlushThreshold = 10
+
+	for range 10 {
+		m.PublishRateLimitMetric("")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(client.putCalls()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	calls := client.putCalls()
+	if len(calls) != 1 || len(calls[0].MetricData) != 10 {
+		t.Fatalf("calls = %d, want one early batch of 10", len(calls))
+	}
+}
@@ -0,0 +1,74 @@
+package monitoring
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// allowedDimensions maps the detail keys that may become metric dimensions
+// to their CloudWatch dimension names. Every distinct combination of
+// dimension values is a separately billed metric, so only low-cardinality
+// keys are listed: user, streamer and guild IDs, IPs and token IDs are
+// always dropped.
+var allowedDimensions = map[string]string{
+	"reason":   "Reason",
+	"action":   "Action",
+	"endpoint": "Endpoint",
+}
+
+// maxDimensionValueLen bounds a dimension value (CloudWatch allows 1024).
+const maxDimensionValueLen = 128
+
+// metricDimensions turns caller-supplied dimensions into CloudWatch
+// dimensions, dropping keys outside allowedDimensions and empty values.
+// Endpoints are normalized so IDs in the path don't each create a metric.
+// The result is sorted by name so the same set always maps to one metric.
+func metricDimensions(dimensions map[string]string) []types.Dimension {
+	var out []types.Dimension
+	for _, key := range slices.Sorted(maps.Keys(dimensions)) {
+		name, ok := allowedDimensions[key]
+		value := dimensions[key]
+		if !ok || value == "" {
+			continue
+		}
+		if key == "endpoint" {
+			value = normalizeEndpoint(value)
+		}
+		if len(value) > maxDimensionValueLen {
+			value = value[:maxDimensionValueLen]
+		}
+		out = append(out, types.Dimension{Name: aws.String(name), Value: aws.String(value)})
+	}
+	return out
+}
+
+// normalizeEndpoint replaces path segments that look like IDs (Discord
+// snowflakes, Twitch IDs, UUIDs) with ":id", so
+// "/api/guilds/123456789012345678/config" becomes "/api/guilds/:id/config".
+// Any query string is dropped.
+func normalizeEndpoint(endpoint string) string {
+	path, _, _ := strings.Cut(endpoint, "?")
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if looksLikeID(seg) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// looksLikeID reports whether a path segment is a numeric ID or a UUID.
+func looksLikeID(seg string) bool {
+	if seg == "" {
+		return false
+	}
+	if strings.Trim(seg, "0123456789") == "" {
+		return true
+	}
+	return len(seg) == 36 && strings.Count(seg, "-") == 4 &&
+		strings.Trim(seg, "0123456789abcdefABCDEF-") == ""
+}
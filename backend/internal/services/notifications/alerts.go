@@ -0,0 +1,57 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/yourusername/streammaxing/internal/db"
+	discordSvc "github.com/yourusername/streammaxing/internal/services/discord"
+)
+
+// Reasons passed to AlertLinkBroken
+const (
+	LinkBrokenAuthRevoked        = "the streamer revoked StreamMaxing's access on Twitch"
+	LinkBrokenSubscriptionFailed = "Twitch stopped delivering their live events"
+)
+
+// AlertLinkBroken tells every guild tracking the streamer that has
+// alert_admins_on_failure enabled that notifications for the streamer have
+// stopped and the streamer needs to be re-linked. The alert goes to the
+// guild's notification channel. Best-effort: failures are logged per guild.
+func (s *FanoutService) AlertLinkBroken(ctx context.Context, streamer *db.Streamer, reason string) {
+	guildIDs, err := db.GetGuildsTrackingStreamer(ctx, streamer.ID)
+	if err != nil {
+		log.Printf("[ALERT_ERROR] Failed to fetch guilds for streamer %s: %v", streamer.ID, err)
+		return
+	}
+
+	name := streamer.TwitchDisplayName
+	if name == "" {
+		name = streamer.TwitchLogin
+	}
+	message := &discordSvc.DiscordMessage{
+		Content: fmt.Sprintf("⚠️ Live notifications for **%s** are broken: %s. "+
+			"A server admin needs to re-link %s from the StreamMaxing dashboard.", name, reason, name),
+	}
+
+	alerted := 0
+	for _, guildID := range guildIDs {
+		config, err := db.GetGuildConfig(ctx, guildID)
+		if err != nil {
+			log.Printf("[ALERT_ERROR] Guild %s: failed to fetch guild config: %v", guildID, err)
+			continue
+		}
+		if !config.AlertAdminsOnFailure || (config.ChannelID == "" && !config.WebhookConfigured) {
+			continue
+		}
+
+		if _, _, err := PostToGuild(ctx, s.DiscordAPI, config, "Notifications broken for "+name, message); err != nil {
+			log.Printf("[ALERT_ERROR] Guild %s: failed to send link-broken alert: %v", guildID, err)
+			continue
+		}
+		alerted++
+	}
+
+	log.Printf("[ALERT] Link broken for %s (%s), alerted %d/%d guilds", name, reason, alerted, len(guildIDs))
+}
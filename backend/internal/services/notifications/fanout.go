@@ -2,8 +2,11 @@ package notifications
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/yourusername/streammaxing/internal/db"
@@ -16,6 +19,9 @@ type FanoutService struct {
 	TwitchAPI   *twitchSvc.APIClient
 	DiscordAPI  *discordSvc.APIClient
 	TemplateSvc *TemplateService
+	// UserTokens fetches stream data with the streamer's own token,
+	// refreshing it on the way; nil (or a failure) uses the app token
+	UserTokens *twitchSvc.UserTokenService
 }
 
 // NewFanoutService creates a new notification fanout service
@@ -37,105 +43,499 @@ type StreamOnlineEvent struct {
 	StartedAt            string `json:"started_at"`
 }
 
-// HandleStreamOnline processes a stream.online event and fans out notifications
-func (s *FanoutService) HandleStreamOnline(ctx context.Context, eventID string, event StreamOnlineEvent) error {
-	start := time.Now()
+// GuildResult describes the outcome of a notification for a single guild
+type GuildResult struct {
+	GuildID string `json:"guild_id"`
+	Reason  string `json:"reason,omitempty"` // why the guild was skipped
+	Error   string `json:"error,omitempty"`  // why the send failed
+}
 
-	// Fetch full stream data (title, game, viewers, thumbnail)
-	streamData, err := s.TwitchAPI.GetStreamData(event.BroadcasterUserID)
-	if err != nil {
-		log.Printf("[FANOUT_ERROR] Failed to fetch stream data for %s: %v", event.BroadcasterUserID, err)
-		return err
+// FanoutResult summarizes a fanout across all guilds tracking a streamer
+type FanoutResult struct {
+	Total    int           `json:"total"`
+	Sent     []GuildResult `json:"sent"`
+	Skipped  []GuildResult `json:"skipped"`
+	Failed   []GuildResult `json:"failed"`
+	Deferred []GuildResult `json:"deferred"` // not sent before FanoutDeadline
+}
+
+// FanoutDeadline bounds a stream.online fanout. Twitch waits 10s for the
+// webhook response and Lambda freezes whatever is still running after it,
+// so sends still pending at the deadline are cancelled and left unclaimed
+// for the stream poller instead. (The webhook answers 200 regardless, so
+// Twitch does not redeliver the event.)
+const FanoutDeadline = 8 * time.Second
+
+// fetchStreamData fetches a streamer's current stream data, with their user
+// token when UserTokens is set (refreshing an expired one, and flagging the
+// streamer for re-auth if the refresh token was revoked). Any failure other
+// than the stream being offline falls back to the app token, so
+// notifications never depend on the streamer's token.
+func (s *FanoutService) fetchStreamData(ctx context.Context, streamerID, broadcasterID string) (*twitchSvc.StreamData, error) {
+	if s.UserTokens != nil {
+		streamData, err := s.UserTokens.GetStreamData(ctx, streamerID, broadcasterID)
+		if err == nil || errors.Is(err, twitchSvc.ErrStreamOffline) {
+			return streamData, err
+		}
+		if !errors.Is(err, twitchSvc.ErrReauthRequired) {
+			log.Printf("[FANOUT_WARN] User token fetch failed for streamer %s, using app token: %v", streamerID, err)
+		}
 	}
+	return s.TwitchAPI.GetStreamData(ctx, broadcasterID)
+}
+
+// HandleStreamOnline processes a stream.online event and fans out notifications.
+// The returned result reports per-guild outcomes; it is nil if the fanout
+// could not start (stream data, streamer, or guild lookup failed).
+func (s *FanoutService) HandleStreamOnline(ctx context.Context, eventID string, event StreamOnlineEvent) (*FanoutResult, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, FanoutDeadline)
+	defer cancel()
 
 	// Get streamer from database
 	streamer, err := db.GetStreamerByBroadcasterID(ctx, event.BroadcasterUserID)
 	if err != nil {
 		log.Printf("[FANOUT_ERROR] Streamer not found: %s: %v", event.BroadcasterUserID, err)
-		return err
+		return nil, err
+	}
+
+	// Fetch full stream data (title, game, viewers, thumbnail)
+	streamData, err := s.fetchStreamData(ctx, streamer.ID, event.BroadcasterUserID)
+	if err != nil {
+		log.Printf("[FANOUT_ERROR] Failed to fetch stream data for %s: %v", event.BroadcasterUserID, err)
+		return nil, err
 	}
 
 	// Query all guilds tracking this streamer
 	guildIDs, err := db.GetGuildsTrackingStreamer(ctx, streamer.ID)
 	if err != nil {
 		log.Printf("[FANOUT_ERROR] Failed to fetch guilds for streamer %s: %v", streamer.ID, err)
-		return err
+		return nil, err
 	}
 
 	log.Printf("[FANOUT] %s went live, notifying %d guilds", event.BroadcasterUserName, len(guildIDs))
 
+	// Pick up renames from the fresh stream data. The stored row is updated
+	// alongside the sends; we wait for it before returning because Lambda
+	// freezes goroutines once the handler returns.
+	var profileWG sync.WaitGroup
+	if profileChanged(streamer, streamData) {
+		prevName := streamer.TwitchDisplayName
+		updated := *streamer
+		updated.TwitchLogin = streamData.UserLogin
+		updated.TwitchDisplayName = streamData.UserName
+		streamer = &updated
+
+		profileWG.Add(1)
+		go func() {
+			defer profileWG.Done()
+			s.refreshStreamerProfile(ctx, streamer, prevName)
+		}()
+	}
+	defer profileWG.Wait()
+
 	// Fan out to each guild
-	successCount := 0
+	result := &FanoutResult{
+		Total:    len(guildIDs),
+		Sent:     []GuildResult{},
+		Skipped:  []GuildResult{},
+		Failed:   []GuildResult{},
+		Deferred: []GuildResult{},
+	}
 	for _, guildID := range guildIDs {
-		if err := s.sendNotificationToGuild(ctx, guildID, streamer, streamData, eventID); err != nil {
-			log.Printf("[NOTIF_ERROR] Guild %s: %v", guildID, err)
-			// Continue to next guild (don't fail entire fanout)
-		} else {
-			successCount++
+		// Past the deadline: don't start sends that would only be cut off
+		if err := ctx.Err(); err != nil {
+			result.Deferred = append(result.Deferred, GuildResult{GuildID: guildID, Error: err.Error()})
+			continue
 		}
+		skipReason, err := s.sendNotificationToGuild(ctx, guildID, streamer, streamData, eventID)
+		result.record(ctx, guildID, skipReason, err)
 	}
 
+	if len(result.Deferred) > 0 {
+		log.Printf("[FANOUT_WARN] %s: deadline reached, %d guilds deferred", event.BroadcasterUserName, len(result.Deferred))
+	}
 	duration := time.Since(start)
-	log.Printf("[FANOUT] Completed: %s, Guilds: %d/%d, Duration: %v", event.BroadcasterUserName, successCount, len(guildIDs), duration)
+	log.Printf("[FANOUT] Completed: %s, Sent: %d, Skipped: %d, Failed: %d, Deferred: %d, Total: %d, Duration: %v",
+		event.BroadcasterUserName, len(result.Sent), len(result.Skipped), len(result.Failed), len(result.Deferred), result.Total, duration)
 
-	return nil
+	return result, nil
+}
+
+// record files one guild's outcome under Sent, Skipped, Failed, or Deferred
+// (failed because ctx was cancelled mid-send, so the claim was released).
+func (r *FanoutResult) record(ctx context.Context, guildID, skipReason string, err error) {
+	switch {
+	case err != nil && ctx.Err() != nil:
+		log.Printf("[NOTIF_DEFERRED] Guild %s: %v", guildID, err)
+		r.Deferred = append(r.Deferred, GuildResult{GuildID: guildID, Error: err.Error()})
+	case err != nil:
+		log.Printf("[NOTIF_ERROR] Guild %s: %v", guildID, err)
+		// Continue to next guild (don't fail entire fanout)
+		r.Failed = append(r.Failed, GuildResult{GuildID: guildID, Error: err.Error()})
+	case skipReason != "":
+		r.Skipped = append(r.Skipped, GuildResult{GuildID: guildID, Reason: skipReason})
+	default:
+		r.Sent = append(r.Sent, GuildResult{GuildID: guildID})
+	}
+}
+
+// profileChanged reports whether Twitch returned a different login or display
+// name than the stored streamer row.
+func profileChanged(streamer *db.Streamer, streamData *twitchSvc.StreamData) bool {
+	if streamData.UserLogin == "" || streamData.UserName == "" {
+		return false
+	}
+	return streamData.UserLogin != streamer.TwitchLogin || streamData.UserName != streamer.TwitchDisplayName
+}
+
+// refreshStreamerProfile stores a streamer's new login and display name.
+// Best-effort: a failure only means the next notification tries again.
+func (s *FanoutService) refreshStreamerProfile(ctx context.Context, streamer *db.Streamer, prevName string) {
+	changed, err := db.UpdateStreamerProfileIfChanged(ctx, streamer.ID, streamer.TwitchLogin, streamer.TwitchDisplayName)
+	if err != nil {
+		log.Printf("[FANOUT_WARN] Failed to refresh profile for streamer %s: %v", streamer.ID, err)
+		return
+	}
+	if changed {
+		log.Printf("[FANOUT] Refreshed streamer profile: %s -> %s (%s)", prevName, streamer.TwitchDisplayName, streamer.TwitchLogin)
+	}
 }
 
-// sendNotificationToGuild sends a notification to a single guild
+// Skip reasons reported in GuildResult.Reason
+const (
+	SkipReasonDuplicate  = "duplicate"
+	SkipReasonDisabled   = "disabled"
+	SkipReasonQuietHours = "quiet_hours"
+	SkipReasonCategory   = "category_filter"
+	SkipReasonCooldown   = "renotify_cooldown"
+)
+
+// sendNotificationToGuild sends a notification to a single guild.
+// Returns a non-empty skip reason if the guild was intentionally not notified.
 func (s *FanoutService) sendNotificationToGuild(
 	ctx context.Context,
 	guildID string,
 	streamer *db.Streamer,
 	streamData *twitchSvc.StreamData,
 	eventID string,
-) error {
+) (string, error) {
 	// Atomically claim the right to send this notification.
 	// The UNIQUE(guild_id, event_id) constraint ensures only one Lambda instance
 	// can win the insert; all others get a conflict and skip sending.
 	// This eliminates the TOCTOU race that caused duplicate Discord messages.
 	claimed, err := db.TryClaimNotification(ctx, guildID, streamer.ID, eventID)
 	if err != nil {
-		return fmt.Errorf("notification claim failed: %w", err)
+		return "", fmt.Errorf("notification claim failed: %w", err)
 	}
 	if !claimed {
 		log.Printf("[NOTIF_SKIP] Duplicate (already claimed): guild=%s event=%s", guildID, eventID)
-		return nil
+		return SkipReasonDuplicate, nil
+	}
+
+	skipReason, err := s.deliverClaimedNotification(ctx, guildID, streamer, streamData, eventID)
+	if err != nil {
+		// Nothing was posted: give the claim back so the stream poller's
+		// next run can send it instead of skipping it as a duplicate. Not
+		// tied to ctx, which may be why the send failed.
+		if relErr := db.ReleaseNotificationClaim(context.WithoutCancel(ctx), guildID, eventID); relErr != nil {
+			log.Printf("[NOTIF_WARN] Failed to release claim for guild=%s event=%s: %v", guildID, eventID, relErr)
+		}
+		return "", err
 	}
+	return skipReason, nil
+}
 
+// deliverClaimedNotification renders and posts a notification the caller has
+// already claimed. Returns a non-empty skip reason if the guild has
+// notifications disabled, filters out the stream's category, notified about
+// this streamer within its re-notify cooldown, or is in skip-mode quiet
+// hours.
+func (s *FanoutService) deliverClaimedNotification(
+	ctx context.Context,
+	guildID string,
+	streamer *db.Streamer,
+	streamData *twitchSvc.StreamData,
+	eventID string,
+) (string, error) {
 	// Fetch guild configuration
 	config, err := db.GetGuildConfig(ctx, guildID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch guild config: %w", err)
+		return "", fmt.Errorf("failed to fetch guild config: %w", err)
 	}
 
 	if !config.Enabled {
 		log.Printf("[NOTIF_SKIP] Disabled: guild=%s", guildID)
-		return nil
+		return SkipReasonDisabled, nil
+	}
+
+	// Category filter: an empty filter notifies for every category
+	categories, err := db.GetStreamerCategoryFilter(ctx, guildID, streamer.ID)
+	if err != nil {
+		log.Printf("[NOTIF_WARN] Failed to fetch category filter for guild=%s streamer=%s: %v", guildID, streamer.ID, err)
+		categories = nil // Fail open: notify rather than drop
+	}
+	if !matchesCategoryFilter(categories, streamData.GameName) {
+		log.Printf("[NOTIF_SKIP] Category %q not in filter: guild=%s", streamData.GameName, guildID)
+		return SkipReasonCategory, nil
+	}
+
+	// Re-notify cooldown: a stream that dropped and reconnected shortly
+	// after the last notification (or its end) isn't announced again
+	if config.RenotifyCooldownMinutes > 0 {
+		last, err := db.GetLastNotificationTime(ctx, guildID, streamer.ID)
+		if err != nil {
+			log.Printf("[NOTIF_WARN] Failed to fetch last notification for guild=%s streamer=%s: %v", guildID, streamer.ID, err)
+		} else if !last.IsZero() && time.Since(last) < time.Duration(config.RenotifyCooldownMinutes)*time.Minute {
+			log.Printf("[NOTIF_SKIP] Re-notify cooldown (last %s ago): guild=%s streamer=%s",
+				time.Since(last).Round(time.Second), guildID, streamer.ID)
+			return SkipReasonCooldown, nil
+		}
 	}
 
+	// Quiet hours: the claim stays either way, so the stream poller still
+	// treats this event as handled
+	quiet, err := QuietHoursFor(config)
+	if err != nil {
+		log.Printf("[NOTIF_WARN] Ignoring invalid quiet hours for guild=%s: %v", guildID, err)
+		quiet = nil
+	}
+	inQuietHours := quiet != nil && quiet.Contains(time.Now())
+	if inQuietHours && quiet.Mode == QuietHoursSkip {
+		log.Printf("[NOTIF_SKIP] Quiet hours: guild=%s", guildID)
+		return SkipReasonQuietHours, nil
+	}
+
+	message, err := s.renderGuildMessage(ctx, config, streamer, streamData)
+	if err != nil {
+		return "", err
+	}
+
+	if inQuietHours {
+		// Post, but don't ping the mention role
+		message.AllowedMentions = &discordSvc.AllowedMentions{Parse: []string{}}
+	}
+
+	// Send Discord message (a new thread, for a forum channel)
+	channelID, messageID, err := PostToGuild(ctx, s.DiscordAPI, config, streamThreadName(streamer, streamData), message)
+	if err != nil {
+		return "", fmt.Errorf("discord send failed: %w", err)
+	}
+
+	log.Printf("[NOTIF_SENT] Guild=%s Channel=%s Event=%s Message=%s", guildID, channelID, eventID, messageID)
+
+	s.trackMessage(ctx, config, eventID, channelID, messageID)
+	return "", nil
+}
+
+// matchesCategoryFilter reports whether a stream in the given category passes
+// a guild's category filter. Names compare case-insensitively.
+func matchesCategoryFilter(categories []string, gameName string) bool {
+	if len(categories) == 0 {
+		return true
+	}
+	for _, c := range categories {
+		if strings.EqualFold(c, gameName) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderGuildMessage renders a guild's notification for a stream, applying
+// the streamer's custom content override if one is set.
+func (s *FanoutService) renderGuildMessage(
+	ctx context.Context,
+	config *db.GuildConfig,
+	streamer *db.Streamer,
+	streamData *twitchSvc.StreamData,
+) (*discordSvc.DiscordMessage, error) {
 	// Check for per-streamer custom content
-	customContent, err := db.GetStreamerCustomContent(ctx, guildID, streamer.ID)
+	customContent, err := db.GetStreamerCustomContent(ctx, config.GuildID, streamer.ID)
 	if err != nil {
-		log.Printf("[NOTIF_WARN] Failed to fetch custom content for guild=%s streamer=%s: %v", guildID, streamer.ID, err)
+		log.Printf("[NOTIF_WARN] Failed to fetch custom content for guild=%s streamer=%s: %v", config.GuildID, streamer.ID, err)
 		customContent = "" // Fall back to template default
 	}
 
-	// Render message template (with optional custom content override)
-	message, err := s.TemplateSvc.RenderTemplate(config.MessageTemplate, streamer, streamData, config.MentionRoleID)
+	// Render message template (with optional custom content override).
+	// The render limits pings to the guild's mention policy, which also
+	// covers the custom content.
+	opts := RenderOptionsFor(config)
+	message, err := s.TemplateSvc.RenderTemplate(TemplateForGuild(config), streamer, streamData, opts)
 	if err != nil {
-		return fmt.Errorf("template rendering failed: %w", err)
+		return nil, fmt.Errorf("template rendering failed: %w", err)
 	}
 
 	// Override text content if streamer has custom content set
 	if customContent != "" {
-		message.Content = s.TemplateSvc.RenderCustomContent(customContent, streamer, streamData, config.MentionRoleID)
+		message.Content = s.TemplateSvc.RenderCustomContent(customContent, streamer, streamData, opts)
 	}
 
-	// Send Discord message
-	if err := s.DiscordAPI.SendMessage(config.ChannelID, message); err != nil {
+	return message, nil
+}
+
+// trackMessage remembers a message posted by PostToGuild so stream.offline
+// can remove it. If the stream already ended while we were sending, the
+// message is removed right away (when the guild deletes ended
+// notifications). The message is already posted, so this runs even if
+// ctx has been cancelled.
+func (s *FanoutService) trackMessage(ctx context.Context, config *db.GuildConfig, eventID, channelID, messageID string) {
+	ctx = context.WithoutCancel(ctx)
+	guildID := config.GuildID
+	ended, err := db.SetNotificationMessageID(ctx, guildID, eventID, channelID, messageID)
+	if err != nil {
+		log.Printf("[NOTIF_WARN] Failed to store message ID for guild=%s event=%s: %v", guildID, eventID, err)
+		return
+	}
+	if ended && config.DeleteOnOffline {
+		log.Printf("[NOTIF_OFFLINE] Stream ended during send, deleting message: guild=%s message=%s", guildID, messageID)
+		if err := deletePosted(ctx, s.DiscordAPI, config, channelID, messageID); err != nil {
+			log.Printf("[NOTIF_WARN] Failed to delete message for guild=%s: %v", guildID, err)
+		}
+	}
+}
+
+// ChannelUpdateEvent represents the event data from a channel.update EventSub notification
+type ChannelUpdateEvent struct {
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	Title                string `json:"title"`
+	CategoryID           string `json:"category_id"`
+	CategoryName         string `json:"category_name"`
+}
+
+// HandleChannelUpdate processes a channel.update event by re-rendering the
+// live notification in every guild that has one. Updates while the streamer
+// is offline (or with no live message in a guild) are ignored.
+func (s *FanoutService) HandleChannelUpdate(ctx context.Context, event ChannelUpdateEvent) error {
+	streamer, err := db.GetStreamerByBroadcasterID(ctx, event.BroadcasterUserID)
+	if err != nil {
+		log.Printf("[FANOUT_ERROR] Streamer not found: %s: %v", event.BroadcasterUserID, err)
+		return err
+	}
+
+	notifs, err := db.GetLiveNotifications(ctx, streamer.ID)
+	if err != nil {
+		log.Printf("[FANOUT_ERROR] Failed to fetch live notifications for streamer %s: %v", streamer.ID, err)
+		return err
+	}
+	if len(notifs) == 0 {
+		return nil
+	}
+
+	streamData, err := s.fetchStreamData(ctx, streamer.ID, event.BroadcasterUserID)
+	if err != nil {
+		// Offline (or lookup failed): nothing live to refresh
+		log.Printf("[FANOUT] Skipping channel.update for %s: %v", event.BroadcasterUserName, err)
+		return nil
+	}
+
+	// Helix stream data can lag the event by a minute; the event is authoritative
+	if event.Title != "" {
+		streamData.Title = event.Title
+	}
+	if event.CategoryName != "" {
+		streamData.GameID = event.CategoryID
+		streamData.GameName = event.CategoryName
+	}
+
+	updated, failed := 0, 0
+	for _, n := range notifs {
+		if err := s.updateGuildMessage(ctx, n, streamer, streamData); err != nil {
+			log.Printf("[NOTIF_ERROR] Guild %s: failed to update message %s: %v", n.GuildID, n.DiscordMessageID, err)
+			failed++
+			continue
+		}
+		updated++
+	}
+
+	log.Printf("[FANOUT] %s updated channel info, Updated: %d, Failed: %d", event.BroadcasterUserName, updated, failed)
+	return nil
+}
+
+// updateGuildMessage edits a guild's live notification with fresh stream
+// data. If the message was deleted, a new one is posted in its place.
+func (s *FanoutService) updateGuildMessage(
+	ctx context.Context,
+	notif db.NotificationLog,
+	streamer *db.Streamer,
+	streamData *twitchSvc.StreamData,
+) error {
+	config, err := db.GetGuildConfig(ctx, notif.GuildID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch guild config: %w", err)
+	}
+	if !config.Enabled || !config.UpdateOnChannelChange {
+		return nil
+	}
+
+	message, err := s.renderGuildMessage(ctx, config, streamer, streamData)
+	if err != nil {
+		return err
+	}
+
+	err = editPosted(ctx, s.DiscordAPI, config, notif.ChannelID, notif.DiscordMessageID, message)
+	if !errors.Is(err, discordSvc.ErrMessageNotFound) {
+		return err
+	}
+
+	// Message is gone: post a fresh one and track it instead
+	channelID, messageID, err := PostToGuild(ctx, s.DiscordAPI, config, streamThreadName(streamer, streamData), message)
+	if err != nil {
 		return fmt.Errorf("discord send failed: %w", err)
 	}
+	log.Printf("[NOTIF_SENT] Reposted: Guild=%s Channel=%s Event=%s Message=%s", notif.GuildID, channelID, notif.EventID, messageID)
+	s.trackMessage(ctx, config, notif.EventID, channelID, messageID)
+	return nil
+}
+
+// StreamOfflineEvent represents the event data from a stream.offline EventSub notification
+type StreamOfflineEvent struct {
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+}
+
+// HandleStreamOffline processes a stream.offline event by deleting the live
+// notifications posted for the stream.
+//
+// Notifications still being sent are marked ended as well; the online fanout
+// deletes those itself once it has the message ID. Guilds with notifications
+// disabled have a claim but no message, so there is nothing to delete.
+func (s *FanoutService) HandleStreamOffline(ctx context.Context, event StreamOfflineEvent) error {
+	streamer, err := db.GetStreamerByBroadcasterID(ctx, event.BroadcasterUserID)
+	if err != nil {
+		log.Printf("[FANOUT_ERROR] Streamer not found: %s: %v", event.BroadcasterUserID, err)
+		return err
+	}
+
+	notifs, err := db.MarkStreamNotificationsEnded(ctx, streamer.ID)
+	if err != nil {
+		log.Printf("[FANOUT_ERROR] Failed to mark notifications ended for streamer %s: %v", streamer.ID, err)
+		return err
+	}
+
+	deleted, failed := 0, 0
+	for _, n := range notifs {
+		if n.DiscordMessageID == "" {
+			continue
+		}
+		config, err := db.GetGuildConfig(ctx, n.GuildID)
+		if err != nil {
+			log.Printf("[NOTIF_WARN] Guild %s: failed to fetch config, deleting message anyway: %v", n.GuildID, err)
+		} else if !config.DeleteOnOffline {
+			continue
+		}
+		if err := deletePosted(ctx, s.DiscordAPI, config, n.ChannelID, n.DiscordMessageID); err != nil {
+			log.Printf("[NOTIF_ERROR] Guild %s: failed to delete message %s: %v", n.GuildID, n.DiscordMessageID, err)
+			failed++
+			continue
+		}
+		deleted++
+	}
 
-	log.Printf("[NOTIF_SENT] Guild=%s Channel=%s Event=%s", guildID, config.ChannelID, eventID)
+	log.Printf("[FANOUT] %s went offline, Deleted: %d, Failed: %d, Tracked: %d",
+		event.BroadcasterUserName, deleted, failed, len(notifs))
 	return nil
 }
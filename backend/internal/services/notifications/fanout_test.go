@@ -0,0 +1,35 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFanoutResultRecord(t *testing.T) {
+	ctx := context.Background()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	var result FanoutResult
+	result.record(ctx, "sent-1", "", nil)
+	result.record(ctx, "dup", SkipReasonDuplicate, nil)
+	result.record(ctx, "broken", "", errors.New("discord: 403 Missing Access"))
+	result.record(ctx, "sent-2", "", nil)
+	result.record(ctx, "quiet", SkipReasonQuietHours, nil)
+	result.record(cancelled, "late", "", context.Canceled)
+
+	want := FanoutResult{
+		Sent: []GuildResult{{GuildID: "sent-1"}, {GuildID: "sent-2"}},
+		Skipped: []GuildResult{
+			{GuildID: "dup", Reason: SkipReasonDuplicate},
+			{GuildID: "quiet", Reason: SkipReasonQuietHours},
+		},
+		Failed:   []GuildResult{{GuildID: "broken", Error: "discord: 403 Missing Access"}},
+		Deferred: []GuildResult{{GuildID: "late", Error: "context canceled"}},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("result = %+v\nwant     %+v", result, want)
+	}
+}
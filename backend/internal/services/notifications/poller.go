@@ -0,0 +1,134 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yourusername/streammaxing/internal/db"
+)
+
+// DefaultStreamPollInterval is how often PollStreamStatus is expected to
+// run when no interval is configured
+const DefaultStreamPollInterval = 5 * time.Minute
+
+// streamPollLookbackIntervals is how many poll intervals back a stream may
+// have started and still be announced by the poller. More than one, so a
+// failed or late run doesn't lose a stream; bounded, so streams that went
+// live before their streamer was linked aren't announced long after.
+const streamPollLookbackIntervals = 3
+
+// StreamPollDeadline bounds a whole PollStreamStatus run, so it answers
+// within the 30s Lambda and API Gateway timeouts however many streams were
+// missed. Fanouts not started by then are left for the next poll.
+const StreamPollDeadline = 25 * time.Second
+
+// StreamPoller is the fallback for stream.online notifications EventSub
+// didn't deliver (Twitch outages, cold starts). It looks up every tracked
+// broadcaster in batches and fans out streams that went live recently but
+// haven't been claimed by every guild tracking them. The stream ID is the
+// event ID, the same one the webhook path claims with, so
+// TryClaimNotification keeps the two from both posting to a guild, and a
+// fanout deferred at its deadline is finished by the next poll.
+type StreamPoller struct {
+	fanout   *FanoutService
+	interval time.Duration
+}
+
+// StreamPollResult summarizes one PollStreamStatus run
+type StreamPollResult struct {
+	Checked   int `json:"checked"`   // tracked broadcasters looked up
+	Live      int `json:"live"`      // of those, currently live
+	Missed    int `json:"missed"`    // recently started, not notified everywhere
+	Announced int `json:"announced"` // missed streams sent to at least one guild
+	Deferred  int `json:"deferred"`  // missed streams left for the next poll
+}
+
+// NewStreamPoller creates a poller expected to run every interval
+// (<= 0 uses DefaultStreamPollInterval).
+func NewStreamPoller(fanout *FanoutService, interval time.Duration) *StreamPoller {
+	if interval <= 0 {
+		interval = DefaultStreamPollInterval
+	}
+	return &StreamPoller{fanout: fanout, interval: interval}
+}
+
+// Interval is how often the poller expects to run.
+func (p *StreamPoller) Interval() time.Duration {
+	return p.interval
+}
+
+// PollStreamStatus checks all tracked broadcasters and fans out any
+// recently started stream that some tracking guild has no notification for.
+// The run is bounded by StreamPollDeadline.
+func (p *StreamPoller) PollStreamStatus(ctx context.Context) (*StreamPollResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, StreamPollDeadline)
+	defer cancel()
+
+	streamers, err := db.GetLinkedStreamers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tracked streamers: %w", err)
+	}
+	result := &StreamPollResult{Checked: len(streamers)}
+	if len(streamers) == 0 {
+		return result, nil
+	}
+
+	broadcasterIDs := make([]string, len(streamers))
+	for i, s := range streamers {
+		broadcasterIDs[i] = s.TwitchBroadcasterID
+	}
+	streams, err := p.fanout.TwitchAPI.GetStreamsData(ctx, broadcasterIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch streams: %w", err)
+	}
+	result.Live = len(streams)
+
+	// Only streams recent enough to still be worth announcing
+	cutoff := time.Now().Add(-streamPollLookbackIntervals * p.interval)
+	var streamIDs []string
+	for _, stream := range streams {
+		if stream.ID != "" && stream.StartedAt.After(cutoff) {
+			streamIDs = append(streamIDs, stream.ID)
+		}
+	}
+	claimed, err := db.GetFullyClaimedEventIDs(ctx, streamIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check notification log: %w", err)
+	}
+
+	for _, stream := range streams {
+		if stream.ID == "" || !stream.StartedAt.After(cutoff) || claimed[stream.ID] {
+			continue
+		}
+		result.Missed++
+		// Past the deadline: the next poll picks the stream up
+		if ctx.Err() != nil {
+			result.Deferred++
+			continue
+		}
+		log.Printf("[POLL] Missed stream.online for %s (stream %s), fanning out", stream.UserName, stream.ID)
+
+		event := StreamOnlineEvent{
+			ID:                   stream.ID,
+			BroadcasterUserID:    stream.UserID,
+			BroadcasterUserLogin: stream.UserLogin,
+			BroadcasterUserName:  stream.UserName,
+			Type:                 "live",
+			StartedAt:            stream.StartedAt.Format(time.RFC3339),
+		}
+		fanout, err := p.fanout.HandleStreamOnline(ctx, stream.ID, event)
+		if err != nil {
+			log.Printf("[POLL_ERROR] Fanout failed for %s: %v", stream.UserID, err)
+			continue
+		}
+		if len(fanout.Sent) > 0 {
+			result.Announced++
+		}
+	}
+
+	log.Printf("[POLL] Checked: %d, Live: %d, Missed: %d, Announced: %d, Deferred: %d",
+		result.Checked, result.Live, result.Missed, result.Announced, result.Deferred)
+	return result, nil
+}
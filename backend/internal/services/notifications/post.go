@@ -0,0 +1,102 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourusername/streammaxing/internal/db"
+	discordSvc "github.com/yourusername/streammaxing/internal/services/discord"
+	"github.com/yourusername/streammaxing/internal/services/encryption"
+	twitchSvc "github.com/yourusername/streammaxing/internal/services/twitch"
+)
+
+// encryptionSvc decrypts guild webhook URLs. Set via SetEncryptionService
+// at startup.
+var encryptionSvc *encryption.Service
+
+// SetEncryptionService configures the service that decrypts guild webhook
+// URLs for PostToGuild. Without one, webhook guilds can't be posted to.
+func SetEncryptionService(s *encryption.Service) {
+	encryptionSvc = s
+}
+
+// guildWebhookURL returns the decrypted webhook URL a guild posts through,
+// or "" when the bot posts for it.
+func guildWebhookURL(ctx context.Context, config *db.GuildConfig) (string, error) {
+	if config == nil || config.WebhookURL == "" {
+		return "", nil
+	}
+	if encryptionSvc == nil {
+		return "", fmt.Errorf("guild %s uses a webhook but no encryption service is configured", config.GuildID)
+	}
+	webhookURL, err := encryptionSvc.Decrypt(ctx, config.WebhookURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt webhook URL for guild %s: %w", config.GuildID, err)
+	}
+	return webhookURL, nil
+}
+
+// PostToGuild posts a message to a guild's notification channel: through
+// the guild's webhook if it has one, otherwise as the bot, as a plain
+// message in a text channel or a new thread named threadName in a forum
+// channel. Returns the channel the message ended up in (the thread, for a
+// forum) and its ID, which is what editing or deleting it needs.
+func PostToGuild(ctx context.Context, api *discordSvc.APIClient, config *db.GuildConfig, threadName string, message *discordSvc.DiscordMessage) (channelID, messageID string, err error) {
+	webhookURL, err := guildWebhookURL(ctx, config)
+	if err != nil {
+		return "", "", err
+	}
+	if webhookURL != "" {
+		return api.SendViaWebhook(ctx, webhookURL, message)
+	}
+
+	if config.ChannelIsForum {
+		return api.CreateForumPost(ctx, config.ChannelID, threadName, message)
+	}
+	messageID, err = api.SendMessage(ctx, config.ChannelID, message)
+	return config.ChannelID, messageID, err
+}
+
+// editPosted edits a message posted by PostToGuild, the same way it was
+// posted. Returns discordSvc.ErrMessageNotFound if the message is gone.
+func editPosted(ctx context.Context, api *discordSvc.APIClient, config *db.GuildConfig, channelID, messageID string, message *discordSvc.DiscordMessage) error {
+	webhookURL, err := guildWebhookURL(ctx, config)
+	if err != nil {
+		return err
+	}
+	if webhookURL != "" {
+		return api.EditWebhookMessage(ctx, webhookURL, messageID, message)
+	}
+	return api.EditMessage(ctx, channelID, messageID, message)
+}
+
+// deletePosted removes a message posted by PostToGuild. Webhook guilds'
+// messages are deleted through the webhook. A bot forum post is recognized
+// by its message ID matching its thread's ID, and the whole thread is
+// deleted rather than leaving it empty. config may be nil when it couldn't
+// be loaded; the bot then tries the delete.
+func deletePosted(ctx context.Context, api *discordSvc.APIClient, config *db.GuildConfig, channelID, messageID string) error {
+	webhookURL, err := guildWebhookURL(ctx, config)
+	if err != nil {
+		return err
+	}
+	if webhookURL != "" {
+		return api.DeleteWebhookMessage(ctx, webhookURL, messageID)
+	}
+	if channelID == messageID {
+		return api.DeleteChannel(ctx, channelID)
+	}
+	return api.DeleteMessage(ctx, channelID, messageID)
+}
+
+// streamThreadName names the forum thread for a live notification.
+func streamThreadName(streamer *db.Streamer, streamData *twitchSvc.StreamData) string {
+	name := streamer.TwitchDisplayName
+	if name == "" {
+		name = streamer.TwitchLogin
+	}
+	if streamData.Title == "" {
+		return fmt.Sprintf("%s is live", name)
+	}
+	return fmt.Sprintf("%s is live: %s", name, streamData.Title)
+}
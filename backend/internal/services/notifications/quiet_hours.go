@@ -0,0 +1,96 @@
+package notifications
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yourusername/streammaxing/internal/db"
+)
+
+// Quiet hours modes (guild_config.quiet_hours_mode)
+const (
+	// QuietHoursSilent posts the notification without pinging anyone
+	QuietHoursSilent = "silent"
+	// QuietHoursSkip posts nothing
+	QuietHoursSkip = "skip"
+)
+
+// QuietHours is a daily window, in a guild's timezone, during which
+// notifications are posted silently or not at all.
+type QuietHours struct {
+	start, end int // minutes after midnight
+	loc        *time.Location
+	Mode       string
+}
+
+// ParseQuietHours validates a guild's quiet hours settings. start and end
+// are "HH:MM" (24h); a window with start after end wraps past midnight.
+// Returns nil, nil when quiet hours are not configured.
+func ParseQuietHours(start, end, timezone, mode string) (*QuietHours, error) {
+	if start == "" && end == "" {
+		return nil, nil
+	}
+	if start == "" || end == "" {
+		return nil, fmt.Errorf("quiet hours need both a start and an end")
+	}
+
+	startMin, err := parseClock(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours start: %w", err)
+	}
+	endMin, err := parseClock(end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours end: %w", err)
+	}
+	if startMin == endMin {
+		return nil, fmt.Errorf("quiet hours start and end must differ")
+	}
+
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown quiet hours timezone %q", timezone)
+	}
+
+	switch mode {
+	case "":
+		mode = QuietHoursSilent
+	case QuietHoursSilent, QuietHoursSkip:
+	default:
+		return nil, fmt.Errorf("unknown quiet hours mode %q", mode)
+	}
+
+	return &QuietHours{start: startMin, end: endMin, loc: loc, Mode: mode}, nil
+}
+
+// QuietHoursFor returns the guild's quiet hours, or nil if none are set.
+func QuietHoursFor(config *db.GuildConfig) (*QuietHours, error) {
+	return ParseQuietHours(config.QuietHoursStart, config.QuietHoursEnd, config.QuietHoursTimezone, config.QuietHoursMode)
+}
+
+// Contains reports whether t falls inside the window.
+func (q *QuietHours) Contains(t time.Time) bool {
+	local := t.In(q.loc)
+	m := local.Hour()*60 + local.Minute()
+	if q.start < q.end {
+		return m >= q.start && m < q.end
+	}
+	// Wraps past midnight (e.g. 22:00-07:00)
+	return m >= q.start || m < q.end
+}
+
+// Timezone returns the IANA name of the window's timezone.
+func (q *QuietHours) Timezone() string {
+	return q.loc.String()
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
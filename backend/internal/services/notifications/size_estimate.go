@@ -0,0 +1,111 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yourusername/streammaxing/internal/db"
+	discordSvc "github.com/yourusername/streammaxing/internal/services/discord"
+)
+
+// maxVariableLengths is the longest text each template variable can render
+// to, used to estimate worst-case message sizes. URL-valued variables are
+// included for completeness though Discord doesn't count URLs toward limits.
+var maxVariableLengths = map[string]int{
+	db.TemplateVarStreamerLogin:       25,  // Twitch login limit
+	db.TemplateVarStreamerDisplayName: 25,  // Twitch display name limit
+	db.TemplateVarStreamerAvatarURL:   200, // CDN URL
+	db.TemplateVarStreamTitle:         140, // Twitch title limit
+	db.TemplateVarGameName:            100, // Longest category names
+	db.TemplateVarViewerCount:         7,
+	db.TemplateVarStreamThumbnailURL:  200, // CDN URL
+	db.TemplateVarStartedAt:           96,  // RFC 3339, or a custom TimeFormat
+	db.TemplateVarStartedAtDiscord:    16,  // <t:unix:f>
+	db.TemplateVarStartedAtRelative:   16,  // <t:unix:R>
+	db.TemplateVarStreamUptime:        7,   // e.g. 23h59m
+	db.TemplateVarMentionRole:         24,  // <@&snowflake>
+	db.TemplateVarMentionEveryone:     9,   // @everyone
+}
+
+// nearLimitRatio is the share of a limit at which EstimateSize warns
+const nearLimitRatio = 0.9
+
+// SizeEstimate is the worst-case rendered size of a message template, in
+// characters, with every variable expanded to its longest value.
+type SizeEstimate struct {
+	Content          int         `json:"content"`
+	EmbedTitle       int         `json:"embed_title"`
+	EmbedDescription int         `json:"embed_description"`
+	EmbedFields      []FieldSize `json:"embed_fields"`
+	EmbedFooter      int         `json:"embed_footer"`
+	// EmbedTotal is what Discord counts against MaxEmbedTotal
+	EmbedTotal int `json:"embed_total"`
+	// Warnings flag parts at or near Discord's limits
+	Warnings []string `json:"warnings"`
+}
+
+// FieldSize is the worst-case size of one embed field
+type FieldSize struct {
+	Name  int `json:"name"`
+	Value int `json:"value"`
+}
+
+// EstimateSize returns the worst-case rendered size of a template and warns
+// about any part that could exceed, or come within 10% of, Discord's limits.
+func (s *TemplateService) EstimateSize(tmpl *db.MessageTemplate) *SizeEstimate {
+	est := &SizeEstimate{
+		Content:     estimateLength(tmpl.Content),
+		EmbedFields: []FieldSize{},
+		Warnings:    []string{},
+	}
+	est.checkLimit("content", est.Content, discordSvc.MaxMessageContent)
+
+	if embed := tmpl.Embed; embed != nil {
+		est.EmbedTitle = estimateLength(embed.Title)
+		est.EmbedDescription = estimateLength(embed.Description)
+		est.checkLimit("embed title", est.EmbedTitle, discordSvc.MaxEmbedTitle)
+		est.checkLimit("embed description", est.EmbedDescription, discordSvc.MaxEmbedDescription)
+		est.EmbedTotal = est.EmbedTitle + est.EmbedDescription
+
+		for i, field := range embed.Fields {
+			size := FieldSize{Name: estimateLength(field.Name), Value: estimateLength(field.Value)}
+			est.checkLimit(fmt.Sprintf("field %d name", i+1), size.Name, discordSvc.MaxEmbedFieldName)
+			est.checkLimit(fmt.Sprintf("field %d value", i+1), size.Value, discordSvc.MaxEmbedFieldValue)
+			est.EmbedFields = append(est.EmbedFields, size)
+			est.EmbedTotal += size.Name + size.Value
+		}
+
+		if embed.Footer != nil {
+			est.EmbedFooter = estimateLength(embed.Footer.Text)
+			est.checkLimit("embed footer", est.EmbedFooter, discordSvc.MaxEmbedFooter)
+			est.EmbedTotal += est.EmbedFooter
+		}
+		est.checkLimit("embed total", est.EmbedTotal, discordSvc.MaxEmbedTotal)
+	}
+
+	return est
+}
+
+// checkLimit adds a warning if size exceeds or nears limit.
+func (e *SizeEstimate) checkLimit(what string, size, limit int) {
+	switch {
+	case size > limit:
+		e.Warnings = append(e.Warnings, fmt.Sprintf("%s can reach %d characters, over Discord's limit of %d", what, size, limit))
+	case float64(size) >= nearLimitRatio*float64(limit):
+		e.Warnings = append(e.Warnings, fmt.Sprintf("%s can reach %d characters, close to Discord's limit of %d", what, size, limit))
+	}
+}
+
+// estimateLength returns the length of text with every known variable
+// expanded to its longest value. Unknown placeholders count as written.
+func estimateLength(text string) int {
+	n := utf8.RuneCountInString(text)
+	for name, max := range maxVariableLengths {
+		placeholder := "{" + name + "}"
+		if count := strings.Count(text, placeholder); count > 0 {
+			n += count * (max - utf8.RuneCountInString(placeholder))
+		}
+	}
+	return n
+}
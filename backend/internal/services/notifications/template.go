@@ -3,7 +3,9 @@ package notifications
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/yourusername/streammaxing/internal/db"
 	discordSvc "github.com/yourusername/streammaxing/internal/services/discord"
@@ -18,36 +20,48 @@ func NewTemplateService() *TemplateService {
 	return &TemplateService{}
 }
 
+// RenderOptions are the guild settings that shape a rendered notification
+type RenderOptions struct {
+	MentionRoleID   string
+	MentionEveryone bool
+	// Location and TimeFormat render {started_at}; nil and "" mean UTC and
+	// RFC 3339
+	Location   *time.Location
+	TimeFormat string
+}
+
+// RenderOptionsFor returns the render options of a guild's config.
+func RenderOptionsFor(config *db.GuildConfig) RenderOptions {
+	opts := RenderOptions{
+		MentionRoleID:   config.MentionRoleID,
+		MentionEveryone: config.MentionEveryone,
+		TimeFormat:      config.TimeFormat,
+	}
+	if config.Timezone != "" {
+		loc, err := time.LoadLocation(config.Timezone)
+		if err != nil {
+			// Validated on save, so only a zoneinfo change gets here
+			log.Printf("[NOTIF_WARN] Guild %s timezone %q: %v, using UTC", config.GuildID, config.Timezone, err)
+		} else {
+			opts.Location = loc
+		}
+	}
+	return opts
+}
+
 // RenderTemplate renders a message template with streamer and stream data
 func (s *TemplateService) RenderTemplate(
 	templateJSON json.RawMessage,
 	streamer *db.Streamer,
 	streamData *twitchSvc.StreamData,
-	mentionRoleID string,
+	opts RenderOptions,
 ) (*discordSvc.DiscordMessage, error) {
 	var tmpl db.MessageTemplate
 	if err := json.Unmarshal(templateJSON, &tmpl); err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	// Build variable map
-	vars := map[string]string{
-		"{streamer_login}":        streamer.TwitchLogin,
-		"{streamer_display_name}": streamer.TwitchDisplayName,
-		"{streamer_avatar_url}":   streamer.TwitchAvatarURL,
-		"{stream_title}":          streamData.Title,
-		"{game_name}":             streamData.GameName,
-		"{viewer_count}":          fmt.Sprintf("%d", streamData.ViewerCount),
-		"{stream_thumbnail_url}":  strings.ReplaceAll(streamData.ThumbnailURL, "{width}x{height}", "1920x1080"),
-		"{started_at}":            streamData.StartedAt.Format("2006-01-02T15:04:05Z07:00"),
-	}
-
-	// Add mention role
-	if mentionRoleID != "" {
-		vars["{mention_role}"] = fmt.Sprintf("<@&%s>", mentionRoleID)
-	} else {
-		vars["{mention_role}"] = ""
-	}
+	vars := templateVars(streamer, streamData, opts)
 
 	// Render content
 	content := replaceVariables(tmpl.Content, vars)
@@ -88,6 +102,8 @@ func (s *TemplateService) RenderTemplate(
 			}
 		}
 
+		// Discord shows embed timestamps in each viewer's timezone, so this
+		// stays ISO 8601 regardless of the guild's time settings
 		if tmpl.Embed.Timestamp {
 			embed.Timestamp = streamData.StartedAt.Format("2006-01-02T15:04:05Z07:00")
 		}
@@ -96,8 +112,9 @@ func (s *TemplateService) RenderTemplate(
 	}
 
 	return &discordSvc.DiscordMessage{
-		Content: content,
-		Embeds:  embeds,
+		Content:         content,
+		Embeds:          embeds,
+		AllowedMentions: allowedMentions(opts.MentionRoleID, opts.MentionEveryone),
 	}, nil
 }
 
@@ -106,28 +123,94 @@ func (s *TemplateService) RenderCustomContent(
 	content string,
 	streamer *db.Streamer,
 	streamData *twitchSvc.StreamData,
-	mentionRoleID string,
+	opts RenderOptions,
 ) string {
-	vars := map[string]string{
-		"{streamer_login}":        streamer.TwitchLogin,
-		"{streamer_display_name}": streamer.TwitchDisplayName,
-		"{streamer_avatar_url}":   streamer.TwitchAvatarURL,
-		"{stream_title}":          streamData.Title,
-		"{game_name}":             streamData.GameName,
-		"{viewer_count}":          fmt.Sprintf("%d", streamData.ViewerCount),
+	vars := templateVars(streamer, streamData, opts)
+	return replaceVariables(content, vars)
+}
+
+// TemplateForGuild returns the template a guild's notifications render:
+// its tone's preset while the stored template is still a preset, otherwise
+// the stored template.
+func TemplateForGuild(config *db.GuildConfig) json.RawMessage {
+	if config.Tone == db.ToneDefault || !db.IsPresetTemplate(config.MessageTemplate) {
+		return config.MessageTemplate
 	}
-	if mentionRoleID != "" {
-		vars["{mention_role}"] = fmt.Sprintf("<@&%s>", mentionRoleID)
-	} else {
-		vars["{mention_role}"] = ""
+	toneJSON, err := json.Marshal(db.MessageTemplateForTone(config.Tone))
+	if err != nil {
+		return config.MessageTemplate
 	}
-	return replaceVariables(content, vars)
+	return toneJSON
 }
 
-// replaceVariables replaces template variables with their values
-func replaceVariables(text string, vars map[string]string) string {
-	for key, value := range vars {
-		text = strings.ReplaceAll(text, key, value)
+// templateVars returns a replacer substituting every variable in
+// db.TemplateVariables for its {placeholder}. Mention variables render empty
+// when the guild hasn't configured them. Other values come from the streamer
+// (titles, game names), so their @everyone/@here are defused: they must not
+// ping even when the guild allows the everyone mention.
+func templateVars(
+	streamer *db.Streamer,
+	streamData *twitchSvc.StreamData,
+	opts RenderOptions,
+) *strings.Replacer {
+	values := map[string]string{
+		db.TemplateVarStreamerLogin:       streamer.TwitchLogin,
+		db.TemplateVarStreamerDisplayName: streamer.TwitchDisplayName,
+		db.TemplateVarStreamerAvatarURL:   streamer.TwitchAvatarURL,
+		db.TemplateVarStreamTitle:         streamData.Title,
+		db.TemplateVarGameName:            streamData.GameName,
+		db.TemplateVarViewerCount:         fmt.Sprintf("%d", streamData.ViewerCount),
+		db.TemplateVarStreamThumbnailURL:  strings.ReplaceAll(streamData.ThumbnailURL, "{width}x{height}", "1920x1080"),
+		db.TemplateVarStartedAt:           formatStartedAt(streamData.StartedAt, opts.Location, opts.TimeFormat),
+		db.TemplateVarStartedAtDiscord:    discordTimestamp(streamData.StartedAt, "f"),
+		db.TemplateVarStartedAtRelative:   discordTimestamp(streamData.StartedAt, "R"),
+		db.TemplateVarStreamUptime:        formatUptime(streamData.StartedAt),
+		db.TemplateVarMentionRole:         "",
+		db.TemplateVarMentionEveryone:     "",
+	}
+	for name, value := range values {
+		values[name] = escapeMassMentions(value)
+	}
+	if opts.MentionRoleID != "" {
+		values[db.TemplateVarMentionRole] = fmt.Sprintf("<@&%s>", opts.MentionRoleID)
+	}
+	if opts.MentionEveryone {
+		values[db.TemplateVarMentionEveryone] = "@everyone"
+	}
+
+	pairs := make([]string, 0, 2*len(db.TemplateVariables))
+	for _, name := range db.TemplateVariables {
+		pairs = append(pairs, "{"+name+"}", values[name])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// massMentionEscaper breaks @everyone and @here with a zero-width space so
+// Discord shows them as text
+var massMentionEscaper = strings.NewReplacer("@everyone", "@\u200beveryone", "@here", "@\u200bhere")
+
+// escapeMassMentions defuses @everyone and @here in a variable value
+func escapeMassMentions(value string) string {
+	return massMentionEscaper.Replace(value)
+}
+
+// allowedMentions returns the mentions a notification may ping: only the
+// guild's mention role, plus @everyone/@here if the guild opted in. Any
+// other mention a template or custom content contains (users, other roles,
+// a literal "@everyone") shows as text without pinging anyone.
+func allowedMentions(mentionRoleID string, mentionEveryone bool) *discordSvc.AllowedMentions {
+	am := &discordSvc.AllowedMentions{Parse: []string{}}
+	if mentionEveryone {
+		am.Parse = append(am.Parse, "everyone")
 	}
-	return text
+	if mentionRoleID != "" {
+		am.Roles = []string{mentionRoleID}
+	}
+	return am
+}
+
+// replaceVariables replaces template variables with their values in a
+// single pass, so a value containing a {placeholder} is never expanded
+func replaceVariables(text string, vars *strings.Replacer) string {
+	return vars.Replace(text)
 }
@@ -0,0 +1,77 @@
+package notifications
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/yourusername/streammaxing/internal/db"
+	twitchSvc "github.com/yourusername/streammaxing/internal/services/twitch"
+)
+
+func TestRenderDiscordTimestamps(t *testing.T) {
+	// 1767323045 is 2026-01-02T03:04:05Z
+	startedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	streamer := &db.Streamer{TwitchLogin: "alpha", TwitchDisplayName: "Alpha"}
+	stream := &twitchSvc.StreamData{Title: "Live", StartedAt: startedAt}
+	// The guild's timezone only affects {started_at}
+	opts := RenderOptions{Location: time.FixedZone("UTC+9", 9*60*60)}
+	s := NewTemplateService()
+
+	tmpl, err := json.Marshal(db.MessageTemplate{
+		Content: "Live since {started_at_discord} ({started_at_relative})",
+		Embed:   &db.EmbedObject{Description: "Started {started_at_relative}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := s.RenderTemplate(tmpl, streamer, stream, opts)
+	if err != nil {
+		t.Fatalf("RenderTemplate: %v", err)
+	}
+	if want := "Live since <t:1767323045:f> (<t:1767323045:R>)"; msg.Content != want {
+		t.Errorf("content = %q, want %q", msg.Content, want)
+	}
+	if want := "Started <t:1767323045:R>"; len(msg.Embeds) != 1 || msg.Embeds[0].Description != want {
+		t.Errorf("embeds = %+v, want description %q", msg.Embeds, want)
+	}
+
+	custom := s.RenderCustomContent("{started_at_discord}|{started_at_relative}", streamer, stream, opts)
+	if want := "<t:1767323045:f>|<t:1767323045:R>"; custom != want {
+		t.Errorf("custom content = %q, want %q", custom, want)
+	}
+
+	// An unparseable started_at renders empty rather than <t:-62135596800:f>
+	empty := s.RenderCustomContent("[{started_at_discord}{started_at_relative}]", streamer, &twitchSvc.StreamData{}, opts)
+	if empty != "[]" {
+		t.Errorf("zero start = %q, want %q", empty, "[]")
+	}
+}
+
+func TestRenderEscapesMassMentionsInValues(t *testing.T) {
+	streamer := &db.Streamer{TwitchLogin: "alpha", TwitchDisplayName: "@here Alpha"}
+	stream := &twitchSvc.StreamData{Title: "@everyone come watch", GameName: "@here"}
+	opts := RenderOptions{MentionEveryone: true}
+	s := NewTemplateService()
+
+	got := s.RenderCustomContent("{mention_everyone} {streamer_display_name}: {stream_title} ({game_name})", streamer, stream, opts)
+	want := "@everyone @\u200bhere Alpha: @\u200beveryone come watch (@\u200bhere)"
+	if got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+}
+
+func TestRenderDoesNotExpandValues(t *testing.T) {
+	streamer := &db.Streamer{TwitchLogin: "alpha", TwitchDisplayName: "Alpha"}
+	stream := &twitchSvc.StreamData{Title: "{mention_everyone} {mention_role} {streamer_login}"}
+	opts := RenderOptions{MentionEveryone: true, MentionRoleID: "42"}
+	s := NewTemplateService()
+
+	// Repeat to cover every map iteration order the old renderer could hit
+	for range 20 {
+		got := s.RenderCustomContent("{stream_title}", streamer, stream, opts)
+		if got != stream.Title {
+			t.Fatalf("content = %q, want the title verbatim %q", got, stream.Title)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxTimeFormatLength caps GuildConfig.TimeFormat
+const maxTimeFormatLength = 64
+
+// ValidateTimeSettings checks a guild's template timezone and time format
+// and returns the timezone's canonical name ("" becomes "UTC"). format is a
+// Go reference layout ("Jan 2, 2006 15:04 MST"); empty means RFC 3339.
+func ValidateTimeSettings(timezone, format string) (string, error) {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	// "Local" is the server's zone, not something a guild can mean
+	if timezone == "Local" {
+		return "", fmt.Errorf("unknown timezone %q", timezone)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return "", fmt.Errorf("unknown timezone %q", timezone)
+	}
+
+	if len(format) > maxTimeFormatLength {
+		return "", fmt.Errorf("time format must be at most %d characters", maxTimeFormatLength)
+	}
+	if strings.ContainsAny(format, "{}\n") {
+		return "", fmt.Errorf("time format may not contain braces or newlines")
+	}
+	// A layout without any reference element renders as itself
+	if format != "" && time.Date(2001, 3, 4, 5, 6, 7, 0, time.UTC).Format(format) == format {
+		return "", fmt.Errorf("time format must use Go's reference time, e.g. \"Jan 2, 2006 15:04 MST\"")
+	}
+	return loc.String(), nil
+}
+
+// formatStartedAt renders a stream start time in loc with layout (empty =
+// RFC 3339). Zero times (unparseable started_at) render empty.
+func formatStartedAt(t time.Time, loc *time.Location, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return t.In(loc).Format(layout)
+}
+
+// discordTimestamp renders t as a Discord timestamp token (<t:unix:style>),
+// which each viewer's client shows in their own locale and timezone. Zero
+// times render empty.
+func discordTimestamp(t time.Time, style string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("<t:%d:%s>", t.Unix(), style)
+}
+
+// formatUptime renders how long ago start was as "5m", "1h3m" or "2h",
+// computed at send time so a delayed notification still says how long the
+// stream has been live. Zero times render empty.
+func formatUptime(start time.Time) string {
+	if start.IsZero() {
+		return ""
+	}
+	elapsed := max(time.Since(start), 0) // clock skew can put start ahead
+	hours := int(elapsed / time.Hour)
+	minutes := int(elapsed % time.Hour / time.Minute)
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+}
@@ -27,9 +27,11 @@ type cachedSecret struct {
 	fetchedAt time.Time
 }
 
-// JWTSecret holds the JWT signing secret.
+// JWTSecret holds the JWT signing secret. PreviousSecret is the secret it
+// replaced, still accepted for validation during a rotation window.
 type JWTSecret struct {
-	Secret string `json:"jwt_secret"`
+	Secret         string `json:"jwt_secret"`
+	PreviousSecret string `json:"previous_jwt_secret,omitempty"`
 }
 
 // DiscordOAuth holds Discord OAuth credentials.
@@ -101,15 +103,41 @@ func (m *Manager) GetJWTSecret() (string, error) {
 	}
 
 	// Fallback: try AWS Secrets Manager (requires IAM permissions + the secret to exist)
+	s, err := m.getJWTSecret()
+	if err != nil {
+		return "", err
+	}
+	return s.Secret, nil
+}
+
+// GetPreviousJWTSecret returns the JWT secret being rotated out, or "" if
+// there is none. Tokens signed with it are still accepted but new tokens
+// always use GetJWTSecret. It comes from the same place as the current
+// secret: JWT_SECRET_PREVIOUS alongside JWT_SECRET, otherwise the
+// previous_jwt_secret field of the Secrets Manager secret.
+func (m *Manager) GetPreviousJWTSecret() (string, error) {
+	if os.Getenv("JWT_SECRET") != "" || m.isDev {
+		return os.Getenv("JWT_SECRET_PREVIOUS"), nil
+	}
+
+	s, err := m.getJWTSecret()
+	if err != nil {
+		return "", err
+	}
+	return s.PreviousSecret, nil
+}
+
+// getJWTSecret fetches and parses the JWT secret from Secrets Manager.
+func (m *Manager) getJWTSecret() (*JWTSecret, error) {
 	var s JWTSecret
 	raw, err := m.getSecret("streammaxing/jwt-secret")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if err := json.Unmarshal([]byte(raw), &s); err != nil {
-		return "", fmt.Errorf("failed to parse JWT secret: %w", err)
+		return nil, fmt.Errorf("failed to parse JWT secret: %w", err)
 	}
-	return s.Secret, nil
+	return &s, nil
 }
 
 // GetDiscordOAuth returns Discord OAuth credentials.
@@ -188,6 +216,7 @@ func (m *Manager) getSecret(secretName string) (string, error) {
 }
 
 // InvalidateCache clears all cached secrets (call after secret rotation).
+// The next lookup picks up both the new JWT secret and the previous one.
 func (m *Manager) InvalidateCache() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -1,15 +1,33 @@
 package twitch
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
+// HTTPClient sends HTTP requests. *http.Client satisfies it; tests can
+// substitute one backed by an httptest.Server.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// newHTTPClient returns the default HTTPClient for the services in this
+// package.
+func newHTTPClient() HTTPClient {
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
 // APIClient handles Twitch API calls with automatic app access token management
 type APIClient struct {
 	ClientID       string
@@ -17,20 +35,54 @@ type APIClient struct {
 	appAccessToken string
 	tokenExpiry    time.Time
 	mu             sync.RWMutex
-	httpClient     *http.Client
+	tokenGroup     singleflight.Group
+	// HTTPClient sends all requests (default: 10s timeout)
+	HTTPClient HTTPClient
+
+	// liveCache holds recent GetLiveStatus results per broadcaster
+	liveMu    sync.Mutex
+	liveCache map[string]liveStatusEntry
+
+	// TokenRefreshWindow is how long before expiry the app access token is
+	// treated as expired and refreshed. Tokens whose lifetime is shorter
+	// than the window are refreshed halfway through their lifetime instead.
+	TokenRefreshWindow time.Duration
 }
 
 // NewAPIClient creates a new Twitch API client with the given credentials.
 func NewAPIClient(clientID, clientSecret string) *APIClient {
 	return &APIClient{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		ClientID:           clientID,
+		ClientSecret:       clientSecret,
+		HTTPClient:         newHTTPClient(),
+		TokenRefreshWindow: defaultTokenRefreshWindow,
+	}
+}
+
+// defaultTokenRefreshWindow is the default value of TokenRefreshWindow.
+const defaultTokenRefreshWindow = 5 * time.Minute
+
+// tokenExpiryFor returns when a token issued now with the given lifetime
+// should be considered expired. The result is always in the future for a
+// positive lifetime, however large the refresh window.
+func (c *APIClient) tokenExpiryFor(now time.Time, expiresIn int) time.Time {
+	lifetime := time.Duration(expiresIn) * time.Second
+	window := c.TokenRefreshWindow
+	if window < 0 {
+		window = 0
+	}
+	if window >= lifetime {
+		window = lifetime / 2
 	}
+	return now.Add(lifetime - window)
 }
 
-// GetAppAccessToken returns a valid app access token, refreshing if expired
-func (c *APIClient) GetAppAccessToken() (string, error) {
+// GetAppAccessToken returns a valid app access token, refreshing if expired.
+// The token request runs outside the mutex so a slow Twitch response never
+// blocks callers that only need the cached token. Concurrent refreshes are
+// coalesced into a single request whose result every waiter shares; each
+// caller still stops waiting when its own ctx is done.
+func (c *APIClient) GetAppAccessToken(ctx context.Context) (string, error) {
 	c.mu.RLock()
 	if c.appAccessToken != "" && time.Now().Before(c.tokenExpiry) {
 		token := c.appAccessToken
@@ -39,41 +91,81 @@ func (c *APIClient) GetAppAccessToken() (string, error) {
 	}
 	c.mu.RUnlock()
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	// The shared request must not fail for everyone when the caller that
+	// happened to start it gives up, so it runs detached from ctx's
+	// cancellation (the HTTP client timeout still bounds it).
+	ch := c.tokenGroup.DoChan("app_access_token", func() (interface{}, error) {
+		return c.refreshAppAccessToken(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(string), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
 
-	// Double-check after acquiring write lock
+// refreshAppAccessToken requests a new app access token and caches it.
+func (c *APIClient) refreshAppAccessToken(ctx context.Context) (string, error) {
+	// Another flight may have refreshed the token after our cache check
+	c.mu.RLock()
 	if c.appAccessToken != "" && time.Now().Before(c.tokenExpiry) {
-		return c.appAccessToken, nil
+		token := c.appAccessToken
+		c.mu.RUnlock()
+		return token, nil
+	}
+	c.mu.RUnlock()
+
+	tokenResp, err := c.requestAppAccessToken(ctx)
+	if err != nil {
+		return "", err
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.appAccessToken = tokenResp.AccessToken
+	// Refresh TokenRefreshWindow early
+	c.tokenExpiry = c.tokenExpiryFor(time.Now(), tokenResp.ExpiresIn)
+
+	return c.appAccessToken, nil
+}
+
+// requestAppAccessToken performs the client-credentials token request.
+func (c *APIClient) requestAppAccessToken(ctx context.Context) (*TokenResponse, error) {
 	data := url.Values{
 		"client_id":     {c.ClientID},
 		"client_secret": {c.ClientSecret},
 		"grant_type":    {"client_credentials"},
 	}
 
-	resp, err := http.PostForm("https://id.twitch.tv/oauth2/token", data)
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://id.twitch.tv/oauth2/token", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to get app access token: %w", err)
+		return nil, fmt.Errorf("failed to get app access token: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to get app access token (%d): %s", resp.StatusCode, body)
+		return nil, fmt.Errorf("failed to get app access token (%d): %s", resp.StatusCode, body)
 	}
 
 	var tokenResp TokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", fmt.Errorf("failed to decode token response: %w", err)
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
 	}
 
-	c.appAccessToken = tokenResp.AccessToken
-	// Refresh 5 minutes early
-	c.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn-300) * time.Second)
-
-	return c.appAccessToken, nil
+	return &tokenResp, nil
 }
 
 // StreamData represents stream information from the Twitch API
@@ -90,25 +182,39 @@ type StreamData struct {
 	StartedAt    time.Time `json:"started_at"`
 }
 
+// ErrStreamOffline is returned by GetStreamData when the broadcaster isn't live.
+var ErrStreamOffline = errors.New("stream not found or offline")
+
 // GetStreamData fetches current stream data for a broadcaster
-func (c *APIClient) GetStreamData(broadcasterID string) (*StreamData, error) {
-	token, err := c.GetAppAccessToken()
+func (c *APIClient) GetStreamData(ctx context.Context, broadcasterID string) (*StreamData, error) {
+	token, err := c.GetAppAccessToken(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	reqURL := fmt.Sprintf("https://api.twitch.tv/helix/streams?user_id=%s", broadcasterID)
-	req, err := http.NewRequest("GET", reqURL, nil)
+	req, err := newStreamDataRequest(ctx, broadcasterID)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Client-Id", c.ClientID)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch stream data: %w", err)
 	}
+	return decodeStreamData(resp)
+}
+
+// newStreamDataRequest builds the Helix streams request for a broadcaster,
+// without authorization.
+func newStreamDataRequest(ctx context.Context, broadcasterID string) (*http.Request, error) {
+	reqURL := fmt.Sprintf("https://api.twitch.tv/helix/streams?user_id=%s", broadcasterID)
+	return http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+}
+
+// decodeStreamData reads a Helix streams response and closes its body.
+func decodeStreamData(resp *http.Response) (*StreamData, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
@@ -124,8 +230,194 @@ func (c *APIClient) GetStreamData(broadcasterID string) (*StreamData, error) {
 	}
 
 	if len(result.Data) == 0 {
-		return nil, fmt.Errorf("stream not found or offline")
+		return nil, ErrStreamOffline
 	}
 
 	return &result.Data[0], nil
 }
+
+// liveStatusCacheTTL is how long GetLiveStatus reuses a broadcaster's result.
+const liveStatusCacheTTL = 60 * time.Second
+
+// maxStreamsPerRequest is the Helix limit on user_id values per request.
+const maxStreamsPerRequest = 100
+
+// liveStatusEntry is a cached live check result.
+type liveStatusEntry struct {
+	live      bool
+	checkedAt time.Time
+}
+
+// GetLiveStatus reports which of the given broadcasters are currently live.
+// Results are cached briefly so dashboard refreshes don't hit Twitch on
+// every request; uncached broadcasters are looked up in batches.
+func (c *APIClient) GetLiveStatus(ctx context.Context, broadcasterIDs []string) (map[string]bool, error) {
+	status := make(map[string]bool, len(broadcasterIDs))
+	var missing []string
+
+	c.liveMu.Lock()
+	for _, id := range broadcasterIDs {
+		if e, ok := c.liveCache[id]; ok && time.Since(e.checkedAt) < liveStatusCacheTTL {
+			status[id] = e.live
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	c.liveMu.Unlock()
+
+	for start := 0; start < len(missing); start += maxStreamsPerRequest {
+		batch := missing[start:min(start+maxStreamsPerRequest, len(missing))]
+		live, err := c.fetchLiveBroadcasters(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		c.liveMu.Lock()
+		if c.liveCache == nil {
+			c.liveCache = make(map[string]liveStatusEntry)
+		}
+		for _, id := range batch {
+			status[id] = live[id]
+			c.liveCache[id] = liveStatusEntry{live: live[id], checkedAt: now}
+		}
+		c.liveMu.Unlock()
+	}
+
+	return status, nil
+}
+
+// GetStreamsData fetches current stream data for many broadcasters,
+// maxStreamsPerRequest per Helix call. Only live broadcasters are in the
+// result; a missing ID means offline.
+func (c *APIClient) GetStreamsData(ctx context.Context, broadcasterIDs []string) (map[string]*StreamData, error) {
+	streams := make(map[string]*StreamData, len(broadcasterIDs))
+	for start := 0; start < len(broadcasterIDs); start += maxStreamsPerRequest {
+		batch := broadcasterIDs[start:min(start+maxStreamsPerRequest, len(broadcasterIDs))]
+		found, err := c.fetchStreams(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for id, stream := range found {
+			streams[id] = stream
+		}
+	}
+	return streams, nil
+}
+
+// fetchLiveBroadcasters returns the set of broadcasters in ids that are live.
+func (c *APIClient) fetchLiveBroadcasters(ctx context.Context, ids []string) (map[string]bool, error) {
+	streams, err := c.fetchStreams(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	live := make(map[string]bool, len(streams))
+	for id := range streams {
+		live[id] = true
+	}
+	return live, nil
+}
+
+// fetchStreams returns the live streams of the broadcasters in ids (at
+// most maxStreamsPerRequest), keyed by broadcaster ID.
+func (c *APIClient) fetchStreams(ctx context.Context, ids []string) (map[string]*StreamData, error) {
+	token, err := c.GetAppAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{"user_id": ids, "first": {strconv.Itoa(len(ids))}}
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.twitch.tv/helix/streams?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Client-Id", c.ClientID)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch streams: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch streams (%d): %s", resp.StatusCode, body)
+	}
+
+	var result struct {
+		Data []StreamData `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode streams: %w", err)
+	}
+
+	streams := make(map[string]*StreamData, len(result.Data))
+	for i := range result.Data {
+		streams[result.Data[i].UserID] = &result.Data[i]
+	}
+	return streams, nil
+}
+
+// maxUsersPerRequest is the Helix limit on login values per /users request.
+const maxUsersPerRequest = 100
+
+// GetUsersByLogin looks up Twitch users by login, maxUsersPerRequest per
+// Helix call, with the app access token. The result is keyed by lowercase
+// login; logins with no account are missing from it.
+func (c *APIClient) GetUsersByLogin(ctx context.Context, logins []string) (map[string]*TwitchUser, error) {
+	users := make(map[string]*TwitchUser, len(logins))
+	for start := 0; start < len(logins); start += maxUsersPerRequest {
+		batch := logins[start:min(start+maxUsersPerRequest, len(logins))]
+		found, err := c.fetchUsers(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range found {
+			users[strings.ToLower(user.Login)] = user
+		}
+	}
+	return users, nil
+}
+
+// fetchUsers returns the users with the given logins (at most
+// maxUsersPerRequest).
+func (c *APIClient) fetchUsers(ctx context.Context, logins []string) ([]*TwitchUser, error) {
+	token, err := c.GetAppAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{"login": logins}
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.twitch.tv/helix/users?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Client-Id", c.ClientID)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch users (%d): %s", resp.StatusCode, body)
+	}
+
+	var result struct {
+		Data []TwitchUser `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode users: %w", err)
+	}
+
+	users := make([]*TwitchUser, len(result.Data))
+	for i := range result.Data {
+		users[i] = &result.Data[i]
+	}
+	return users, nil
+}
@@ -0,0 +1,235 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetAppAccessToken(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Method != http.MethodPost || r.URL.Path != "/oauth2/token" {
+			t.Errorf("request = %s %s, want POST /oauth2/token", r.Method, r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		if got := r.PostForm.Get("grant_type"); got != "client_credentials" {
+			t.Errorf("grant_type = %q", got)
+		}
+		if got := r.PostForm.Get("client_id"); got != "test-client-id" {
+			t.Errorf("client_id = %q", got)
+		}
+		w.Write([]byte(`{"access_token":"fresh-token","expires_in":3600,"token_type":"bearer"}`))
+	}))
+	defer srv.Close()
+
+	c := NewAPIClient("test-client-id", "test-client-secret")
+	c.HTTPClient = serverClient{srv}
+
+	for range 2 {
+		token, err := c.GetAppAccessToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetAppAccessToken: %v", err)
+		}
+		if token != "fresh-token" {
+			t.Errorf("token = %q, want fresh-token", token)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("sent %d token requests, want 1 (second call cached)", got)
+	}
+}
+
+func TestGetAppAccessTokenError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"status":403,"message":"invalid client secret"}`))
+	}))
+	defer srv.Close()
+
+	c := NewAPIClient("test-client-id", "wrong-secret")
+	c.HTTPClient = serverClient{srv}
+
+	_, err := c.GetAppAccessToken(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("err = %v, want a 403 error", err)
+	}
+}
+
+func TestGetStreamData(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/helix/streams" || r.URL.Query().Get("user_id") != "1234" {
+			t.Errorf("request = %s, want /helix/streams?user_id=1234", r.URL)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-app-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		w.Write([]byte(`{"data":[{"id":"stream-1","user_id":"1234","user_login":"streamer","user_name":"Streamer",
+			"game_name":"Chess","title":"Live now","viewer_count":42,"started_at":"2026-01-02T03:04:05Z"}]}`))
+	}))
+	defer srv.Close()
+
+	stream, err := newTestAPIClient(srv).GetStreamData(context.Background(), "1234")
+	if err != nil {
+		t.Fatalf("GetStreamData: %v", err)
+	}
+	if stream.ID != "stream-1" || stream.UserLogin != "streamer" || stream.GameName != "Chess" || stream.ViewerCount != 42 {
+		t.Errorf("stream = %+v", stream)
+	}
+	if stream.StartedAt.IsZero() {
+		t.Error("StartedAt not decoded")
+	}
+}
+
+func TestGetStreamDataErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"server error", http.StatusInternalServerError, `{"error":"boom"}`, "500"},
+		{"offline", http.StatusOK, `{"data":[]}`, "offline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := newTestAPIClient(srv).GetStreamData(context.Background(), "1234")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want one mentioning %q", err, tt.wantErr)
+			}
+			if offline := errors.Is(err, ErrStreamOffline); offline != (tt.name == "offline") {
+				t.Errorf("errors.Is(err, ErrStreamOffline) = %v for %s", offline, tt.name)
+			}
+		})
+	}
+}
+
+func TestGetAppAccessTokenRespectsDeadline(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(`{"access_token":"slow-token","expires_in":3600}`))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := NewAPIClient("test-client-id", "test-client-secret")
+	c.HTTPClient = serverClient{srv}
+
+	const callers = 10
+	const deadline = 100 * time.Millisecond
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), deadline)
+			defer cancel()
+			start := time.Now()
+			_, err := c.GetAppAccessToken(ctx)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("err = %v, want context.DeadlineExceeded", err)
+			}
+			if waited := time.Since(start); waited > deadline+time.Second {
+				t.Errorf("caller blocked for %v, past its %v deadline", waited, deadline)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("sent %d token requests, want 1", got)
+	}
+}
+
+func TestGetAppAccessTokenCoalescesRefreshes(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(`{"access_token":"shared-token","expires_in":3600}`))
+	}))
+	defer srv.Close()
+
+	c := NewAPIClient("test-client-id", "test-client-secret")
+	c.HTTPClient = serverClient{srv}
+	// An expired token, as when it lapses mid-burst
+	c.appAccessToken = "expired-token"
+	c.tokenExpiry = time.Now().Add(-time.Second)
+
+	const callers = 20
+	tokens := make(chan string, callers)
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := c.GetAppAccessToken(context.Background())
+			if err != nil {
+				t.Errorf("GetAppAccessToken: %v", err)
+			}
+			tokens <- token
+		}()
+	}
+	// Hold the request until every caller is waiting on it
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(tokens)
+
+	for token := range tokens {
+		if token != "shared-token" {
+			t.Errorf("token = %q, want shared-token", token)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("sent %d token requests, want 1", got)
+	}
+}
+
+func TestTokenExpiryFor(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name      string
+		window    time.Duration
+		expiresIn int
+		want      time.Duration
+	}{
+		{"default window", defaultTokenRefreshWindow, 3600, 55 * time.Minute},
+		{"custom window", time.Minute, 3600, 59 * time.Minute},
+		{"no window", 0, 3600, time.Hour},
+		{"negative window", -time.Minute, 3600, time.Hour},
+		{"short-lived token", defaultTokenRefreshWindow, 60, 30 * time.Second},
+		{"lifetime equals window", defaultTokenRefreshWindow, 300, 150 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &APIClient{TokenRefreshWindow: tt.window}
+			got := c.tokenExpiryFor(now, tt.expiresIn)
+			if !got.After(now) {
+				t.Fatalf("expiry %v is not after issue time %v", got, now)
+			}
+			if got.Sub(now) != tt.want {
+				t.Errorf("expiry in %v, want %v", got.Sub(now), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package twitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+)
+
+// subscriptionVersions maps each per-broadcaster subscription type the
+// notifier manages to the EventSub version it uses.
+var subscriptionVersions = map[string]string{
+	SubscriptionTypeStreamOnline:  "1",
+	SubscriptionTypeStreamOffline: "1",
+	SubscriptionTypeChannelUpdate: "2",
+}
+
+// SubscriptionChanges reports what EnsureSubscriptions did at Twitch.
+type SubscriptionChanges struct {
+	// Kept are existing, healthy subscriptions of a wanted type
+	Kept []Subscription
+	// Created are newly created subscriptions
+	Created []Subscription
+	// Deleted are subscriptions removed as unwanted, duplicate or failed
+	Deleted []Subscription
+}
+
+// EnsureSubscriptions reconciles a broadcaster's subscriptions at Twitch
+// with the wanted types: it keeps one healthy subscription per wanted type,
+// creates missing ones, and deletes the rest (types no longer wanted,
+// duplicates, and subscriptions Twitch has disabled). Only the types in
+// subscriptionVersions are managed; others are left alone.
+//
+// Failures on individual subscriptions don't stop the rest; they are joined
+// into the returned error alongside the changes that did happen.
+func (s *EventSubService) EnsureSubscriptions(ctx context.Context, broadcasterID string, types []string) (*SubscriptionChanges, error) {
+	for _, t := range types {
+		if _, ok := subscriptionVersions[t]; !ok {
+			return nil, fmt.Errorf("%w: unsupported subscription type %q", ErrSubscriptionInvalid, t)
+		}
+	}
+
+	existing, err := s.ListBroadcasterSubscriptions(ctx, broadcasterID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &SubscriptionChanges{}
+	var errs []error
+	have := make(map[string]bool)
+	for _, sub := range existing {
+		if _, managed := subscriptionVersions[sub.Type]; !managed {
+			continue
+		}
+		if condition, _ := sub.Condition["broadcaster_user_id"].(string); condition != broadcasterID {
+			continue
+		}
+
+		if slices.Contains(types, sub.Type) && IsHealthyStatus(sub.Status) && !have[sub.Type] {
+			have[sub.Type] = true
+			changes.Kept = append(changes.Kept, sub)
+			continue
+		}
+
+		if err := s.DeleteSubscription(ctx, sub.ID); err != nil {
+			errs = append(errs, fmt.Errorf("delete %s %s: %w", sub.Type, sub.ID, err))
+			continue
+		}
+		log.Printf("[EVENTSUB] Deleted %s subscription %s (status=%s) for broadcaster %s",
+			sub.Type, sub.ID, sub.Status, broadcasterID)
+		changes.Deleted = append(changes.Deleted, sub)
+	}
+
+	for _, t := range types {
+		if have[t] {
+			continue
+		}
+		sub, err := s.createBroadcasterSubscription(ctx, t, subscriptionVersions[t], broadcasterID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("create %s: %w", t, err))
+			continue
+		}
+		have[t] = true
+		changes.Created = append(changes.Created, *sub)
+	}
+
+	return changes, errors.Join(errs...)
+}
+
+// CreateBroadcasterSubscriptions creates a subscription of each of types
+// for a broadcaster, in parallel so the OAuth callback isn't held up by one
+// Twitch round trip per type. A failed type doesn't stop the others: the
+// created subscriptions are returned with the per-type failures joined into
+// the error. It doesn't check what already exists (a type Twitch
+// already has fails with ErrSubscriptionExists); use EnsureSubscriptions to
+// reconcile.
+func (s *EventSubService) CreateBroadcasterSubscriptions(ctx context.Context, broadcasterID string, types []string) ([]Subscription, error) {
+	subs := make([]*Subscription, len(types))
+	errs := make([]error, len(types))
+
+	var wg sync.WaitGroup
+	for i, t := range types {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub, err := s.createBroadcasterSubscription(ctx, t, subscriptionVersions[t], broadcasterID)
+			if err != nil {
+				errs[i] = fmt.Errorf("create %s: %w", t, err)
+				return
+			}
+			subs[i] = sub
+		}()
+	}
+	wg.Wait()
+
+	var created []Subscription
+	for _, sub := range subs {
+		if sub != nil {
+			created = append(created, *sub)
+		}
+	}
+	return created, errors.Join(errs...)
+}
+
+// IsHealthyStatus reports whether a subscription with this status is, or
+// is about to be, delivering events.
+func IsHealthyStatus(status string) bool {
+	return status == "enabled" || status == "webhook_callback_verification_pending"
+}
+
+// ListBroadcasterSubscriptions lists every EventSub subscription that
+// references a broadcaster, following pagination.
+func (s *EventSubService) ListBroadcasterSubscriptions(ctx context.Context, broadcasterID string) ([]Subscription, error) {
+	token, err := s.apiClient.GetAppAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var subs []Subscription
+	cursor := ""
+	for {
+		params := url.Values{"user_id": {broadcasterID}}
+		if cursor != "" {
+			params.Set("after", cursor)
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", "https://api.twitch.tv/helix/eventsub/subscriptions?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Client-Id", s.apiClient.ClientID)
+
+		resp, err := s.apiClient.HTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+		}
+
+		var result struct {
+			Data       []Subscription `json:"data"`
+			Pagination struct {
+				Cursor string `json:"cursor"`
+			} `json:"pagination"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to list subscriptions (%d)", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		subs = append(subs, result.Data...)
+		if result.Pagination.Cursor == "" {
+			return subs, nil
+		}
+		cursor = result.Pagination.Cursor
+	}
+}
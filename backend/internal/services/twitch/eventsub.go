@@ -2,28 +2,62 @@ package twitch
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math/rand/v2"
 	"net/http"
+	"time"
 )
 
 // EventSubService manages Twitch EventSub subscriptions
 type EventSubService struct {
 	apiClient     *APIClient
-	apiBaseURL    string
+	webhookURL    string
 	webhookSecret string
+
+	// MaxCreateAttempts caps how many times a subscription create is sent
+	// while Twitch answers 429 or 5xx.
+	MaxCreateAttempts int
+	// CreateRetryBackoff is the delay before the first retry; it doubles on
+	// each further attempt and gets up to 50% jitter added.
+	CreateRetryBackoff time.Duration
 }
 
-// NewEventSubService creates a new EventSub service with the given configuration.
-func NewEventSubService(apiClient *APIClient, apiBaseURL, webhookSecret string) *EventSubService {
+// NewEventSubService creates a new EventSub service with the given
+// configuration. webhookURL is the callback Twitch delivers events to.
+func NewEventSubService(apiClient *APIClient, webhookURL, webhookSecret string) *EventSubService {
 	return &EventSubService{
-		apiClient:     apiClient,
-		apiBaseURL:    apiBaseURL,
-		webhookSecret: webhookSecret,
+		apiClient:          apiClient,
+		webhookURL:         webhookURL,
+		webhookSecret:      webhookSecret,
+		MaxCreateAttempts:  defaultMaxCreateAttempts,
+		CreateRetryBackoff: defaultCreateRetryBackoff,
 	}
 }
 
+// Defaults for MaxCreateAttempts and CreateRetryBackoff.
+const (
+	defaultMaxCreateAttempts  = 3
+	defaultCreateRetryBackoff = 500 * time.Millisecond
+)
+
+// Errors returned by the Create*Subscription methods, wrapped with details.
+// Use errors.Is to tell them apart.
+var (
+	// ErrSubscriptionExists means Twitch already has this subscription (409).
+	ErrSubscriptionExists = errors.New("eventsub subscription already exists")
+	// ErrSubscriptionRateLimited means Twitch kept answering 429 until
+	// attempts ran out.
+	ErrSubscriptionRateLimited = errors.New("eventsub subscription rate limited")
+	// ErrSubscriptionInvalid means Twitch rejected the request (4xx), so
+	// retrying it unchanged won't help.
+	ErrSubscriptionInvalid = errors.New("eventsub subscription rejected")
+)
+
 // Subscription represents a Twitch EventSub subscription
 type Subscription struct {
 	ID        string                 `json:"id"`
@@ -50,24 +84,77 @@ type CreateSubscriptionRequest struct {
 	Transport Transport              `json:"transport"`
 }
 
+// EventSub subscription types used by the notifier
+const (
+	SubscriptionTypeStreamOnline  = "stream.online"
+	SubscriptionTypeStreamOffline = "stream.offline"
+	SubscriptionTypeChannelUpdate = "channel.update"
+	SubscriptionTypeAuthRevoke    = "user.authorization.revoke"
+)
+
 // CreateStreamOnlineSubscription creates a stream.online EventSub subscription
-func (s *EventSubService) CreateStreamOnlineSubscription(broadcasterID string) (*Subscription, error) {
-	token, err := s.apiClient.GetAppAccessToken()
+func (s *EventSubService) CreateStreamOnlineSubscription(ctx context.Context, broadcasterID string) (*Subscription, error) {
+	return s.createBroadcasterSubscription(ctx, SubscriptionTypeStreamOnline, subscriptionVersions[SubscriptionTypeStreamOnline], broadcasterID)
+}
+
+// CreateStreamOfflineSubscription creates a stream.offline EventSub subscription
+func (s *EventSubService) CreateStreamOfflineSubscription(ctx context.Context, broadcasterID string) (*Subscription, error) {
+	return s.createBroadcasterSubscription(ctx, SubscriptionTypeStreamOffline, subscriptionVersions[SubscriptionTypeStreamOffline], broadcasterID)
+}
+
+// CreateChannelUpdateSubscription creates a channel.update EventSub
+// subscription (title or category changes)
+func (s *EventSubService) CreateChannelUpdateSubscription(ctx context.Context, broadcasterID string) (*Subscription, error) {
+	return s.createBroadcasterSubscription(ctx, SubscriptionTypeChannelUpdate, subscriptionVersions[SubscriptionTypeChannelUpdate], broadcasterID)
+}
+
+// CreateAuthRevokeSubscription creates the app-wide user.authorization.revoke
+// EventSub subscription, notified when any user revokes our app's access.
+// Only one is needed per client ID; a second call returns ErrSubscriptionExists.
+func (s *EventSubService) CreateAuthRevokeSubscription(ctx context.Context) (*Subscription, error) {
+	return s.CreateSubscription(ctx, SubscriptionTypeAuthRevoke, "1", map[string]interface{}{
+		"client_id": s.apiClient.ClientID,
+	})
+}
+
+// sleepCtx waits for d, returning early with the context's error if ctx
+// is done first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// createBroadcasterSubscription creates a subscription conditioned on a
+// single broadcaster
+func (s *EventSubService) createBroadcasterSubscription(ctx context.Context, subType, version, broadcasterID string) (*Subscription, error) {
+	return s.CreateSubscription(ctx, subType, version, map[string]interface{}{
+		"broadcaster_user_id": broadcasterID,
+	})
+}
+
+// CreateSubscription creates a webhook EventSub subscription of any type,
+// version and condition (for example channel.subscribe with
+// {"broadcaster_user_id": id}). The typed Create*Subscription methods wrap
+// it; adding an event type only needs its constant and version.
+func (s *EventSubService) CreateSubscription(ctx context.Context, subType, version string, condition map[string]interface{}) (*Subscription, error) {
+	token, err := s.apiClient.GetAppAccessToken(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	webhookURL := s.apiBaseURL + "/webhooks/twitch"
-
 	reqBody := CreateSubscriptionRequest{
-		Type:    "stream.online",
-		Version: "1",
-		Condition: map[string]interface{}{
-			"broadcaster_user_id": broadcasterID,
-		},
+		Type:      subType,
+		Version:   version,
+		Condition: condition,
 		Transport: Transport{
 			Method:   "webhook",
-			Callback: webhookURL,
+			Callback: s.webhookURL,
 			Secret:   s.webhookSecret,
 		},
 	}
@@ -77,33 +164,49 @@ func (s *EventSubService) CreateStreamOnlineSubscription(broadcasterID string) (
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.twitch.tv/helix/eventsub/subscriptions", bytes.NewReader(body))
-	if err != nil {
-		return nil, err
+	maxAttempts := s.MaxCreateAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Client-Id", s.apiClient.ClientID)
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.apiClient.httpClient.Do(req)
+	var status int
+	var respBody []byte
+	for attempt := 1; ; attempt++ {
+		status, respBody, err = s.postSubscription(ctx, token, body)
+		retryable := err != nil || status == http.StatusTooManyRequests || status >= 500
+		if !retryable || attempt >= maxAttempts {
+			break
+		}
+
+		backoff := s.CreateRetryBackoff << (attempt - 1)
+		if backoff > 0 {
+			backoff += rand.N(backoff/2 + 1)
+		}
+		log.Printf("[EVENTSUB_WARN] Create %s %v failed (status=%d, err=%v), retry %d/%d in %v",
+			subType, condition, status, err, attempt, maxAttempts-1, backoff)
+		if err := sleepCtx(ctx, backoff); err != nil {
+			return nil, fmt.Errorf("subscription create abandoned during retry backoff: %w", err)
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create subscription: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusConflict {
-		return nil, fmt.Errorf("subscription already exists for broadcaster %s", broadcasterID)
-	}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to create subscription (%d): %s", resp.StatusCode, respBody)
+	switch {
+	case status == http.StatusConflict:
+		return nil, fmt.Errorf("%w: %s %v", ErrSubscriptionExists, subType, condition)
+	case status == http.StatusTooManyRequests:
+		return nil, fmt.Errorf("%w: %s %v after %d attempts", ErrSubscriptionRateLimited, subType, condition, maxAttempts)
+	case status >= 400 && status < 500:
+		return nil, fmt.Errorf("%w (%d): %s", ErrSubscriptionInvalid, status, respBody)
+	case status != http.StatusOK && status != http.StatusAccepted:
+		return nil, fmt.Errorf("failed to create subscription (%d): %s", status, respBody)
 	}
 
 	var result struct {
 		Data []Subscription `json:"data"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(respBody, &result); err != nil {
 		return nil, fmt.Errorf("failed to decode subscription response: %w", err)
 	}
 
@@ -111,25 +214,55 @@ func (s *EventSubService) CreateStreamOnlineSubscription(broadcasterID string) (
 		return nil, fmt.Errorf("no subscription data returned")
 	}
 
-	return &result.Data[0], nil
+	sub := &result.Data[0]
+	if sub.Status != "webhook_callback_verification_pending" && sub.Status != "enabled" {
+		log.Printf("[EVENTSUB_WARN] Created %s subscription %s %v with unexpected status %q",
+			subType, sub.ID, condition, sub.Status)
+	}
+
+	return sub, nil
+}
+
+// postSubscription sends one create-subscription request and returns the
+// response status and body.
+func (s *EventSubService) postSubscription(ctx context.Context, token string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.twitch.tv/helix/eventsub/subscriptions", bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Client-Id", s.apiClient.ClientID)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.apiClient.HTTPClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, respBody, nil
 }
 
 // DeleteSubscription deletes an EventSub subscription
-func (s *EventSubService) DeleteSubscription(subscriptionID string) error {
-	token, err := s.apiClient.GetAppAccessToken()
+func (s *EventSubService) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	token, err := s.apiClient.GetAppAccessToken(ctx)
 	if err != nil {
 		return err
 	}
 
 	reqURL := fmt.Sprintf("https://api.twitch.tv/helix/eventsub/subscriptions?id=%s", subscriptionID)
-	req, err := http.NewRequest("DELETE", reqURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", reqURL, nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Client-Id", s.apiClient.ClientID)
 
-	resp, err := s.apiClient.httpClient.Do(req)
+	resp, err := s.apiClient.HTTPClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to delete subscription: %w", err)
 	}
@@ -143,20 +276,20 @@ func (s *EventSubService) DeleteSubscription(subscriptionID string) error {
 }
 
 // ListSubscriptions lists all EventSub subscriptions
-func (s *EventSubService) ListSubscriptions() ([]Subscription, error) {
-	token, err := s.apiClient.GetAppAccessToken()
+func (s *EventSubService) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	token, err := s.apiClient.GetAppAccessToken(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("GET", "https://api.twitch.tv/helix/eventsub/subscriptions", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.twitch.tv/helix/eventsub/subscriptions", nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Client-Id", s.apiClient.ClientID)
 
-	resp, err := s.apiClient.httpClient.Do(req)
+	resp, err := s.apiClient.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
 	}
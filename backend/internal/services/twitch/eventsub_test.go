@@ -0,0 +1,316 @@
+package twitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serverClient is an HTTPClient that sends every request to an
+// httptest.Server instead of the Twitch host in its URL, keeping the path
+// and query.
+type serverClient struct {
+	srv *httptest.Server
+}
+
+func (c serverClient) Do(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(c.srv.URL)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.Host = ""
+	return c.srv.Client().Do(req)
+}
+
+// newTestAPIClient returns an APIClient talking to srv, with an app access
+// token already cached.
+func newTestAPIClient(srv *httptest.Server) *APIClient {
+	c := NewAPIClient("test-client-id", "test-client-secret")
+	c.HTTPClient = serverClient{srv}
+	c.appAccessToken = "test-app-token"
+	c.tokenExpiry = time.Now().Add(time.Hour)
+	return c
+}
+
+func newTestEventSubService(srv *httptest.Server) *EventSubService {
+	s := NewEventSubService(newTestAPIClient(srv), "https://example.com/webhooks/twitch", "test-webhook-secret")
+	s.CreateRetryBackoff = 0
+	return s
+}
+
+func TestCreateSubscriptionRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		create    func(*EventSubService) (*Subscription, error)
+		subType   string
+		version   string
+		condition map[string]interface{}
+	}{
+		{
+			name: "stream.online",
+			create: func(s *EventSubService) (*Subscription, error) {
+				return s.CreateStreamOnlineSubscription(context.Background(), "1234")
+			},
+			subType:   SubscriptionTypeStreamOnline,
+			version:   "1",
+			condition: map[string]interface{}{"broadcaster_user_id": "1234"},
+		},
+		{
+			name: "stream.offline",
+			create: func(s *EventSubService) (*Subscription, error) {
+				return s.CreateStreamOfflineSubscription(context.Background(), "1234")
+			},
+			subType:   SubscriptionTypeStreamOffline,
+			version:   "1",
+			condition: map[string]interface{}{"broadcaster_user_id": "1234"},
+		},
+		{
+			name: "channel.update",
+			create: func(s *EventSubService) (*Subscription, error) {
+				return s.CreateChannelUpdateSubscription(context.Background(), "1234")
+			},
+			subType:   SubscriptionTypeChannelUpdate,
+			version:   "2",
+			condition: map[string]interface{}{"broadcaster_user_id": "1234"},
+		},
+		{
+			name: "user.authorization.revoke",
+			create: func(s *EventSubService) (*Subscription, error) {
+				return s.CreateAuthRevokeSubscription(context.Background())
+			},
+			subType:   SubscriptionTypeAuthRevoke,
+			version:   "1",
+			condition: map[string]interface{}{"client_id": "test-client-id"},
+		},
+		{
+			name: "generic",
+			create: func(s *EventSubService) (*Subscription, error) {
+				return s.CreateSubscription(context.Background(), "channel.subscribe", "1",
+					map[string]interface{}{"broadcaster_user_id": "5678"})
+			},
+			subType:   "channel.subscribe",
+			version:   "1",
+			condition: map[string]interface{}{"broadcaster_user_id": "5678"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/helix/eventsub/subscriptions" {
+					t.Errorf("request = %s %s, want POST /helix/eventsub/subscriptions", r.Method, r.URL.Path)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer test-app-token" {
+					t.Errorf("Authorization = %q", got)
+				}
+				if got := r.Header.Get("Client-Id"); got != "test-client-id" {
+					t.Errorf("Client-Id = %q", got)
+				}
+				if got := r.Header.Get("Content-Type"); got != "application/json" {
+					t.Errorf("Content-Type = %q", got)
+				}
+
+				var body CreateSubscriptionRequest
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("decode request body: %v", err)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if body.Type != tt.subType || body.Version != tt.version {
+					t.Errorf("type/version = %s/%s, want %s/%s", body.Type, body.Version, tt.subType, tt.version)
+				}
+				if len(body.Condition) != len(tt.condition) {
+					t.Errorf("condition = %v, want %v", body.Condition, tt.condition)
+				}
+				for k, v := range tt.condition {
+					if body.Condition[k] != v {
+						t.Errorf("condition[%s] = %v, want %v", k, body.Condition[k], v)
+					}
+				}
+				want := Transport{Method: "webhook", Callback: "https://example.com/webhooks/twitch", Secret: "test-webhook-secret"}
+				if body.Transport != want {
+					t.Errorf("transport = %+v, want %+v", body.Transport, want)
+				}
+
+				w.WriteHeader(http.StatusAccepted)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"data": []Subscription{{
+						ID:        "sub-1",
+						Status:    "webhook_callback_verification_pending",
+						Type:      body.Type,
+						Version:   body.Version,
+						Condition: body.Condition,
+					}},
+				})
+			}))
+			defer srv.Close()
+
+			sub, err := tt.create(newTestEventSubService(srv))
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if sub.ID != "sub-1" || sub.Type != tt.subType || sub.Status != "webhook_callback_verification_pending" {
+				t.Errorf("subscription = %+v", sub)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantErr  error
+		attempts int32
+	}{
+		{"conflict", http.StatusConflict, ErrSubscriptionExists, 1},
+		{"bad request", http.StatusBadRequest, ErrSubscriptionInvalid, 1},
+		{"rate limited", http.StatusTooManyRequests, ErrSubscriptionRateLimited, defaultMaxCreateAttempts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"error":"nope"}`))
+			}))
+			defer srv.Close()
+
+			sub, err := newTestEventSubService(srv).CreateStreamOnlineSubscription(context.Background(), "1234")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if sub != nil {
+				t.Errorf("subscription = %+v, want nil", sub)
+			}
+			if got := calls.Load(); got != tt.attempts {
+				t.Errorf("sent %d requests, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"data":[{"id":"sub-2","status":"enabled","type":"stream.online"}]}`))
+	}))
+	defer srv.Close()
+
+	sub, err := newTestEventSubService(srv).CreateStreamOnlineSubscription(context.Background(), "1234")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if sub.ID != "sub-2" {
+		t.Errorf("subscription ID = %q, want sub-2", sub.ID)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("sent %d requests, want 2", got)
+	}
+}
+
+func TestCreateSubscriptionGivesUpOnServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"Internal Server Error"}`))
+	}))
+	defer srv.Close()
+
+	_, err := newTestEventSubService(srv).CreateStreamOnlineSubscription(context.Background(), "1234")
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("err = %v, want the 500 error", err)
+	}
+	for _, typed := range []error{ErrSubscriptionExists, ErrSubscriptionInvalid, ErrSubscriptionRateLimited} {
+		if errors.Is(err, typed) {
+			t.Errorf("err = %v, should not be %v", err, typed)
+		}
+	}
+	if got := calls.Load(); got != defaultMaxCreateAttempts {
+		t.Errorf("sent %d requests, want %d", got, defaultMaxCreateAttempts)
+	}
+}
+
+func TestCreateSubscriptionBackoffRespectsContext(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := newTestEventSubService(srv)
+	s.CreateRetryBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s.CreateStreamOnlineSubscription(ctx, "1234")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v, want soon after the deadline", elapsed)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("sent %d requests, want 1 before the deadline", got)
+	}
+}
+
+func TestCreateSubscriptionResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus string // subscription status, empty if an error is wanted
+	}{
+		{"verification pending", http.StatusAccepted, `{"data":[{"id":"sub-1","status":"webhook_callback_verification_pending"}]}`, "webhook_callback_verification_pending"},
+		{"enabled", http.StatusOK, `{"data":[{"id":"sub-1","status":"enabled"}]}`, "enabled"},
+		// Logged, but still returned so the caller can record it
+		{"unexpected status", http.StatusAccepted, `{"data":[{"id":"sub-1","status":"authorization_revoked"}]}`, "authorization_revoked"},
+		{"no data", http.StatusAccepted, `{"data":[]}`, ""},
+		{"unexpected success code", http.StatusNoContent, ``, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			sub, err := newTestEventSubService(srv).CreateStreamOnlineSubscription(context.Background(), "1234")
+			if tt.wantStatus == "" {
+				if err == nil {
+					t.Fatalf("create succeeded with %+v, want an error", sub)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if sub.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", sub.Status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package twitch
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // OAuthService handles Twitch OAuth 2.0 flows
@@ -13,14 +16,17 @@ type OAuthService struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURI  string
+	// HTTPClient sends all requests (default: 10s timeout)
+	HTTPClient HTTPClient
 }
 
 // NewOAuthService creates a new Twitch OAuth service with the given credentials.
-func NewOAuthService(clientID, clientSecret, apiBaseURL string) *OAuthService {
+func NewOAuthService(clientID, clientSecret, redirectURI string) *OAuthService {
 	return &OAuthService{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
-		RedirectURI:  apiBaseURL + "/api/auth/twitch/callback",
+		RedirectURI:  redirectURI,
+		HTTPClient:   newHTTPClient(),
 	}
 }
 
@@ -46,7 +52,7 @@ type TokenResponse struct {
 }
 
 // ExchangeCode exchanges an authorization code for an access token
-func (s *OAuthService) ExchangeCode(code string) (*TokenResponse, error) {
+func (s *OAuthService) ExchangeCode(ctx context.Context, code string) (*TokenResponse, error) {
 	data := url.Values{
 		"client_id":     {s.ClientID},
 		"client_secret": {s.ClientSecret},
@@ -55,7 +61,7 @@ func (s *OAuthService) ExchangeCode(code string) (*TokenResponse, error) {
 		"redirect_uri":  {s.RedirectURI},
 	}
 
-	resp, err := http.PostForm("https://id.twitch.tv/oauth2/token", data)
+	resp, err := s.postForm(ctx, "https://id.twitch.tv/oauth2/token", data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
@@ -83,15 +89,15 @@ type TwitchUser struct {
 }
 
 // GetUser fetches the authenticated Twitch user's information
-func (s *OAuthService) GetUser(accessToken string) (*TwitchUser, error) {
-	req, err := http.NewRequest("GET", "https://api.twitch.tv/helix/users", nil)
+func (s *OAuthService) GetUser(ctx context.Context, accessToken string) (*TwitchUser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.twitch.tv/helix/users", nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("Client-Id", s.ClientID)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch user: %w", err)
 	}
@@ -116,8 +122,12 @@ func (s *OAuthService) GetUser(accessToken string) (*TwitchUser, error) {
 	return &result.Data[0], nil
 }
 
+// ErrRefreshTokenInvalid is returned by RefreshToken when Twitch rejects the
+// refresh token (revoked, or invalidated by a password change).
+var ErrRefreshTokenInvalid = errors.New("twitch refresh token is invalid")
+
 // RefreshToken refreshes an expired Twitch access token
-func (s *OAuthService) RefreshToken(refreshToken string) (*TokenResponse, error) {
+func (s *OAuthService) RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
 	data := url.Values{
 		"client_id":     {s.ClientID},
 		"client_secret": {s.ClientSecret},
@@ -125,12 +135,17 @@ func (s *OAuthService) RefreshToken(refreshToken string) (*TokenResponse, error)
 		"refresh_token": {refreshToken},
 	}
 
-	resp, err := http.PostForm("https://id.twitch.tv/oauth2/token", data)
+	resp, err := s.postForm(ctx, "https://id.twitch.tv/oauth2/token", data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to refresh token: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%w (%d): %s", ErrRefreshTokenInvalid, resp.StatusCode, body)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("failed to refresh token (%d): %s", resp.StatusCode, body)
@@ -143,3 +158,35 @@ func (s *OAuthService) RefreshToken(refreshToken string) (*TokenResponse, error)
 
 	return &tokenResp, nil
 }
+
+// RevokeToken revokes a user access token, ending the authorization it was
+// issued under. Twitch answers 400 for a token that is already invalid,
+// which is reported as success.
+func (s *OAuthService) RevokeToken(ctx context.Context, accessToken string) error {
+	data := url.Values{
+		"client_id": {s.ClientID},
+		"token":     {accessToken},
+	}
+
+	resp, err := s.postForm(ctx, "https://id.twitch.tv/oauth2/revoke", data)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to revoke token (%d): %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// postForm posts form-encoded data through the service's HTTPClient.
+func (s *OAuthService) postForm(ctx context.Context, reqURL string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.HTTPClient.Do(req)
+}
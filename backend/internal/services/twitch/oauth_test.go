@@ -0,0 +1,145 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestOAuthService(srv *httptest.Server) *OAuthService {
+	s := NewOAuthService("test-client-id", "test-client-secret", "https://example.com/callback")
+	s.HTTPClient = serverClient{srv}
+	return s
+}
+
+func TestExchangeCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/oauth2/token" {
+			t.Errorf("request = %s %s, want POST /oauth2/token", r.Method, r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		for field, want := range map[string]string{
+			"grant_type":    "authorization_code",
+			"code":          "auth-code",
+			"client_id":     "test-client-id",
+			"client_secret": "test-client-secret",
+			"redirect_uri":  "https://example.com/callback",
+		} {
+			if got := r.PostForm.Get(field); got != want {
+				t.Errorf("%s = %q, want %q", field, got, want)
+			}
+		}
+		w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","expires_in":14400,
+			"token_type":"bearer","scope":["user:read:email"]}`))
+	}))
+	defer srv.Close()
+
+	tok, err := newTestOAuthService(srv).ExchangeCode(context.Background(), "auth-code")
+	if err != nil {
+		t.Fatalf("ExchangeCode: %v", err)
+	}
+	if tok.AccessToken != "access" || tok.RefreshToken != "refresh" || tok.ExpiresIn != 14400 {
+		t.Errorf("token = %+v", tok)
+	}
+	if len(tok.Scope) != 1 || tok.Scope[0] != "user:read:email" {
+		t.Errorf("scope = %v", tok.Scope)
+	}
+}
+
+func TestExchangeCodeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status":400,"message":"Invalid authorization code"}`))
+	}))
+	defer srv.Close()
+
+	_, err := newTestOAuthService(srv).ExchangeCode(context.Background(), "bad-code")
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("err = %v, want a 400 error", err)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/helix/users" {
+			t.Errorf("path = %s, want /helix/users", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer user-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("Client-Id"); got != "test-client-id" {
+			t.Errorf("Client-Id = %q", got)
+		}
+		w.Write([]byte(`{"data":[{"id":"1234","login":"streamer","display_name":"Streamer","profile_image_url":"https://example.com/a.png"}]}`))
+	}))
+	defer srv.Close()
+
+	user, err := newTestOAuthService(srv).GetUser(context.Background(), "user-token")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	want := TwitchUser{ID: "1234", Login: "streamer", DisplayName: "Streamer", ProfileImageURL: "https://example.com/a.png"}
+	if *user != want {
+		t.Errorf("user = %+v, want %+v", *user, want)
+	}
+}
+
+func TestGetUserError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"status":401,"message":"Invalid OAuth token"}`))
+	}))
+	defer srv.Close()
+
+	_, err := newTestOAuthService(srv).GetUser(context.Background(), "expired")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("err = %v, want a 401 error", err)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		wantInvalid bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"revoked", http.StatusBadRequest, true},
+		{"server error", http.StatusBadGateway, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				if got := r.PostForm.Get("refresh_token"); got != "old-refresh" {
+					t.Errorf("refresh_token = %q", got)
+				}
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusOK {
+					w.Write([]byte(`{"access_token":"new-access","refresh_token":"new-refresh"}`))
+				}
+			}))
+			defer srv.Close()
+
+			tok, err := newTestOAuthService(srv).RefreshToken(context.Background(), "old-refresh")
+			if got := errors.Is(err, ErrRefreshTokenInvalid); got != tt.wantInvalid {
+				t.Errorf("errors.Is(err, ErrRefreshTokenInvalid) = %v, want %v (err = %v)", got, tt.wantInvalid, err)
+			}
+			if tt.status == http.StatusOK {
+				if err != nil {
+					t.Fatalf("RefreshToken: %v", err)
+				}
+				if tok.AccessToken != "new-access" || tok.RefreshToken != "new-refresh" {
+					t.Errorf("token = %+v", tok)
+				}
+			} else if err == nil {
+				t.Error("RefreshToken succeeded, want an error")
+			}
+		})
+	}
+}
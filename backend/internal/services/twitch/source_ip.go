@@ -0,0 +1,55 @@
+package twitch
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// eventSubIPRanges are the networks EventSub webhooks are accepted from.
+// Set via SetEventSubIPRanges at startup.
+//
+// This is defense in depth against unsigned spam reaching the endpoint, not
+// a replacement for VerifyWebhookSignature: Twitch's ranges change, and a
+// request from an allowed IP still has to carry a valid signature.
+var eventSubIPRanges []netip.Prefix
+
+// SetEventSubIPRanges configures the CIDR ranges IsFromTwitch accepts.
+// Must be called before handling any webhooks.
+func SetEventSubIPRanges(cidrs []string) error {
+	ranges := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid EventSub IP range %q: %w", cidr, err)
+		}
+		ranges = append(ranges, prefix.Masked())
+	}
+	eventSubIPRanges = ranges
+	return nil
+}
+
+// IsFromTwitch reports whether ip (optionally with a port) falls in the
+// configured EventSub IP ranges.
+func IsFromTwitch(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(ip)
+		if err != nil {
+			return false
+		}
+		addr = addrPort.Addr()
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range eventSubIPRanges {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/yourusername/streammaxing/internal/db"
+	"github.com/yourusername/streammaxing/internal/services/encryption"
+)
+
+// ErrReauthRequired is returned when a streamer has no usable user token and
+// must link their Twitch account again.
+var ErrReauthRequired = errors.New("streamer must re-authorize with Twitch")
+
+// UserTokenService makes Twitch API calls on behalf of a streamer with their
+// stored user access token, refreshing it when Twitch rejects it.
+type UserTokenService struct {
+	oauth         *OAuthService
+	encryptionSvc *encryption.Service
+	httpClient    HTTPClient
+}
+
+// NewUserTokenService creates a user token service. encryptionSvc may be nil,
+// in which case tokens are read and written as stored (plaintext).
+func NewUserTokenService(oauth *OAuthService, encryptionSvc *encryption.Service) *UserTokenService {
+	return &UserTokenService{
+		oauth:         oauth,
+		encryptionSvc: encryptionSvc,
+		httpClient:    newHTTPClient(),
+	}
+}
+
+// Do sends req with the streamer's user access token. If Twitch answers 401,
+// the token is refreshed once and the request retried. Returns
+// ErrReauthRequired if the streamer has no tokens or the refresh token has
+// been revoked (the streamer is then flagged for re-auth).
+func (s *UserTokenService) Do(ctx context.Context, streamerID string, req *http.Request) (*http.Response, error) {
+	accessToken, refreshToken, err := s.loadTokens(ctx, streamerID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.send(ctx, req, accessToken)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	accessToken, err = s.refresh(ctx, streamerID, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		req.Body = body
+	}
+	return s.send(ctx, req, accessToken)
+}
+
+// send issues req authorized with accessToken.
+func (s *UserTokenService) send(ctx context.Context, req *http.Request, accessToken string) (*http.Response, error) {
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Client-Id", s.oauth.ClientID)
+	return s.httpClient.Do(req)
+}
+
+// loadTokens reads and decrypts a streamer's stored tokens.
+func (s *UserTokenService) loadTokens(ctx context.Context, streamerID string) (string, string, error) {
+	encAccess, encRefresh, err := db.GetStreamerTokens(ctx, streamerID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load streamer tokens: %w", err)
+	}
+	if encAccess == "" || encRefresh == "" {
+		return "", "", ErrReauthRequired
+	}
+
+	accessToken, err := s.decrypt(ctx, encAccess)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decrypt access token: %w", err)
+	}
+	refreshToken, err := s.decrypt(ctx, encRefresh)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decrypt refresh token: %w", err)
+	}
+	return accessToken, refreshToken, nil
+}
+
+// refresh exchanges the refresh token for a new token pair, stores it
+// encrypted, and returns the new access token.
+func (s *UserTokenService) refresh(ctx context.Context, streamerID, refreshToken string) (string, error) {
+	tokenResp, err := s.oauth.RefreshToken(ctx, refreshToken)
+	if errors.Is(err, ErrRefreshTokenInvalid) {
+		log.Printf("[TWITCH_TOKEN_WARN] Refresh token rejected for streamer %s, marking for re-auth: %v", streamerID, err)
+		if markErr := db.MarkStreamerNeedsReauth(ctx, streamerID); markErr != nil {
+			log.Printf("[TWITCH_TOKEN_ERROR] Failed to flag streamer %s for re-auth: %v", streamerID, markErr)
+		}
+		return "", ErrReauthRequired
+	}
+	if err != nil {
+		return "", err
+	}
+
+	encAccess, err := s.encrypt(ctx, tokenResp.AccessToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+	// Twitch may rotate the refresh token; keep the old one if it didn't
+	newRefresh := tokenResp.RefreshToken
+	if newRefresh == "" {
+		newRefresh = refreshToken
+	}
+	encRefresh, err := s.encrypt(ctx, newRefresh)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt refresh token: %w", err)
+	}
+
+	if err := db.UpdateStreamerTokens(ctx, streamerID, encAccess, encRefresh); err != nil {
+		return "", fmt.Errorf("failed to store refreshed tokens: %w", err)
+	}
+
+	log.Printf("[TWITCH_TOKEN] Refreshed user token for streamer %s", streamerID)
+	return tokenResp.AccessToken, nil
+}
+
+func (s *UserTokenService) encrypt(ctx context.Context, plaintext string) (string, error) {
+	if s.encryptionSvc == nil {
+		return plaintext, nil
+	}
+	return s.encryptionSvc.EncryptEnvelope(ctx, plaintext)
+}
+
+func (s *UserTokenService) decrypt(ctx context.Context, ciphertext string) (string, error) {
+	if s.encryptionSvc == nil {
+		return ciphertext, nil
+	}
+	return s.encryptionSvc.DecryptEnvelope(ctx, ciphertext)
+}
+
+// GetStreamData fetches current stream data for a broadcaster with the
+// streamer's own user token, refreshing it if Twitch rejects it.
+func (s *UserTokenService) GetStreamData(ctx context.Context, streamerID, broadcasterID string) (*StreamData, error) {
+	req, err := newStreamDataRequest(ctx, broadcasterID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Do(ctx, streamerID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stream data: %w", err)
+	}
+	return decodeStreamData(resp)
+}
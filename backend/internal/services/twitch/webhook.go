@@ -7,6 +7,14 @@ import (
 	"time"
 )
 
+// EventSub webhook message types, sent in the MessageTypeHeader header
+const (
+	MessageTypeHeader       = "Twitch-Eventsub-Message-Type"
+	MessageTypeVerification = "webhook_callback_verification"
+	MessageTypeNotification = "notification"
+	MessageTypeRevocation   = "revocation"
+)
+
 // webhookSecret is the shared secret used for webhook signature verification.
 // Set via SetWebhookSecret at startup rather than reading from env vars.
 var webhookSecret string
@@ -1,14 +1,32 @@
 package validation
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/yourusername/streammaxing/internal/db"
+	"github.com/yourusername/streammaxing/internal/services/discord"
 )
 
 var (
 	// Discord IDs are snowflakes: 17-20 digit numeric strings
 	snowflakeRegex = regexp.MustCompile(`^\d{17,20}$`)
+
+	// Template variables look like {name}
+	templateVarRegex = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+	// Raw Discord role mentions look like <@&123456789012345678>
+	roleMentionRegex = regexp.MustCompile(`<@&\d+>`)
+
+	// Twitch logins are letters, digits and underscores, at most 25 long
+	twitchLoginRegex = regexp.MustCompile(`^[A-Za-z0-9_]{1,25}$`)
+
+	// Streamer IDs are our own UUIDs
+	uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
 )
 
 // Validator provides input validation for API endpoints.
@@ -35,6 +53,22 @@ func (v *Validator) ValidateUserID(userID string) error {
 	return nil
 }
 
+// ValidateStreamerID checks that a streamer ID is a valid UUID.
+func (v *Validator) ValidateStreamerID(streamerID string) error {
+	if !uuidRegex.MatchString(streamerID) {
+		return fmt.Errorf("invalid streamer ID format")
+	}
+	return nil
+}
+
+// ValidateTwitchLogin checks that a Twitch login is well formed.
+func (v *Validator) ValidateTwitchLogin(login string) error {
+	if !twitchLoginRegex.MatchString(login) {
+		return fmt.Errorf("invalid Twitch login format")
+	}
+	return nil
+}
+
 // ValidateChannelID checks that a Discord channel ID is a valid snowflake.
 func (v *Validator) ValidateChannelID(channelID string) error {
 	if channelID == "" {
@@ -46,6 +80,17 @@ func (v *Validator) ValidateChannelID(channelID string) error {
 	return nil
 }
 
+// ValidateRoleID checks that a Discord role ID is a valid snowflake.
+func (v *Validator) ValidateRoleID(roleID string) error {
+	if roleID == "" {
+		return nil // No mention role
+	}
+	if !snowflakeRegex.MatchString(roleID) {
+		return fmt.Errorf("invalid role ID format")
+	}
+	return nil
+}
+
 // ValidateTemplateContent checks message template content for injection attempts.
 func (v *Validator) ValidateTemplateContent(content string) error {
 	if len(content) > 4000 {
@@ -64,8 +109,28 @@ func (v *Validator) ValidateTemplateContent(content string) error {
 	return nil
 }
 
-// ValidateCustomContent validates custom notification text.
-func (v *Validator) ValidateCustomContent(content string) error {
+// ValidateTemplateVariables rejects {placeholders} that are not in
+// db.TemplateVariables, listing the unknown names so typos get fixed before
+// they show up literally in Discord.
+func (v *Validator) ValidateTemplateVariables(content string) error {
+	var unknown []string
+	for _, m := range templateVarRegex.FindAllStringSubmatch(content, -1) {
+		if !slices.Contains(db.TemplateVariables, m[1]) && !slices.Contains(unknown, m[0]) {
+			unknown = append(unknown, m[0])
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown template variables %s (available: {%s})",
+			strings.Join(unknown, ", "), strings.Join(db.TemplateVariables, "}, {"))
+	}
+	return nil
+}
+
+// ValidateCustomContent validates custom notification text. Besides size
+// and script patterns it rejects mentions beyond the guild's policy: raw
+// role mentions always (the guild's role is pinged through {mention_role}),
+// and @everyone/@here unless the guild allows them (allowEveryone).
+func (v *Validator) ValidateCustomContent(content string, allowEveryone bool) error {
 	if len(content) > 2000 {
 		return fmt.Errorf("custom content too long (max 2000 characters)")
 	}
@@ -78,6 +143,13 @@ func (v *Validator) ValidateCustomContent(content string) error {
 		}
 	}
 
+	if roleMentionRegex.MatchString(content) {
+		return fmt.Errorf("content can't mention roles directly, use {mention_role}")
+	}
+	if !allowEveryone && (strings.Contains(contentLower, "@everyone") || strings.Contains(contentLower, "@here")) {
+		return fmt.Errorf("this server doesn't allow @everyone or @here mentions")
+	}
+
 	return nil
 }
 
@@ -109,3 +181,66 @@ func (v *Validator) ValidateInviteCode(code string) error {
 	}
 	return nil
 }
+
+// ValidateEmbedTemplate parses a message template and checks it against
+// Discord's message and embed limits. Limits apply to the template text
+// before variables are filled in.
+func (v *Validator) ValidateEmbedTemplate(templateJSON []byte) error {
+	var tmpl db.MessageTemplate
+	if err := json.Unmarshal(templateJSON, &tmpl); err != nil {
+		return fmt.Errorf("template is not valid JSON: %w", err)
+	}
+
+	if n := utf8.RuneCountInString(tmpl.Content); n > discord.MaxMessageContent {
+		return fmt.Errorf("content is %d characters (max %d)", n, discord.MaxMessageContent)
+	}
+	if tmpl.Content == "" && tmpl.Embed == nil {
+		return fmt.Errorf("template needs content or an embed")
+	}
+
+	embed := tmpl.Embed
+	if embed == nil {
+		return nil
+	}
+
+	total := 0
+	check := func(what, text string, max int) error {
+		n := utf8.RuneCountInString(text)
+		total += n
+		if n > max {
+			return fmt.Errorf("embed %s is %d characters (max %d)", what, n, max)
+		}
+		return nil
+	}
+
+	if err := check("title", embed.Title, discord.MaxEmbedTitle); err != nil {
+		return err
+	}
+	if err := check("description", embed.Description, discord.MaxEmbedDescription); err != nil {
+		return err
+	}
+	if len(embed.Fields) > discord.MaxEmbedFields {
+		return fmt.Errorf("embed has %d fields (max %d)", len(embed.Fields), discord.MaxEmbedFields)
+	}
+	for i, field := range embed.Fields {
+		if field.Name == "" || field.Value == "" {
+			return fmt.Errorf("embed field %d needs a name and a value", i+1)
+		}
+		if err := check(fmt.Sprintf("field %d name", i+1), field.Name, discord.MaxEmbedFieldName); err != nil {
+			return err
+		}
+		if err := check(fmt.Sprintf("field %d value", i+1), field.Value, discord.MaxEmbedFieldValue); err != nil {
+			return err
+		}
+	}
+	if embed.Footer != nil {
+		if err := check("footer", embed.Footer.Text, discord.MaxEmbedFooter); err != nil {
+			return err
+		}
+	}
+	if total > discord.MaxEmbedTotal {
+		return fmt.Errorf("embed text totals %d characters (max %d)", total, discord.MaxEmbedTotal)
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+-- Migration 008: Stream offline handling
+-- Stores the Discord message posted for each notification so it can be
+-- removed when the stream ends, and records the EventSub type per subscription.
+
+-- Discord message posted for this notification (NULL until the send succeeds)
+ALTER TABLE notification_log ADD COLUMN IF NOT EXISTS channel_id TEXT;
+ALTER TABLE notification_log ADD COLUMN IF NOT EXISTS discord_message_id TEXT;
+
+-- Set when stream.offline is received for the streamer
+ALTER TABLE notification_log ADD COLUMN IF NOT EXISTS ended_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_notification_log_streamer_open
+    ON notification_log(streamer_id, sent_at) WHERE ended_at IS NULL;
+
+-- Streamers now have one subscription per EventSub type
+ALTER TABLE eventsub_subscriptions ADD COLUMN IF NOT EXISTS subscription_type TEXT NOT NULL DEFAULT 'stream.online';
+
+CREATE INDEX IF NOT EXISTS idx_eventsub_subscriptions_streamer ON eventsub_subscriptions(streamer_id);
@@ -0,0 +1,5 @@
+-- Migration 009: Notification history index
+-- Serves per-streamer history (newest first) within a guild.
+
+CREATE INDEX IF NOT EXISTS idx_notification_log_guild_streamer_sent
+    ON notification_log(guild_id, streamer_id, sent_at DESC);
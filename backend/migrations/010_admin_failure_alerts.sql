@@ -0,0 +1,5 @@
+-- Migration 010: Admin alerts for broken streamer links
+-- Opt-in: post a message to the notification channel when a streamer's
+-- authorization is revoked or their EventSub subscription fails.
+
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS alert_admins_on_failure BOOLEAN NOT NULL DEFAULT false;
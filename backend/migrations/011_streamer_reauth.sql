@@ -0,0 +1,5 @@
+-- Migration 011: Streamer re-auth flag
+-- Set when a streamer's Twitch tokens can no longer be refreshed (refresh
+-- token rejected or authorization revoked); cleared when they link again.
+
+ALTER TABLE streamers ADD COLUMN IF NOT EXISTS needs_reauth BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,9 @@
+-- Migration 012: Quiet hours
+-- Daily window (guild-local "HH:MM") during which notifications are posted
+-- without pings ('silent') or not at all ('skip').
+
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS quiet_hours_start TEXT;
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS quiet_hours_end TEXT;
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS quiet_hours_timezone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS quiet_hours_mode TEXT NOT NULL DEFAULT 'silent'
+    CHECK (quiet_hours_mode IN ('silent', 'skip'));
@@ -0,0 +1,5 @@
+-- Migration 013: @everyone mentions
+-- Opt-in per guild; {mention_everyone} renders empty and @everyone never
+-- pings unless this is enabled.
+
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS mention_everyone BOOLEAN NOT NULL DEFAULT false;
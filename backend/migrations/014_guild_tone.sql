@@ -0,0 +1,6 @@
+-- Migration 014: Notification tone presets
+-- Picks a preset variation of the default template (see db.Tones); applies
+-- only while message_template is still a preset.
+
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS tone TEXT NOT NULL DEFAULT 'default'
+    CHECK (tone IN ('default', 'hype', 'minimal', 'professional'));
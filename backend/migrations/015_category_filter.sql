@@ -0,0 +1,5 @@
+-- Migration 015: Per-link category filter
+-- Game/category names (matched case-insensitively) a guild wants live
+-- notifications for; NULL or empty notifies for every category.
+
+ALTER TABLE guild_streamers ADD COLUMN IF NOT EXISTS category_filter TEXT[];
@@ -0,0 +1,7 @@
+-- Migration 016: Per-guild event type toggles
+-- Whether a guild edits posted notifications on title/category changes
+-- (channel.update) and deletes them when the stream ends (stream.offline).
+-- Streamers only get the EventSub subscriptions some tracking guild needs.
+
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS update_on_channel_change BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS delete_on_offline BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,7 @@
+-- Migration 017: Per-guild re-notify cooldown
+-- A streamer whose stream drops and reconnects fires a new stream.online
+-- (new event ID), which would post a second notification. Skip it if the
+-- guild was notified about that streamer, or saw the stream end, within
+-- this many minutes. 0 disables the cooldown.
+
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS renotify_cooldown_minutes INTEGER NOT NULL DEFAULT 30;
@@ -0,0 +1,5 @@
+-- Migration 018: Forum notification channels
+-- When the notification channel is a forum, each notification is posted as
+-- a new thread. Set from the channel's type when the config is saved.
+
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS channel_is_forum BOOLEAN NOT NULL DEFAULT false;
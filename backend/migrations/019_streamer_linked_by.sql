@@ -0,0 +1,19 @@
+-- Migration 019: Record who linked each streamer
+-- The Discord user who completed the Twitch OAuth for a streamer, so that
+-- user can later unlink their Twitch account (DELETE /api/streamers/me).
+-- Backfilled from the most recent guild link they added.
+
+ALTER TABLE streamers ADD COLUMN IF NOT EXISTS linked_by_user_id TEXT REFERENCES users(user_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_streamers_linked_by ON streamers(linked_by_user_id);
+
+UPDATE streamers s
+SET linked_by_user_id = gs.added_by
+FROM (
+    SELECT DISTINCT ON (streamer_id) streamer_id, added_by
+    FROM guild_streamers
+    WHERE added_by IS NOT NULL
+    ORDER BY streamer_id, added_at DESC
+) gs
+WHERE s.id = gs.streamer_id
+  AND s.linked_by_user_id IS NULL;
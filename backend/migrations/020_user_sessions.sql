@@ -0,0 +1,16 @@
+-- Migration 020: Issued sessions
+-- One row per session JWT issued at login, so users can list their active
+-- sessions and revoke the others. Revocation itself is still recorded in
+-- revoked_sessions; rows here are pruned by the cleanup job once expired.
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    jti TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    user_agent TEXT,
+    ip_address TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_expires ON user_sessions(expires_at);
@@ -0,0 +1,5 @@
+-- Migration 021: Guild audit log lookups
+-- Guild admins read their guild's audit entries, matched by the guild_id
+-- stored in details (newest first).
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_guild ON audit_log((details->>'guild_id'), timestamp DESC);
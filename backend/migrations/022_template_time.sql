@@ -0,0 +1,6 @@
+-- Migration 022: Template timestamps
+-- Timezone (IANA name) and Go time layout {started_at} renders in; an
+-- empty format keeps RFC 3339.
+
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS time_format TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- Migration 023: Webhook delivery
+-- A guild can receive notifications through a channel webhook instead of
+-- the bot. The URL carries its own credential, so it is stored encrypted
+-- (encryption.Service); NULL means the bot posts.
+
+ALTER TABLE guild_config ADD COLUMN IF NOT EXISTS webhook_url TEXT;
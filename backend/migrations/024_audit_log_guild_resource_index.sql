@@ -0,0 +1,7 @@
+-- Migration 024: Guild-level audit log lookups
+-- The guild audit log also lists entries about the guild itself
+-- (resource_type guild or guild_config, resource_id = guild ID), read as
+-- their own branch next to idx_audit_log_guild (newest first).
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_guild_resource ON audit_log(resource_id, timestamp DESC)
+    WHERE resource_type IN ('guild', 'guild_config');
@@ -0,0 +1,11 @@
+-- Migration 025: Used service token nonces
+-- Each internal service token validates once. Recording its nonce here
+-- rejects a replay on any Lambda instance, not just the one that saw it
+-- first. Rows are pruned by the cleanup job once the token has expired.
+
+CREATE TABLE IF NOT EXISTS service_token_nonces (
+    nonce TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_token_nonces_expires ON service_token_nonces(expires_at);
@@ -0,0 +1,192 @@
+// Package migrations holds the versioned SQL schema migrations and applies
+// the ones a database hasn't had yet.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yourusername/streammaxing/internal/db"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockID is the Postgres advisory lock key held while migrating, so cold
+// starts racing each other apply each migration once.
+const lockID = 0x53_4d_4d_49_47 // "SMMIG"
+
+// migration is one NNN_name.sql file
+type migration struct {
+	version int
+	name    string
+}
+
+// RunMigrations applies, in version order, every migration not yet recorded
+// in schema_migrations, each in its own transaction with its version row.
+// Concurrent callers wait on an advisory lock; once it is theirs, they find
+// the work done. Rollback (.down.sql) files are never applied.
+//
+// A database set up by hand before this runner existed has the initial
+// schema but no schema_migrations; its 001 is recorded without being run,
+// and the later migrations (all written to be idempotent) are applied.
+func RunMigrations(ctx context.Context) error {
+	if db.Pool == nil {
+		return errors.New("database not connected")
+	}
+
+	pending, err := list()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedVersions(ctx, conn.Conn())
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		if err := baseline(ctx, conn.Conn(), pending, applied); err != nil {
+			return err
+		}
+	}
+
+	count := 0
+	for _, m := range pending {
+		if applied[m.version] {
+			continue
+		}
+		if err := apply(ctx, conn.Conn(), m); err != nil {
+			return err
+		}
+		log.Printf("[MIGRATE] Applied %s", m.name)
+		count++
+	}
+	if count > 0 {
+		log.Printf("[MIGRATE] Applied %d migration(s)", count)
+	}
+	return nil
+}
+
+// list returns the embedded migrations ordered by version.
+func list() ([]migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var out []migration
+	seen := make(map[int]string)
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, ".down.sql") {
+			continue
+		}
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: name must start with a version number", name)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+		out = append(out, migration{version: version, name: name})
+	}
+
+	slices.SortFunc(out, func(a, b migration) int { return a.version - b.version })
+	return out, nil
+}
+
+// appliedVersions returns the versions recorded in schema_migrations.
+func appliedVersions(ctx context.Context, conn *pgx.Conn) (map[int]bool, error) {
+	rows, err := conn.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+// baseline records the initial migration as applied, without running it,
+// when its tables already exist (a database that predates the runner).
+func baseline(ctx context.Context, conn *pgx.Conn, pending []migration, applied map[int]bool) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass('public.guilds') IS NOT NULL`).Scan(&exists); err != nil {
+		return fmt.Errorf("check existing schema: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	initial := pending[0]
+	if _, err := conn.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+		initial.version, initial.name); err != nil {
+		return fmt.Errorf("record baseline: %w", err)
+	}
+	applied[initial.version] = true
+	log.Printf("[MIGRATE] Existing schema found, recorded %s as applied", initial.name)
+	return nil
+}
+
+// apply runs one migration and records it, atomically.
+func apply(ctx context.Context, conn *pgx.Conn, m migration) error {
+	sql, err := files.ReadFile(m.name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, string(sql)); err != nil {
+		return fmt.Errorf("migration %s: %w", m.name, err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name); err != nil {
+		return fmt.Errorf("record migration %s: %w", m.name, err)
+	}
+	return tx.Commit(ctx)
+}
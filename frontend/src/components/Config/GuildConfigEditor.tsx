@@ -7,9 +7,37 @@ import {
   getGuildRoles,
   getBotInstallURL,
 } from '../../services/api';
-import type { GuildConfig, Channel, Role } from '../../types';
+import type { GuildConfig, Channel, Role, Tone } from '../../types';
 import { LoadingSpinner } from '../common/LoadingSpinner';
 
+interface ChannelGroup {
+  category: Channel | null;
+  channels: Channel[];
+}
+
+// groupChannels splits text channels by their parent category: uncategorized
+// channels first, then each category in Discord's order. Empty categories
+// are dropped.
+function groupChannels(channels: Channel[]): ChannelGroup[] {
+  const byPosition = (a: Channel, b: Channel) => a.position - b.position;
+  const text = channels.filter((c) => c.type !== 4).sort(byPosition);
+  const categories = channels.filter((c) => c.type === 4).sort(byPosition);
+  const categoryIds = new Set(categories.map((c) => c.id));
+
+  const groups: ChannelGroup[] = [
+    { category: null, channels: text.filter((c) => !c.parent_id || !categoryIds.has(c.parent_id)) },
+    ...categories.map((category) => ({
+      category,
+      channels: text.filter((c) => c.parent_id === category.id),
+    })),
+  ];
+  return groups.filter((g) => g.channels.length > 0);
+}
+
+function channelLabel(channel: Channel): string {
+  return channel.type === 15 ? `#${channel.name} (forum)` : `#${channel.name}`;
+}
+
 export function GuildConfigEditor() {
   const { guildId } = useParams<{ guildId: string }>();
   const [config, setConfig] = useState<GuildConfig | null>(null);
@@ -129,15 +157,38 @@ export function GuildConfigEditor() {
           <select
             id="channel"
             value={config.channel_id}
-            onChange={(e) => setConfig({ ...config, channel_id: e.target.value })}
+            onChange={(e) =>
+              setConfig({
+                ...config,
+                channel_id: e.target.value,
+                channel_is_forum: channels.some((c) => c.id === e.target.value && c.type === 15),
+              })
+            }
           >
             <option value="">Select a channel...</option>
-            {channels.map((channel) => (
-              <option key={channel.id} value={channel.id}>
-                #{channel.name}
-              </option>
-            ))}
+            {groupChannels(channels).map(({ category, channels: group }) =>
+              category ? (
+                <optgroup key={category.id} label={category.name}>
+                  {group.map((channel) => (
+                    <option key={channel.id} value={channel.id}>
+                      {channelLabel(channel)}
+                    </option>
+                  ))}
+                </optgroup>
+              ) : (
+                group.map((channel) => (
+                  <option key={channel.id} value={channel.id}>
+                    {channelLabel(channel)}
+                  </option>
+                ))
+              )
+            )}
           </select>
+          {config.channel_is_forum && (
+            <p className="form-help">
+              Forum channel: each notification is posted as a new thread.
+            </p>
+          )}
         </div>
 
         <div className="form-group">
@@ -170,6 +221,148 @@ export function GuildConfigEditor() {
           <p className="form-help">When disabled, no notifications will be sent to this server.</p>
         </div>
 
+        <div className="form-group">
+          <label htmlFor="tone">Message Tone</label>
+          <select
+            id="tone"
+            value={config.tone || 'default'}
+            onChange={(e) => setConfig({ ...config, tone: e.target.value as Tone })}
+          >
+            <option value="default">Default</option>
+            <option value="hype">Hype 🔥</option>
+            <option value="minimal">Minimal</option>
+            <option value="professional">Professional</option>
+          </select>
+          <p className="form-help">Preset phrasing for notifications. Ignored once a custom message template is saved.</p>
+        </div>
+
+        <div className="form-group form-group-checkbox">
+          <label>
+            <input
+              type="checkbox"
+              checked={config.mention_everyone}
+              onChange={(e) => setConfig({ ...config, mention_everyone: e.target.checked })}
+            />
+            <span>Allow @everyone Mentions</span>
+          </label>
+          <p className="form-help">
+            Enables the {'{mention_everyone}'} variable. Requires the bot to have the Mention @everyone permission. Without this, @everyone in a template never pings.
+          </p>
+        </div>
+
+        <div className="form-group form-group-checkbox">
+          <label>
+            <input
+              type="checkbox"
+              checked={config.update_on_channel_change}
+              onChange={(e) => setConfig({ ...config, update_on_channel_change: e.target.checked })}
+            />
+            <span>Update Notifications on Title/Category Change</span>
+          </label>
+        </div>
+
+        <div className="form-group form-group-checkbox">
+          <label>
+            <input
+              type="checkbox"
+              checked={config.delete_on_offline}
+              onChange={(e) => setConfig({ ...config, delete_on_offline: e.target.checked })}
+            />
+            <span>Delete Notifications When the Stream Ends</span>
+          </label>
+        </div>
+
+        <div className="form-group">
+          <label htmlFor="renotify-cooldown">Re-notify Cooldown (minutes)</label>
+          <input
+            id="renotify-cooldown"
+            type="number"
+            min={0}
+            max={1440}
+            value={config.renotify_cooldown_minutes}
+            onChange={(e) => setConfig({ ...config, renotify_cooldown_minutes: Number(e.target.value) })}
+          />
+          <p className="form-help">
+            Skips a new notification if the streamer was announced, or went offline, within this many minutes. Stops a dropped and reconnected stream from being posted twice. 0 turns it off.
+          </p>
+        </div>
+
+        <div className="form-group form-group-checkbox">
+          <label>
+            <input
+              type="checkbox"
+              checked={config.alert_admins_on_failure}
+              onChange={(e) => setConfig({ ...config, alert_admins_on_failure: e.target.checked })}
+            />
+            <span>Alert When a Streamer Link Breaks</span>
+          </label>
+          <p className="form-help">
+            Posts a message in the notification channel if a streamer revokes access or their Twitch subscription fails, so it can be re-linked.
+          </p>
+        </div>
+
+        <div className="form-group">
+          <label>Quiet Hours (Optional)</label>
+          <div className="form-row">
+            <input
+              type="time"
+              aria-label="Quiet hours start"
+              value={config.quiet_hours_start || ''}
+              onChange={(e) => setConfig({ ...config, quiet_hours_start: e.target.value })}
+            />
+            <span>to</span>
+            <input
+              type="time"
+              aria-label="Quiet hours end"
+              value={config.quiet_hours_end || ''}
+              onChange={(e) => setConfig({ ...config, quiet_hours_end: e.target.value })}
+            />
+            <input
+              type="text"
+              aria-label="Quiet hours timezone"
+              placeholder="UTC"
+              value={config.quiet_hours_timezone || ''}
+              onChange={(e) => setConfig({ ...config, quiet_hours_timezone: e.target.value })}
+            />
+            <select
+              aria-label="Quiet hours mode"
+              value={config.quiet_hours_mode || 'silent'}
+              onChange={(e) =>
+                setConfig({ ...config, quiet_hours_mode: e.target.value as 'silent' | 'skip' })
+              }
+            >
+              <option value="silent">Post without pings</option>
+              <option value="skip">Don't post</option>
+            </select>
+          </div>
+          <p className="form-help">
+            During this daily window (e.g. 23:00 to 07:00, timezone like Europe/Berlin), notifications are posted without pinging the mention role, or skipped entirely. Leave both times empty to disable.
+          </p>
+        </div>
+
+        <div className="form-group">
+          <label>Stream Start Time</label>
+          <div className="form-row">
+            <input
+              type="text"
+              aria-label="Timezone"
+              placeholder="UTC"
+              value={config.timezone || ''}
+              onChange={(e) => setConfig({ ...config, timezone: e.target.value })}
+            />
+            <input
+              type="text"
+              aria-label="Time format"
+              placeholder="2006-01-02T15:04:05Z07:00"
+              value={config.time_format || ''}
+              onChange={(e) => setConfig({ ...config, time_format: e.target.value })}
+            />
+          </div>
+          <p className="form-help">
+            How {'{started_at}'} is written: a timezone like America/New_York, and a format written as Go's reference time (e.g. Jan 2, 3:04 PM MST). Leave the format empty for ISO 8601. {'{started_at_relative}'} shows "5 minutes ago" in each viewer's own language instead.
+          </p>
+        </div>
+
         <div className="form-actions">
           <button onClick={handleSave} disabled={saving} className="btn btn-primary">
             {saving ? 'Saving...' : saved ? 'Saved!' : 'Save Configuration'}
@@ -13,7 +13,11 @@ const PLACEHOLDERS = [
   { key: '{stream_title}', desc: 'Stream title' },
   { key: '{game_name}', desc: 'Game being played' },
   { key: '{viewer_count}', desc: 'Current viewers' },
+  { key: '{started_at_discord}', desc: 'Stream start, in each viewer\'s timezone' },
+  { key: '{started_at_relative}', desc: 'When the stream started ("5 minutes ago")' },
+  { key: '{stream_uptime}', desc: 'How long the stream has been live (e.g. 1h3m)' },
   { key: '{mention_role}', desc: 'Mention role (if set)' },
+  { key: '{mention_everyone}', desc: '@everyone (if enabled by an admin)' },
 ];
 
 export function StreamerMessageEditor({ guildId, streamerId, streamerName, initialContent }: StreamerMessageEditorProps) {
@@ -46,6 +50,9 @@ export function StreamerMessageEditor({ guildId, streamerId, streamerName, initi
         .replace(/\{stream_title\}/g, 'Playing some games!')
         .replace(/\{game_name\}/g, 'Just Chatting')
         .replace(/\{viewer_count\}/g, '142')
+        .replace(/\{started_at_discord\}/g, new Date().toLocaleString(undefined, { dateStyle: 'long', timeStyle: 'short' }))
+        .replace(/\{started_at_relative\}/g, 'just now')
+        .replace(/\{stream_uptime\}/g, '5m')
         .replace(/\{mention_role\}/g, '@everyone')
     : null;
 
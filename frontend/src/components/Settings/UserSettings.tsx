@@ -1,11 +1,20 @@
 import { useEffect, useState } from 'react';
-import { getUserPreferences, updateUserPreference } from '../../services/api';
-import type { UserPreference } from '../../types';
+import {
+  getSessions,
+  getUserPreferences,
+  revokeOtherSessions,
+  unlinkMyTwitchAccount,
+  updateUserPreference,
+} from '../../services/api';
+import type { UserPreference, UserSession } from '../../types';
 import { LoadingSpinner } from '../common/LoadingSpinner';
 
 export function UserSettings() {
   const [preferences, setPreferences] = useState<UserPreference[]>([]);
   const [loading, setLoading] = useState(true);
+  const [unlinking, setUnlinking] = useState(false);
+  const [sessions, setSessions] = useState<UserSession[]>([]);
+  const [revoking, setRevoking] = useState(false);
 
   useEffect(() => {
     getUserPreferences()
@@ -14,8 +23,28 @@ export function UserSettings() {
         // Handle error silently
       })
       .finally(() => setLoading(false));
+    getSessions()
+      .then(setSessions)
+      .catch(() => {
+        // Handle error silently
+      });
   }, []);
 
+  const handleRevokeOtherSessions = async () => {
+    if (!confirm('Sign out of every other device?')) {
+      return;
+    }
+    setRevoking(true);
+    try {
+      await revokeOtherSessions();
+      setSessions((prev) => prev.filter((session) => session.current));
+    } catch {
+      alert('Failed to sign out other sessions. Please try again.');
+    } finally {
+      setRevoking(false);
+    }
+  };
+
   const togglePreference = async (
     guildId: string,
     streamerId: string,
@@ -35,6 +64,25 @@ export function UserSettings() {
     }
   };
 
+  const handleUnlinkTwitch = async () => {
+    if (!confirm('Unlink your Twitch account? Every server will stop getting your live notifications.')) {
+      return;
+    }
+    setUnlinking(true);
+    try {
+      const { removed } = await unlinkMyTwitchAccount();
+      alert(
+        removed.length > 0
+          ? `Unlinked ${removed.join(', ')}.`
+          : 'You have no linked Twitch account.'
+      );
+    } catch {
+      alert('Failed to unlink your Twitch account. Please try again.');
+    } finally {
+      setUnlinking(false);
+    }
+  };
+
   if (loading) return <LoadingSpinner />;
 
   return (
@@ -77,6 +125,46 @@ export function UserSettings() {
           ))}
         </div>
       )}
+
+      <h2>Active Sessions</h2>
+      <p className="section-subtitle">
+        Devices signed in to your account. Sign out the others if you don't recognize one.
+      </p>
+      <div className="preferences-list">
+        {sessions.map((session) => (
+          <div key={session.id} className="preference-item">
+            <div className="preference-info">
+              <span className="preference-guild">
+                {session.user_agent || 'Unknown device'}
+                {session.current && ' (this device)'}
+              </span>
+              <span className="preference-streamer">
+                Signed in {new Date(session.created_at).toLocaleString()}
+                {session.ip_address && ` from ${session.ip_address}`}
+              </span>
+            </div>
+          </div>
+        ))}
+      </div>
+      <button
+        onClick={handleRevokeOtherSessions}
+        className="btn btn-secondary"
+        disabled={revoking || sessions.every((session) => session.current)}
+      >
+        {revoking ? 'Signing out...' : 'Sign out other sessions'}
+      </button>
+
+      <h2>Twitch Account</h2>
+      <p className="section-subtitle">
+        Remove the Twitch account you linked and delete the access we stored for it.
+      </p>
+      <button
+        onClick={handleUnlinkTwitch}
+        className="btn btn-danger"
+        disabled={unlinking}
+      >
+        {unlinking ? 'Unlinking...' : 'Unlink my Twitch account'}
+      </button>
     </div>
   );
 }
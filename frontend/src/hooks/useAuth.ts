@@ -1,7 +1,19 @@
 import { useState, useEffect } from 'react';
-import { getMe } from '../services/api';
+import { getMe, refreshSession } from '../services/api';
 import type { User } from '../types';
 
+// Renew the session once per page load, so a regular visitor isn't sent
+// back to Discord every day. Shared by every useAuth caller.
+let sessionRefresh: Promise<unknown> | null = null;
+
+function refreshSessionOnce() {
+  if (!sessionRefresh) {
+    sessionRefresh = refreshSession().catch(() => {
+      // Past the renewal cap or offline: the current session still works
+    });
+  }
+}
+
 export function useAuth() {
   const [user, setUser] = useState<User | null>(null);
   const [isAuthenticated, setIsAuthenticated] = useState(false);
@@ -12,6 +24,7 @@ export function useAuth() {
       .then((userData) => {
         setUser(userData);
         setIsAuthenticated(true);
+        refreshSessionOnce();
       })
       .catch(() => {
         setUser(null);
@@ -1,4 +1,4 @@
-import type { Guild, Channel, Role, Streamer, GuildConfig, UserPreference, User, InviteLink, InviteInfo } from '../types';
+import type { Guild, Channel, Role, Streamer, GuildConfig, UserPreference, User, UserSession, InviteLink, InviteInfo, MessageTemplate, TemplatePreviewStream, DiscordMessagePreview, TonePreset, ConfigValidation, GuildDeletionImpact, BulkStreamerResult } from '../types';
 
 // In production VITE_API_URL is "" (same origin via CloudFront).
 // Use ?? so empty string isn't treated as missing (|| would fall back to localhost).
@@ -79,6 +79,20 @@ export async function getMe(): Promise<User> {
   return fetchAPI('/api/auth/me');
 }
 
+/** Swap the session cookie for a fresh one (the backend caps renewals at 30 days from login). */
+export async function refreshSession(): Promise<{ message: string; expires_at: string }> {
+  return fetchAPI('/api/auth/refresh', { method: 'POST' });
+}
+
+export async function getSessions(): Promise<UserSession[]> {
+  return fetchAPI('/api/auth/sessions');
+}
+
+/** Sign out every session except the current one. */
+export async function revokeOtherSessions(): Promise<{ message: string; revoked: number }> {
+  return fetchAPI('/api/auth/sessions/revoke-all', { method: 'POST' });
+}
+
 // Guilds
 export async function getUserGuilds(): Promise<Guild[]> {
   return fetchAPI('/api/guilds');
@@ -96,6 +110,11 @@ export async function getGuildStreamers(guildId: string): Promise<Streamer[]> {
   return fetchAPI(`/api/guilds/${guildId}/streamers`);
 }
 
+/** Remove the Twitch account(s) the signed-in user linked, from every server. */
+export async function unlinkMyTwitchAccount(): Promise<{ message: string; removed: string[] }> {
+  return fetchAPI('/api/streamers/me', { method: 'DELETE' });
+}
+
 export async function initiateStreamerLink(guildId: string): Promise<{ url: string }> {
   return fetchAPI(`/api/guilds/${guildId}/streamers/link`);
 }
@@ -117,10 +136,87 @@ export async function updateGuildConfig(guildId: string, config: Partial<GuildCo
   });
 }
 
+export async function validateGuildConfig(guildId: string, config: Partial<GuildConfig>): Promise<ConfigValidation> {
+  return fetchAPI(`/api/guilds/${guildId}/config?validate_only=true`, {
+    method: 'PUT',
+    body: JSON.stringify(config),
+  });
+}
+
+// Webhook delivery: the URL is write-only, config only reports webhook_configured
+export async function setGuildWebhook(guildId: string, webhookUrl: string): Promise<{ webhook_configured: boolean; channel_id: string }> {
+  return fetchAPI(`/api/guilds/${guildId}/webhook`, {
+    method: 'PUT',
+    body: JSON.stringify({ webhook_url: webhookUrl }),
+  });
+}
+
+export async function deleteGuildWebhook(guildId: string): Promise<{ message: string }> {
+  return fetchAPI(`/api/guilds/${guildId}/webhook`, { method: 'DELETE' });
+}
+
+export async function getTones(): Promise<TonePreset[]> {
+  return fetchAPI('/api/templates/tones');
+}
+
+export async function previewTemplate(
+  guildId: string,
+  template: MessageTemplate,
+  stream?: TemplatePreviewStream,
+): Promise<DiscordMessagePreview> {
+  return fetchAPI(`/api/guilds/${guildId}/template/preview`, {
+    method: 'POST',
+    body: JSON.stringify({ template, stream }),
+  });
+}
+
+export async function sendTestNotification(guildId: string): Promise<{ message_id: string; channel_id: string }> {
+  return fetchAPI(`/api/guilds/${guildId}/test-notification`, { method: 'POST' });
+}
+
 export async function getBotInstallURL(guildId: string): Promise<{ url: string }> {
   return fetchAPI(`/api/guilds/${guildId}/bot-install-url`);
 }
 
+// Guild deletion (requires confirming with the guild ID)
+export async function getGuildDeletionImpact(guildId: string): Promise<GuildDeletionImpact> {
+  return fetchAPI(`/api/guilds/${guildId}/deletion-impact`);
+}
+
+export async function deleteGuild(guildId: string, confirm: string): Promise<{ message: string; deleted: GuildDeletionImpact }> {
+  return fetchAPI(`/api/guilds/${guildId}?confirm=${encodeURIComponent(confirm)}`, {
+    method: 'DELETE',
+  });
+}
+
+// Streamer category filter (empty = every category)
+export async function getStreamerFilter(guildId: string, streamerId: string): Promise<{ categories: string[] }> {
+  return fetchAPI(`/api/guilds/${guildId}/streamers/${streamerId}/filter`);
+}
+
+export async function updateStreamerFilter(guildId: string, streamerId: string, categories: string[]): Promise<{ categories: string[] }> {
+  return fetchAPI(`/api/guilds/${guildId}/streamers/${streamerId}/filter`, {
+    method: 'PUT',
+    body: JSON.stringify({ categories }),
+  });
+}
+
+// Bulk import streamers by Twitch login (admin; no streamer OAuth needed)
+export async function addStreamersBulk(guildId: string, logins: string[]): Promise<{ results: BulkStreamerResult[] }> {
+  return fetchAPI(`/api/guilds/${guildId}/streamers/bulk`, {
+    method: 'POST',
+    body: JSON.stringify({ logins }),
+  });
+}
+
+// Pause or resume a streamer's notifications in a guild
+export async function setStreamerEnabled(guildId: string, streamerId: string, enabled: boolean): Promise<{ enabled: boolean }> {
+  return fetchAPI(`/api/guilds/${guildId}/streamers/${streamerId}/enabled`, {
+    method: 'PUT',
+    body: JSON.stringify({ enabled }),
+  });
+}
+
 // Streamer message (custom notification text)
 export async function getStreamerMessage(guildId: string, streamerId: string): Promise<{ custom_content: string }> {
   return fetchAPI(`/api/guilds/${guildId}/streamers/${streamerId}/message`);
@@ -20,13 +20,26 @@ export interface Streamer {
   twitch_avatar_url: string;
   custom_content?: string;
   added_by?: string;
+  enabled?: boolean;
+  needs_reauth?: boolean;
+  last_notified_at?: string | null;
+  subscription_status?: string; // stored stream.online EventSub status, 'missing' if none
+  is_live?: boolean;
+}
+
+export interface BulkStreamerResult {
+  login: string;
+  status: 'linked' | 'already_linked' | 'failed';
+  streamer_id?: string;
+  error?: string;
 }
 
 export interface Channel {
   id: string;
-  type: number;
+  type: number; // 0 text, 4 category, 5 announcement, 15 forum
   name: string;
   position: number;
+  parent_id?: string;
 }
 
 export interface Role {
@@ -34,14 +47,53 @@ export interface Role {
   name: string;
   color: number;
   position: number;
+  permissions?: string;
+  mentionable?: boolean;
 }
 
 export interface GuildConfig {
   guild_id: string;
   channel_id: string;
+  channel_is_forum: boolean;
   mention_role_id: string | null;
   message_template: MessageTemplate;
   enabled: boolean;
+  alert_admins_on_failure: boolean;
+  mention_everyone: boolean;
+  update_on_channel_change: boolean;
+  delete_on_offline: boolean;
+  renotify_cooldown_minutes: number;
+  tone: Tone;
+  quiet_hours_start?: string;
+  quiet_hours_end?: string;
+  quiet_hours_timezone?: string;
+  quiet_hours_mode?: 'silent' | 'skip';
+  timezone: string;
+  time_format: string;
+  webhook_configured?: boolean;
+}
+
+export interface GuildDeletionImpact {
+  guild_config: number;
+  streamer_links: number;
+  user_preferences: number;
+  notification_logs: number;
+  invite_links: number;
+  members: number;
+}
+
+export interface ConfigValidation {
+  valid: boolean;
+  errors: string[];
+  warnings: string[];
+  size_estimate?: SizeEstimate;
+}
+
+export type Tone = 'default' | 'hype' | 'minimal' | 'professional';
+
+export interface TonePreset {
+  tone: Tone;
+  template: MessageTemplate;
 }
 
 export interface MessageTemplate {
@@ -63,6 +115,52 @@ export interface MessageTemplate {
   };
 }
 
+export interface TemplatePreviewStream {
+  streamer_login?: string;
+  streamer_display_name?: string;
+  streamer_avatar_url?: string;
+  stream_title?: string;
+  game_name?: string;
+  viewer_count?: number;
+  stream_uptime_minutes?: number;
+}
+
+export interface DiscordMessagePreview {
+  content?: string;
+  embeds?: Array<{
+    title?: string;
+    description?: string;
+    url?: string;
+    color?: number;
+    thumbnail?: { url: string };
+    image?: { url: string };
+    fields?: Array<{ name: string; value: string; inline?: boolean }>;
+    footer?: { text: string };
+    timestamp?: string;
+  }>;
+  allowed_mentions?: { parse: string[] };
+  size_estimate?: SizeEstimate;
+}
+
+export interface SizeEstimate {
+  content: number;
+  embed_title: number;
+  embed_description: number;
+  embed_fields: Array<{ name: number; value: number }>;
+  embed_footer: number;
+  embed_total: number;
+  warnings: string[];
+}
+
+export interface UserSession {
+  id: string;
+  user_agent?: string;
+  ip_address?: string;
+  created_at: string;
+  expires_at: string;
+  current: boolean;
+}
+
 export interface UserPreference {
   user_id: string;
   guild_id: string;